@@ -1,81 +1,416 @@
 package main
 
 import (
+	"bytes"
+	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"os"
+	"strings"
 
 	"github.com/nihei9/9gram/grammar"
 	"github.com/nihei9/9gram/log"
 	"github.com/nihei9/9gram/parser"
 )
 
+// exitCodeConflict is the exit code when the grammar has conflicts. It lets build scripts tell grammar ambiguities
+// apart from other errors.
+const exitCodeConflict = 2
+
+var errEmptyGrammar = errors.New("empty grammar; the source does not contain any productions")
+
+// logWriter replaces 9gram.log as the sink of the log when it isn't nil. Tests set it so as not to leave the log
+// file behind.
+var logWriter io.Writer
+
 func main() {
-	os.Exit(doMain())
+	os.Exit(doMain(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
 }
 
-func doMain() int {
-	flag.Parse()
+const (
+	formatJSON = "json"
+	formatYAML = "yaml"
+	formatDOT  = "dot"
+)
+
+type options struct {
+	output        string
+	format        string
+	embedSource   bool
+	compress      bool
+	emitGo        bool
+	goPackage     string
+	preferShift   bool
+	check         bool
+	checkPatterns bool
+	statsJSON     bool
+	tabWidth      int
+	maxRHSLen     int
+	sourceOrder   bool
+	strictTokens  bool
+	eofMode       string
+	verbose       bool
+	logLevel      string
+}
 
-	err := run(flag.Args())
+func doMain(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("9gram", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	opts := &options{}
+	flags.StringVar(&opts.output, "o", "", "write the output to the file instead of stdout")
+	flags.StringVar(&opts.format, "format", formatJSON, "output format; json, yaml, or dot")
+	flags.BoolVar(&opts.embedSource, "embed-source", false, "embed the grammar source in the output")
+	flags.BoolVar(&opts.compress, "compress", false, "compress the ACTION and GOTO tables with row displacement")
+	flags.BoolVar(&opts.emitGo, "emit-go", false, "emit a standalone Go parser instead of the table")
+	flags.StringVar(&opts.goPackage, "go-package", "parser", "package name of the Go parser that -emit-go emits")
+	flags.IntVar(&opts.tabWidth, "tab-width", parser.DefaultTabWidth, "interval of tab stops used to count columns in error messages")
+	flags.IntVar(&opts.maxRHSLen, "max-rhs-len", grammar.DefaultMaxRHSLen, "maximum number of symbols in an alternative")
+	flags.BoolVar(&opts.sourceOrder, "source-order", false, "number the productions in the source order and the generated ones after them")
+	flags.BoolVar(&opts.strictTokens, "strict-tokens", false, "make terminal symbols that no alternative uses an error")
+	flags.BoolVar(&opts.verbose, "verbose", false, "write the log to stderr as well as 9gram.log")
+	flags.StringVar(&opts.logLevel, "log-level", log.LevelDebug.String(), "minimum level of log messages; debug, info, warn, or error")
+	flags.BoolVar(&opts.preferShift, "prefer-shift", false, "resolve shift/reduce conflicts in favor of the shift action")
+	flags.StringVar(&opts.eofMode, "eof-mode", string(grammar.EOFModeExplicit), "lookaheads to accept on; explicit accepts only at the end of the input, and implicit also accepts a prefix")
+	flags.BoolVar(&opts.check, "check", false, "validate the grammar and print a summary instead of the table")
+	flags.BoolVar(&opts.checkPatterns, "check-patterns", false, "with -check, warn about terminal symbols whose patterns overlap")
+	flags.BoolVar(&opts.statsJSON, "stats-json", false, "emit metrics of the grammar as JSON instead of the table")
+	err := flags.Parse(args)
 	if err != nil {
-		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	err = run(opts, flags.Args(), stdin, stdout, stderr)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		var cErr *grammar.ConflictError
+		if errors.As(err, &cErr) {
+			return exitCodeConflict
+		}
 		return 1
 	}
 
 	return 0
 }
 
-func run(args []string) error {
+func run(opts *options, args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	switch opts.format {
+	case formatJSON, formatYAML, formatDOT:
+	default:
+		return fmt.Errorf("unknown format: %v; supported formats: %v, %v, %v", opts.format, formatJSON, formatYAML, formatDOT)
+	}
+	if opts.check && opts.statsJSON {
+		return errors.New("-check and -stats-json cannot be used together")
+	}
+	if opts.checkPatterns && !opts.check {
+		return errors.New("-check-patterns requires -check")
+	}
+
+	var srcPath string
 	var src io.Reader
 	if len(args) > 0 {
-		filepath := args[0]
-		file, err := os.Open(filepath)
+		srcPath = args[0]
+		file, err := os.Open(srcPath)
 		if err != nil {
 			return err
 		}
 		defer file.Close()
 		src = file
 	} else {
-		src = os.Stdin
+		src = stdin
 	}
 
-	err := log.Init("9gram.log")
+	srcText, err := ioutil.ReadAll(src)
 	if err != nil {
 		return err
 	}
+	if strings.TrimSpace(string(srcText)) == "" {
+		return errEmptyGrammar
+	}
+
+	logLevel, err := log.ParseLevel(opts.logLevel)
+	if err != nil {
+		return err
+	}
+	if logWriter != nil {
+		log.InitWithWriter(logWriter, logLevel)
+	} else {
+		err = log.Init("9gram.log", logLevel)
+		if err != nil {
+			return err
+		}
+	}
 	defer log.Close()
+	if opts.verbose {
+		log.AddWriter(stderr)
+	}
 
-	psr, err := parser.NewParser(src)
+	psrOpts := []parser.ParserOption{
+		parser.WithTabWidth(opts.tabWidth),
+	}
+	psr, err := parser.NewParser(bytes.NewReader(srcText), psrOpts...)
 	if err != nil {
-		log.Log("Failed to craete a parser: %v", err)
+		log.Error("Failed to craete a parser: %v", err)
 		return err
 	}
 	ast, err := psr.Parse()
 	if err != nil {
-		log.Log("Failed to parse: %v", err)
-		return err
+		log.Error("Failed to parse: %v", err)
+		return formatSyntaxError(srcText, err)
+	}
+	included, err := parser.ResolveIncludes(ast, srcPath, psrOpts...)
+	if err != nil {
+		log.Error("Failed to resolve includes: %v", err)
+		return formatSyntaxError(srcText, err)
+	}
+	// The embedded source must be self-contained, so it is the canonical form of the resolved AST when the source
+	// includes other files.
+	if len(included) > 0 {
+		srcText = []byte(ast.String())
+	}
+
+	gramOpts := []grammar.GrammarOption{
+		grammar.WithMaxRHSLen(opts.maxRHSLen),
+	}
+	if opts.sourceOrder {
+		gramOpts = append(gramOpts, grammar.WithSourceOrderNumbering())
+	}
+	if opts.strictTokens {
+		gramOpts = append(gramOpts, grammar.WithStrictTokens())
+	}
+	tabOpts := []grammar.TableOption{
+		grammar.WithEOFMode(grammar.EOFMode(opts.eofMode)),
+	}
+	if opts.preferShift {
+		tabOpts = append(tabOpts, grammar.WithConflictPolicy(grammar.ConflictPolicyPreferShift))
 	}
 
-	gram, err := grammar.GenGrammar(ast)
+	gram, tab, diags, err := grammar.BuildFromAST(ast, grammar.WithGrammarOptions(gramOpts...), grammar.WithTableOptions(tabOpts...))
+	// The errors are reported as the error of run.
+	for _, d := range diags.Filter(grammar.SeverityWarning) {
+		fmt.Fprintln(stderr, d)
+	}
+	if gram == nil {
+		return grammarError(err)
+	}
+	if opts.check {
+		return check(gram, tab, diags, err, stdout, stderr, opts.checkPatterns)
+	}
+	if opts.statsJSON {
+		// Conflicts don't make the report fail, so the error of BuildFromAST is counted in the report instead.
+		report, err := grammar.GenGrammarReport(gram, tabOpts...)
+		if err != nil {
+			log.Error("Failed to generate a grammar report: %v", err)
+			return err
+		}
+		d, err := json.Marshal(report)
+		if err != nil {
+			return err
+		}
+		return writeOutput(opts.output, stdout, d)
+	}
 	if err != nil {
-		log.Log("Failed to generate a grammar information: %v", err)
+		log.Error("Failed to generate a parsing table: %v", err)
 		return err
 	}
+	printResolvedConflictSummary(stderr, tab)
 
-	tab, err := grammar.GenTable(gram)
-	if err != nil {
-		log.Log("Failed to generate a parsing table: %v", err)
+	var outOpts []grammar.OutputOption
+	if opts.embedSource {
+		outOpts = append(outOpts, grammar.WithGrammarSource(string(srcText)))
+	}
+	if opts.compress {
+		outOpts = append(outOpts, grammar.WithCompressedTable())
+	}
+	if !opts.emitGo && opts.format == formatJSON {
+		err := writeJSONOutput(opts.output, stdout, gram, tab, outOpts...)
+		if err != nil {
+			log.Error("Failed to generate a %v output: %v", opts.format, err)
+			return err
+		}
+		return nil
+	}
+	var d []byte
+	if opts.emitGo {
+		d, err = grammar.GenGoSource(gram, tab, opts.goPackage)
+		if err != nil {
+			log.Error("Failed to generate a Go source: %v", err)
+			return err
+		}
+	} else {
+		d, err = genOutput(opts.format, gram, tab, outOpts...)
+		if err != nil {
+			log.Error("Failed to generate a %v output: %v", opts.format, err)
+			return err
+		}
+	}
+	return writeOutput(opts.output, stdout, d)
+}
+
+// grammarError converts an error of GenGrammar into the error of run.
+func grammarError(err error) error {
+	log.Error("Failed to generate a grammar information: %v", err)
+	if errors.Is(err, grammar.ErrNoProductions) {
+		return errEmptyGrammar
+	}
+	return err
+}
+
+// formatSyntaxError renders a syntax error with the offending line. A syntax error in an included file is rendered
+// with the line of the file. The returned error wraps err, so errors.As still finds the *parser.SyntaxError and the
+// *parser.IncludeError.
+func formatSyntaxError(src []byte, err error) error {
+	var incErr *parser.IncludeError
+	if errors.As(err, &incErr) {
+		var synErr *parser.SyntaxError
+		if errors.As(incErr.Err, &synErr) {
+			return &formattedError{
+				message: fmt.Sprintf("%v: %v", incErr.Path, parser.FormatError(incErr.Source, synErr)),
+				err:     err,
+			}
+		}
 		return err
 	}
+	var synErr *parser.SyntaxError
+	if errors.As(err, &synErr) {
+		return &formattedError{
+			message: parser.FormatError(src, synErr),
+			err:     err,
+		}
+	}
+	return err
+}
+
+// formattedError replaces the message of an error with a rendered one.
+type formattedError struct {
+	message string
+	err     error
+}
+
+func (e *formattedError) Error() string {
+	return e.message
+}
+
+func (e *formattedError) Unwrap() error {
+	return e.err
+}
+
+// writeOutput writes the output to the file when the path isn't empty. Otherwise, it writes the output to stdout.
+func writeOutput(path string, stdout io.Writer, d []byte) error {
+	d = bytes.TrimRight(d, "\n")
+	if path != "" {
+		err := ioutil.WriteFile(path, append(d, '\n'), 0644)
+		if err != nil {
+			return fmt.Errorf("failed to write the output to a file; path: %v, error: %v", path, err)
+		}
+		return nil
+	}
+	fmt.Fprintln(stdout, string(d))
 
-	d, err := grammar.GenJSON(gram, tab)
+	return nil
+}
+
+// writeJSONOutput streams the JSON output to the file when the path isn't empty. Otherwise, it streams the output to
+// stdout. It removes the file when it fails to write the output.
+func writeJSONOutput(path string, stdout io.Writer, gram *grammar.Grammar, tab *grammar.Table, opts ...grammar.OutputOption) error {
+	if path == "" {
+		return grammar.WriteJSON(stdout, gram, tab, opts...)
+	}
+	f, err := os.Create(path)
 	if err != nil {
-		log.Log("Failed to generate a JSON output: %v", err)
-		return err
+		return fmt.Errorf("failed to write the output to a file; path: %v, error: %v", path, err)
+	}
+	err = grammar.WriteJSON(f, gram, tab, opts...)
+	if cErr := f.Close(); err == nil {
+		err = cErr
+	}
+	if err != nil {
+		os.Remove(path)
+		return fmt.Errorf("failed to write the output to a file; path: %v, error: %v", path, err)
 	}
-	fmt.Println(string(d))
+	return nil
+}
+
+func genOutput(format string, gram *grammar.Grammar, tab *grammar.Table, opts ...grammar.OutputOption) ([]byte, error) {
+	switch format {
+	case formatYAML:
+		return grammar.GenYAML(gram, tab, opts...)
+	case formatDOT:
+		return grammar.GenDOT(gram, tab)
+	default:
+		return grammar.GenJSON(gram, tab, opts...)
+	}
+}
+
+type checkError struct {
+	problems  []string
+	conflicts *grammar.ConflictError
+}
+
+func (e *checkError) Error() string {
+	return strings.Join(e.problems, "\n")
+}
+
+func (e *checkError) Unwrap() error {
+	if e.conflicts == nil {
+		return nil
+	}
+	return e.conflicts
+}
+
+func printResolvedConflictSummary(w io.Writer, tab *grammar.Table) {
+	if len(tab.ResolvedConflicts) == 0 {
+		return
+	}
+	fmt.Fprintf(w, "warning: %v conflicts resolved: %v\n", len(tab.ResolvedConflicts), tab.ResolvedConflictCounts())
+}
+
+// check turns the result of BuildFromAST into the result of the -check option. The warnings of diags are printed
+// already. On top of the errors of diags, unreachable and unproductive symbols fail the check.
+func check(gram *grammar.Grammar, tab *grammar.Table, diags *grammar.Diagnostics, buildErr error, stdout, stderr io.Writer, checkPatterns bool) error {
+	var problems []string
+	var conflicts *grammar.ConflictError
+	if checkPatterns {
+		patConflicts, err := grammar.FindPatternConflicts(gram)
+		if err != nil {
+			return err
+		}
+		for _, c := range patConflicts {
+			fmt.Fprintf(stderr, "warning: %v\n", c)
+		}
+	}
+	if len(grammar.FindUnreachableSymbols(gram)) > 0 || len(grammar.FindUnproductiveSymbols(gram)) > 0 {
+		problems = append(problems, "the grammar has unreachable or unproductive symbols")
+	}
+	for _, d := range diags.Filter(grammar.SeverityError) {
+		problems = append(problems, d.Message)
+	}
+	if buildErr != nil {
+		log.Error("Failed to generate a parsing table: %v", buildErr)
+		if errors.As(buildErr, &conflicts) {
+			class, err := grammar.ClassifyGrammar(gram)
+			if err != nil {
+				return err
+			}
+			switch class {
+			case grammar.GrammarClassLALR1, grammar.GrammarClassLR1:
+				problems = append(problems, fmt.Sprintf("the grammar is %v but not SLR(1), and 9gram generates only SLR(1) parsing tables", class))
+			}
+		}
+	} else {
+		printResolvedConflictSummary(stderr, tab)
+	}
+	if len(problems) > 0 {
+		return &checkError{
+			problems:  problems,
+			conflicts: conflicts,
+		}
+	}
+
+	fmt.Fprintf(stdout, "ok; states: %v, productions: %v, terminals: %v\n", tab.NumOfStates(), gram.NumOfProductions(), gram.NumOfTerminalSymbols())
+	fmt.Fprintf(stdout, "automaton; %v\n", grammar.AutomatonStats(tab.LR0Automaton))
 
 	return nil
 }
@@ -0,0 +1,558 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/nihei9/9gram/grammar"
+	"github.com/nihei9/9gram/log"
+	"github.com/nihei9/9gram/parser"
+)
+
+func TestMain(m *testing.M) {
+	logWriter = ioutil.Discard
+	os.Exit(m.Run())
+}
+
+func TestRun_EmptyGrammar(t *testing.T) {
+	tests := []struct {
+		caption string
+		src     string
+	}{
+		{
+			caption: "when the source is empty, the CLI reports an empty grammar",
+			src:     "",
+		},
+		{
+			caption: "when the source contains only whitespace, the CLI reports an empty grammar",
+			src:     " \n\t\r\n ",
+		},
+		{
+			caption: "when the source contains only comments, the CLI reports an empty grammar",
+			src:     "// only a comment\n/* and another */",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.caption, func(t *testing.T) {
+			err := run(&options{format: formatJSON, logLevel: log.LevelDebug.String()}, nil, strings.NewReader(tt.src), &bytes.Buffer{}, &bytes.Buffer{})
+			if !errors.Is(err, errEmptyGrammar) {
+				t.Fatalf("unexpected error; want: %v, got: %v", errEmptyGrammar, err)
+			}
+
+			var stdout, stderr bytes.Buffer
+			code := doMain(nil, strings.NewReader(tt.src), &stdout, &stderr)
+			if code != 1 {
+				t.Fatalf("unexpected exit code; want: %v, got: %v", 1, code)
+			}
+			if !strings.Contains(stderr.String(), "empty grammar") {
+				t.Fatalf("unexpected error message: %v", stderr.String())
+			}
+			if stdout.Len() > 0 {
+				t.Fatalf("unexpected output: %v", stdout.String())
+			}
+		})
+	}
+}
+
+func TestRun_Output(t *testing.T) {
+	src := "s: FOO s | ;"
+
+	t.Run("when -o is passed, the CLI writes the output to the file", func(t *testing.T) {
+		dir, err := ioutil.TempDir("", "9gram")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.RemoveAll(dir)
+		path := filepath.Join(dir, "out.json")
+
+		var stdout, stderr bytes.Buffer
+		code := doMain([]string{"-o", path}, strings.NewReader(src), &stdout, &stderr)
+		if code != 0 {
+			t.Fatalf("unexpected exit code; want: %v, got: %v, stderr: %v", 0, code, stderr.String())
+		}
+		if stdout.Len() > 0 {
+			t.Fatalf("stdout must be empty: %v", stdout.String())
+		}
+		d, err := ioutil.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !json.Valid(d) {
+			t.Fatalf("the output is not a valid JSON: %s", d)
+		}
+	})
+
+	t.Run("when -o is omitted, the CLI writes the output to stdout", func(t *testing.T) {
+		var stdout, stderr bytes.Buffer
+		code := doMain(nil, strings.NewReader(src), &stdout, &stderr)
+		if code != 0 {
+			t.Fatalf("unexpected exit code; want: %v, got: %v, stderr: %v", 0, code, stderr.String())
+		}
+		if !json.Valid(stdout.Bytes()) {
+			t.Fatalf("the output is not a valid JSON: %v", stdout.String())
+		}
+	})
+
+	t.Run("when the output path is unwritable, the CLI reports the path", func(t *testing.T) {
+		dir, err := ioutil.TempDir("", "9gram")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.RemoveAll(dir)
+		path := filepath.Join(dir, "no_such_dir", "out.json")
+
+		var stdout, stderr bytes.Buffer
+		code := doMain([]string{"-o", path}, strings.NewReader(src), &stdout, &stderr)
+		if code != 1 {
+			t.Fatalf("unexpected exit code; want: %v, got: %v", 1, code)
+		}
+		if !strings.Contains(stderr.String(), path) {
+			t.Fatalf("the error message doesn't contain the path: %v", stderr.String())
+		}
+	})
+}
+
+func TestRun_Format(t *testing.T) {
+	src := "s: FOO s | ;"
+
+	tests := []struct {
+		caption string
+		format  string
+		check   func(out string) bool
+		code    int
+	}{
+		{
+			caption: "json format",
+			format:  "json",
+			check: func(out string) bool {
+				return json.Valid([]byte(out))
+			},
+		},
+		{
+			caption: "yaml format",
+			format:  "yaml",
+			check: func(out string) bool {
+				return strings.Contains(out, "\nstate_count: ")
+			},
+		},
+		{
+			caption: "dot format",
+			format:  "dot",
+			check: func(out string) bool {
+				return strings.HasPrefix(out, "digraph ")
+			},
+		},
+		{
+			caption: "compressed json format",
+			format:  "json --compress",
+			check: func(out string) bool {
+				return json.Valid([]byte(out)) && strings.Contains(out, `"compressed_action":`) && !strings.Contains(out, `"action":`)
+			},
+		},
+		{
+			caption: "go source",
+			format:  "json --emit-go --go-package=calc",
+			check: func(out string) bool {
+				return strings.Contains(out, "\npackage calc\n") && strings.Contains(out, "func Parse(tokens []int) (*Node, error)")
+			},
+		},
+		{
+			caption: "unknown format",
+			format:  "xml",
+			code:    1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.caption, func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+			args := strings.Fields("--format=" + tt.format)
+			code := doMain(args, strings.NewReader(src), &stdout, &stderr)
+			if code != tt.code {
+				t.Fatalf("unexpected exit code; want: %v, got: %v, stderr: %v", tt.code, code, stderr.String())
+			}
+			if tt.check != nil && !tt.check(stdout.String()) {
+				t.Fatalf("unexpected output: %v", stdout.String())
+			}
+		})
+	}
+}
+
+func TestRun_Check(t *testing.T) {
+	tests := []struct {
+		caption string
+		src     string
+		code    int
+		stdout  string
+		stderr  []string
+	}{
+		{
+			caption: "a valid grammar passes the check",
+			src:     "s: A s | B;",
+			stdout:  "ok; states: 5, productions: 2, terminals: 2\nautomaton; states: 5, kernel size: avg 1.00, max 1, reduce-only states: 3, LR(0) conflict states: 0\n",
+		},
+		{
+			caption: "a grammar that has unreachable and unproductive symbols fails the check",
+			src:     "s: A | b; b: B b; c: C;",
+			code:    1,
+			stderr: []string{
+				"unreachable symbol: c",
+				"unproductive symbol: b",
+			},
+		},
+		{
+			caption: "a grammar that has a conflict fails the check",
+			src:     "e: e ADD e | NUM;",
+			code:    2,
+			stderr: []string{
+				"shift/reduce conflict",
+			},
+		},
+		{
+			caption: "a grammar that is LALR(1) but not SLR(1) fails the check with its class",
+			src:     "s: l EQ r | r; l: STAR r | ID; r: l;",
+			code:    2,
+			stderr: []string{
+				"the grammar is LALR(1) but not SLR(1)",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.caption, func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+			code := doMain([]string{"--check"}, strings.NewReader(tt.src), &stdout, &stderr)
+			if code != tt.code {
+				t.Fatalf("unexpected exit code; want: %v, got: %v, stderr: %v", tt.code, code, stderr.String())
+			}
+			if stdout.String() != tt.stdout {
+				t.Fatalf("unexpected stdout; want: %q, got: %q", tt.stdout, stdout.String())
+			}
+			for _, msg := range tt.stderr {
+				if !strings.Contains(stderr.String(), msg) {
+					t.Fatalf("stderr doesn't contain the message; message: %v, stderr: %v", msg, stderr.String())
+				}
+			}
+		})
+	}
+}
+
+func TestRun_CheckPatterns(t *testing.T) {
+	src := `s: IF ID; IF: "if"; ID: "[a-z]+";`
+
+	t.Run("-check-patterns warns about overlapping patterns", func(t *testing.T) {
+		var stdout, stderr bytes.Buffer
+		code := doMain([]string{"-check", "-check-patterns"}, strings.NewReader(src), &stdout, &stderr)
+		if code != 0 {
+			t.Fatalf("unexpected exit code; want: %v, got: %v, stderr: %v", 0, code, stderr.String())
+		}
+		msg := "warning: every string that a pattern matches is matched by another pattern; symbol: IF (if), other: ID ([a-z]+)"
+		if !strings.Contains(stderr.String(), msg) {
+			t.Fatalf("stderr doesn't contain the message; message: %v, stderr: %v", msg, stderr.String())
+		}
+	})
+
+	t.Run("-check-patterns requires -check", func(t *testing.T) {
+		var stdout, stderr bytes.Buffer
+		code := doMain([]string{"-check-patterns"}, strings.NewReader(src), &stdout, &stderr)
+		if code != 1 {
+			t.Fatalf("unexpected exit code; want: %v, got: %v", 1, code)
+		}
+		if !strings.Contains(stderr.String(), "-check-patterns requires -check") {
+			t.Fatalf("unexpected stderr: %v", stderr.String())
+		}
+	})
+}
+
+func TestRun_Include(t *testing.T) {
+	dir, err := ioutil.TempDir("", "9gram")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	for name, src := range map[string]string{
+		"main.9g": `%include "expr.9g"; s: expr;`,
+		"expr.9g": `expr: expr ADD NUM | NUM;`,
+		"bad.9g":  `%include "broken.9g"; s: A;`,
+		"broken.9g": `s: A;
+t B;`,
+	} {
+		err := ioutil.WriteFile(filepath.Join(dir, name), []byte(src), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	t.Run("the included files are resolved against the directory of the including file", func(t *testing.T) {
+		var stdout, stderr bytes.Buffer
+		code := doMain([]string{"-embed-source", filepath.Join(dir, "main.9g")}, nil, &stdout, &stderr)
+		if code != 0 {
+			t.Fatalf("unexpected exit code; want: %v, got: %v, stderr: %v", 0, code, stderr.String())
+		}
+		tab, err := grammar.DecodeTable(stdout.Bytes())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ok, err := grammar.MatchesGrammar([]byte(*tab.GrammarSource), tab); err != nil || !ok {
+			t.Fatalf("the embedded source must generate the table; matches: %v, error: %v", ok, err)
+		}
+	})
+
+	t.Run("an error in an included file is reported with the file", func(t *testing.T) {
+		var stdout, stderr bytes.Buffer
+		code := doMain([]string{filepath.Join(dir, "bad.9g")}, nil, &stdout, &stderr)
+		if code != 1 {
+			t.Fatalf("unexpected exit code; want: %v, got: %v", 1, code)
+		}
+		msg := filepath.Join(dir, "broken.9g") + ": syntax error: unexpected token; expected: :, actual: id (2, 3)\n2 | t B;\n  |   ^"
+		if !strings.Contains(stderr.String(), msg) {
+			t.Fatalf("stderr doesn't contain the message; message: %v, stderr: %v", msg, stderr.String())
+		}
+	})
+}
+
+func TestFormatSyntaxError(t *testing.T) {
+	src := []byte("s: A;\nt B;")
+	psr, err := parser.NewParser(bytes.NewReader(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = psr.Parse()
+	fmtErr := formatSyntaxError(src, err)
+	msg := "syntax error: unexpected token; expected: :, actual: id (2, 3)\n2 | t B;\n  |   ^"
+	if fmtErr.Error() != msg {
+		t.Fatalf("unexpected message; want: %q, got: %q", msg, fmtErr.Error())
+	}
+	var synErr *parser.SyntaxError
+	if !errors.As(fmtErr, &synErr) {
+		t.Fatalf("the formatted error must wrap the syntax error: %#v", fmtErr)
+	}
+
+	incErr := &parser.IncludeError{
+		Path:   "broken.9g",
+		Source: src,
+		Err:    err,
+	}
+	fmtErr = formatSyntaxError(nil, incErr)
+	if fmtErr.Error() != "broken.9g: "+msg {
+		t.Fatalf("unexpected message; want: %q, got: %q", "broken.9g: "+msg, fmtErr.Error())
+	}
+	var gotIncErr *parser.IncludeError
+	if !errors.As(fmtErr, &gotIncErr) || !errors.As(fmtErr, &synErr) {
+		t.Fatalf("the formatted error must wrap the include error and the syntax error: %#v", fmtErr)
+	}
+}
+
+func TestRun_Conflict(t *testing.T) {
+	src := "s: a | b | A C; a: A; b: A;"
+
+	var stdout, stderr bytes.Buffer
+	code := doMain(nil, strings.NewReader(src), &stdout, &stderr)
+	if code != exitCodeConflict {
+		t.Fatalf("unexpected exit code; want: %v, got: %v, stderr: %v", exitCodeConflict, code, stderr.String())
+	}
+	if stdout.Len() > 0 {
+		t.Fatalf("unexpected output: %v", stdout.String())
+	}
+	for _, msg := range []string{
+		"1 conflicts found: 0 shift/reduce, 1 reduce/reduce",
+		"state 4: reduce/reduce conflict on <eof>: reduce by #5 a: A, reduce by #6 b: A; path: A; example: A <eof>",
+	} {
+		if !strings.Contains(stderr.String(), msg) {
+			t.Fatalf("stderr doesn't contain the message; want: %v, got: %v", msg, stderr.String())
+		}
+	}
+}
+
+func TestRun_PreferShift(t *testing.T) {
+	src := "stmt: IF stmt | IF stmt ELSE stmt | OTHER;"
+
+	var stdout, stderr bytes.Buffer
+	code := doMain(nil, strings.NewReader(src), &stdout, &stderr)
+	if code != exitCodeConflict {
+		t.Fatalf("unexpected exit code; want: %v, got: %v, stderr: %v", exitCodeConflict, code, stderr.String())
+	}
+
+	stdout.Reset()
+	stderr.Reset()
+	code = doMain([]string{"--prefer-shift"}, strings.NewReader(src), &stdout, &stderr)
+	if code != 0 {
+		t.Fatalf("unexpected exit code; want: %v, got: %v, stderr: %v", 0, code, stderr.String())
+	}
+	if !strings.Contains(stderr.String(), "warning: ") || !strings.Contains(stderr.String(), "shift/reduce conflict on ELSE") || !strings.Contains(stderr.String(), "resolved as shift") {
+		t.Fatalf("the resolved conflict must be reported: %v", stderr.String())
+	}
+	if !strings.Contains(stderr.String(), "warning: 1 conflicts resolved: 1 shift/reduce, 0 reduce/reduce\n") {
+		t.Fatalf("the summary of the resolved conflicts must be reported: %v", stderr.String())
+	}
+	if !json.Valid(stdout.Bytes()) {
+		t.Fatalf("the output is not a valid JSON: %v", stdout.String())
+	}
+}
+
+func TestRun_NeverReducedProductions(t *testing.T) {
+	src := "s: A B | a B C; a: A;"
+
+	for _, args := range [][]string{
+		{"--prefer-shift"},
+		{"--prefer-shift", "--check"},
+	} {
+		var stdout, stderr bytes.Buffer
+		code := doMain(args, strings.NewReader(src), &stdout, &stderr)
+		if code != 0 {
+			t.Fatalf("unexpected exit code; args: %v, want: %v, got: %v, stderr: %v", args, 0, code, stderr.String())
+		}
+		if !strings.Contains(stderr.String(), "warning: never-reduced production: a: A\n") {
+			t.Fatalf("the never-reduced production must be reported; args: %v, stderr: %v", args, stderr.String())
+		}
+	}
+}
+
+func TestRun_Warnings(t *testing.T) {
+	src := "s: a | B; a: B C | B C;"
+
+	var stdout, stderr bytes.Buffer
+	code := doMain(nil, strings.NewReader(src), &stdout, &stderr)
+	if code != 0 {
+		t.Fatalf("unexpected exit code; want: %v, got: %v, stderr: %v", 0, code, stderr.String())
+	}
+	msg := "warning: duplicate alternative: a: B C\n"
+	if stderr.String() != msg {
+		t.Fatalf("unexpected stderr; want: %q, got: %q", msg, stderr.String())
+	}
+	if !json.Valid(stdout.Bytes()) {
+		t.Fatalf("the output is not a valid JSON: %v", stdout.String())
+	}
+
+	stdout.Reset()
+	stderr.Reset()
+	code = doMain(nil, strings.NewReader("s: B;\nNUM: \"[0-9]+\";"), &stdout, &stderr)
+	if code != 0 {
+		t.Fatalf("unexpected exit code; want: %v, got: %v, stderr: %v", 0, code, stderr.String())
+	}
+	msg = "warning: unused terminal symbol: NUM (2, 1)\n"
+	if stderr.String() != msg {
+		t.Fatalf("unexpected stderr; want: %q, got: %q", msg, stderr.String())
+	}
+
+	// --strict-tokens makes the unused terminal symbol an error.
+	stdout.Reset()
+	stderr.Reset()
+	code = doMain([]string{"--strict-tokens"}, strings.NewReader("s: B;\nNUM: \"[0-9]+\";"), &stdout, &stderr)
+	if code != 1 {
+		t.Fatalf("unexpected exit code; want: %v, got: %v, stderr: %v", 1, code, stderr.String())
+	}
+	msg = "unused terminal symbols; symbols: NUM\n"
+	if stderr.String() != msg {
+		t.Fatalf("unexpected stderr; want: %q, got: %q", msg, stderr.String())
+	}
+	if stdout.Len() > 0 {
+		t.Fatalf("no output must be written: %v", stdout.String())
+	}
+}
+
+func TestRun_Verbose(t *testing.T) {
+	src := "s: FOO s | ;"
+
+	for _, verbose := range []bool{false, true} {
+		var stdout, stderr bytes.Buffer
+		args := []string{}
+		if verbose {
+			args = append(args, "--verbose")
+		}
+		code := doMain(args, strings.NewReader(src), &stdout, &stderr)
+		if code != 0 {
+			t.Fatalf("unexpected exit code; want: %v, got: %v, stderr: %v", 0, code, stderr.String())
+		}
+		logged := strings.Contains(stderr.String(), "--- Production Set starts")
+		if logged != verbose {
+			t.Fatalf("unexpected stderr; verbose: %v, stderr: %v", verbose, stderr.String())
+		}
+		if !json.Valid(stdout.Bytes()) {
+			t.Fatalf("the output is not a valid JSON: %v", stdout.String())
+		}
+	}
+}
+
+func TestRun_LogLevel(t *testing.T) {
+	src := "s: FOO s | ;"
+
+	tests := []struct {
+		level  string
+		code   int
+		logged bool
+	}{
+		{level: "debug", logged: true},
+		{level: "info", logged: false},
+		{level: "ERROR", logged: false},
+		{level: "trace", code: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.level, func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+			code := doMain([]string{"--verbose", "--log-level", tt.level}, strings.NewReader(src), &stdout, &stderr)
+			if code != tt.code {
+				t.Fatalf("unexpected exit code; want: %v, got: %v, stderr: %v", tt.code, code, stderr.String())
+			}
+			if tt.code != 0 {
+				return
+			}
+			logged := strings.Contains(stderr.String(), "--- LR0 Automaton starts")
+			if logged != tt.logged {
+				t.Fatalf("unexpected stderr; want logged: %v, stderr: %v", tt.logged, stderr.String())
+			}
+		})
+	}
+}
+
+func TestRun_StatsJSON(t *testing.T) {
+	src := "e: e ADD e | NUM | c; c: C c | ; u: A;"
+
+	var stdout, stderr bytes.Buffer
+	code := doMain([]string{"--stats-json"}, strings.NewReader(src), &stdout, &stderr)
+	if code != 0 {
+		t.Fatalf("unexpected exit code; want: %v, got: %v, stderr: %v", 0, code, stderr.String())
+	}
+	var report grammar.GrammarReport
+	err := json.Unmarshal(stdout.Bytes(), &report)
+	if err != nil {
+		t.Fatalf("the output is not a valid report: %v; output: %v", err, stdout.String())
+	}
+	if report.Productions != 6 || report.NonTerminalSymbols != 3 || report.TerminalSymbols != 4 {
+		t.Fatalf("unexpected counts: %+v", report)
+	}
+	if report.Conflicts != 1 || report.ShiftReduceConflicts != 1 || report.States == 0 {
+		t.Fatalf("conflicts must be counted instead of failing: %+v", report)
+	}
+	if strings.Join(report.UnreachableSymbols, ",") != "u" || report.MaxRHSLength != 3 {
+		t.Fatalf("unexpected report: %+v", report)
+	}
+
+	dir, err := ioutil.TempDir("", "9gram")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	out := filepath.Join(dir, "stats.json")
+	stdout.Reset()
+	code = doMain([]string{"--stats-json", "-o", out}, strings.NewReader(src), &stdout, &stderr)
+	if code != 0 {
+		t.Fatalf("unexpected exit code; want: %v, got: %v, stderr: %v", 0, code, stderr.String())
+	}
+	d, err := ioutil.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !json.Valid(d) || stdout.Len() > 0 {
+		t.Fatalf("the report must be written only to the file; file: %v, stdout: %v", string(d), stdout.String())
+	}
+
+	code = doMain([]string{"--stats-json", "--check"}, strings.NewReader(src), &stdout, &stderr)
+	if code != 1 {
+		t.Fatalf("-check and -stats-json must be exclusive; exit code: %v", code)
+	}
+}
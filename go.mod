@@ -1,3 +1,8 @@
 module github.com/nihei9/9gram
 
 go 1.14
+
+require (
+	golang.org/x/sync v0.1.0
+	gopkg.in/yaml.v2 v2.4.0
+)
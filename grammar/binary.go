@@ -0,0 +1,44 @@
+package grammar
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/gob"
+	"fmt"
+	"io"
+)
+
+// binaryTableMagic precedes a binary table so that decoders can reject other data early.
+var binaryTableMagic = []byte("9gram\x00tbl")
+
+// EncodeTableBinary writes the same data as GenJSON in a binary form using encoding/gob.
+func EncodeTableBinary(w io.Writer, gram *Grammar, tab *Table, opts ...OutputOption) error {
+	t, err := genSerializedTable(gram, tab, opts...)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(binaryTableMagic)
+	if err != nil {
+		return err
+	}
+	return gob.NewEncoder(w).Encode(t)
+}
+
+// DecodeTableBinary reads a table written by EncodeTableBinary. It rejects tables of unknown versions.
+func DecodeTableBinary(r io.Reader) (*SerializedTable, error) {
+	br := bufio.NewReader(r)
+	magic := make([]byte, len(binaryTableMagic))
+	_, err := io.ReadFull(br, magic)
+	if err != nil || !bytes.Equal(magic, binaryTableMagic) {
+		return nil, fmt.Errorf("not a binary table")
+	}
+	var t SerializedTable
+	err = gob.NewDecoder(br).Decode(&t)
+	if err != nil {
+		return nil, err
+	}
+	if t.Version != SerializedTableVersion {
+		return nil, fmt.Errorf("unsupported table version: %v; supported version: %v", t.Version, SerializedTableVersion)
+	}
+	return &t, nil
+}
@@ -0,0 +1,67 @@
+package grammar
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestEncodeTableBinary(t *testing.T) {
+	gram, tab := genTestTable(t, mediumGrammarSource)
+
+	var b bytes.Buffer
+	err := EncodeTableBinary(&b, gram, tab)
+	if err != nil {
+		t.Fatal(err)
+	}
+	jsonOut, err := GenJSON(gram, tab)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b.Len() >= len(jsonOut) {
+		t.Fatalf("the binary table is not smaller than JSON; binary: %v bytes, JSON: %v bytes", b.Len(), len(jsonOut))
+	}
+
+	decoded, err := DecodeTableBinary(&b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ptab := tab.LR
+	if decoded.StateCount != ptab.numOfStates || decoded.TerminalSymbolCount != ptab.numOfTSymbols || decoded.NonTerminalSymbolCount != ptab.numOfNSymbols {
+		t.Fatalf("unexpected table size; states: %v, terminals: %v, non-terminals: %v", decoded.StateCount, decoded.TerminalSymbolCount, decoded.NonTerminalSymbolCount)
+	}
+	for state := 0; state < ptab.numOfStates; state++ {
+		for sym := 0; sym < ptab.numOfTSymbols; sym++ {
+			eTy, eNext, eProd := ptab.actionEntryAt(StateNum(state), SymbolNum(sym)).describe()
+			aTy, aNext, aProd := actionEntry(decoded.Action[state*ptab.numOfTSymbols+sym]).describe()
+			if aTy != eTy || aNext != eNext || aProd != eProd {
+				t.Fatalf("unexpected action; state: %v, symbol: %v, want: %v %v %v, got: %v %v %v", state, sym, eTy, eNext, eProd, aTy, aNext, aProd)
+			}
+		}
+		for sym := 0; sym < ptab.numOfNSymbols; sym++ {
+			eTy, eNext := ptab.getGoTo(StateNum(state), SymbolNum(sym))
+			aTy, aNext := goToEntry(decoded.GoTo[state*ptab.numOfNSymbols+sym]).describe()
+			if aTy != eTy || aNext != eNext {
+				t.Fatalf("unexpected goto; state: %v, symbol: %v, want: %v %v, got: %v %v", state, sym, eTy, eNext, aTy, aNext)
+			}
+		}
+	}
+	if strings.Join(decoded.TerminalSymbols, ",") != strings.Join(mustDecodeTable(t, jsonOut).TerminalSymbols, ",") {
+		t.Fatalf("unexpected terminal symbols: %v", decoded.TerminalSymbols)
+	}
+
+	_, err = DecodeTableBinary(bytes.NewReader(jsonOut))
+	if err == nil {
+		t.Fatalf("DecodeTableBinary must reject data other than a binary table")
+	}
+}
+
+func mustDecodeTable(t *testing.T, d []byte) *SerializedTable {
+	t.Helper()
+
+	tab, err := DecodeTable(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tab
+}
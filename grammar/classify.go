@@ -0,0 +1,304 @@
+package grammar
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// GrammarClass is the most restrictive class of LR grammars that a grammar belongs to.
+type GrammarClass string
+
+const (
+	GrammarClassSLR1  = GrammarClass("SLR(1)")
+	GrammarClassLALR1 = GrammarClass("LALR(1)")
+	GrammarClassLR1   = GrammarClass("LR(1)")
+
+	// GrammarClassNone means that even the canonical LR(1) table has conflicts. Such a grammar is ambiguous or needs
+	// more than one lookahead symbol.
+	GrammarClassNone = GrammarClass("none")
+)
+
+// ClassifyGrammar tries the SLR(1), LALR(1), and canonical LR(1) constructions in this order and returns the class of
+// the first one that has no conflicts. The precedences of the grammar resolve conflicts as GenTable does, but the
+// conflict policy doesn't; a conflict that only ConflictPolicyPreferShift resolves makes the grammar fall in the next
+// class. The canonical LR(1) automaton can have far more states than the LR0 automaton, so ClassifyGrammar is meant
+// for diagnosing a grammar rather than for every build.
+func ClassifyGrammar(gram *Grammar) (GrammarClass, error) {
+	_, err := GenTable(gram)
+	if err == nil {
+		return GrammarClassSLR1, nil
+	}
+	var cErr *ConflictError
+	if !errors.As(err, &cErr) {
+		return GrammarClassNone, err
+	}
+
+	fst, err := genFirst(gram.ProductionSet)
+	if err != nil {
+		return GrammarClassNone, fmt.Errorf("failed to create a FIRST set: %v", err)
+	}
+	lr1States, err := genLR1States(gram.ProductionSet, gram.AugmentedStartSymbol, fst)
+	if err != nil {
+		return GrammarClassNone, err
+	}
+	numOfTSyms := gram.SymbolTable.getNumOfTerminalSymbols()
+	if !hasLR1Conflicts(mergeLR1States(lr1States), gram, numOfTSyms) {
+		return GrammarClassLALR1, nil
+	}
+	if !hasLR1Conflicts(lr1States, gram, numOfTSyms) {
+		return GrammarClassLR1, nil
+	}
+	return GrammarClassNone, nil
+}
+
+type lr1Item struct {
+	prod      *production
+	dot       int
+	lookahead Symbol
+}
+
+func (i lr1Item) dottedSymbol() Symbol {
+	if i.dot >= i.prod.rhsLen {
+		return symbolNil
+	}
+	return i.prod.rhs[i.dot]
+}
+
+// lr1State is a state of the canonical LR(1) automaton, or of the LALR(1) automaton when it is made by merging the
+// states that have the same core. reductions maps each reducible production to its lookahead symbols.
+type lr1State struct {
+	kernel     []lr1Item
+	next       map[Symbol]int
+	reductions map[ProductionNum]map[Symbol]struct{}
+}
+
+// genLR1States generates the canonical LR(1) automaton. The first state is the initial state.
+func genLR1States(prods *productionSet, startSym Symbol, fst *First) ([]*lr1State, error) {
+	startProds, ok := prods.findByLHS(startSym)
+	if !ok {
+		return nil, fmt.Errorf("the production of the augmented start symbol was not found")
+	}
+	initial := []lr1Item{
+		{
+			prod:      startProds[0],
+			dot:       0,
+			lookahead: SymbolEOF,
+		},
+	}
+
+	var states []*lr1State
+	known := map[string]int{}
+	var kernels [][]lr1Item
+	add := func(kernel []lr1Item) int {
+		key := lr1KernelKey(kernel, true)
+		if num, ok := known[key]; ok {
+			return num
+		}
+		num := len(states)
+		known[key] = num
+		states = append(states, &lr1State{
+			kernel:     kernel,
+			next:       map[Symbol]int{},
+			reductions: map[ProductionNum]map[Symbol]struct{}{},
+		})
+		kernels = append(kernels, kernel)
+		return num
+	}
+	add(initial)
+	for num := 0; num < len(states); num++ {
+		state := states[num]
+		items, err := genLR1Closure(kernels[num], prods, fst)
+		if err != nil {
+			return nil, err
+		}
+		neighbours := map[Symbol][]lr1Item{}
+		var syms []Symbol
+		for _, item := range items {
+			sym := item.dottedSymbol()
+			if sym.isNil() {
+				if _, ok := state.reductions[item.prod.num]; !ok {
+					state.reductions[item.prod.num] = map[Symbol]struct{}{}
+				}
+				state.reductions[item.prod.num][item.lookahead] = struct{}{}
+				continue
+			}
+			if _, ok := neighbours[sym]; !ok {
+				syms = append(syms, sym)
+			}
+			neighbours[sym] = append(neighbours[sym], lr1Item{
+				prod:      item.prod,
+				dot:       item.dot + 1,
+				lookahead: item.lookahead,
+			})
+		}
+		for _, sym := range syms {
+			state.next[sym] = add(sortLR1Items(neighbours[sym]))
+		}
+	}
+	return states, nil
+}
+
+func genLR1Closure(kernel []lr1Item, prods *productionSet, fst *First) ([]lr1Item, error) {
+	items := append([]lr1Item{}, kernel...)
+	known := map[lr1Item]struct{}{}
+	for _, item := range kernel {
+		known[item] = struct{}{}
+	}
+	for i := 0; i < len(items); i++ {
+		item := items[i]
+		sym := item.dottedSymbol()
+		if !sym.isNonTerminal() {
+			continue
+		}
+		e, err := fst.OfSequence(item.prod.rhs[item.dot+1:])
+		if err != nil {
+			return nil, err
+		}
+		lookaheads := sortedSymbols(e.symbols)
+		if e.empty {
+			lookaheads = append(lookaheads, item.lookahead)
+		}
+		ps, _ := prods.findByLHS(sym)
+		for _, p := range ps {
+			for _, la := range lookaheads {
+				newItem := lr1Item{
+					prod:      p,
+					dot:       0,
+					lookahead: la,
+				}
+				if _, ok := known[newItem]; ok {
+					continue
+				}
+				known[newItem] = struct{}{}
+				items = append(items, newItem)
+			}
+		}
+	}
+	return items, nil
+}
+
+func sortLR1Items(items []lr1Item) []lr1Item {
+	sort.Slice(items, func(i, j int) bool {
+		if items[i].prod.num != items[j].prod.num {
+			return items[i].prod.num < items[j].prod.num
+		}
+		if items[i].dot != items[j].dot {
+			return items[i].dot < items[j].dot
+		}
+		return items[i].lookahead < items[j].lookahead
+	})
+	return items
+}
+
+// lr1KernelKey identifies a kernel. Without the lookaheads, the key identifies the core of the kernel, which is the
+// kernel of an LR0 state.
+func lr1KernelKey(kernel []lr1Item, withLookahead bool) string {
+	var b strings.Builder
+	prev := ""
+	for _, item := range kernel {
+		var key string
+		if withLookahead {
+			key = fmt.Sprintf("%v.%v.%v;", item.prod.num, item.dot, item.lookahead)
+		} else {
+			key = fmt.Sprintf("%v.%v;", item.prod.num, item.dot)
+		}
+		// The items having the same core are adjacent because the kernel is sorted.
+		if key == prev {
+			continue
+		}
+		prev = key
+		b.WriteString(key)
+	}
+	return b.String()
+}
+
+// mergeLR1States makes the LALR(1) automaton by merging the states of the canonical LR(1) automaton that have the same
+// core. The states having the same core move to the states having the same core, so the transitions of the merged
+// states never disagree.
+func mergeLR1States(states []*lr1State) []*lr1State {
+	merged := map[string]int{}
+	nums := make([]int, len(states))
+	var lalrStates []*lr1State
+	for i, state := range states {
+		key := lr1KernelKey(state.kernel, false)
+		num, ok := merged[key]
+		if !ok {
+			num = len(lalrStates)
+			merged[key] = num
+			lalrStates = append(lalrStates, &lr1State{
+				kernel:     state.kernel,
+				next:       map[Symbol]int{},
+				reductions: map[ProductionNum]map[Symbol]struct{}{},
+			})
+		}
+		nums[i] = num
+		for prod, las := range state.reductions {
+			if _, ok := lalrStates[num].reductions[prod]; !ok {
+				lalrStates[num].reductions[prod] = map[Symbol]struct{}{}
+			}
+			for la := range las {
+				lalrStates[num].reductions[prod][la] = struct{}{}
+			}
+		}
+	}
+	for i, state := range states {
+		for sym, next := range state.next {
+			lalrStates[nums[i]].next[sym] = nums[next]
+		}
+	}
+	return lalrStates
+}
+
+// hasLR1Conflicts writes the ACTION entries of the states to a scratch table and reports whether any entry has a
+// conflict that the precedences don't resolve.
+func hasLR1Conflicts(states []*lr1State, gram *Grammar, numOfTSyms int) bool {
+	prodPrecs := map[ProductionNum]*Precedence{}
+	for _, prod := range gram.ProductionSet.getAll() {
+		if prod.prec != nil {
+			prodPrecs[prod.num] = prod.prec
+		}
+	}
+	ptab := &ParsingTable{
+		actionTable:   make([]actionEntry, len(states)*numOfTSyms),
+		numOfStates:   len(states),
+		numOfTSymbols: numOfTSyms,
+		policy:        ConflictPolicyError,
+		eofMode:       EOFModeExplicit,
+		symPrecs:      gram.Precedences,
+		prodPrecs:     prodPrecs,
+		errorCells:    map[int]struct{}{},
+	}
+	for num, state := range states {
+		for sym, next := range state.next {
+			if !sym.isTerminal() {
+				continue
+			}
+			if c := ptab.writeShiftAction(StateNum(num), sym, StateNum(next)); c != nil {
+				return true
+			}
+		}
+		var prods []ProductionNum
+		for prod := range state.reductions {
+			prods = append(prods, prod)
+		}
+		sort.Slice(prods, func(i, j int) bool {
+			return prods[i] < prods[j]
+		})
+		for _, prod := range prods {
+			for _, la := range sortedSymbols(state.reductions[prod]) {
+				var c *Conflict
+				if prod == ProductionNumStart {
+					c = ptab.writeAcceptAction(StateNum(num), la)
+				} else {
+					c = ptab.writeReduceAction(StateNum(num), la, prod)
+				}
+				if c != nil {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
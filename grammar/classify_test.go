@@ -0,0 +1,53 @@
+package grammar
+
+import "testing"
+
+func TestClassifyGrammar(t *testing.T) {
+	tests := []struct {
+		caption string
+		src     string
+		class   GrammarClass
+	}{
+		{
+			caption: "an SLR(1) grammar",
+			src:     "e: e ADD t | t; t: t MUL f | f; f: NUM | LPAREN e RPAREN;",
+			class:   GrammarClassSLR1,
+		},
+		{
+			caption: "a grammar whose conflicts the precedences resolve is SLR(1)",
+			src:     "%left ADD; %left MUL; e: e ADD e | e MUL e | NUM;",
+			class:   GrammarClassSLR1,
+		},
+		{
+			caption: "an LALR(1) grammar that isn't SLR(1)",
+			src:     "s: l EQ r | r; l: STAR r | ID; r: l;",
+			class:   GrammarClassLALR1,
+		},
+		{
+			caption: "an LR(1) grammar that isn't LALR(1)",
+			src:     "s: A a D | B b D | A b E | B a E; a: C; b: C;",
+			class:   GrammarClassLR1,
+		},
+		{
+			caption: "an ambiguous grammar belongs to no class",
+			src:     "e: e ADD e | NUM;",
+			class:   GrammarClassNone,
+		},
+		{
+			caption: "the conflict policy doesn't resolve the dangling else",
+			src:     "stmt: IF cond THEN stmt | IF cond THEN stmt ELSE stmt | ID; cond: ID;",
+			class:   GrammarClassNone,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.caption, func(t *testing.T) {
+			class, err := ClassifyGrammar(genTestGrammar(t, tt.src))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if class != tt.class {
+				t.Fatalf("unexpected class; want: %v, got: %v", tt.class, class)
+			}
+		})
+	}
+}
@@ -0,0 +1,166 @@
+package grammar
+
+import (
+	"fmt"
+	"sort"
+)
+
+// rowDisplacedTable is a compressed form of a dense table using row displacement. Identical rows of the dense table
+// share one row, and each row keeps only the entries that differ from the default value of the row. The rows are
+// overlaid in next/check so that the entries of different rows never occupy the same slot.
+//
+// The entry (r, col) of the dense table is next[base[row[r]]+col] when check[base[row[r]]+col] is row[r];
+// otherwise it is def[row[r]].
+type rowDisplacedTable struct {
+	row   []int
+	base  []int
+	def   []int64
+	next  []int64
+	check []int
+}
+
+func genRowDisplacedTable(dense []int64, numOfDenseRows, rowLen int) *rowDisplacedTable {
+	t := &rowDisplacedTable{
+		row: make([]int, numOfDenseRows),
+	}
+
+	var uniqueRows [][]int64
+	{
+		knownRows := map[string]int{}
+		for r := 0; r < numOfDenseRows; r++ {
+			entries := dense[r*rowLen : (r+1)*rowLen]
+			key := fmt.Sprint(entries)
+			if row, ok := knownRows[key]; ok {
+				t.row[r] = row
+				continue
+			}
+			knownRows[key] = len(uniqueRows)
+			t.row[r] = len(uniqueRows)
+			uniqueRows = append(uniqueRows, entries)
+		}
+	}
+	numOfRows := len(uniqueRows)
+	t.base = make([]int, numOfRows)
+	t.def = make([]int64, numOfRows)
+
+	// The default value of a row is its most frequent value. It may be an empty entry, so the lookup always
+	// returns the same value as the dense table.
+	rowEntries := make([][]int, numOfRows)
+	for row := 0; row < numOfRows; row++ {
+		entries := uniqueRows[row]
+		freq := map[int64]int{}
+		for _, e := range entries {
+			freq[e]++
+		}
+		def := int64(0)
+		for v, n := range freq {
+			if n > freq[def] || (n == freq[def] && v < def) {
+				def = v
+			}
+		}
+		t.def[row] = def
+		for col, e := range entries {
+			if e != def {
+				rowEntries[row] = append(rowEntries[row], col)
+			}
+		}
+	}
+
+	// Place the densest rows first because they are the hardest to fit.
+	rows := make([]int, numOfRows)
+	for row := range rows {
+		rows[row] = row
+	}
+	sort.SliceStable(rows, func(i, j int) bool {
+		return len(rowEntries[rows[i]]) > len(rowEntries[rows[j]])
+	})
+
+	for _, row := range rows {
+		cols := rowEntries[row]
+		if len(cols) == 0 {
+			continue
+		}
+		base := 0
+		for {
+			fits := true
+			for _, col := range cols {
+				pos := base + col
+				if pos < len(t.check) && t.check[pos] >= 0 {
+					fits = false
+					break
+				}
+			}
+			if fits {
+				break
+			}
+			base++
+		}
+		t.base[row] = base
+		for _, col := range cols {
+			pos := base + col
+			for len(t.check) <= pos {
+				t.check = append(t.check, -1)
+				t.next = append(t.next, 0)
+			}
+			t.check[pos] = row
+			t.next[pos] = uniqueRows[row][col]
+		}
+	}
+
+	return t
+}
+
+func (t *rowDisplacedTable) get(r, col int) int64 {
+	row := t.row[r]
+	pos := t.base[row] + col
+	if pos < len(t.check) && t.check[pos] == row {
+		return t.next[pos]
+	}
+	return t.def[row]
+}
+
+// CompressedParsingTable is a compressed form of ParsingTable. Its lookups return the same results as the ones of
+// the original table. Like ParsingTable.getAction, a reduce-only state reduces by its production on any terminal
+// symbol, so the rows of such states need no entries. That only delays the detection of a syntax error until the next
+// shift. A state having an error entry made by `%nonassoc` is never a reduce-only state, so the error is kept.
+type CompressedParsingTable struct {
+	action       *rowDisplacedTable
+	goTo         *rowDisplacedTable
+	numOfStates  int
+	InitialState StateNum
+}
+
+func CompressParsingTable(ptab *ParsingTable) *CompressedParsingTable {
+	action := make([]int64, len(ptab.actionTable))
+	for i, e := range ptab.actionTable {
+		action[i] = int64(e)
+	}
+	for state := 0; state < ptab.numOfStates; state++ {
+		prod := ptab.reduceOnlyProduction(StateNum(state))
+		if prod == productionNumNil {
+			continue
+		}
+		row := action[state*ptab.numOfTSymbols : (state+1)*ptab.numOfTSymbols]
+		for i := range row {
+			row[i] = int64(newReduceActionEntry(prod))
+		}
+	}
+	goTo := make([]int64, len(ptab.goToTable))
+	for i, e := range ptab.goToTable {
+		goTo[i] = int64(e)
+	}
+	return &CompressedParsingTable{
+		action:       genRowDisplacedTable(action, ptab.numOfStates, ptab.numOfTSymbols),
+		goTo:         genRowDisplacedTable(goTo, ptab.numOfStates, ptab.numOfNSymbols),
+		numOfStates:  ptab.numOfStates,
+		InitialState: ptab.InitialState,
+	}
+}
+
+func (t *CompressedParsingTable) getAction(state StateNum, sym SymbolNum) (ActionType, StateNum, ProductionNum) {
+	return actionEntry(t.action.get(state.Int(), sym.Int())).describe()
+}
+
+func (t *CompressedParsingTable) getGoTo(state StateNum, sym SymbolNum) (GoToType, StateNum) {
+	return goToEntry(t.goTo.get(state.Int(), sym.Int())).describe()
+}
@@ -0,0 +1,184 @@
+package grammar
+
+import (
+	"strings"
+	"testing"
+)
+
+// mediumGrammarSource is a grammar of a small programming language.
+const mediumGrammarSource = `
+program: stmts;
+stmts: stmts stmt | ;
+stmt: IF LPAREN expr RPAREN block else | WHILE LPAREN expr RPAREN block | VAR ID ASSIGN expr SEMICOLON | ID ASSIGN expr SEMICOLON | RETURN expr SEMICOLON | expr SEMICOLON;
+else: ELSE block | ;
+block: LBRACE stmts RBRACE;
+expr: expr OR and | and;
+and: and AND cmp | cmp;
+cmp: cmp EQ add | cmp LT add | add;
+add: add PLUS mul | add MINUS mul | mul;
+mul: mul STAR unary | mul SLASH unary | unary;
+unary: MINUS unary | NOT unary | call;
+call: call LPAREN args RPAREN | primary;
+args: arglist | ;
+arglist: arglist COMMA expr | expr;
+primary: ID | NUMBER | STRING | LPAREN expr RPAREN;
+`
+
+func TestCompressParsingTable(t *testing.T) {
+	srcs := []string{
+		"s: A s | B;",
+		"e: e ADD t | t; t: t MUL f | f; f: LPAREN e RPAREN | NUMBER;",
+		mediumGrammarSource,
+	}
+	for _, src := range srcs {
+		_, tab := genTestTable(t, src)
+		ptab := tab.LR
+		ctab := CompressParsingTable(ptab)
+		for state := 0; state < ptab.numOfStates; state++ {
+			for sym := 0; sym < ptab.numOfTSymbols; sym++ {
+				eTy, eNext, eProd := ptab.getAction(StateNum(state), SymbolNum(sym))
+				aTy, aNext, aProd := ctab.getAction(StateNum(state), SymbolNum(sym))
+				if aTy != eTy || aNext != eNext || aProd != eProd {
+					t.Fatalf("unexpected action; state: %v, symbol: %v, want: %v %v %v, got: %v %v %v", state, sym, eTy, eNext, eProd, aTy, aNext, aProd)
+				}
+			}
+			for sym := 0; sym < ptab.numOfNSymbols; sym++ {
+				eTy, eNext := ptab.getGoTo(StateNum(state), SymbolNum(sym))
+				aTy, aNext := ctab.getGoTo(StateNum(state), SymbolNum(sym))
+				if aTy != eTy || aNext != eNext {
+					t.Fatalf("unexpected goto; state: %v, symbol: %v, want: %v %v, got: %v %v", state, sym, eTy, eNext, aTy, aNext)
+				}
+			}
+		}
+	}
+}
+
+func TestCompressParsingTable_ReduceOnlyStates(t *testing.T) {
+	_, tab := genTestTable(t, "e: e ADD t | t; t: t MUL f | f; f: LPAREN e RPAREN | NUMBER;")
+	ptab := tab.LR
+	ctab := CompressParsingTable(ptab)
+	found := false
+	for state := 0; state < ptab.numOfStates; state++ {
+		prod := ptab.reduceOnlyProduction(StateNum(state))
+		if prod == productionNumNil {
+			continue
+		}
+		found = true
+		for sym := 0; sym < ptab.numOfTSymbols; sym++ {
+			// The entries written in the dense table still have errors, but the compressed table has no entries in
+			// the row and reduces on any terminal symbol.
+			ty, _, p := ctab.getAction(StateNum(state), SymbolNum(sym))
+			if ty != ActionTypeReduce || p != prod {
+				t.Fatalf("a reduce-only state must reduce on any terminal symbol; state: %v, symbol: %v, want: reduce %v, got: %v %v", state, sym, prod, ty, p)
+			}
+		}
+	}
+	if !found {
+		t.Fatalf("the grammar must have reduce-only states")
+	}
+}
+
+func TestCompressParsingTable_NonAssoc(t *testing.T) {
+	gram, tab := genTestTable(t, "%left ADD; %nonassoc LT; expr: expr ADD expr | expr LT expr | NUM;")
+	ctab := CompressParsingTable(tab.LR)
+	prods := map[ProductionNum]*production{}
+	for _, prod := range gram.ProductionSet.getAll() {
+		prods[prod.num] = prod
+	}
+	parse := func(input []string) bool {
+		stack := []StateNum{ctab.InitialState}
+		i := 0
+		for {
+			sym := SymbolEOF.Num()
+			if i < len(input) {
+				sym = symbolOf(t, gram, input[i]).Num()
+			}
+			ty, next, prodNum := ctab.getAction(stack[len(stack)-1], sym)
+			switch ty {
+			case ActionTypeShift:
+				stack = append(stack, next)
+				i++
+			case ActionTypeReduce:
+				stack = stack[:len(stack)-prods[prodNum].rhsLen]
+				_, next := ctab.getGoTo(stack[len(stack)-1], prods[prodNum].lhs.Num())
+				stack = append(stack, next)
+			case ActionTypeAccept:
+				return true
+			default:
+				return false
+			}
+		}
+	}
+	for input, accepted := range map[string]bool{
+		"NUM LT NUM":                true,
+		"NUM LT NUM ADD NUM":        true,
+		"NUM LT NUM LT NUM":         false,
+		"NUM ADD NUM LT NUM LT NUM": false,
+	} {
+		if parse(strings.Fields(input)) != accepted {
+			t.Fatalf("the compressed table must agree with the dense one; input: %v, want: %v", input, accepted)
+		}
+	}
+}
+
+func TestGenJSON_CompressedTable(t *testing.T) {
+	gram, tab := genTestTable(t, mediumGrammarSource)
+
+	dense, err := GenJSON(gram, tab)
+	if err != nil {
+		t.Fatal(err)
+	}
+	compressed, err := GenJSON(gram, tab, WithCompressedTable())
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := DecodeTable(compressed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decoded.Action != nil || decoded.GoTo != nil {
+		t.Fatalf("the compressed output must not contain the dense tables")
+	}
+	if decoded.CompressedAction == nil || decoded.CompressedGoTo == nil {
+		t.Fatalf("the compressed output must contain the compressed tables")
+	}
+	if len(compressed) >= len(dense) {
+		t.Fatalf("the compressed output is not smaller than the dense one; dense: %v bytes, compressed: %v bytes", len(dense), len(compressed))
+	}
+	t.Logf("dense: %v bytes, compressed: %v bytes", len(dense), len(compressed))
+}
+
+func TestGenJSON_ReduceOnlyStates(t *testing.T) {
+	gram, tab := genTestTable(t, mediumGrammarSource)
+	d, err := GenJSON(gram, tab, WithCompressedTable())
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := DecodeTable(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(out.ReduceOnlyStates) != out.StateCount {
+		t.Fatalf("reduce_only_states must have an entry per state; want: %v, got: %v", out.StateCount, len(out.ReduceOnlyStates))
+	}
+	marked := 0
+	for state, prod := range out.ReduceOnlyStates {
+		if prod == 0 {
+			continue
+		}
+		marked++
+		// The compressed row of a reduce-only state consists only of the default.
+		row := out.CompressedAction.Row[state]
+		if out.CompressedAction.Default[row] != int64(prod) {
+			t.Fatalf("unexpected default; state: %v, want: %v, got: %v", state, prod, out.CompressedAction.Default[row])
+		}
+		for _, r := range out.CompressedAction.Check {
+			if r == row {
+				t.Fatalf("the compressed row of a reduce-only state must have no entries; state: %v", state)
+			}
+		}
+	}
+	if marked == 0 {
+		t.Fatalf("the grammar must have reduce-only states")
+	}
+}
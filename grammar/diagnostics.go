@@ -0,0 +1,220 @@
+package grammar
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/nihei9/9gram/parser"
+)
+
+// Severity tells whether a diagnostic prevents generating a parsing table.
+type Severity string
+
+const (
+	SeverityWarning = Severity("warning")
+	SeverityError   = Severity("error")
+)
+
+// Diagnostic is a finding about a grammar. Pos is the position in the source that the finding refers to, such as the
+// definition of an unused symbol. HasPos is false when the finding refers to no position, such as a conflict.
+type Diagnostic struct {
+	Severity Severity
+	Message  string
+	Pos      parser.Position
+	HasPos   bool
+}
+
+func (d *Diagnostic) String() string {
+	if d.HasPos {
+		return fmt.Sprintf("%v: %v (%v, %v)", d.Severity, d.Message, d.Pos.Line, d.Pos.Column)
+	}
+	return fmt.Sprintf("%v: %v", d.Severity, d.Message)
+}
+
+// Diagnostics is the findings about a grammar in the order that Build found them.
+type Diagnostics struct {
+	Items []*Diagnostic
+}
+
+func (ds *Diagnostics) add(severity Severity, message string) {
+	ds.Items = append(ds.Items, &Diagnostic{
+		Severity: severity,
+		Message:  message,
+	})
+}
+
+func (ds *Diagnostics) addAt(severity Severity, message string, pos parser.Position) {
+	ds.Items = append(ds.Items, &Diagnostic{
+		Severity: severity,
+		Message:  message,
+		Pos:      pos,
+		HasPos:   true,
+	})
+}
+
+// Filter returns the diagnostics of the severity.
+func (ds *Diagnostics) Filter(severity Severity) []*Diagnostic {
+	var items []*Diagnostic
+	for _, d := range ds.Items {
+		if d.Severity == severity {
+			items = append(items, d)
+		}
+	}
+	return items
+}
+
+// HasErrors reports whether the diagnostics contain an error.
+func (ds *Diagnostics) HasErrors() bool {
+	return len(ds.Filter(SeverityError)) > 0
+}
+
+// Write writes the diagnostics to w one per line.
+func (ds *Diagnostics) Write(w io.Writer) error {
+	for _, d := range ds.Items {
+		_, err := fmt.Fprintln(w, d)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type buildConfig struct {
+	srcPath  string
+	psrOpts  []parser.ParserOption
+	gramOpts []GrammarOption
+	tabOpts  []TableOption
+}
+
+// BuildOption configures Build and BuildFromAST.
+type BuildOption func(*buildConfig)
+
+// WithSourcePath tells Build the path of the source, against which the paths of `%include` directives are
+// resolved. Without this option, they are resolved against the current directory.
+func WithSourcePath(path string) BuildOption {
+	return func(c *buildConfig) {
+		c.srcPath = path
+	}
+}
+
+// WithParserOptions passes the options to the parser of Build and to the parsers of the included files.
+func WithParserOptions(opts ...parser.ParserOption) BuildOption {
+	return func(c *buildConfig) {
+		c.psrOpts = append(c.psrOpts, opts...)
+	}
+}
+
+// WithGrammarOptions passes the options to GenGrammar.
+func WithGrammarOptions(opts ...GrammarOption) BuildOption {
+	return func(c *buildConfig) {
+		c.gramOpts = append(c.gramOpts, opts...)
+	}
+}
+
+// WithTableOptions passes the options to GenTable.
+func WithTableOptions(opts ...TableOption) BuildOption {
+	return func(c *buildConfig) {
+		c.tabOpts = append(c.tabOpts, opts...)
+	}
+}
+
+// Build parses a grammar source, resolves its includes, and generates the grammar and the parsing table. The
+// diagnostics collect every finding on the way: the warnings of GenGrammar, unused terminal symbols, unreachable and
+// unproductive symbols, the conflicts, and the productions that the conflict resolution left never reduced. The
+// diagnostics are returned even when Build fails, and the error is also recorded in them. When generating the parsing
+// table fails, such as due to conflicts, Build returns the grammar along with the error.
+func Build(src io.Reader, opts ...BuildOption) (*Grammar, *Table, *Diagnostics, error) {
+	config := &buildConfig{}
+	for _, opt := range opts {
+		opt(config)
+	}
+	diags := &Diagnostics{}
+	psr, err := parser.NewParser(src, config.psrOpts...)
+	if err != nil {
+		diags.add(SeverityError, err.Error())
+		return nil, nil, diags, err
+	}
+	ast, err := psr.Parse()
+	if err == nil {
+		_, err = parser.ResolveIncludes(ast, config.srcPath, config.psrOpts...)
+	}
+	if err != nil {
+		var synErr *parser.SyntaxError
+		var incErr *parser.IncludeError
+		if errors.As(err, &synErr) && !errors.As(err, &incErr) {
+			diags.addAt(SeverityError, synErr.Message(), synErr.Pos())
+		} else {
+			diags.add(SeverityError, err.Error())
+		}
+		return nil, nil, diags, err
+	}
+	gram, tab, astDiags, err := BuildFromAST(ast, opts...)
+	diags.Items = append(diags.Items, astDiags.Items...)
+	return gram, tab, diags, err
+}
+
+// BuildFromAST is the same as Build except that it takes a parsed source whose includes are resolved already.
+func BuildFromAST(root *parser.AST, opts ...BuildOption) (*Grammar, *Table, *Diagnostics, error) {
+	config := &buildConfig{}
+	for _, opt := range opts {
+		opt(config)
+	}
+	diags := &Diagnostics{}
+	gram, err := GenGrammar(root, config.gramOpts...)
+	if err != nil {
+		diags.add(SeverityError, err.Error())
+		return nil, nil, diags, err
+	}
+
+	// The findings about symbols refer to the first definitions of the symbols.
+	defPos := map[string]parser.Position{}
+	for _, prodAST := range parser.Find(root, parser.ASTTypeProduction) {
+		lhsText, _ := prodAST.Children[0].GetText()
+		if _, ok := defPos[lhsText]; ok {
+			continue
+		}
+		defPos[lhsText], _ = prodAST.Children[0].Pos()
+	}
+	addSymbolWarning := func(message string, text string) {
+		msg := fmt.Sprintf("%v: %v", message, text)
+		if pos, ok := defPos[text]; ok {
+			diags.addAt(SeverityWarning, msg, pos)
+		} else {
+			diags.add(SeverityWarning, msg)
+		}
+	}
+	for _, w := range gram.Warnings {
+		diags.add(SeverityWarning, w)
+	}
+	for _, num := range findUnusedTerminalSymbols(gram) {
+		text, _ := gram.SymbolTable.ToTextFromNumT(num)
+		addSymbolWarning("unused terminal symbol", text)
+	}
+	for _, text := range FindUnreachableSymbols(gram) {
+		addSymbolWarning("unreachable symbol", text)
+	}
+	for _, text := range FindUnproductiveSymbols(gram) {
+		addSymbolWarning("unproductive symbol", text)
+	}
+
+	tab, err := GenTable(gram, config.tabOpts...)
+	if err != nil {
+		var cErr *ConflictError
+		if !errors.As(err, &cErr) {
+			diags.add(SeverityError, err.Error())
+			return gram, nil, diags, err
+		}
+		for _, c := range cErr.Conflicts {
+			diags.add(SeverityError, c.String())
+		}
+		return gram, nil, diags, err
+	}
+	for _, c := range tab.ResolvedConflicts {
+		diags.add(SeverityWarning, c.String())
+	}
+	for _, text := range FindNeverReducedProductions(gram, tab) {
+		diags.add(SeverityWarning, fmt.Sprintf("never-reduced production: %v", text))
+	}
+	return gram, tab, diags, nil
+}
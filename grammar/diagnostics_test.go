@@ -0,0 +1,107 @@
+package grammar
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestBuild(t *testing.T) {
+	tests := []struct {
+		caption   string
+		src       string
+		opts      []BuildOption
+		diags     []string
+		hasTable  bool
+		conflicts bool
+		failed    bool
+	}{
+		{
+			caption:  "a grammar without problems has no diagnostics",
+			src:      "s: A s | ;",
+			diags:    nil,
+			hasTable: true,
+		},
+		{
+			caption: "warnings don't prevent generating a table",
+			src: `s: A | A;
+NUM: "[0-9]+";
+a: B;
+`,
+			diags: []string{
+				"warning: duplicate alternative: s: A",
+				"warning: unused terminal symbol: NUM (2, 1)",
+				"warning: unreachable symbol: a (3, 1)",
+			},
+			hasTable: true,
+		},
+		{
+			caption: "resolved conflicts are warnings",
+			src:     "s: IF s | IF s ELSE s | OTHER;",
+			opts:    []BuildOption{WithTableOptions(WithConflictPolicy(ConflictPolicyPreferShift))},
+			diags: []string{
+				"warning: state 4: shift/reduce conflict on ELSE: shift to state 5, reduce by #2 s: IF s; path: IF s; resolved as shift",
+			},
+			hasTable: true,
+		},
+		{
+			caption: "a production that the conflict resolution shadowed is a warning",
+			src:     "s: A B | a B C; a: A;",
+			opts:    []BuildOption{WithTableOptions(WithConflictPolicy(ConflictPolicyPreferShift))},
+			diags: []string{
+				"warning: state 3: shift/reduce conflict on B: shift to state 5, reduce by #4 a: A; path: A; resolved as shift",
+				"warning: never-reduced production: a: A",
+			},
+			hasTable: true,
+		},
+		{
+			caption: "unresolved conflicts are errors",
+			src:     "s: IF s | IF s ELSE s | OTHER;",
+			diags: []string{
+				"error: state 4: shift/reduce conflict on ELSE: shift to state 5, reduce by #2 s: IF s; path: IF s",
+			},
+			conflicts: true,
+		},
+		{
+			caption: "a syntax error is an error with its position",
+			src:     "s: A\nB: ;;",
+			diags: []string{
+				"error: unexpected token; expected: ;, actual: id (2, 1)",
+			},
+			failed: true,
+		},
+		{
+			caption: "an error of the grammar is an error",
+			src:     `s: A %i;`,
+			diags: []string{
+				"error: %i is allowed only in lexeme productions; symbol: s, position: (1, 6)",
+			},
+			failed: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.caption, func(t *testing.T) {
+			gram, tab, diags, err := Build(strings.NewReader(tt.src), tt.opts...)
+			var diagTexts []string
+			for _, d := range diags.Items {
+				diagTexts = append(diagTexts, d.String())
+			}
+			if strings.Join(diagTexts, "\n") != strings.Join(tt.diags, "\n") {
+				t.Fatalf("unexpected diagnostics;\nwant:\n%v\ngot:\n%v", strings.Join(tt.diags, "\n"), strings.Join(diagTexts, "\n"))
+			}
+			if (tab != nil) != tt.hasTable {
+				t.Fatalf("unexpected table; want: %v, got: %v", tt.hasTable, tab != nil)
+			}
+			var cErr *ConflictError
+			if errors.As(err, &cErr) != tt.conflicts {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if (gram == nil) != tt.failed {
+				t.Fatalf("unexpected grammar; failed: %v, error: %v", tt.failed, err)
+			}
+			if diags.HasErrors() != (err != nil) {
+				t.Fatalf("the diagnostics must contain an error when Build fails; error: %v", err)
+			}
+		})
+	}
+}
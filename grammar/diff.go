@@ -0,0 +1,148 @@
+package grammar
+
+import "fmt"
+
+// TableDiffKind tells which part of the parsing tables a TableDiff is about.
+type TableDiffKind string
+
+const (
+	// TableDiffKindStateCount means that the tables have different numbers of states.
+	TableDiffKindStateCount = TableDiffKind("state-count")
+
+	// TableDiffKindAction means that the tables have different ACTION entries.
+	TableDiffKindAction = TableDiffKind("action")
+
+	// TableDiffKindGoTo means that the tables have different GOTO entries.
+	TableDiffKindGoTo = TableDiffKind("goto")
+)
+
+// TableDiff is a difference between two parsing tables. For an ACTION or GOTO difference, State and Symbol locate the
+// cell, and A and B are the cells of the two tables in the notation of WriteParsingTableGrid. An empty cell is an
+// error, which is also what a table without the state or the symbol has. For a state-count difference, A and B are
+// the numbers of the states, and State and Symbol are unused.
+type TableDiff struct {
+	Kind   TableDiffKind
+	State  StateNum
+	Symbol SymbolNum
+
+	// SymbolText is the text of Symbol. The text comes from the first table unless only the second one has the
+	// symbol. The text of EOF is `<eof>`.
+	SymbolText string
+
+	A string
+	B string
+}
+
+func (d *TableDiff) String() string {
+	if d.Kind == TableDiffKindStateCount {
+		return fmt.Sprintf("state count: %v → %v", d.A, d.B)
+	}
+	return fmt.Sprintf("%v state %v, %v: %v → %v", d.Kind, d.State, d.SymbolText, cellOrError(d.A), cellOrError(d.B))
+}
+
+func cellOrError(cell string) string {
+	if cell == "" {
+		return "error"
+	}
+	return cell
+}
+
+// DiffTables compares two parsing tables cell by cell and returns the differences: the state-count difference first,
+// then the ACTION differences, and then the GOTO differences, each in ascending order of states and symbols. The
+// cells are compared by the numbers of states and symbols, so the tables of grammars declaring the symbols in
+// different orders differ even when they accept the same language. DiffTables returns nil when the tables are the
+// same.
+func DiffTables(a, b *Table) []TableDiff {
+	var diffs []TableDiff
+	if a.LR.numOfStates != b.LR.numOfStates {
+		diffs = append(diffs, TableDiff{
+			Kind: TableDiffKindStateCount,
+			A:    fmt.Sprint(a.LR.numOfStates),
+			B:    fmt.Sprint(b.LR.numOfStates),
+		})
+	}
+	numOfStates := maxInt(a.LR.numOfStates, b.LR.numOfStates)
+
+	tSyms := []SymbolNum{SymbolEOF.Num()}
+	for num := terminalSymbolNumMin.Int(); num < maxInt(a.LR.numOfTSymbols, b.LR.numOfTSymbols); num++ {
+		tSyms = append(tSyms, SymbolNum(num))
+	}
+	for state := 0; state < numOfStates; state++ {
+		for _, sym := range tSyms {
+			cellA := a.actionCellText(StateNum(state), sym)
+			cellB := b.actionCellText(StateNum(state), sym)
+			if cellA == cellB {
+				continue
+			}
+			diffs = append(diffs, TableDiff{
+				Kind:       TableDiffKindAction,
+				State:      StateNum(state),
+				Symbol:     sym,
+				SymbolText: diffSymbolText(a, b, sym, true),
+				A:          cellA,
+				B:          cellB,
+			})
+		}
+	}
+
+	for state := 0; state < numOfStates; state++ {
+		for num := nonTerminalSymbolNumMin.Int() + 1; num < maxInt(a.LR.numOfNSymbols, b.LR.numOfNSymbols); num++ {
+			sym := SymbolNum(num)
+			cellA := a.goToCellText(StateNum(state), sym)
+			cellB := b.goToCellText(StateNum(state), sym)
+			if cellA == cellB {
+				continue
+			}
+			diffs = append(diffs, TableDiff{
+				Kind:       TableDiffKindGoTo,
+				State:      StateNum(state),
+				Symbol:     sym,
+				SymbolText: diffSymbolText(a, b, sym, false),
+				A:          cellA,
+				B:          cellB,
+			})
+		}
+	}
+	return diffs
+}
+
+// actionCellText and goToCellText return an empty cell for a state or a symbol out of the table.
+func (tab *Table) actionCellText(state StateNum, sym SymbolNum) string {
+	if state.Int() >= tab.LR.numOfStates || sym.Int() >= tab.LR.numOfTSymbols {
+		return ""
+	}
+	return tab.LR.actionCellText(state, sym)
+}
+
+func (tab *Table) goToCellText(state StateNum, sym SymbolNum) string {
+	if state.Int() >= tab.LR.numOfStates || sym.Int() >= tab.LR.numOfNSymbols {
+		return ""
+	}
+	return tab.LR.goToCellText(state, sym)
+}
+
+func diffSymbolText(a, b *Table, sym SymbolNum, terminal bool) string {
+	if terminal && sym == SymbolEOF.Num() {
+		return "<eof>"
+	}
+	for _, tab := range []*Table{a, b} {
+		var text string
+		var err error
+		if terminal {
+			text, err = tab.symTab.ToTextFromNumT(sym)
+		} else {
+			text, err = tab.symTab.ToTextFromNumN(sym)
+		}
+		if err == nil {
+			return text
+		}
+	}
+	return ""
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,52 @@
+package grammar
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDiffTables(t *testing.T) {
+	tests := []struct {
+		caption string
+		srcA    string
+		srcB    string
+		diffs   []string
+	}{
+		{
+			caption: "the tables of the same grammar have no differences",
+			srcA:    "s: A s | B;",
+			srcB:    "s: A s | B;",
+		},
+		{
+			caption: "the tables of grammars that differ only in labels have no differences",
+			srcA:    "s: A s | B;",
+			srcB:    "s: A s #cons | B #nil;",
+		},
+		{
+			caption: "an added alternative changes the state count and the cells",
+			srcA:    "s: A s | B;",
+			srcB:    "s: A s | B | C;",
+			diffs: []string{
+				"state count: 5 → 6",
+				"action state 0, C: error → s4",
+				"action state 2, C: error → s4",
+				"action state 4, <eof>: r2 → r4",
+				"action state 5, <eof>: error → r2",
+				"goto state 2, s: 4 → 5",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.caption, func(t *testing.T) {
+			_, a := genTestTable(t, tt.srcA)
+			_, b := genTestTable(t, tt.srcB)
+			var diffs []string
+			for _, d := range DiffTables(a, b) {
+				diffs = append(diffs, d.String())
+			}
+			if strings.Join(diffs, "\n") != strings.Join(tt.diffs, "\n") {
+				t.Fatalf("unexpected differences;\nwant:\n%v\ngot:\n%v", strings.Join(tt.diffs, "\n"), strings.Join(diffs, "\n"))
+			}
+		})
+	}
+}
@@ -68,12 +68,25 @@ func newFirst(prods *productionSet) *First {
 }
 
 func (fst *First) Get(prod *production, head int) (*FirstEntry, error) {
-	entry := newFirstEntry()
 	if prod.rhsLen <= head {
+		entry := newFirstEntry()
 		entry.addEmpty()
 		return entry, nil
 	}
-	for _, sym := range prod.rhs[head:] {
+	entry, err := fst.OfSequence(prod.rhs[head:])
+	if err != nil {
+		return nil, err
+	}
+	return entry, nil
+}
+
+// OfSequence returns a FIRST set of a symbol sequence. When every symbol of the sequence is nullable,
+// including the case that the sequence is empty, the FIRST set contains the empty string.
+// OfSequence returns an error when a non-terminal symbol of the sequence has no FIRST set, such as a symbol of
+// another grammar.
+func (fst *First) OfSequence(syms []Symbol) (*FirstEntry, error) {
+	entry := newFirstEntry()
+	for _, sym := range syms {
 		if sym.isTerminal() {
 			entry.add(sym)
 			return entry, nil
@@ -134,16 +147,21 @@ func genProdFirstEntry(cc *firstComContext, acc *FirstEntry, prod *production) (
 		return acc.addEmpty(), nil
 	}
 
+	// The changes made while passing through nullable symbols must be reported even when the last step changes
+	// nothing. Otherwise, the fixed-point iteration may stop before the FIRST sets converge.
+	changed := false
 	for _, rhsSym := range prod.rhs {
 		if rhsSym.isTerminal() {
-			return acc.add(rhsSym), nil
+			return acc.add(rhsSym) || changed, nil
 		}
 
 		e := cc.first.getBySymbol(rhsSym)
-		changed := acc.mergeExceptEmpty(e)
+		if acc.mergeExceptEmpty(e) {
+			changed = true
+		}
 		if !e.empty {
 			return changed, nil
 		}
 	}
-	return acc.addEmpty(), nil
+	return acc.addEmpty() || changed, nil
 }
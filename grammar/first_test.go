@@ -162,3 +162,107 @@ func testFirst(t *testing.T, actual, expected *FirstEntry) {
 		}
 	}
 }
+
+func TestFirst_OfSequence(t *testing.T) {
+	fst, gram := genActualFirst(t, "s: a b C; a: A | ; b: B | ; c: ;")
+
+	tests := []struct {
+		caption string
+		seq     []string
+		symbols []string
+		empty   bool
+	}{
+		{
+			caption: "the FIRST set of an empty sequence contains only the empty string",
+			seq:     []string{},
+			symbols: []string{},
+			empty:   true,
+		},
+		{
+			caption: "the FIRST set of a terminal symbol is the symbol itself",
+			seq:     []string{"C", "A"},
+			symbols: []string{"C"},
+		},
+		{
+			caption: "the FIRST set passes through nullable symbols",
+			seq:     []string{"a", "b", "C"},
+			symbols: []string{"A", "B", "C"},
+		},
+		{
+			caption: "when all symbols are nullable, the FIRST set contains the empty string",
+			seq:     []string{"a", "c", "b"},
+			symbols: []string{"A", "B"},
+			empty:   true,
+		},
+		{
+			caption: "the FIRST set stops at a non-nullable symbol",
+			seq:     []string{"a", "s", "b"},
+			symbols: []string{"A", "B", "C"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.caption, func(t *testing.T) {
+			var seq []Symbol
+			for _, text := range tt.seq {
+				sym, ok := gram.SymbolTable.ToSymbol(text)
+				if !ok {
+					t.Fatalf("a symbol was not found; symbol: %v", text)
+				}
+				seq = append(seq, sym)
+			}
+			actual, err := fst.OfSequence(seq)
+			if err != nil {
+				t.Fatal(err)
+			}
+			expected := genExpectedFirstEntry(t, tt.symbols, tt.empty, gram.SymbolTable)
+			testFirst(t, actual, expected)
+		})
+	}
+
+	t.Run("a non-terminal symbol without a FIRST set is an error", func(t *testing.T) {
+		fst, gram := genActualFirst(t, "s: a C; a: A;")
+		sym, ok := gram.SymbolTable.ToSymbol("a")
+		if !ok {
+			t.Fatalf("a symbol was not found; symbol: a")
+		}
+		delete(fst.set, sym)
+		entry, err := fst.OfSequence([]Symbol{sym})
+		if err == nil {
+			t.Fatalf("an error must be returned; entry: %+v", entry)
+		}
+	})
+}
+
+func TestGenProdFirstEntry_NullablePrefix(t *testing.T) {
+	gram := genTestGrammar(t, "s: a Z; a: X | ;")
+	genSym := newTestSymbolGenerator(t, gram.SymbolTable)
+	genProd := newTestProductionGenerator(t, genSym)
+
+	cc := newFirstComContext(gram.ProductionSet)
+	aEntry := cc.first.getBySymbol(genSym("a"))
+	aEntry.add(genSym("X"))
+	aEntry.addEmpty()
+	sEntry := cc.first.getBySymbol(genSym("s"))
+	sEntry.add(genSym("Z"))
+
+	// Z is in the FIRST set already, but X, which comes from the nullable symbol a, is new.
+	changed, err := genProdFirstEntry(cc, sEntry, genProd("s", "a", "Z"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !changed {
+		t.Fatal("the change made through a nullable symbol must be reported")
+	}
+}
+
+func BenchmarkGenFirst(b *testing.B) {
+	gram := genBenchmarkGrammar(b)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, err := genFirst(gram.ProductionSet)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
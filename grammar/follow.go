@@ -85,12 +85,15 @@ func newFollow(prods *productionSet) *Follow {
 	return flw
 }
 
-func (flw *Follow) Get(sym Symbol) (*FollowEntry, error) {
+// Get returns a FOLLOW set of a non-terminal symbol. When the symbol doesn't have a FOLLOW set yet,
+// Get creates an empty one.
+func (flw *Follow) Get(sym Symbol) *FollowEntry {
 	e, ok := flw.set[sym]
 	if !ok {
-		return nil, fmt.Errorf("FOLLOW set was not found; symbol: %s", sym)
+		e = newFollowEntry()
+		flw.set[sym] = e
 	}
-	return e, nil
+	return e
 }
 
 type followComContext struct {
@@ -120,10 +123,7 @@ func genFollow(prods *productionSet, first *First) (*Follow, error) {
 	for {
 		more := false
 		for ntsym := range ntsyms {
-			e, err := cc.follow.Get(ntsym)
-			if err != nil {
-				return nil, err
-			}
+			e := cc.follow.Get(ntsym)
 			if ntsym.isStart() {
 				changed := e.addEOF()
 				if changed {
@@ -144,10 +144,7 @@ func genFollow(prods *productionSet, first *First) (*Follow, error) {
 						more = true
 					}
 					if fst.empty {
-						flw, err := cc.follow.Get(prod.lhs)
-						if err != nil {
-							return nil, err
-						}
+						flw := cc.follow.Get(prod.lhs)
 						changed := e.merge(nil, flw)
 						if changed {
 							more = true
@@ -187,10 +184,7 @@ func genFollowEntry(cc *followComContext, acc *FollowEntry, ntsym Symbol) (bool,
 				isEntryChanged = true
 			}
 			if fst.empty {
-				flw, err := cc.follow.Get(prod.lhs)
-				if err != nil {
-					return false, err
-				}
+				flw := cc.follow.Get(prod.lhs)
 				changed := acc.merge(nil, flw)
 				if changed {
 					isEntryChanged = true
@@ -220,11 +214,7 @@ func PrintFollow(w io.Writer, follow *Follow, symTab *SymbolTable) {
 		if !ok {
 			nsymText = "<Symbol Not Found>"
 		}
-		e, err := follow.Get(nsym)
-		if err != nil {
-			fmt.Fprintf(w, "! %v", err)
-			continue
-		}
+		e := follow.Get(nsym)
 		fmt.Fprintf(w, "%v:", nsymText)
 		if e.eof {
 			fmt.Fprintf(w, " <eof>")
@@ -74,10 +74,7 @@ func TestFollowSet(t *testing.T) {
 					t.Fatalf("a symbol was not found; symbol: %v", ttFollow.nSym)
 				}
 
-				actualFollow, err := flw.Get(nSym)
-				if err != nil {
-					t.Fatalf("failed to get a FOLLOW set; non-terminal symbol: %v (%v), error: %v", ttFollow.nSym, nSym, err)
-				}
+				actualFollow := flw.Get(nSym)
 
 				expectedFollow := genExpectedFollowEntry(t, ttFollow.symbols, ttFollow.eof, gram.SymbolTable)
 
@@ -146,3 +143,38 @@ func testFollow(t *testing.T, actual, expected *FollowEntry) {
 		}
 	}
 }
+
+func TestFollow_GetUnknownSymbol(t *testing.T) {
+	flw, gram := genActualFollow(t, "s: FOO;")
+
+	sym, err := gram.SymbolTable.registerNonTerminalSymbol("unknown")
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := flw.Get(sym)
+	if e == nil {
+		t.Fatalf("Get returned nil")
+	}
+	if len(e.symbols) != 0 || e.eof {
+		t.Fatalf("the FOLLOW set of a symbol that doesn't appear in the productions must be empty")
+	}
+	if flw.Get(sym) != e {
+		t.Fatalf("Get must return the same entry for the same symbol")
+	}
+}
+
+func BenchmarkGenFollow(b *testing.B) {
+	gram := genBenchmarkGrammar(b)
+	fst, err := genFirst(gram.ProductionSet)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, err := genFollow(gram.ProductionSet, fst)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
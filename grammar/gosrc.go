@@ -0,0 +1,135 @@
+package grammar
+
+import (
+	"fmt"
+	"go/format"
+	"strings"
+)
+
+// GenGoSource generates a self-contained Go source file that embeds the parsing table and a shift/reduce loop.
+// The generated file exposes `Parse(tokens []int) (*Node, error)`, which takes terminal symbol numbers, and doesn't
+// depend on this package.
+func GenGoSource(gram *Grammar, tab *Table, pkgName string) ([]byte, error) {
+	t, err := genSerializedTable(gram, tab)
+	if err != nil {
+		return nil, err
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "// Code generated by 9gram. DO NOT EDIT.\n\n")
+	fmt.Fprintf(&b, "package %v\n\n", pkgName)
+	fmt.Fprintf(&b, "import \"fmt\"\n\n")
+	fmt.Fprintf(&b, "const (\n")
+	fmt.Fprintf(&b, "stateCount = %v\n", t.StateCount)
+	fmt.Fprintf(&b, "initialState = %v\n", t.InitialState)
+	fmt.Fprintf(&b, "acceptAction = %v\n", t.AcceptAction)
+	fmt.Fprintf(&b, "eofSymbol = %v\n", t.EOFSymbol)
+	fmt.Fprintf(&b, "terminalSymbolCount = %v\n", t.TerminalSymbolCount)
+	fmt.Fprintf(&b, "nonTerminalSymbolCount = %v\n", t.NonTerminalSymbolCount)
+	fmt.Fprintf(&b, ")\n\n")
+
+	writeGoIntArray(&b, "actionTable", "int32", len(t.Action), func(i int) int64 { return int64(t.Action[i]) })
+	writeGoIntArray(&b, "goToTable", "uint32", len(t.GoTo), func(i int) int64 { return int64(t.GoTo[i]) })
+	writeGoIntArray(&b, "headSymbols", "int", len(t.HeadSymbols), func(i int) int64 { return int64(t.HeadSymbols[i]) })
+	writeGoIntArray(&b, "alternativeSymbolCounts", "int", len(t.AlternativeSymbolCounts), func(i int) int64 { return int64(t.AlternativeSymbolCounts[i]) })
+
+	tsyms := make([]string, len(t.TerminalSymbols))
+	copy(tsyms, t.TerminalSymbols)
+	tsyms[t.EOFSymbol] = "<eof>"
+	writeGoStringArray(&b, "terminalSymbols", tsyms)
+	writeGoStringArray(&b, "nonTerminalSymbols", t.NonTerminalSymbols)
+
+	b.WriteString(goParserSource)
+
+	return format.Source([]byte(b.String()))
+}
+
+func writeGoIntArray(b *strings.Builder, name, ty string, n int, get func(i int) int64) {
+	fmt.Fprintf(b, "var %v = [...]%v{", name, ty)
+	for i := 0; i < n; i++ {
+		if i%20 == 0 {
+			fmt.Fprintf(b, "\n")
+		}
+		fmt.Fprintf(b, "%v, ", get(i))
+	}
+	fmt.Fprintf(b, "\n}\n\n")
+}
+
+func writeGoStringArray(b *strings.Builder, name string, strs []string) {
+	fmt.Fprintf(b, "var %v = [...]string{\n", name)
+	for _, s := range strs {
+		fmt.Fprintf(b, "%q,\n", s)
+	}
+	fmt.Fprintf(b, "}\n\n")
+}
+
+const goParserSource = `// Node is a node of a syntax tree. A leaf has a terminal symbol, and an inner node has a non-terminal symbol.
+type Node struct {
+	KindName string
+	Symbol   int
+	Terminal bool
+	Children []*Node
+}
+
+// TerminalSymbolNum returns the number of a terminal symbol that Parse accepts.
+func TerminalSymbolNum(name string) (int, bool) {
+	for num, text := range terminalSymbols {
+		if num != eofSymbol && text != "" && text == name {
+			return num, true
+		}
+	}
+	return 0, false
+}
+
+// Parse parses a sequence of terminal symbol numbers. The EOF symbol is appended implicitly.
+func Parse(tokens []int) (*Node, error) {
+	stateStack := []int{initialState}
+	nodeStack := []*Node{}
+	pos := 0
+	for {
+		tok := eofSymbol
+		if pos < len(tokens) {
+			tok = tokens[pos]
+			if tok <= eofSymbol || tok >= terminalSymbolCount {
+				return nil, fmt.Errorf("invalid terminal symbol; position: %v, symbol: %v", pos, tok)
+			}
+		}
+
+		state := stateStack[len(stateStack)-1]
+		act := actionTable[state*terminalSymbolCount+tok]
+		switch {
+		case act < 0:
+			stateStack = append(stateStack, int(-act))
+			nodeStack = append(nodeStack, &Node{
+				KindName: terminalSymbols[tok],
+				Symbol:   tok,
+				Terminal: true,
+			})
+			pos++
+		case act == acceptAction:
+			return nodeStack[len(nodeStack)-1], nil
+		case act > 0:
+			prod := int(act)
+			n := alternativeSymbolCounts[prod]
+			children := make([]*Node, n)
+			copy(children, nodeStack[len(nodeStack)-n:])
+			stateStack = stateStack[:len(stateStack)-n]
+			nodeStack = nodeStack[:len(nodeStack)-n]
+
+			lhs := headSymbols[prod]
+			next := goToTable[stateStack[len(stateStack)-1]*nonTerminalSymbolCount+lhs]
+			if next == 0 {
+				return nil, fmt.Errorf("no GOTO entry; state: %v, symbol: %v", stateStack[len(stateStack)-1], nonTerminalSymbols[lhs])
+			}
+			stateStack = append(stateStack, int(next))
+			nodeStack = append(nodeStack, &Node{
+				KindName: nonTerminalSymbols[lhs],
+				Symbol:   lhs,
+				Children: children,
+			})
+		default:
+			return nil, fmt.Errorf("unexpected terminal symbol; position: %v, symbol: %v", pos, terminalSymbols[tok])
+		}
+	}
+}
+`
@@ -0,0 +1,125 @@
+package grammar
+
+import (
+	"go/format"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+func TestGenGoSource(t *testing.T) {
+	gram, tab := genTestTable(t, "e: e ADD t | t; t: t MUL f | f; f: LPAREN e RPAREN | NUMBER;")
+
+	src, err := GenGoSource(gram, tab, "main")
+	if err != nil {
+		t.Fatal(err)
+	}
+	formatted, err := format.Source(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(formatted) != string(src) {
+		t.Fatalf("the generated source is not gofmt-clean")
+	}
+
+	goCmd, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("the go command is not available")
+	}
+
+	dir, err := ioutil.TempDir("", "9gram")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	files := map[string]string{
+		"go.mod":    "module gentest\n\ngo 1.14\n",
+		"parser.go": string(src),
+		"main.go": `package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+func printTree(n *Node, depth int) {
+	fmt.Printf("%v%v\n", strings.Repeat(" ", depth), n.KindName)
+	for _, c := range n.Children {
+		printTree(c, depth+1)
+	}
+}
+
+func main() {
+	var tokens []int
+	for _, name := range os.Args[1:] {
+		num, ok := TerminalSymbolNum(name)
+		if !ok {
+			fmt.Printf("unknown terminal symbol: %v\n", name)
+			os.Exit(1)
+		}
+		tokens = append(tokens, num)
+	}
+	tree, err := Parse(tokens)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	printTree(tree, 0)
+}
+`,
+	}
+	for name, content := range files {
+		err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		tokens []string
+		out    string
+		fail   bool
+	}{
+		{
+			tokens: []string{"NUMBER", "ADD", "NUMBER", "MUL", "NUMBER"},
+			out: `e
+ e
+  t
+   f
+    NUMBER
+ ADD
+ t
+  t
+   f
+    NUMBER
+  MUL
+  f
+   NUMBER
+`,
+		},
+		{
+			tokens: []string{"NUMBER", "ADD"},
+			out:    "unexpected terminal symbol; position: 2, symbol: <eof>\n",
+			fail:   true,
+		},
+	}
+	for _, tt := range tests {
+		cmd := exec.Command(goCmd, append([]string{"run", "."}, tt.tokens...)...)
+		cmd.Dir = dir
+		cmd.Env = append(os.Environ(), "GOFLAGS=-mod=mod")
+		out, err := cmd.Output()
+		if (err != nil) != tt.fail {
+			var stderr []byte
+			if ee, ok := err.(*exec.ExitError); ok {
+				stderr = ee.Stderr
+			}
+			t.Fatalf("unexpected result; tokens: %v, error: %v, output: %s, stderr: %s", tt.tokens, err, out, stderr)
+		}
+		if string(out) != tt.out {
+			t.Fatalf("unexpected output; tokens: %v\nwant:\n%v\ngot:\n%s", tt.tokens, tt.out, out)
+		}
+	}
+}
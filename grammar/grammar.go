@@ -1,37 +1,138 @@
 package grammar
 
 import (
-	"encoding/json"
+	"errors"
 	"fmt"
 	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
 
 	"github.com/nihei9/9gram/log"
 	"github.com/nihei9/9gram/parser"
+	"golang.org/x/sync/errgroup"
 )
 
 type Grammar struct {
-	SymbolTable          *SymbolTable
-	Patterns             map[SymbolNum]string
+	SymbolTable *SymbolTable
+
+	// Patterns maps terminal symbols to their patterns. A pattern of a lexeme production starting with `(?x)` is
+	// stored after the verbose mode normalization, so it contains neither the flag, whitespace, nor comments.
+	Patterns map[SymbolNum]string
+
+	// CaseInsensitiveTerminals holds the terminal symbols of the lexeme productions flagged with `%i`. They don't
+	// affect the parsing table and only tell a lexer to fold the case of the input.
+	CaseInsensitiveTerminals map[SymbolNum]struct{}
+
+	// TerminalModes maps the terminal symbols of the lexeme productions annotated with `%mode` to the modes of the
+	// lexer that the patterns are active in, in the order of the annotation. 9gram doesn't run a lexer, so the modes
+	// don't affect the parsing table and are only passed to a lexer generator.
+	TerminalModes map[SymbolNum][]string
+
+	// SyntheticOrigins maps the generated non-terminal symbols like `$$0` to what they expand.
+	SyntheticOrigins map[SymbolNum]*SyntheticOrigin
+
+	// Precedences holds the precedences of the terminal symbols that `%left`, `%right`, and `%nonassoc` declare.
+	Precedences          map[SymbolNum]*Precedence
 	ProductionSet        *productionSet
 	AugmentedStartSymbol Symbol
+
+	// Warnings holds problems that don't prevent generating the grammar, such as duplicate alternatives.
+	Warnings []string
+
+	// maxRHSLen is the limit of the number of symbols in the RHS of a production, which also applies to the
+	// productions that the transformations like LeftFactor make. See WithMaxRHSLen.
+	maxRHSLen int
+}
+
+// SyntheticKind is the construct that a generated non-terminal symbol expands.
+type SyntheticKind string
+
+const (
+	SyntheticKindOptional   = SyntheticKind("?")
+	SyntheticKindZeroOrMore = SyntheticKind("*")
+	SyntheticKindOneOrMore  = SyntheticKind("+")
+
+	// SyntheticKindLeftFactor is a symbol deriving the rest of the alternatives that LeftFactor factored.
+	SyntheticKindLeftFactor = SyntheticKind("left-factor")
+)
+
+// SyntheticOrigin tells what a generated non-terminal symbol expands. Symbol is the qualified symbol, such as `A`
+// of `A*`, or the LHS of the factored alternatives.
+type SyntheticOrigin struct {
+	Kind   SyntheticKind
+	Symbol Symbol
+}
+
+// ErrNoProductions means that a grammar source defines no productions except lexeme productions.
+var ErrNoProductions = errors.New("no productions defined")
+
+// DefaultMaxRHSLen is the default limit of the number of symbols in the RHS of an alternative. It is high enough for
+// hand-written grammars and guards against machine-generated ones blowing up the number of LR0 items.
+const DefaultMaxRHSLen = 4096
+
+type grammarConfig struct {
+	maxRHSLen    int
+	sourceOrder  bool
+	strictTokens bool
+}
+
+type GrammarOption func(*grammarConfig)
+
+// WithMaxRHSLen sets the limit of the number of symbols in the RHS of an alternative. The limit also applies to the
+// productions that the transformations like LeftFactor make from the grammar. The default is DefaultMaxRHSLen, and
+// a limit of 0 or less means no limit.
+func WithMaxRHSLen(n int) GrammarOption {
+	return func(c *grammarConfig) {
+		c.maxRHSLen = n
+	}
+}
+
+// WithSourceOrderNumbering numbers the productions in the order of their declarations in the source. The synthetic
+// productions that the qualifiers like `?` expand into are numbered after all the user productions. By default,
+// the synthetic productions precede the alternatives using them.
+func WithSourceOrderNumbering() GrammarOption {
+	return func(c *grammarConfig) {
+		c.sourceOrder = true
+	}
+}
+
+// WithStrictTokens makes a terminal symbol that no alternative uses an error. Such a symbol is often a typo or a
+// leftover of a dropped alternative. By default, it is reported only as unused.
+func WithStrictTokens() GrammarOption {
+	return func(c *grammarConfig) {
+		c.strictTokens = true
+	}
 }
 
-func GenGrammar(root *parser.AST) (*Grammar, error) {
+func GenGrammar(root *parser.AST, opts ...GrammarOption) (*Grammar, error) {
+	config := &grammarConfig{
+		maxRHSLen: DefaultMaxRHSLen,
+	}
+	for _, opt := range opts {
+		opt(config)
+	}
+
 	symTab := newSymbolTable()
 	sym2Pat := map[SymbolNum]string{}
 	pat2Sym := map[string]Symbol{}
 	prods := newProductionSet()
 	gram := &Grammar{
-		SymbolTable:   symTab,
-		Patterns:      sym2Pat,
-		ProductionSet: prods,
+		SymbolTable:              symTab,
+		Patterns:                 sym2Pat,
+		CaseInsensitiveTerminals: map[SymbolNum]struct{}{},
+		TerminalModes:            map[SymbolNum][]string{},
+		SyntheticOrigins:         map[SymbolNum]*SyntheticOrigin{},
+		ProductionSet:            prods,
+		maxRHSLen:                config.maxRHSLen,
 	}
 
 	defer func() {
-		log.Log("--- Symbol Table starts")
-		PrintSymbolTable(log.GetWriter(), gram.SymbolTable)
-		log.Log("--- Symbol Table ends")
-		log.Log("--- Patterns starts")
+		log.Debug("--- Symbol Table starts")
+		PrintSymbolTable(log.GetWriterAt(log.LevelDebug), gram.SymbolTable)
+		log.Debug("--- Symbol Table ends")
+		log.Debug("--- Patterns starts")
 		{
 			syms := []SymbolNum{}
 			for sym := range gram.Patterns {
@@ -42,25 +143,39 @@ func GenGrammar(root *parser.AST) (*Grammar, error) {
 			})
 			for _, sym := range syms {
 				patText := gram.Patterns[sym]
-				log.Log("%v: %v", sym, patText)
+				log.Debug("%v: %v", sym, patText)
 			}
 		}
-		log.Log("--- Patterns ends")
-		log.Log("--- Production Set starts")
-		PrintProductionSet(log.GetWriter(), gram.ProductionSet, gram.SymbolTable)
-		log.Log("--- Production Set ends")
+		log.Debug("--- Patterns ends")
+		log.Debug("--- Production Set starts")
+		PrintProductionSet(log.GetWriterAt(log.LevelDebug), gram.ProductionSet, gram.SymbolTable)
+		log.Debug("--- Production Set ends")
 	}()
 
-	// Register the augmented start symbol with the symbol table and generate its production
+	if !hasNonLexemeProduction(root) {
+		return nil, ErrNoProductions
+	}
+
+	ds, err := genDirectives(root)
+	if err != nil {
+		return nil, err
+	}
+
+	// Register the augmented start symbol with the symbol table and generate its production.
+	// The start symbol is the one that %start specifies, or the LHS of the first production.
 	for _, ast := range root.Children {
 		if ast.Ty != parser.ASTTypeProduction {
 			continue
 		}
 
-		lhsAST := ast.Children[0]
-		startText, ok := lhsAST.GetText()
-		if !ok {
-			return nil, fmt.Errorf("a node of the AST does not have a text representation; node: %#v", lhsAST)
+		startText := ds.start
+		if startText == "" {
+			lhsAST := ast.Children[0]
+			var ok bool
+			startText, ok = lhsAST.GetText()
+			if !ok {
+				return nil, fmt.Errorf("a node of the AST does not have a text representation; node: %#v", lhsAST)
+			}
 		}
 		augmentedStartText := fmt.Sprintf("%s'", startText)
 		augmentedStartSym, err := symTab.registerStartSymbol(augmentedStartText)
@@ -71,7 +186,7 @@ func GenGrammar(root *parser.AST) (*Grammar, error) {
 		if err != nil {
 			return nil, err
 		}
-		prod, err := newProduction(augmentedStartSym, []Symbol{startSym})
+		prod, err := newProduction(augmentedStartSym, []Symbol{startSym}, config.maxRHSLen)
 		if err != nil {
 			return nil, err
 		}
@@ -83,12 +198,28 @@ func GenGrammar(root *parser.AST) (*Grammar, error) {
 	}
 
 	// Register all non-terminal symbols with symbol table
+	lhsDefs := map[string]*parser.AST{}
 	for _, ast := range root.Children {
 		if ast.Ty != parser.ASTTypeProduction {
 			continue
 		}
 		lhsAST := ast.Children[0]
 		lhsText, _ := lhsAST.GetText()
+		if err := checkUserSymbolText(lhsText); err != nil {
+			return nil, err
+		}
+		if prev, ok := lhsDefs[lhsText]; ok && isLexemeProduction(prev) != isLexemeProduction(ast) {
+			lexAST, prodAST := prev, ast
+			if !isLexemeProduction(prev) {
+				lexAST, prodAST = ast, prev
+			}
+			lexPos, _ := lexAST.Children[0].Pos()
+			prodPos, _ := prodAST.Children[0].Pos()
+			return nil, fmt.Errorf("a symbol is defined as both a lexeme and a non-terminal symbol; symbol: %v, lexeme: (%v, %v), production: (%v, %v)",
+				lhsText, lexPos.Line, lexPos.Column, prodPos.Line, prodPos.Column)
+		} else if !ok {
+			lhsDefs[lhsText] = ast
+		}
 		if isLexemeProduction(ast) {
 			_, err := symTab.registerTerminalSymbol(lhsText)
 			if err != nil {
@@ -102,68 +233,664 @@ func GenGrammar(root *parser.AST) (*Grammar, error) {
 		}
 	}
 
-	// Generate productions
+	// Register the declared terminal symbols and the aliases, and then make sure that the productions use no other
+	// terminal symbols.
+	for _, text := range ds.tokens {
+		if sym, ok := symTab.ToSymbol(text); ok && sym.isNonTerminal() {
+			return nil, fmt.Errorf("%%token declares a non-terminal symbol; symbol: %v", text)
+		}
+		_, err := symTab.registerTerminalSymbol(text)
+		if err != nil {
+			return nil, err
+		}
+	}
+	err = registerAliases(ds.aliases, symTab, ds.declaresTokens)
+	if err != nil {
+		return nil, err
+	}
+	if ds.declaresTokens {
+		err := checkUndeclaredSymbols(root, symTab)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Register all lexemes before generating productions so that an inline literal in an alternative resolves to
+	// the terminal symbol of the lexeme having the same pattern even if the lexeme is defined after the alternative.
+	lexemePos := map[Symbol]parser.Position{}
+	for _, ast := range root.Children {
+		if ast.Ty != parser.ASTTypeProduction || !isLexemeProduction(ast) {
+			continue
+		}
+		err := registerLexemes(ast, symTab, sym2Pat, pat2Sym, gram.CaseInsensitiveTerminals, gram.TerminalModes, lexemePos)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	patNum := 0
+	precs, err := genPrecedenceDecls(root, symTab, sym2Pat, pat2Sym, &patNum)
+	if err != nil {
+		return nil, err
+	}
+	gram.Precedences = precs.terminals
+
+	// Generate productions. The productions that the qualifiers expand into are synthetic. They are numbered
+	// together with the user productions, or after all of them in the source order mode.
 	prodNum := 0
+	synthProds := prods
+	if config.sourceOrder {
+		synthProds = newProductionSet()
+	}
 	for _, ast := range root.Children {
-		if ast.Ty != parser.ASTTypeProduction {
+		if ast.Ty != parser.ASTTypeProduction || isLexemeProduction(ast) {
 			continue
 		}
-		if isLexemeProduction(ast) {
-			registerLexemes(ast, symTab, sym2Pat, pat2Sym)
-		} else {
-			err := registerProds(ast, prods, symTab, sym2Pat, pat2Sym, &patNum, &prodNum)
+		err := registerProds(ast, prods, synthProds, symTab, sym2Pat, pat2Sym, gram.SyntheticOrigins, precs, &patNum, &prodNum, config.maxRHSLen, &gram.Warnings)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if synthProds != prods {
+		for _, prod := range synthProds.getAllSorted() {
+			prods.append(prod)
+		}
+	}
+	precs.resolveNames(symTab)
+
+	err = checkAugmentedStartReferences(prods, symTab)
+	if err != nil {
+		return nil, err
+	}
+	err = checkUndefinedNonTerminals(prods, symTab)
+	if err != nil {
+		return nil, err
+	}
+	err = checkUnitCycles(gram)
+	if err != nil {
+		return nil, err
+	}
+	if config.strictTokens {
+		err := checkUnusedTerminals(gram)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if !ds.declaresTokens {
+		for _, msg := range findSuspiciousTerminals(prods, symTab, sym2Pat) {
+			log.Warn("%v", msg)
+			gram.Warnings = append(gram.Warnings, msg)
+		}
+	}
+
+	return gram, nil
+}
+
+// checkUndefinedNonTerminals returns an error listing the non-terminal symbols that appear in the RHS of
+// productions but have no productions, together with the productions using them. Such a symbol makes the automaton
+// go to a state that can never reduce.
+func checkUndefinedNonTerminals(prods *productionSet, symTab *SymbolTable) error {
+	var undefined []string
+	reported := map[Symbol]struct{}{}
+	for _, prod := range prods.getAllSorted() {
+		for _, sym := range prod.rhs {
+			if !sym.isNonTerminal() {
+				continue
+			}
+			if _, ok := prods.findByLHS(sym); ok {
+				continue
+			}
+			if _, ok := reported[sym]; ok {
+				continue
+			}
+			reported[sym] = struct{}{}
+			text, _ := symTab.ToText(sym)
+			undefined = append(undefined, fmt.Sprintf("%v (used in `%v`)", text, productionText(prod, symTab)))
+		}
+	}
+	if len(undefined) > 0 {
+		return fmt.Errorf("non-terminal symbols have no productions; symbols: %v", strings.Join(undefined, ", "))
+	}
+	return nil
+}
+
+// checkUnusedTerminals returns an error listing the terminal symbols that no alternative uses.
+func checkUnusedTerminals(gram *Grammar) error {
+	nums := findUnusedTerminalSymbols(gram)
+	if len(nums) == 0 {
+		return nil
+	}
+	var texts []string
+	for _, num := range nums {
+		text, _ := gram.SymbolTable.ToTextFromNumT(num)
+		texts = append(texts, text)
+	}
+	return fmt.Errorf("unused terminal symbols; symbols: %v", strings.Join(texts, ", "))
+}
+
+// checkUnitCycles returns an error listing the cycles of unit productions like `a: b; b: a;`. The symbols of such
+// a cycle derive each other without consuming any input.
+func checkUnitCycles(gram *Grammar) error {
+	cycles := FindUnitCycles(gram)
+	if len(cycles) == 0 {
+		return nil
+	}
+	var texts []string
+	for _, cycle := range cycles {
+		var b strings.Builder
+		for _, sym := range cycle {
+			text, _ := gram.SymbolTable.ToText(sym)
+			fmt.Fprintf(&b, "%v → ", text)
+		}
+		text, _ := gram.SymbolTable.ToText(cycle[0])
+		fmt.Fprintf(&b, "%v", text)
+		texts = append(texts, b.String())
+	}
+	return fmt.Errorf("unit productions form cycles; cycles: %v", strings.Join(texts, ", "))
+}
+
+// findSuspiciousTerminals returns warnings about terminal symbols that have no patterns and are named in lower
+// case. Because a symbol that no production defines becomes a terminal symbol, such a symbol is likely a misspelled
+// or undefined non-terminal symbol. Each symbol is reported once with the first production using it.
+func findSuspiciousTerminals(prods *productionSet, symTab *SymbolTable, sym2Pat map[SymbolNum]string) []string {
+	var warnings []string
+	reported := map[Symbol]struct{}{}
+	for _, prod := range prods.getAllSorted() {
+		for _, sym := range prod.rhs {
+			if !sym.isTerminal() {
+				continue
+			}
+			if _, ok := sym2Pat[sym.Num()]; ok {
+				continue
+			}
+			if _, ok := reported[sym]; ok {
+				continue
+			}
+			text, _ := symTab.ToText(sym)
+			c, _ := utf8.DecodeRuneInString(text)
+			if !unicode.IsLower(c) {
+				continue
+			}
+			reported[sym] = struct{}{}
+			warnings = append(warnings, fmt.Sprintf("a terminal symbol without a pattern looks like an undefined non-terminal symbol; symbol: %v, production: %v", text, productionText(prod, symTab)))
+		}
+	}
+	return warnings
+}
+
+type directives struct {
+	// start is the symbol that %start specifies. It is empty when the source has no %start.
+	start string
+
+	// tokens are the terminal symbols that %token declares in the order of their declarations. When the source has
+	// %token, every terminal symbol must be declared by %token or defined by a lexeme production.
+	tokens         []string
+	declaresTokens bool
+
+	// aliases are the arguments of %alias directives. The first symbol of each is the canonical name of a terminal
+	// symbol, and the rest are its aliases.
+	aliases [][]string
+}
+
+func genDirectives(root *parser.AST) (*directives, error) {
+	ds := &directives{}
+	for _, ast := range root.Children {
+		if ast.Ty != parser.ASTTypeDirective {
+			continue
+		}
+		name, _ := ast.GetText()
+		switch name {
+		case "start":
+			if ds.start != "" {
+				return nil, fmt.Errorf("%%start appears more than once")
+			}
+			if len(ast.Children) != 1 {
+				return nil, fmt.Errorf("%%start takes exactly one symbol")
+			}
+			text, ok := directiveSymbolText(ast.Children[0])
+			if !ok {
+				return nil, fmt.Errorf("%%start takes exactly one symbol")
+			}
+			ds.start = text
+		case "token":
+			ds.declaresTokens = true
+			for _, arg := range ast.Children {
+				text, ok := directiveSymbolText(arg)
+				if !ok {
+					return nil, fmt.Errorf("%%token takes only symbols")
+				}
+				if err := checkUserSymbolText(text); err != nil {
+					return nil, err
+				}
+				ds.tokens = append(ds.tokens, text)
+			}
+		case "alias":
+			var names []string
+			for _, arg := range ast.Children {
+				text, ok := directiveSymbolText(arg)
+				if !ok {
+					return nil, fmt.Errorf("%%alias takes only symbols")
+				}
+				if err := checkUserSymbolText(text); err != nil {
+					return nil, err
+				}
+				names = append(names, text)
+			}
+			if len(names) < 2 {
+				return nil, fmt.Errorf("%%alias takes a terminal symbol followed by its aliases")
+			}
+			ds.aliases = append(ds.aliases, names)
+		case "left", "right", "nonassoc":
+			// genPrecedenceDecls resolves them after the lexemes are registered.
+		case "include":
+			return nil, fmt.Errorf("%%include must be resolved with parser.ResolveIncludes before generating a grammar")
+		default:
+			return nil, fmt.Errorf("unknown directive; directive: %%%v", name)
+		}
+	}
+
+	if ds.start != "" {
+		defined := false
+		for _, ast := range root.Children {
+			if ast.Ty != parser.ASTTypeProduction || isLexemeProduction(ast) {
+				continue
+			}
+			if lhsText, _ := ast.Children[0].GetText(); lhsText == ds.start {
+				defined = true
+				break
+			}
+		}
+		if !defined {
+			return nil, fmt.Errorf("%%start names an undefined non-terminal symbol; symbol: %v", ds.start)
+		}
+	}
+
+	return ds, nil
+}
+
+// directiveSymbolText returns the text of a directive argument naming a symbol. The parser reads digits like `2` as a
+// number in the arguments of directives, but a number without a sign names a symbol as well as in alternatives.
+func directiveSymbolText(arg *parser.AST) (string, bool) {
+	text, _ := arg.GetText()
+	switch arg.Ty {
+	case parser.ASTTypeSymbol:
+		return text, true
+	case parser.ASTTypeNumber:
+		return text, !strings.HasPrefix(text, "-")
+	}
+	return "", false
+}
+
+// registerAliases registers the aliases of terminal symbols. An alias must not be the name of another symbol. When
+// the source has %token, the canonical names must be declared as well as the other terminal symbols.
+func registerAliases(aliases [][]string, symTab *SymbolTable, declaresTokens bool) error {
+	for _, names := range aliases {
+		sym, ok := symTab.ToSymbol(names[0])
+		if ok && !sym.isTerminal() {
+			return fmt.Errorf("%%alias takes only terminal symbols; symbol: %v", names[0])
+		}
+		if !ok {
+			if declaresTokens {
+				return fmt.Errorf("%%alias names an undeclared terminal symbol; symbol: %v", names[0])
+			}
+			var err error
+			sym, err = symTab.registerTerminalSymbol(names[0])
 			if err != nil {
-				return nil, err
+				return err
+			}
+		}
+		for _, alias := range names[1:] {
+			err := symTab.registerAlias(alias, sym)
+			if err != nil {
+				return err
 			}
 		}
 	}
+	return nil
+}
 
-	return gram, nil
+// checkUndeclaredSymbols returns an error listing the symbols that appear in the alternatives but are neither
+// registered non-terminal symbols nor registered terminal symbols.
+func checkUndeclaredSymbols(root *parser.AST, symTab *SymbolTable) error {
+	var undeclared []string
+	known := map[string]struct{}{}
+	for _, alt := range parser.Find(root, parser.ASTTypeAlternative) {
+		for _, elem := range alt.Children {
+			if elem.Ty != parser.ASTTypeSymbol {
+				continue
+			}
+			text, _ := elem.GetText()
+			if _, ok := symTab.ToSymbol(text); ok {
+				continue
+			}
+			if _, ok := known[text]; ok {
+				continue
+			}
+			known[text] = struct{}{}
+			undeclared = append(undeclared, text)
+		}
+	}
+	if len(undeclared) > 0 {
+		return fmt.Errorf("undeclared terminal symbols; declare them with %%token or fix the typos; symbols: %v", strings.Join(undeclared, ", "))
+	}
+	return nil
+}
+
+// Clone returns a deep copy of the grammar. Transformations can modify the copy without affecting the original.
+func (g *Grammar) Clone() *Grammar {
+	patterns := make(map[SymbolNum]string, len(g.Patterns))
+	for sym, pat := range g.Patterns {
+		patterns[sym] = pat
+	}
+	var caseInsensitive map[SymbolNum]struct{}
+	if g.CaseInsensitiveTerminals != nil {
+		caseInsensitive = make(map[SymbolNum]struct{}, len(g.CaseInsensitiveTerminals))
+		for sym := range g.CaseInsensitiveTerminals {
+			caseInsensitive[sym] = struct{}{}
+		}
+	}
+	var modes map[SymbolNum][]string
+	if g.TerminalModes != nil {
+		modes = make(map[SymbolNum][]string, len(g.TerminalModes))
+		for sym, m := range g.TerminalModes {
+			modes[sym] = append([]string(nil), m...)
+		}
+	}
+	var origins map[SymbolNum]*SyntheticOrigin
+	if g.SyntheticOrigins != nil {
+		origins = make(map[SymbolNum]*SyntheticOrigin, len(g.SyntheticOrigins))
+		for sym, origin := range g.SyntheticOrigins {
+			o := *origin
+			origins[sym] = &o
+		}
+	}
+	var precs map[SymbolNum]*Precedence
+	if g.Precedences != nil {
+		precs = make(map[SymbolNum]*Precedence, len(g.Precedences))
+		for sym, prec := range g.Precedences {
+			precs[sym] = prec
+		}
+	}
+	var warnings []string
+	if g.Warnings != nil {
+		warnings = make([]string, len(g.Warnings))
+		copy(warnings, g.Warnings)
+	}
+	return &Grammar{
+		SymbolTable:              g.SymbolTable.clone(),
+		Patterns:                 patterns,
+		CaseInsensitiveTerminals: caseInsensitive,
+		TerminalModes:            modes,
+		SyntheticOrigins:         origins,
+		Precedences:              precs,
+		ProductionSet:            g.ProductionSet.clone(),
+		AugmentedStartSymbol:     g.AugmentedStartSymbol,
+		Warnings:                 warnings,
+		maxRHSLen:                g.maxRHSLen,
+	}
+}
+
+// NumOfProductions returns the number of productions except the production of the augmented start symbol.
+func (g *Grammar) NumOfProductions() int {
+	return len(g.ProductionSet.getAll()) - 1
+}
+
+// Productions returns the views of all productions in ascending order of their numbers. The first one is the
+// production of the augmented start symbol, whose number is ProductionNumStart.
+func (g *Grammar) Productions() []ProductionView {
+	prods := g.ProductionSet.getAllSorted()
+	views := make([]ProductionView, len(prods))
+	for i, prod := range prods {
+		views[i] = newProductionView(prod, g.SymbolTable)
+	}
+	return views
+}
+
+// NumOfTerminalSymbols returns the number of terminal symbols except the EOF symbol.
+func (g *Grammar) NumOfTerminalSymbols() int {
+	n := g.SymbolTable.getNumOfTerminalSymbols()
+	if n == 0 {
+		return 0
+	}
+	return n - terminalSymbolNumMin.Int()
+}
+
+// NumOfNonTerminalSymbols returns the number of non-terminal symbols except the augmented start symbol.
+func (g *Grammar) NumOfNonTerminalSymbols() int {
+	n := g.SymbolTable.getNumOfNonTerminalSymbols()
+	if n == 0 {
+		return 0
+	}
+	return n - nonTerminalSymbolNumMin.Int() - 1
+}
+
+// checkUserSymbolText returns an error when a symbol the user wrote begins with `$` or ends with `'`. The prefix is
+// reserved for the symbols that registerAlternative generates, such as `$0` for a pattern and `$$0` for a qualifier,
+// and the suffix is reserved for the augmented start symbol. The lexer rejects both in a source already, so this is a
+// safety net that keeps the reservation from depending on the lexer.
+func checkUserSymbolText(text string) error {
+	if strings.HasPrefix(text, "$") {
+		return fmt.Errorf("the $ prefix is reserved for generated symbols; symbol: %v", text)
+	}
+	if strings.HasSuffix(text, "'") {
+		return fmt.Errorf("the ' suffix is reserved for the augmented start symbol; symbol: %v", text)
+	}
+	return nil
+}
+
+// checkAugmentedStartReferences returns an error when the RHS of a production references the augmented start
+// symbol. The symbol must appear only in the LHS of the start production so that reducing by it means accepting. A
+// source can't name the symbol because the lexer rejects the ' suffix, so this guards the invariant against the code
+// that builds productions.
+func checkAugmentedStartReferences(prods *productionSet, symTab *SymbolTable) error {
+	for _, prod := range prods.getAllSorted() {
+		for _, sym := range prod.rhs {
+			if sym.isStart() {
+				return fmt.Errorf("the augmented start symbol must not appear in the RHS of a production; production: %v", productionText(prod, symTab))
+			}
+		}
+	}
+	return nil
+}
+
+func hasNonLexemeProduction(root *parser.AST) bool {
+	for _, ast := range root.Children {
+		if ast.Ty == parser.ASTTypeProduction && !isLexemeProduction(ast) {
+			return true
+		}
+	}
+	return false
 }
 
 func isLexemeProduction(prodAST *parser.AST) bool {
 	if prodAST.Ty != parser.ASTTypeProduction {
 		return false
 	}
-	if len(prodAST.Children) == 2 && len(prodAST.Children[1].Children) == 1 && prodAST.Children[1].Children[0].Ty == parser.ASTTypePattern {
-		return true
+	if len(prodAST.Children) != 2 {
+		return false
 	}
-	return false
+	// The parser keeps the order of a pattern, `%i`, and `%mode`.
+	elems := prodAST.Children[1].Children
+	if len(elems) == 0 || elems[0].Ty != parser.ASTTypePattern {
+		return false
+	}
+	for _, elem := range elems[1:] {
+		if elem.Ty != parser.ASTTypeCaseInsensitive && elem.Ty != parser.ASTTypeMode {
+			return false
+		}
+	}
+	return true
 }
 
-func registerLexemes(ast *parser.AST, symTab *SymbolTable, sym2Pat map[SymbolNum]string, pat2Sym map[string]Symbol) {
+// isCaseInsensitiveLexeme reports whether a lexeme production is flagged with `%i`.
+func isCaseInsensitiveLexeme(prodAST *parser.AST) bool {
+	return len(parser.Find(prodAST.Children[1], parser.ASTTypeCaseInsensitive)) > 0
+}
+
+// lexemeModes returns the modes that `%mode` annotates a lexeme production with, or nil.
+func lexemeModes(prodAST *parser.AST) ([]string, error) {
+	modeASTs := parser.Find(prodAST.Children[1], parser.ASTTypeMode)
+	if len(modeASTs) == 0 {
+		return nil, nil
+	}
+	var modes []string
+	seen := map[string]struct{}{}
+	for _, modeAST := range modeASTs[0].Children {
+		mode, _ := modeAST.GetText()
+		if _, ok := seen[mode]; ok {
+			lhsText, _ := prodAST.Children[0].GetText()
+			pos, _ := modeAST.Pos()
+			return nil, fmt.Errorf("a mode appears more than once; symbol: %v, mode: %v, position: (%v, %v)", lhsText, mode, pos.Line, pos.Column)
+		}
+		seen[mode] = struct{}{}
+		modes = append(modes, mode)
+	}
+	return modes, nil
+}
+
+// registerLexemes registers the pattern of a lexeme production. lexemePos holds the positions of the lexeme
+// productions registered already, and a lexeme redefined with a different pattern, case sensitivity, or modes is an
+// error.
+func registerLexemes(ast *parser.AST, symTab *SymbolTable, sym2Pat map[SymbolNum]string, pat2Sym map[string]Symbol, caseInsensitive map[SymbolNum]struct{}, termModes map[SymbolNum][]string, lexemePos map[Symbol]parser.Position) error {
 	lhsAST := ast.Children[0]
 	lhsText, _ := lhsAST.GetText()
 	lhsSym, _ := symTab.ToSymbol(lhsText)
 	patText, _ := ast.Children[1].Children[0].GetText()
+	patText = normalizePattern(patText)
+	if patText == "" {
+		return fmt.Errorf("a pattern is empty after the verbose mode normalization; symbol: %v", lhsText)
+	}
+	pos, _ := lhsAST.Pos()
+	modes, err := lexemeModes(ast)
+	if err != nil {
+		return err
+	}
+	if prevPos, ok := lexemePos[lhsSym]; ok {
+		if prevPat := sym2Pat[lhsSym.Num()]; prevPat != patText {
+			return fmt.Errorf("a lexeme is redefined with a different pattern; symbol: %v, first: %q (%v, %v), second: %q (%v, %v)",
+				lhsText, prevPat, prevPos.Line, prevPos.Column, patText, pos.Line, pos.Column)
+		}
+		if _, prevFolds := caseInsensitive[lhsSym.Num()]; prevFolds != isCaseInsensitiveLexeme(ast) {
+			return fmt.Errorf("a lexeme is redefined with a different case sensitivity; symbol: %v, first: (%v, %v), second: (%v, %v)",
+				lhsText, prevPos.Line, prevPos.Column, pos.Line, pos.Column)
+		}
+		if strings.Join(termModes[lhsSym.Num()], ",") != strings.Join(modes, ",") {
+			return fmt.Errorf("a lexeme is redefined with different modes; symbol: %v, first: (%v, %v), second: (%v, %v)",
+				lhsText, prevPos.Line, prevPos.Column, pos.Line, pos.Column)
+		}
+		return nil
+	}
+	lexemePos[lhsSym] = pos
 	pat2Sym[patText] = lhsSym
 	sym2Pat[lhsSym.Num()] = patText
+	if isCaseInsensitiveLexeme(ast) {
+		caseInsensitive[lhsSym.Num()] = struct{}{}
+	}
+	if modes != nil {
+		termModes[lhsSym.Num()] = modes
+	}
+	return nil
 }
 
-func registerProds(ast *parser.AST, prods *productionSet, symTab *SymbolTable, sym2Pat map[SymbolNum]string, pat2Sym map[string]Symbol, patNum *int, prodNum *int) error {
+// findOutOfRangeRef returns the first `$N` in the code of an action where N exceeds rhsLen. `$$` isn't a reference.
+// The code is opaque, so a `$N` in a comment or a string literal of the code is checked as well.
+func findOutOfRangeRef(code string, rhsLen int) (string, bool) {
+	for i := 0; i < len(code); i++ {
+		if code[i] != '$' {
+			continue
+		}
+		if i+1 < len(code) && code[i+1] == '$' {
+			i++
+			continue
+		}
+		j := i + 1
+		for j < len(code) && code[j] >= '0' && code[j] <= '9' {
+			j++
+		}
+		if j == i+1 {
+			continue
+		}
+		digits := code[i+1 : j]
+		if n, err := strconv.Atoi(digits); err != nil || n > rhsLen {
+			return digits, true
+		}
+		i = j - 1
+	}
+	return "", false
+}
+
+func registerProds(ast *parser.AST, prods *productionSet, synthProds *productionSet, symTab *SymbolTable, sym2Pat map[SymbolNum]string, pat2Sym map[string]Symbol, origins map[SymbolNum]*SyntheticOrigin, precs *precedenceDecls, patNum *int, prodNum *int, maxRHSLen int, warnings *[]string) error {
 	lhsAST := ast.Children[0]
 	lhsText, _ := lhsAST.GetText()
 	lhsSym, _ := symTab.ToSymbol(lhsText)
 	for _, altAST := range ast.Children[1:] {
-		err := registerAlternative(altAST, prods, lhsSym, symTab, sym2Pat, pat2Sym, patNum, prodNum)
+		prod, added, err := registerAlternative(altAST, prods, synthProds, lhsSym, symTab, sym2Pat, pat2Sym, origins, precs, patNum, prodNum, maxRHSLen)
 		if err != nil {
 			return err
 		}
+		if !added {
+			msg := fmt.Sprintf("duplicate alternative: %v", productionText(prod, symTab))
+			log.Warn("%v", msg)
+			*warnings = append(*warnings, msg)
+
+			// The first alternative is kept, so the label and the action of the duplicate are lost.
+			kept, ok := prods.findByID(prod.id)
+			if ok && prod.label != "" && prod.label != kept.label {
+				msg := fmt.Sprintf("the label of a duplicate alternative is dropped: %v #%v", productionText(prod, symTab), prod.label)
+				log.Warn("%v", msg)
+				*warnings = append(*warnings, msg)
+			}
+			if ok && prod.action != "" && prod.action != kept.action {
+				msg := fmt.Sprintf("the action of a duplicate alternative is dropped: %v {%v}", productionText(prod, symTab), prod.action)
+				log.Warn("%v", msg)
+				*warnings = append(*warnings, msg)
+			}
+		}
 	}
 	return nil
 }
 
-func registerAlternative(altAST *parser.AST, prods *productionSet, lhsSym Symbol, symTab *SymbolTable, sym2Pat map[SymbolNum]string, pat2Sym map[string]Symbol, patNum *int, prodNum *int) error {
+func registerAlternative(altAST *parser.AST, prods *productionSet, synthProds *productionSet, lhsSym Symbol, symTab *SymbolTable, sym2Pat map[SymbolNum]string, pat2Sym map[string]Symbol, origins map[SymbolNum]*SyntheticOrigin, precs *precedenceDecls, patNum *int, prodNum *int, maxRHSLen int) (*production, bool, error) {
+	elems := altAST.Children
+	var actionAST *parser.AST
+	if len(elems) > 0 && elems[len(elems)-1].Ty == parser.ASTTypeAction {
+		actionAST = elems[len(elems)-1]
+		elems = elems[:len(elems)-1]
+	}
+	label := ""
+	if len(elems) > 0 && elems[len(elems)-1].Ty == parser.ASTTypeLabel {
+		label, _ = elems[len(elems)-1].GetText()
+		elems = elems[:len(elems)-1]
+	}
+	var precAST *parser.AST
+	if len(elems) > 0 && elems[len(elems)-1].Ty == parser.ASTTypePrec {
+		precAST = elems[len(elems)-1]
+		elems = elems[:len(elems)-1]
+	}
+	// `%empty` denotes the empty RHS. The parser guarantees that it is the only element.
+	if len(elems) == 1 && elems[0].Ty == parser.ASTTypeEmpty {
+		elems = nil
+	}
+	if len(elems) > 0 && elems[len(elems)-1].Ty == parser.ASTTypeMode {
+		lhsText, _ := symTab.ToText(lhsSym)
+		pos, _ := elems[len(elems)-1].Pos()
+		return nil, false, fmt.Errorf("%%mode is allowed only in lexeme productions; symbol: %v, position: (%v, %v)", lhsText, pos.Line, pos.Column)
+	}
+	if len(elems) > 0 && elems[len(elems)-1].Ty == parser.ASTTypeCaseInsensitive {
+		lhsText, _ := symTab.ToText(lhsSym)
+		pos, _ := elems[len(elems)-1].Pos()
+		return nil, false, fmt.Errorf("%%i is allowed only in lexeme productions; symbol: %v, position: (%v, %v)", lhsText, pos.Line, pos.Column)
+	}
+
 	var rhsSyms []Symbol
 	i := 0
-	for i < len(altAST.Children) {
+	for i < len(elems) {
 		var rhsSym Symbol
-		elemAST := altAST.Children[i]
+		elemAST := elems[i]
 		if elemAST.Ty == parser.ASTTypePattern {
 			patText, ok := elemAST.GetText()
 			if !ok {
-				return fmt.Errorf("text representation of pattern string is not found")
+				return nil, false, fmt.Errorf("text representation of pattern string is not found")
 			}
 			sym, ok := pat2Sym[patText]
 			if !ok {
@@ -172,7 +899,7 @@ func registerAlternative(altAST *parser.AST, prods *productionSet, lhsSym Symbol
 				var err error
 				sym, err = symTab.registerTerminalSymbol(symText)
 				if err != nil {
-					return err
+					return nil, false, err
 				}
 				pat2Sym[patText] = sym
 				sym2Pat[sym.Num()] = patText
@@ -180,22 +907,25 @@ func registerAlternative(altAST *parser.AST, prods *productionSet, lhsSym Symbol
 			rhsSym = sym
 		} else if elemAST.Ty == parser.ASTTypeSymbol {
 			symText, _ := elemAST.GetText()
+			if err := checkUserSymbolText(symText); err != nil {
+				return nil, false, err
+			}
 			sym, err := symTab.registerTerminalSymbol(symText)
 			if err != nil {
-				return err
+				return nil, false, err
 			}
 			rhsSym = sym
 		} else {
-			return fmt.Errorf("invalid symbol sequence")
+			return nil, false, fmt.Errorf("invalid symbol sequence")
 		}
 		i++
 
-		if i >= len(altAST.Children) {
+		if i >= len(elems) {
 			rhsSyms = append(rhsSyms, rhsSym)
 			break
 		}
 
-		switch altAST.Children[i].Ty {
+		switch elems[i].Ty {
 		case parser.ASTTypeOptional:
 			optSym := rhsSym
 
@@ -203,18 +933,24 @@ func registerAlternative(altAST *parser.AST, prods *productionSet, lhsSym Symbol
 			*prodNum = *prodNum + 1
 			lhsSym, err := symTab.registerNonTerminalSymbol(lhsText)
 			if err != nil {
-				return err
+				return nil, false, err
 			}
-			optProd1, err := newProduction(lhsSym, []Symbol{optSym})
+			origins[lhsSym.Num()] = &SyntheticOrigin{
+				Kind:   SyntheticKindOptional,
+				Symbol: optSym,
+			}
+			optProd1, err := newProduction(lhsSym, []Symbol{optSym}, maxRHSLen)
 			if err != nil {
-				return err
+				return nil, false, err
 			}
-			optProd2, err := newProduction(lhsSym, []Symbol{})
+			optProd2, err := newProduction(lhsSym, []Symbol{}, maxRHSLen)
 			if err != nil {
-				return err
+				return nil, false, err
 			}
-			prods.append(optProd1)
-			prods.append(optProd2)
+			optProd1.synthetic = true
+			synthProds.append(optProd1)
+			optProd2.synthetic = true
+			synthProds.append(optProd2)
 
 			rhsSym = lhsSym
 			i++
@@ -225,18 +961,24 @@ func registerAlternative(altAST *parser.AST, prods *productionSet, lhsSym Symbol
 			*prodNum = *prodNum + 1
 			lhsSym, err := symTab.registerNonTerminalSymbol(lhsText)
 			if err != nil {
-				return err
+				return nil, false, err
+			}
+			origins[lhsSym.Num()] = &SyntheticOrigin{
+				Kind:   SyntheticKindZeroOrMore,
+				Symbol: repeatSym,
 			}
-			repeatProd1, err := newProduction(lhsSym, []Symbol{repeatSym, lhsSym})
+			repeatProd1, err := newProduction(lhsSym, []Symbol{repeatSym, lhsSym}, maxRHSLen)
 			if err != nil {
-				return err
+				return nil, false, err
 			}
-			repeatProd2, err := newProduction(lhsSym, []Symbol{})
+			repeatProd2, err := newProduction(lhsSym, []Symbol{}, maxRHSLen)
 			if err != nil {
-				return err
+				return nil, false, err
 			}
-			prods.append(repeatProd1)
-			prods.append(repeatProd2)
+			repeatProd1.synthetic = true
+			synthProds.append(repeatProd1)
+			repeatProd2.synthetic = true
+			synthProds.append(repeatProd2)
 
 			rhsSym = lhsSym
 			i++
@@ -247,18 +989,24 @@ func registerAlternative(altAST *parser.AST, prods *productionSet, lhsSym Symbol
 			*prodNum = *prodNum + 1
 			lhsSym, err := symTab.registerNonTerminalSymbol(lhsText)
 			if err != nil {
-				return err
+				return nil, false, err
 			}
-			repeatProd1, err := newProduction(lhsSym, []Symbol{repeatSym, lhsSym})
+			origins[lhsSym.Num()] = &SyntheticOrigin{
+				Kind:   SyntheticKindOneOrMore,
+				Symbol: repeatSym,
+			}
+			repeatProd1, err := newProduction(lhsSym, []Symbol{repeatSym, lhsSym}, maxRHSLen)
 			if err != nil {
-				return err
+				return nil, false, err
 			}
-			repeatProd2, err := newProduction(lhsSym, []Symbol{repeatSym})
+			repeatProd2, err := newProduction(lhsSym, []Symbol{repeatSym}, maxRHSLen)
 			if err != nil {
-				return err
+				return nil, false, err
 			}
-			prods.append(repeatProd1)
-			prods.append(repeatProd2)
+			repeatProd1.synthetic = true
+			synthProds.append(repeatProd1)
+			repeatProd2.synthetic = true
+			synthProds.append(repeatProd2)
 
 			rhsSym = lhsSym
 			i++
@@ -266,13 +1014,36 @@ func registerAlternative(altAST *parser.AST, prods *productionSet, lhsSym Symbol
 
 		rhsSyms = append(rhsSyms, rhsSym)
 	}
-	prod, err := newProduction(lhsSym, rhsSyms)
+	prod, err := newProduction(lhsSym, rhsSyms, maxRHSLen)
 	if err != nil {
-		return err
+		var lenErr *rhsLenError
+		if errors.As(err, &lenErr) {
+			lhsText, _ := symTab.ToText(lhsSym)
+			pos, _ := altAST.Pos()
+			return nil, false, fmt.Errorf("an alternative has too many symbols; symbol: %v, position: (%v, %v), length: %v, limit: %v", lhsText, pos.Line, pos.Column, lenErr.length, lenErr.limit)
+		}
+		return nil, false, err
 	}
-	prods.append(prod)
+	prod.label = label
+	if precAST != nil {
+		prod.prec, err = precs.lookUp(precAST, symTab, pat2Sym)
+		if err != nil {
+			return nil, false, err
+		}
+	} else {
+		prod.prec = precs.defaultPrecedence(rhsSyms, symTab)
+	}
+	if actionAST != nil {
+		prod.action, _ = actionAST.GetText()
+		if index, ok := findOutOfRangeRef(prod.action, prod.rhsLen); ok {
+			pos, _ := actionAST.Pos()
+			return nil, false, fmt.Errorf("an action refers to a symbol out of the RHS; production: %v, position: (%v, %v), index: $%v, RHS length: %v",
+				productionText(prod, symTab), pos.Line, pos.Column, index, prod.rhsLen)
+		}
+	}
+	added := prods.append(prod)
 
-	return nil
+	return prod, added, nil
 }
 
 type Table struct {
@@ -280,128 +1051,239 @@ type Table struct {
 	LR0Automaton *LR0Automaton
 	Follow       *Follow
 	First        *First
+
+	// ResolvedConflicts are the conflicts that the conflict policy resolved. They aren't errors, but they are kept
+	// so that tools can report them.
+	ResolvedConflicts []*Conflict
+
+	symTab *SymbolTable
 }
 
-func GenTable(gram *Grammar) (*Table, error) {
-	fst, err := genFirst(gram.ProductionSet)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create a FIRST set: %v", err)
+type tableConfig struct {
+	conflictPolicy ConflictPolicy
+	eofMode        EOFMode
+}
+
+type TableOption func(*tableConfig)
+
+// WithConflictPolicy sets the policy to deal with conflicts. The default is ConflictPolicyError.
+func WithConflictPolicy(policy ConflictPolicy) TableOption {
+	return func(c *tableConfig) {
+		c.conflictPolicy = policy
 	}
+}
 
-	flw, err := genFollow(gram.ProductionSet, fst)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create a FOLLOW set: %v", err)
+// WithEOFMode sets the mode deciding on which lookahead symbols the parser accepts the input. The default is
+// EOFModeExplicit. See EOFModeImplicit for how parsing a prefix of the input changes the semantics.
+func WithEOFMode(mode EOFMode) TableOption {
+	return func(c *tableConfig) {
+		c.eofMode = mode
+	}
+}
+
+// ResolvedConflictCounts returns the number of the resolved conflicts of each type.
+func (t *Table) ResolvedConflictCounts() ConflictCounts {
+	return CountConflicts(t.ResolvedConflicts)
+}
+
+// NumOfStates returns the number of states of the parsing table.
+func (t *Table) NumOfStates() int {
+	return t.LR.numOfStates
+}
+
+// GoToByName returns the state that the GOTO table maps a pair of a state and a non-terminal symbol to.
+// When the symbol is unknown or the GOTO entry is empty, GoToByName returns false.
+func (t *Table) GoToByName(state StateNum, nonTerminalName string) (StateNum, bool) {
+	if state < 0 || state.Int() >= t.LR.numOfStates {
+		return stateNumInitial, false
 	}
-	log.Log("--- Follow starts")
-	PrintFollow(log.GetWriter(), flw, gram.SymbolTable)
-	log.Log("--- Follow ends")
+	sym, ok := t.symTab.ToSymbol(nonTerminalName)
+	if !ok || !sym.isNonTerminal() || sym.isStart() {
+		return stateNumInitial, false
+	}
+	ty, next := t.LR.getGoTo(state, sym.Num())
+	if ty != GoToTypeRegistered {
+		return stateNumInitial, false
+	}
+	return next, true
+}
 
-	automaton, err := genLR0Automaton(gram.ProductionSet, gram.AugmentedStartSymbol)
+// ActionByName returns the ACTION entry that a pair of a state and a terminal symbol is mapped to. A reduce-only
+// state reduces by its production on any terminal symbol. When the state or the symbol is unknown, ActionByName
+// returns false.
+func (t *Table) ActionByName(state StateNum, terminalName string) (ActionType, StateNum, ProductionNum, bool) {
+	if state < 0 || state.Int() >= t.LR.numOfStates {
+		return ActionTypeError, stateNumInitial, productionNumMin, false
+	}
+	sym, ok := t.symTab.ToSymbol(terminalName)
+	if !ok || !sym.isTerminal() {
+		return ActionTypeError, stateNumInitial, productionNumMin, false
+	}
+	ty, next, prod := t.LR.getAction(state, sym.Num())
+	return ty, next, prod, true
+}
+
+// FirstOf returns the texts of the terminal symbols in the FIRST set of a non-terminal symbol and whether the set
+// contains the empty string.
+func (t *Table) FirstOf(symText string) ([]string, bool, error) {
+	sym, err := t.lookUpNonTerminal(symText)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create a LR0 automaton: %v", err)
+		return nil, false, err
+	}
+	e := t.First.getBySymbol(sym)
+	if e == nil {
+		return nil, false, fmt.Errorf("a FIRST set was not found; symbol: %v", symText)
 	}
-	log.Log("--- LR0 Automaton starts")
-	PrintLR0Automaton(log.GetWriter(), automaton, gram.ProductionSet, gram.SymbolTable)
-	log.Log("--- LR0 Automaton ends")
+	return t.terminalTexts(e.symbols), e.empty, nil
+}
 
-	numOfTSyms := gram.SymbolTable.getNumOfTerminalSymbols()
-	numOfNSyms := gram.SymbolTable.getNumOfNonTerminalSymbols()
-	ptab, err := genSLRParsingTable(automaton, gram.ProductionSet, flw, numOfTSyms, numOfNSyms)
+// FollowOf returns the texts of the terminal symbols in the FOLLOW set of a non-terminal symbol and whether the
+// set contains the EOF symbol.
+func (t *Table) FollowOf(symText string) ([]string, bool, error) {
+	sym, err := t.lookUpNonTerminal(symText)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create a SLR parsing table: %v", err)
+		return nil, false, err
 	}
-	log.Log("--- Parsing Table starts")
-	PrintParsingTable(log.GetWriter(), ptab)
-	log.Log("--- ParsingTable ends")
+	e, ok := t.Follow.set[sym]
+	if !ok {
+		return nil, false, fmt.Errorf("a FOLLOW set was not found; symbol: %v", symText)
+	}
+	return t.terminalTexts(e.symbols), e.eof, nil
+}
 
-	return &Table{
-		LR:           ptab,
-		LR0Automaton: automaton,
-		Follow:       flw,
-		First:        fst,
-	}, nil
+func (t *Table) lookUpNonTerminal(symText string) (Symbol, error) {
+	sym, ok := t.symTab.ToSymbol(symText)
+	if !ok {
+		return symbolNil, fmt.Errorf("unknown symbol; symbol: %v", symText)
+	}
+	if !sym.isNonTerminal() {
+		return symbolNil, fmt.Errorf("not a non-terminal symbol; symbol: %v", symText)
+	}
+	return sym, nil
 }
 
-func GenJSON(gram *Grammar, tab *Table) ([]byte, error) {
-	headSyms := make([]int, len(gram.ProductionSet.getAll())+1)
-	altSymCounts := make([]int, len(gram.ProductionSet.getAll())+1)
-	for _, p := range gram.ProductionSet.getAll() {
-		headSyms[p.num] = p.lhs.Num().Int()
-		altSymCounts[p.num] = p.rhsLen
+func (t *Table) terminalTexts(set map[Symbol]struct{}) []string {
+	syms := make([]Symbol, 0, len(set))
+	for sym := range set {
+		syms = append(syms, sym)
+	}
+	texts := symbolTexts(syms, t.symTab)
+	if texts == nil {
+		texts = []string{}
 	}
+	return texts
+}
 
-	tsymCount := gram.SymbolTable.getNumOfTerminalSymbols()
-	tsyms := make([]string, tsymCount)
-	patterns := make([]string, tsymCount)
-	for num := terminalSymbolNumMin.Int(); num < tsymCount; num++ {
-		text, err := gram.SymbolTable.ToTextFromNumT(SymbolNum(num))
+func GenTable(gram *Grammar, opts ...TableOption) (*Table, error) {
+	return NewTableGenerator(opts...).Generate(gram)
+}
+
+// TableGenerator generates parsing tables with the same options. It keeps the closures of the LR0 items across
+// runs, so generating tables of the same grammar repeatedly, such as each time a tool reloads it, skips computing
+// them again. The closures are discarded when the generator is given another production set or productions are
+// added to the set. A TableGenerator isn't safe for concurrent use.
+type TableGenerator struct {
+	config *tableConfig
+
+	closures    *closureCache
+	closuresNum ProductionNum
+}
+
+func NewTableGenerator(opts ...TableOption) *TableGenerator {
+	config := &tableConfig{
+		conflictPolicy: ConflictPolicyError,
+		eofMode:        EOFModeExplicit,
+	}
+	for _, opt := range opts {
+		opt(config)
+	}
+	return &TableGenerator{
+		config: config,
+	}
+}
+
+// closureCacheOf returns the closure cache for prods, reusing the one of the previous run when it is still valid.
+func (g *TableGenerator) closureCacheOf(prods *productionSet) *closureCache {
+	if g.closures == nil || g.closures.prods != prods || g.closuresNum != prods.num {
+		g.closures = newClosureCache(prods)
+		g.closuresNum = prods.num
+	}
+	return g.closures
+}
+
+// Generate generates a parsing table of gram.
+func (g *TableGenerator) Generate(gram *Grammar) (*Table, error) {
+	config := g.config
+	switch config.eofMode {
+	case EOFModeExplicit, EOFModeImplicit:
+	default:
+		return nil, fmt.Errorf("unknown EOF mode: %v; supported modes: %v, %v", config.eofMode, EOFModeExplicit, EOFModeImplicit)
+	}
+	cache := g.closureCacheOf(gram.ProductionSet)
+
+	// The LR0 automaton doesn't depend on the FIRST and FOLLOW sets, so we build them concurrently.
+	// Both phases only read the production set.
+	var fst *First
+	var flw *Follow
+	var automaton *LR0Automaton
+	var eg errgroup.Group
+	eg.Go(func() error {
+		var err error
+		fst, err = genFirst(gram.ProductionSet)
 		if err != nil {
-			return nil, err
+			return fmt.Errorf("failed to create a FIRST set: %v", err)
 		}
-		tsyms[num] = text
-		patterns[num] = gram.Patterns[SymbolNum(num)]
-	}
-	var unusedTSyms []int
-	{
-		tsymUseChek := make([]bool, tsymCount)
-		tsymUseChek[symbolNil.Num().Int()] = true
-		tsymUseChek[SymbolEOF.Num().Int()] = true
-		for _, prod := range gram.ProductionSet.getAll() {
-			for _, rhsSym := range prod.rhs {
-				if !rhsSym.isTerminal() {
-					continue
-				}
-				tsymUseChek[rhsSym.Num().Int()] = true
-			}
+
+		flw, err = genFollow(gram.ProductionSet, fst)
+		if err != nil {
+			return fmt.Errorf("failed to create a FOLLOW set: %v", err)
 		}
-		for num, used := range tsymUseChek {
-			if used {
-				continue
-			}
-			unusedTSyms = append(unusedTSyms, num)
+		return nil
+	})
+	eg.Go(func() error {
+		var err error
+		automaton, err = genLR0AutomatonWithCache(gram.ProductionSet, gram.AugmentedStartSymbol, cache)
+		if err != nil {
+			return fmt.Errorf("failed to create a LR0 automaton: %v", err)
 		}
+		return nil
+	})
+	err := eg.Wait()
+	if err != nil {
+		return nil, err
 	}
+	log.Debug("--- Follow starts")
+	PrintFollow(log.GetWriterAt(log.LevelDebug), flw, gram.SymbolTable)
+	log.Debug("--- Follow ends")
+	log.Debug("--- LR0 Automaton starts")
+	PrintLR0Automaton(log.GetWriterAt(log.LevelDebug), automaton, gram.ProductionSet, gram.SymbolTable)
+	log.Debug("--- LR0 Automaton ends")
 
-	nsymCount := gram.SymbolTable.getNumOfNonTerminalSymbols()
-	nsyms := make([]string, nsymCount)
-	// nonTerminalSymbolNumMin represents the augmented start symbol.
-	for num := nonTerminalSymbolNumMin.Int() + 1; num < nsymCount; num++ {
-		text, err := gram.SymbolTable.ToTextFromNumN(SymbolNum(num))
-		if err != nil {
-			return nil, err
+	numOfTSyms := gram.SymbolTable.getNumOfTerminalSymbols()
+	numOfNSyms := gram.SymbolTable.getNumOfNonTerminalSymbols()
+	ptab, err := genSLRParsingTable(automaton, gram.ProductionSet, flw, numOfTSyms, numOfNSyms, config.conflictPolicy, config.eofMode, gram.Precedences)
+	if err != nil {
+		if cErr, ok := err.(*ConflictError); ok {
+			cErr.resolveTexts(automaton, gram.ProductionSet, gram.SymbolTable)
+			return nil, cErr
 		}
-		nsyms[num] = text
-	}
-
-	return json.Marshal(struct {
-		Action                  []actionEntry `json:"action"`
-		GoTo                    []goToEntry   `json:"goto"`
-		StateCount              int           `json:"state_count"`
-		InitialState            StateNum      `json:"initial_state"`
-		StartProduction         int           `json:"start_production"`
-		HeadSymbols             []int         `json:"head_symbols"`
-		AlternativeSymbolCounts []int         `json:"alternative_symbol_counts"`
-		EOFSymbol               int           `json:"eof_symbol"`
-		TerminalSymbols         []string      `json:"terminal_symbols"`
-		TerminalSymbolPatterns  []string      `json:"terminal_symbol_patterns"`
-		TerminalSymbolCount     int           `json:"terminal_symbol_count"`
-		UnusedTerminalSymbols   []int         `json:"unused_terminal_symbols"`
-		NonTerminalSymbols      []string      `json:"non_terminal_symbols"`
-		NonTerminalSymbolCount  int           `json:"non_terminal_symbol_count"`
-	}{
-		Action:                  tab.LR.actionTable,
-		GoTo:                    tab.LR.goToTable,
-		StateCount:              len(tab.LR0Automaton.states),
-		InitialState:            tab.LR.InitialState,
-		StartProduction:         ProductionNumStart.Int(),
-		HeadSymbols:             headSyms,
-		AlternativeSymbolCounts: altSymCounts,
-		EOFSymbol:               SymbolEOF.Num().Int(),
-		TerminalSymbols:         tsyms,
-		TerminalSymbolPatterns:  patterns,
-		TerminalSymbolCount:     tsymCount,
-		UnusedTerminalSymbols:   unusedTSyms,
-		NonTerminalSymbols:      nsyms,
-		NonTerminalSymbolCount:  nsymCount,
-	})
+		return nil, fmt.Errorf("failed to create a SLR parsing table: %v", err)
+	}
+	log.Debug("--- Parsing Table starts")
+	PrintParsingTable(log.GetWriterAt(log.LevelDebug), ptab)
+	log.Debug("--- ParsingTable ends")
+
+	resolveConflictTexts(ptab.resolvedConflicts, automaton, gram.ProductionSet, gram.SymbolTable)
+	for _, c := range ptab.resolvedConflicts {
+		log.Warn("%v", c)
+	}
+
+	return &Table{
+		LR:                ptab,
+		LR0Automaton:      automaton,
+		Follow:            flw,
+		First:             fst,
+		ResolvedConflicts: ptab.resolvedConflicts,
+		symTab:            gram.SymbolTable,
+	}, nil
 }
@@ -1,6 +1,9 @@
 package grammar
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
 	"strings"
 	"testing"
 
@@ -135,3 +138,1273 @@ func matchProduction(t *testing.T, lhs string, num int, rhs []string, gram *Gram
 		}
 	}
 }
+
+func TestGenGrammar_InlineLiterals(t *testing.T) {
+	tests := []struct {
+		caption  string
+		src      string
+		literals map[string]string
+	}{
+		{
+			caption: "the same literal used in many rules yields a single terminal symbol",
+			src: `
+s: a | b | c | d | e | f | g | h | i | j;
+a: "if" A; b: "if" B; c: "if" C; d: "if" D; e: "if" E;
+f: "if" F; g: "if" G; h: "if" H; i: "if" I; j: "if" J;
+`,
+			literals: map[string]string{
+				"if": "$0",
+			},
+		},
+		{
+			caption: "a literal resolves to the lexeme having the same pattern even if the lexeme is defined later",
+			src: `
+if_stmt: "if" cond "then" body;
+cond: ID;
+body: ID;
+IF: "if";
+`,
+			literals: map[string]string{
+				"if":   "IF",
+				"then": "$0",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.caption, func(t *testing.T) {
+			gram, tab := genTestTable(t, tt.src)
+			if len(gram.Patterns) != len(tt.literals) {
+				t.Fatalf("unexpected number of patterns; want: %v, got: %v", len(tt.literals), len(gram.Patterns))
+			}
+			d, err := GenJSON(gram, tab)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var out SerializedTable
+			err = json.Unmarshal(d, &out)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(out.LiteralSymbols) != len(tt.literals) {
+				t.Fatalf("unexpected literal_symbols; want: %v, got: %v", tt.literals, out.LiteralSymbols)
+			}
+			for lit, symText := range tt.literals {
+				num, ok := out.LiteralSymbols[lit]
+				if !ok {
+					t.Fatalf("a literal was not found; literal: %v", lit)
+				}
+				if out.TerminalSymbols[num] != symText {
+					t.Fatalf("unexpected terminal symbol; literal: %v, want: %v, got: %v", lit, symText, out.TerminalSymbols[num])
+				}
+			}
+		})
+	}
+}
+
+func TestGenGrammar_DuplicateAlternatives(t *testing.T) {
+	tests := []struct {
+		caption  string
+		src      string
+		warnings []string
+	}{
+		{
+			caption:  "a grammar without duplicate alternatives has no warnings",
+			src:      "s: A B | A;",
+			warnings: nil,
+		},
+		{
+			caption: "duplicate alternatives are reported by LHS and RHS",
+			src:     `s: a | b | a; a: B C | B C #dup; b: | "x" | ;`,
+			warnings: []string{
+				"duplicate alternative: s: a",
+				"duplicate alternative: a: B C",
+				"the label of a duplicate alternative is dropped: a: B C #dup",
+				"duplicate alternative: b:",
+			},
+		},
+		{
+			caption: "a duplicate alternative having a different action loses the action",
+			src:     "s: a | b; a: B C {x} | B C {y} | D; b: D {z} | D {z};",
+			warnings: []string{
+				"duplicate alternative: a: B C",
+				"the action of a duplicate alternative is dropped: a: B C {y}",
+				"duplicate alternative: b: D",
+			},
+		},
+		{
+			caption: "a duplicate alternative having the same label as the first one loses nothing",
+			src:     "s: a | b; a: B C #bc | B C #bc | D; b: D;",
+			warnings: []string{
+				"duplicate alternative: a: B C",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.caption, func(t *testing.T) {
+			gram := genTestGrammar(t, tt.src)
+			if len(gram.Warnings) != len(tt.warnings) {
+				t.Fatalf("unexpected warnings; want: %v, got: %v", tt.warnings, gram.Warnings)
+			}
+			for i, w := range tt.warnings {
+				if gram.Warnings[i] != w {
+					t.Fatalf("unexpected warning; want: %q, got: %q", w, gram.Warnings[i])
+				}
+			}
+			if tt.warnings != nil && gram.NumOfProductions() != 5 {
+				t.Fatalf("duplicate alternatives must be merged; want: %v productions, got: %v", 5, gram.NumOfProductions())
+			}
+		})
+	}
+}
+
+func TestGenGrammar_DigitSymbols(t *testing.T) {
+	tests := []struct {
+		caption string
+		src     string
+		prods   map[string][][]string
+	}{
+		{
+			caption: "a terminal symbol can consist of digits",
+			src:     `s: A 2;`,
+			prods: map[string][][]string{
+				"s": {{"A", "2"}},
+			},
+		},
+		{
+			caption: "%token and %start can name a symbol consisting of digits",
+			src:     `%token A 2; %start 1; 1: A 2;`,
+			prods: map[string][][]string{
+				"1": {{"A", "2"}},
+			},
+		},
+		{
+			caption: "a lexeme production can define a symbol consisting of digits",
+			src:     `s: A 1 B; 1: "x";`,
+			prods: map[string][][]string{
+				"s": {{"A", "1", "B"}},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.caption, func(t *testing.T) {
+			gram := genTestGrammar(t, tt.src)
+			for lhs, alts := range tt.prods {
+				for i, rhs := range alts {
+					matchProduction(t, lhs, i, rhs, gram, gram.SymbolTable)
+				}
+			}
+		})
+	}
+}
+
+func TestGenGrammar_StartDirective(t *testing.T) {
+	tests := []struct {
+		caption string
+		src     string
+		start   string
+		err     string
+	}{
+		{
+			caption: "without %start, the LHS of the first production is the start symbol",
+			src:     "b: A; a: b;",
+			start:   "b",
+		},
+		{
+			caption: "%start chooses the start symbol",
+			src:     "b: A; a: b; %start a;",
+			start:   "a",
+		},
+		{
+			caption: "%start naming an undefined symbol is an error",
+			src:     "%start c; a: A;",
+			err:     "%start names an undefined non-terminal symbol; symbol: c",
+		},
+		{
+			caption: "%start naming a lexeme is an error",
+			src:     `%start a; s: a; a: "a";`,
+			err:     "%start names an undefined non-terminal symbol; symbol: a",
+		},
+		{
+			caption: "%start appearing twice is an error",
+			src:     "%start a; %start a; a: A;",
+			err:     "%start appears more than once",
+		},
+		{
+			caption: "%start without a symbol is an error",
+			src:     "%start; a: A;",
+			err:     "%start takes exactly one symbol",
+		},
+		{
+			caption: "an unknown directive is an error",
+			src:     "%foo; a: A;",
+			err:     "unknown directive; directive: %foo",
+		},
+		{
+			caption: "an unresolved %include is an error",
+			src:     `%include "a.9g"; a: A;`,
+			err:     "%include must be resolved with parser.ResolveIncludes before generating a grammar",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.caption, func(t *testing.T) {
+			psr, err := parser.NewParser(strings.NewReader(tt.src))
+			if err != nil {
+				t.Fatal(err)
+			}
+			ast, err := psr.Parse()
+			if err != nil {
+				t.Fatal(err)
+			}
+			gram, err := GenGrammar(ast)
+			if tt.err != "" {
+				if err == nil || err.Error() != tt.err {
+					t.Fatalf("unexpected error; want: %v, got: %v", tt.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			prods, _ := gram.ProductionSet.findByLHS(gram.AugmentedStartSymbol)
+			start, _ := gram.SymbolTable.ToText(prods[0].rhs[0])
+			if start != tt.start {
+				t.Fatalf("unexpected start symbol; want: %v, got: %v", tt.start, start)
+			}
+			augmented, _ := gram.SymbolTable.ToText(gram.AugmentedStartSymbol)
+			if augmented != tt.start+"'" {
+				t.Fatalf("unexpected augmented start symbol; want: %v, got: %v", tt.start+"'", augmented)
+			}
+		})
+	}
+}
+
+func TestGenGrammar_TokenDirective(t *testing.T) {
+	tests := []struct {
+		caption string
+		src     string
+		tokens  []string
+		err     string
+	}{
+		{
+			caption: "without %token, undeclared symbols are terminal symbols",
+			src:     "s: A exrp; expr: B;",
+			tokens:  []string{"A", "exrp", "B"},
+		},
+		{
+			caption: "%token declares terminal symbols in the order of the declarations",
+			src:     `%token C B; %token A; s: A B | C d; d: "d";`,
+			tokens:  []string{"d", "C", "B", "A"},
+		},
+		{
+			caption: "with %token, a symbol that is neither declared nor defined is an error",
+			src:     "%token A B; s: A exrp | A Z; expr: B;",
+			err:     "undeclared terminal symbols; declare them with %token or fix the typos; symbols: exrp, Z",
+		},
+		{
+			caption: "%token declaring a non-terminal symbol is an error",
+			src:     "%token A s; s: A;",
+			err:     "%token declares a non-terminal symbol; symbol: s",
+		},
+		{
+			caption: "%token taking a pattern is an error",
+			src:     `%token A "b"; s: A;`,
+			err:     "%token takes only symbols",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.caption, func(t *testing.T) {
+			psr, err := parser.NewParser(strings.NewReader(tt.src))
+			if err != nil {
+				t.Fatal(err)
+			}
+			ast, err := psr.Parse()
+			if err != nil {
+				t.Fatal(err)
+			}
+			gram, err := GenGrammar(ast)
+			if tt.err != "" {
+				if err == nil || err.Error() != tt.err {
+					t.Fatalf("unexpected error; want: %v, got: %v", tt.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			var tokens []string
+			gram.SymbolTable.EachSymbol(func(sym Symbol, text string) {
+				if sym.IsTerminal() {
+					tokens = append(tokens, text)
+				}
+			})
+			if strings.Join(tokens, ",") != strings.Join(tt.tokens, ",") {
+				t.Fatalf("unexpected terminal symbols; want: %v, got: %v", tt.tokens, tokens)
+			}
+		})
+	}
+}
+
+func TestGenGrammar_AliasDirective(t *testing.T) {
+	tests := []struct {
+		caption string
+		src     string
+		tokens  []string
+		prods   []string
+		err     string
+	}{
+		{
+			caption: "an alias resolves to the same terminal symbol as the canonical name",
+			src:     "%alias PLUS ADD; s: s PLUS NUM | t; t: t ADD NUM | NUM;",
+			tokens:  []string{"PLUS", "NUM"},
+			prods:   []string{"s: s PLUS NUM", "s: t", "t: t PLUS NUM", "t: NUM"},
+		},
+		{
+			caption: "the canonical name can be a lexeme",
+			src:     `%alias PLUS ADD AND; s: s ADD NUM | NUM AND NUM; PLUS: "[+]";`,
+			tokens:  []string{"PLUS", "NUM"},
+			prods:   []string{"s: s PLUS NUM", "s: NUM PLUS NUM"},
+		},
+		{
+			caption: "with %token, an alias needs no declaration",
+			src:     "%token PLUS NUM; %alias PLUS ADD; s: s ADD NUM | NUM;",
+			tokens:  []string{"PLUS", "NUM"},
+			prods:   []string{"s: s PLUS NUM", "s: NUM"},
+		},
+		{
+			caption: "with %token, the canonical name must be declared",
+			src:     "%token NUM; %alias PLUS ADD; s: s ADD NUM | NUM;",
+			err:     "%alias names an undeclared terminal symbol; symbol: PLUS",
+		},
+		{
+			caption: "an alias colliding with a symbol is an error",
+			src:     "%alias PLUS s; s: PLUS;",
+			err:     "an alias collides with a symbol; alias: s",
+		},
+		{
+			caption: "an alias of a non-terminal symbol is an error",
+			src:     "%alias s S; s: A;",
+			err:     "%alias takes only terminal symbols; symbol: s",
+		},
+		{
+			caption: "%alias without aliases is an error",
+			src:     "%alias PLUS; s: PLUS;",
+			err:     "%alias takes a terminal symbol followed by its aliases",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.caption, func(t *testing.T) {
+			psr, err := parser.NewParser(strings.NewReader(tt.src))
+			if err != nil {
+				t.Fatal(err)
+			}
+			ast, err := psr.Parse()
+			if err != nil {
+				t.Fatal(err)
+			}
+			gram, err := GenGrammar(ast)
+			if tt.err != "" {
+				if err == nil || err.Error() != tt.err {
+					t.Fatalf("unexpected error; want: %v, got: %v", tt.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			var tokens []string
+			gram.SymbolTable.EachSymbol(func(sym Symbol, text string) {
+				if sym.IsTerminal() {
+					tokens = append(tokens, text)
+				}
+			})
+			if strings.Join(tokens, ",") != strings.Join(tt.tokens, ",") {
+				t.Fatalf("unexpected terminal symbols; want: %v, got: %v", tt.tokens, tokens)
+			}
+			var prods []string
+			for _, prod := range gram.ProductionSet.getAllSorted() {
+				if prod.num != ProductionNumStart {
+					prods = append(prods, productionText(prod, gram.SymbolTable))
+				}
+			}
+			if strings.Join(prods, "\n") != strings.Join(tt.prods, "\n") {
+				t.Fatalf("unexpected productions;\nwant:\n%v\ngot:\n%v", strings.Join(tt.prods, "\n"), strings.Join(prods, "\n"))
+			}
+		})
+	}
+}
+
+func TestGenGrammar_VerbosePattern(t *testing.T) {
+	t.Run("the pattern of a lexeme production is normalized", func(t *testing.T) {
+		gram := genTestGrammar(t, "s: NUM; NUM: \"(?x) [0-9]+  # digits\n  (\\\\.[0-9]+)?\";")
+		sym, _ := gram.SymbolTable.ToSymbol("NUM")
+		if pat := gram.Patterns[sym.Num()]; pat != `[0-9]+(\.[0-9]+)?` {
+			t.Fatalf("unexpected pattern: %q", pat)
+		}
+	})
+
+	t.Run("a pattern that is empty after the normalization is an error", func(t *testing.T) {
+		psr, err := parser.NewParser(strings.NewReader(`s: A; A: "(?x) # nothing";`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		ast, err := psr.Parse()
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = GenGrammar(ast)
+		if err == nil {
+			t.Fatal("GenGrammar must fail")
+		}
+	})
+}
+
+func TestGenGrammar_UnitCycles(t *testing.T) {
+	tests := []struct {
+		caption string
+		src     string
+		err     string
+	}{
+		{
+			caption: "unit productions without cycles are allowed",
+			src:     "s: a X; a: b; b: Y;",
+		},
+		{
+			caption: "a production deriving its LHS",
+			src:     "s: s | X;",
+			err:     "unit productions form cycles; cycles: s → s",
+		},
+		{
+			caption: "an indirect cycle",
+			src:     "s: a X | X; a: b | ; b: a;",
+			err:     "unit productions form cycles; cycles: a → b → a",
+		},
+		{
+			caption: "a cycle through the start symbol",
+			src:     "s: a | X; a: s;",
+			err:     "unit productions form cycles; cycles: s → a → s",
+		},
+		{
+			caption: "a cycle through a generated symbol",
+			src:     "s: x? | X; x: s;",
+			err:     "unit productions form cycles; cycles: s → $$0 → x → s",
+		},
+		{
+			caption: "a production with a nullable symbol isn't a unit production",
+			src:     "s: a e | X; a: s; e: ;",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.caption, func(t *testing.T) {
+			psr, err := parser.NewParser(strings.NewReader(tt.src))
+			if err != nil {
+				t.Fatal(err)
+			}
+			ast, err := psr.Parse()
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, err = GenGrammar(ast)
+			if tt.err == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || err.Error() != tt.err {
+				t.Fatalf("unexpected error; want: %v, got: %v", tt.err, err)
+			}
+		})
+	}
+}
+
+func TestGenGrammar_NoProductions(t *testing.T) {
+	for _, src := range []string{
+		"",
+		"// only a comment",
+		"/* only a comment */\n",
+		"%start s;",
+		`num: "[0-9]+";`,
+	} {
+		psr, err := parser.NewParser(strings.NewReader(src))
+		if err != nil {
+			t.Fatal(err)
+		}
+		ast, err := psr.Parse()
+		if err != nil {
+			t.Fatalf("unexpected syntax error; source: %q, error: %v", src, err)
+		}
+		_, err = GenGrammar(ast)
+		if !errors.Is(err, ErrNoProductions) {
+			t.Fatalf("unexpected error; source: %q, want: %v, got: %v", src, ErrNoProductions, err)
+		}
+	}
+}
+
+func TestGenGrammar_Empty(t *testing.T) {
+	explicit := genTestGrammar(t, `s: sign NUM; sign: "-" | %empty; opt: %empty #none | A;`)
+	implicit := genTestGrammar(t, `s: sign NUM; sign: "-" | ; opt: #none | A;`)
+	if explicit.Hash() != implicit.Hash() {
+		t.Fatalf("%%empty must generate the same productions as an empty alternative")
+	}
+	if explicit.NumOfProductions() != 5 {
+		t.Fatalf("unexpected number of productions; want: %v, got: %v", 5, explicit.NumOfProductions())
+	}
+}
+
+func TestGenGrammar_UndefinedNonTerminals(t *testing.T) {
+	t.Run("a terminal symbol named like a non-terminal symbol is reported", func(t *testing.T) {
+		gram := genTestGrammar(t, "s: expr | stmt; expr: NUM; stmt: exprr SEMI | exprr; id: \"[a-z]+\";")
+		want := []string{
+			"a terminal symbol without a pattern looks like an undefined non-terminal symbol; symbol: exprr, production: stmt: exprr SEMI",
+		}
+		if strings.Join(gram.Warnings, "\n") != strings.Join(want, "\n") {
+			t.Fatalf("unexpected warnings; want: %v, got: %v", want, gram.Warnings)
+		}
+	})
+
+	t.Run("%token suppresses the heuristic", func(t *testing.T) {
+		gram := genTestGrammar(t, "%token num; s: num;")
+		if len(gram.Warnings) > 0 {
+			t.Fatalf("unexpected warnings: %v", gram.Warnings)
+		}
+	})
+
+	t.Run("a non-terminal symbol without productions is an error", func(t *testing.T) {
+		gram := genTestGrammar(t, "s: A;")
+		sSym, _ := gram.SymbolTable.ToSymbol("s")
+		undefSym, err := gram.SymbolTable.registerNonTerminalSymbol("undef")
+		if err != nil {
+			t.Fatal(err)
+		}
+		prod, err := newProduction(sSym, []Symbol{undefSym, undefSym}, DefaultMaxRHSLen)
+		if err != nil {
+			t.Fatal(err)
+		}
+		gram.ProductionSet.append(prod)
+		err = checkUndefinedNonTerminals(gram.ProductionSet, gram.SymbolTable)
+		if err == nil {
+			t.Fatal("an error must occur")
+		}
+		msg := "non-terminal symbols have no productions; symbols: undef (used in `s: undef undef`)"
+		if err.Error() != msg {
+			t.Fatalf("unexpected error; want: %v, got: %v", msg, err)
+		}
+	})
+}
+
+func TestGenGrammar_Redefinition(t *testing.T) {
+	tests := []struct {
+		caption string
+		src     string
+		err     string
+	}{
+		{
+			caption: "a lexeme redefined with a different pattern is an error",
+			src:     "s: NUM;\nNUM: \"[0-9]+\";\n  NUM: \"[a-z]+\";",
+			err:     `a lexeme is redefined with a different pattern; symbol: NUM, first: "[0-9]+" (2, 1), second: "[a-z]+" (3, 3)`,
+		},
+		{
+			caption: "a lexeme redefined with the same pattern is allowed",
+			src:     "s: NUM; NUM: \"[0-9]+\"; NUM: \"(?x) [0-9] +\";",
+		},
+		{
+			caption: "a symbol defined as both a lexeme and a non-terminal symbol is an error",
+			src:     "s: NUM;\nNUM: A;\nNUM: \"[0-9]+\";",
+			err:     "a symbol is defined as both a lexeme and a non-terminal symbol; symbol: NUM, lexeme: (3, 1), production: (2, 1)",
+		},
+		{
+			caption: "a lexeme redefined with a different case sensitivity is an error",
+			src:     "s: SELECT;\nSELECT: \"select\" %i;\nSELECT: \"select\";",
+			err:     "a lexeme is redefined with a different case sensitivity; symbol: SELECT, first: (2, 1), second: (3, 1)",
+		},
+		{
+			caption: "%i in a non-lexeme production is an error",
+			src:     "s: A %i;",
+			err:     "%i is allowed only in lexeme productions; symbol: s, position: (1, 6)",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.caption, func(t *testing.T) {
+			psr, err := parser.NewParser(strings.NewReader(tt.src))
+			if err != nil {
+				t.Fatal(err)
+			}
+			ast, err := psr.Parse()
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, err = GenGrammar(ast)
+			if tt.err == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			if err == nil || err.Error() != tt.err {
+				t.Fatalf("unexpected error; want: %v, got: %v", tt.err, err)
+			}
+		})
+	}
+}
+
+func TestGenGrammar_CaseInsensitiveTerminals(t *testing.T) {
+	gram := genTestGrammar(t, `s: SELECT ID "from" ID; SELECT: "select" %i; FROM: "from" %i; ID: "[a-z]+";`)
+	genSym := newTestSymbolGenerator(t, gram.SymbolTable)
+	for _, text := range []string{"SELECT", "FROM"} {
+		if _, ok := gram.CaseInsensitiveTerminals[genSym(text).Num()]; !ok {
+			t.Fatalf("%v must be case-insensitive", text)
+		}
+	}
+	if _, ok := gram.CaseInsensitiveTerminals[genSym("ID").Num()]; ok {
+		t.Fatalf("ID must be case-sensitive")
+	}
+	if len(gram.CaseInsensitiveTerminals) != 2 {
+		t.Fatalf("unexpected case-insensitive terminals: %v", gram.CaseInsensitiveTerminals)
+	}
+}
+
+func TestGenGrammar_TerminalModes(t *testing.T) {
+	tests := []struct {
+		caption string
+		src     string
+		modes   map[string]string
+		err     string
+	}{
+		{
+			caption: "%mode annotates lexemes with modes",
+			src:     `s: QUOTE CHAR* QUOTE | ID; QUOTE: "\"" %mode(normal, string); CHAR: "[^\"]" %mode(string); ID: "[a-z]+" %i %mode(normal);`,
+			modes: map[string]string{
+				"QUOTE": "normal,string",
+				"CHAR":  "string",
+				"ID":    "normal",
+			},
+		},
+		{
+			caption: "a lexeme redefined with the same modes is accepted",
+			src:     `s: CHAR; CHAR: "[a-z]" %mode(string); CHAR: "[a-z]" %mode(string);`,
+			modes: map[string]string{
+				"CHAR": "string",
+			},
+		},
+		{
+			caption: "a lexeme redefined with different modes is an error",
+			src:     `s: CHAR; CHAR: "[a-z]" %mode(string); CHAR: "[a-z]";`,
+			err:     "a lexeme is redefined with different modes; symbol: CHAR, first: (1, 10), second: (1, 39)",
+		},
+		{
+			caption: "a mode appearing more than once is an error",
+			src:     `s: CHAR; CHAR: "[a-z]" %mode(string, string);`,
+			err:     "a mode appears more than once; symbol: CHAR, mode: string, position: (1, 38)",
+		},
+		{
+			caption: "%mode in a non-lexeme production is an error",
+			src:     `s: A %mode(string);`,
+			err:     "%mode is allowed only in lexeme productions; symbol: s, position: (1, 6)",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.caption, func(t *testing.T) {
+			psr, err := parser.NewParser(strings.NewReader(tt.src))
+			if err != nil {
+				t.Fatal(err)
+			}
+			ast, err := psr.Parse()
+			if err != nil {
+				t.Fatal(err)
+			}
+			gram, err := GenGrammar(ast)
+			if tt.err != "" {
+				if err == nil || err.Error() != tt.err {
+					t.Fatalf("unexpected error; want: %v, got: %v", tt.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(gram.TerminalModes) != len(tt.modes) {
+				t.Fatalf("unexpected number of terminal symbols with modes; want: %v, got: %v", len(tt.modes), len(gram.TerminalModes))
+			}
+			genSym := newTestSymbolGenerator(t, gram.SymbolTable)
+			for text, modes := range tt.modes {
+				if actual := strings.Join(gram.TerminalModes[genSym(text).Num()], ","); actual != modes {
+					t.Fatalf("unexpected modes; symbol: %v, want: %v, got: %v", text, modes, actual)
+				}
+			}
+		})
+	}
+}
+
+func TestGrammar_Clone(t *testing.T) {
+	gram := genTestGrammar(t, `s: s ADD NUM | NUM; ADD: "[+]"; NUM: "[0-9]+";`)
+	origHash := gram.Hash()
+	origProds := gram.NumOfProductions()
+	genSym := newTestSymbolGenerator(t, gram.SymbolTable)
+
+	clone := gram.Clone()
+	if clone.Hash() != origHash {
+		t.Fatalf("a clone must be equivalent to the original")
+	}
+
+	// Mutate the clone.
+	sub, err := clone.SymbolTable.registerTerminalSymbol("SUB")
+	if err != nil {
+		t.Fatal(err)
+	}
+	clone.Patterns[sub.Num()] = "-"
+	prod, err := newProduction(genSym("s"), []Symbol{genSym("s"), sub, genSym("NUM")}, DefaultMaxRHSLen)
+	if err != nil {
+		t.Fatal(err)
+	}
+	clone.ProductionSet.append(prod)
+	prods, _ := clone.ProductionSet.findByLHS(genSym("s"))
+	prods[0].rhs[1] = sub
+
+	if _, ok := gram.SymbolTable.ToSymbol("SUB"); ok {
+		t.Fatalf("registering a symbol with the clone must not affect the original")
+	}
+	if _, ok := gram.Patterns[sub.Num()]; ok {
+		t.Fatalf("adding a pattern to the clone must not affect the original")
+	}
+	if gram.NumOfProductions() != origProds {
+		t.Fatalf("appending a production to the clone must not affect the original; want: %v, got: %v", origProds, gram.NumOfProductions())
+	}
+	if gram.Hash() != origHash {
+		t.Fatalf("modifying the productions of the clone must not affect the original")
+	}
+	if _, err := GenTable(gram); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestGenGrammar_MaxRHSLen(t *testing.T) {
+	src := "s: a;\na: A B C D | A? B* C+;"
+	psr, err := parser.NewParser(strings.NewReader(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ast, err := psr.Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = GenGrammar(ast, WithMaxRHSLen(4))
+	if err != nil {
+		t.Fatalf("an alternative within the limit must be accepted: %v", err)
+	}
+	_, err = GenGrammar(ast, WithMaxRHSLen(3))
+	expected := "an alternative has too many symbols; symbol: a, position: (2, 4), length: 4, limit: 3"
+	if err == nil || err.Error() != expected {
+		t.Fatalf("unexpected error; want: %v, got: %v", expected, err)
+	}
+
+	// The default limit accepts long alternatives of ordinary grammars.
+	var long strings.Builder
+	long.WriteString("s:")
+	for i := 0; i < 300; i++ {
+		long.WriteString(" A")
+	}
+	long.WriteString(";")
+	genTestGrammar(t, long.String())
+}
+
+func TestGenGrammar_StrictTokens(t *testing.T) {
+	src := "%token A B C; s: A; C: \"c\";"
+	psr, err := parser.NewParser(strings.NewReader(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ast, err := psr.Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = GenGrammar(ast)
+	if err != nil {
+		t.Fatalf("unused terminal symbols must be accepted by default: %v", err)
+	}
+	_, err = GenGrammar(ast, WithStrictTokens())
+	expected := "unused terminal symbols; symbols: C, B"
+	if err == nil || err.Error() != expected {
+		t.Fatalf("unexpected error; want: %v, got: %v", expected, err)
+	}
+
+	// A pseudo-token that only %prec refers to is used.
+	psr, err = parser.NewParser(strings.NewReader("%token ADD NUM UMINUS; %left ADD; %right UMINUS; e: e ADD e | ADD e %prec UMINUS | NUM;"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ast, err = psr.Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	gram, err := GenGrammar(ast, WithStrictTokens())
+	if err != nil {
+		t.Fatalf("a terminal symbol named by a precedence directive must be used: %v", err)
+	}
+	if nums := findUnusedTerminalSymbols(gram); len(nums) > 0 {
+		t.Fatalf("unexpected unused terminal symbols: %v", nums)
+	}
+}
+
+func TestGenGrammar_ActionRefs(t *testing.T) {
+	tests := []struct {
+		caption string
+		src     string
+		message string
+	}{
+		{
+			caption: "references within the RHS are accepted",
+			src:     "s: A? B* C { $$ = f($1, $2, $3) } | { $$ = nil };",
+		},
+		{
+			caption: "a reference beyond the RHS is an error",
+			src:     "s: A B { $$ = $1 + $4 };",
+			message: "an action refers to a symbol out of the RHS; production: s: A B, position: (1, 8), index: $4, RHS length: 2",
+		},
+		{
+			caption: "any reference in an empty alternative is an error",
+			src:     "s: A | {\n\t$$ = $1\n};",
+			message: "an action refers to a symbol out of the RHS; production: s:, position: (1, 8), index: $1, RHS length: 0",
+		},
+		{
+			caption: "$$ followed by digits isn't a reference",
+			src:     "s: A { $$1 = $1 };",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.caption, func(t *testing.T) {
+			psr, err := parser.NewParser(strings.NewReader(tt.src))
+			if err != nil {
+				t.Fatal(err)
+			}
+			ast, err := psr.Parse()
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, err = GenGrammar(ast)
+			if tt.message == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || err.Error() != tt.message {
+				t.Fatalf("unexpected error; want: %v, got: %v", tt.message, err)
+			}
+		})
+	}
+}
+
+func TestGenGrammar_SourceOrderNumbering(t *testing.T) {
+	src := "s: a? B | C; a: A* D;"
+	tests := []struct {
+		caption string
+		opts    []GrammarOption
+		prods   []string
+	}{
+		{
+			caption: "the synthetic productions precede the alternatives using them by default",
+			prods: []string{
+				"1 s': s",
+				"2 $$0: a (synthetic)",
+				"3 $$0: (synthetic)",
+				"4 s: $$0 B",
+				"5 s: C",
+				"6 $$1: A $$1 (synthetic)",
+				"7 $$1: (synthetic)",
+				"8 a: $$1 D",
+			},
+		},
+		{
+			caption: "the synthetic productions follow all the user productions in the source order mode",
+			opts:    []GrammarOption{WithSourceOrderNumbering()},
+			prods: []string{
+				"1 s': s",
+				"2 s: $$0 B",
+				"3 s: C",
+				"4 a: $$1 D",
+				"5 $$0: a (synthetic)",
+				"6 $$0: (synthetic)",
+				"7 $$1: A $$1 (synthetic)",
+				"8 $$1: (synthetic)",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.caption, func(t *testing.T) {
+			psr, err := parser.NewParser(strings.NewReader(src))
+			if err != nil {
+				t.Fatal(err)
+			}
+			ast, err := psr.Parse()
+			if err != nil {
+				t.Fatal(err)
+			}
+			gram, err := GenGrammar(ast, tt.opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var prods []string
+			for _, v := range gram.Productions() {
+				text := strings.TrimSpace(fmt.Sprintf("%v %v: %v", v.Num(), v.LHS(), strings.Join(v.RHS(), " ")))
+				if v.IsSynthetic() {
+					text += " (synthetic)"
+				}
+				prods = append(prods, text)
+			}
+			if strings.Join(prods, "\n") != strings.Join(tt.prods, "\n") {
+				t.Fatalf("unexpected productions;\nwant:\n%v\ngot:\n%v", strings.Join(tt.prods, "\n"), strings.Join(prods, "\n"))
+			}
+			if _, err := GenTable(gram); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestGenGrammar_SyntheticOrigins(t *testing.T) {
+	gram := genTestGrammar(t, "s: a? B*; a: A+ s;")
+	expected := map[string]string{
+		"$$0": "? a",
+		"$$1": "* B",
+		"$$2": "+ A",
+	}
+	origins := map[string]string{}
+	for num, origin := range gram.SyntheticOrigins {
+		text, err := gram.SymbolTable.ToTextFromNumN(num)
+		if err != nil {
+			t.Fatal(err)
+		}
+		symText, ok := gram.SymbolTable.ToText(origin.Symbol)
+		if !ok {
+			t.Fatalf("an origin symbol was not found; symbol: %v", origin.Symbol)
+		}
+		origins[text] = fmt.Sprintf("%v %v", origin.Kind, symText)
+	}
+	if fmt.Sprint(origins) != fmt.Sprint(expected) {
+		t.Fatalf("unexpected origins; want: %v, got: %v", expected, origins)
+	}
+
+	clone := gram.Clone()
+	for num := range clone.SyntheticOrigins {
+		clone.SyntheticOrigins[num].Kind = SyntheticKindLeftFactor
+	}
+	for _, origin := range gram.SyntheticOrigins {
+		if origin.Kind == SyntheticKindLeftFactor {
+			t.Fatalf("a clone must not share the origins with the original grammar")
+		}
+	}
+}
+
+func TestCheckUserSymbolText(t *testing.T) {
+	for _, text := range []string{"$foo", "$0", "$$0", "e'"} {
+		if err := checkUserSymbolText(text); err == nil {
+			t.Fatalf("a symbol beginning with $ or ending with ' must be rejected; symbol: %v", text)
+		}
+	}
+	for _, text := range []string{"foo", "foo$", "FOO"} {
+		if err := checkUserSymbolText(text); err != nil {
+			t.Fatalf("unexpected error; symbol: %v, error: %v", text, err)
+		}
+	}
+}
+
+func TestCheckAugmentedStartReferences(t *testing.T) {
+	gram := genTestGrammar(t, "e: e ADD NUM | NUM;")
+	err := checkAugmentedStartReferences(gram.ProductionSet, gram.SymbolTable)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	genSym := newTestSymbolGenerator(t, gram.SymbolTable)
+	prod, err := newProduction(genSym("e"), []Symbol{genSym("e'"), genSym("ADD")}, DefaultMaxRHSLen)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gram.ProductionSet.append(prod)
+	err = checkAugmentedStartReferences(gram.ProductionSet, gram.SymbolTable)
+	expected := "the augmented start symbol must not appear in the RHS of a production; production: e: e' ADD"
+	if err == nil || err.Error() != expected {
+		t.Fatalf("unexpected error; want: %v, got: %v", expected, err)
+	}
+}
+
+func TestTable_ByName(t *testing.T) {
+	src := "e: e ADD t | t; t: t MUL f | f; f: LPAREN e RPAREN | NUMBER;"
+
+	parser, err := parser.NewParser(strings.NewReader(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ast, err := parser.Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	gram, err := GenGrammar(ast)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tab, err := GenTable(gram)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	genSym := newTestSymbolGenerator(t, gram.SymbolTable)
+	genProd := newTestProductionGenerator(t, genSym)
+	genLR0Item := newTestLR0ItemGenerator(t, genProd)
+
+	findState := func(items ...*LR0Item) StateNum {
+		t.Helper()
+
+		k, err := newKernel(items)
+		if err != nil {
+			t.Fatal(err)
+		}
+		state, ok := tab.LR0Automaton.states[k.ID]
+		if !ok {
+			t.Fatalf("state was not found; kernel: %v", k.ID)
+		}
+		return state.Num
+	}
+
+	t.Run("GoToByName returns the GOTO target of a non-terminal", func(t *testing.T) {
+		expected := findState(genLR0Item("e'", 1, "e"), genLR0Item("e", 1, "e", "ADD", "t"))
+		next, ok := tab.GoToByName(tab.LR.InitialState, "e")
+		if !ok {
+			t.Fatalf("GOTO entry was not found")
+		}
+		if next != expected {
+			t.Fatalf("unexpected GOTO target; want: %v, got: %v", expected, next)
+		}
+	})
+
+	t.Run("GoToByName returns false for an unknown or terminal symbol", func(t *testing.T) {
+		for _, name := range []string{"unknown", "ADD"} {
+			if _, ok := tab.GoToByName(tab.LR.InitialState, name); ok {
+				t.Fatalf("GOTO entry must not be found; symbol: %v", name)
+			}
+		}
+	})
+
+	t.Run("ActionByName returns the ACTION entry of a terminal", func(t *testing.T) {
+		expected := findState(genLR0Item("f", 1, "NUMBER"))
+		ty, next, _, ok := tab.ActionByName(tab.LR.InitialState, "NUMBER")
+		if !ok {
+			t.Fatalf("ACTION entry was not found")
+		}
+		if ty != ActionTypeShift || next != expected {
+			t.Fatalf("unexpected ACTION entry; want: shift %v, got: %v %v", expected, ty, next)
+		}
+	})
+
+	t.Run("ActionByName returns false for an unknown or non-terminal symbol", func(t *testing.T) {
+		for _, name := range []string{"unknown", "e"} {
+			if _, _, _, ok := tab.ActionByName(tab.LR.InitialState, name); ok {
+				t.Fatalf("ACTION entry must not be found; symbol: %v", name)
+			}
+		}
+	})
+}
+
+func TestProductionSet_GetAllSorted(t *testing.T) {
+	src := "a: b c? | d*; b: E; c: F; d: G;"
+
+	parser, err := parser.NewParser(strings.NewReader(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ast, err := parser.Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	gram, err := GenGrammar(ast)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	prods := gram.ProductionSet.getAllSorted()
+	if len(prods) != len(gram.ProductionSet.getAll()) {
+		t.Fatalf("unexpected number of productions; want: %v, got: %v", len(gram.ProductionSet.getAll()), len(prods))
+	}
+	for i := 1; i < len(prods); i++ {
+		if prods[i-1].num >= prods[i].num {
+			t.Fatalf("productions are not sorted; #%v: %v, #%v: %v", i-1, prods[i-1].num, i, prods[i].num)
+		}
+	}
+}
+
+func TestGrammar_Productions(t *testing.T) {
+	gram := genTestGrammar(t, `s: s ADD NUM | NUM | "(" ")" | ;`)
+
+	var texts []string
+	for i, v := range gram.Productions() {
+		if v.Num() != i+ProductionNumStart.Int() {
+			t.Fatalf("unexpected production number; want: %v, got: %v", i+ProductionNumStart.Int(), v.Num())
+		}
+		texts = append(texts, fmt.Sprintf("%v: %v", v.LHS(), strings.Join(v.RHS(), " ")))
+	}
+	expected := []string{
+		"s': s",
+		"s: s ADD NUM",
+		"s: NUM",
+		"s: $0 $1",
+		"s: ",
+	}
+	if strings.Join(texts, "\n") != strings.Join(expected, "\n") {
+		t.Fatalf("unexpected productions;\nwant:\n%v\ngot:\n%v", strings.Join(expected, "\n"), strings.Join(texts, "\n"))
+	}
+
+	v := gram.Productions()[1]
+	v.RHS()[0] = "x"
+	if v.RHS()[0] != "s" {
+		t.Fatalf("modifying the result of RHS must not change the view")
+	}
+}
+
+func TestProductionSet_IDCollision(t *testing.T) {
+	symTab := newSymbolTable()
+	a, _ := symTab.registerNonTerminalSymbol("a")
+	b, _ := symTab.registerTerminalSymbol("B")
+	c, _ := symTab.registerTerminalSymbol("C")
+
+	p1, err := newProduction(a, []Symbol{b}, DefaultMaxRHSLen)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p2, err := newProduction(a, []Symbol{c}, DefaultMaxRHSLen)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Simulate a hash collision.
+	p2.id = p1.id
+
+	prods := newProductionSet()
+	if !prods.append(p1) {
+		t.Fatalf("failed to append a production")
+	}
+	if !prods.append(p2) {
+		t.Fatalf("a production whose ID collided with another one must be appended")
+	}
+	if p1.id == p2.id {
+		t.Fatalf("the collided ID must be replaced; ID: %v", p2.id)
+	}
+	if p, ok := prods.findByID(p2.id); !ok || p != p2 {
+		t.Fatalf("failed to find a production by the replaced ID")
+	}
+
+	p3, err := newProduction(a, []Symbol{b}, DefaultMaxRHSLen)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if prods.append(p3) {
+		t.Fatalf("a duplicate production must not be appended")
+	}
+}
+
+func BenchmarkGenTable(b *testing.B) {
+	gram := genBenchmarkGrammar(b)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, err := GenTable(gram)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkTableGenerator(b *testing.B) {
+	gram := genBenchmarkGrammar(b)
+	g := NewTableGenerator()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, err := g.Generate(gram)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestTableGenerator(t *testing.T) {
+	gram := genTestGrammar(t, "e: e ADD t | t; t: t MUL f | f; f: LPAREN e RPAREN | NUMBER;")
+	tab, err := GenTable(gram)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected, err := GenJSON(gram, tab)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	g := NewTableGenerator()
+	for i := 0; i < 2; i++ {
+		tab, err := g.Generate(gram)
+		if err != nil {
+			t.Fatal(err)
+		}
+		actual, err := GenJSON(gram, tab)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(actual) != string(expected) {
+			t.Fatalf("a table differs from the one of GenTable; run: %v\nwant: %s\ngot: %s", i, expected, actual)
+		}
+	}
+
+	// A generator must not reuse the closures of another grammar.
+	other := genTestGrammar(t, "s: s A | B;")
+	tab, err = g.Generate(other)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tab.NumOfStates() != 4 {
+		t.Fatalf("unexpected number of states; want: 4, got: %v", tab.NumOfStates())
+	}
+}
+
+func TestTable_FirstOfFollowOf(t *testing.T) {
+	_, tab := genTestTable(t, "s: a b C | D; a: A | ; b: B | ;")
+
+	tests := []struct {
+		sym        string
+		first      []string
+		firstEmpty bool
+		follow     []string
+		followEOF  bool
+	}{
+		{sym: "s", first: []string{"C", "D", "A", "B"}, firstEmpty: false, follow: []string{}, followEOF: true},
+		{sym: "a", first: []string{"A"}, firstEmpty: true, follow: []string{"C", "B"}, followEOF: false},
+		{sym: "b", first: []string{"B"}, firstEmpty: true, follow: []string{"C"}, followEOF: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.sym, func(t *testing.T) {
+			first, empty, err := tab.FirstOf(tt.sym)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if strings.Join(first, ",") != strings.Join(tt.first, ",") || empty != tt.firstEmpty {
+				t.Fatalf("unexpected FIRST set; want: %v (empty: %v), got: %v (empty: %v)", tt.first, tt.firstEmpty, first, empty)
+			}
+			follow, eof, err := tab.FollowOf(tt.sym)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if strings.Join(follow, ",") != strings.Join(tt.follow, ",") || eof != tt.followEOF {
+				t.Fatalf("unexpected FOLLOW set; want: %v (eof: %v), got: %v (eof: %v)", tt.follow, tt.followEOF, follow, eof)
+			}
+		})
+	}
+
+	t.Run("an unknown or terminal symbol is an error", func(t *testing.T) {
+		for _, sym := range []string{"unknown", "A"} {
+			if _, _, err := tab.FirstOf(sym); err == nil {
+				t.Fatalf("FirstOf must fail; symbol: %v", sym)
+			}
+			if _, _, err := tab.FollowOf(sym); err == nil {
+				t.Fatalf("FollowOf must fail; symbol: %v", sym)
+			}
+		}
+	})
+}
@@ -0,0 +1,21 @@
+package grammar
+
+const (
+	fnv64Offset = uint64(14695981039346656037)
+	fnv64Prime  = uint64(1099511628211)
+)
+
+// hash64 returns a 64-bit FNV-1a hash of b. IDs based on this hash can collide, so the places that deduplicate
+// values by their IDs must compare the values themselves as well.
+func hash64(b []byte) uint64 {
+	h := fnv64Offset
+	for _, c := range b {
+		h ^= uint64(c)
+		h *= fnv64Prime
+	}
+	return h
+}
+
+func appendUint64(b []byte, v uint64) []byte {
+	return append(b, byte(v>>56), byte(v>>48), byte(v>>40), byte(v>>32), byte(v>>24), byte(v>>16), byte(v>>8), byte(v))
+}
@@ -1,8 +1,6 @@
 package grammar
 
 import (
-	"crypto/sha256"
-	"encoding/binary"
 	"fmt"
 	"io"
 	"sort"
@@ -10,14 +8,10 @@ import (
 	"strings"
 )
 
-type LR0ItemID [32]byte
+type LR0ItemID uint64
 
 func (id LR0ItemID) String() string {
-	return fmt.Sprintf("%x", id.num())
-}
-
-func (id LR0ItemID) num() uint32 {
-	return binary.LittleEndian.Uint32(id[:])
+	return fmt.Sprintf("%016x", uint64(id))
 }
 
 type LR0Item struct {
@@ -57,12 +51,10 @@ func newLR0Item(prod *production, dot int) (*LR0Item, error) {
 
 	var id LR0ItemID
 	{
-		b := []byte{}
-		b = append(b, prod.id[:]...)
-		bDot := make([]byte, 8)
-		binary.LittleEndian.PutUint64(bDot, uint64(dot))
-		b = append(b, bDot...)
-		id = sha256.Sum256(b)
+		b := make([]byte, 0, 16)
+		b = appendUint64(b, uint64(prod.id))
+		b = appendUint64(b, uint64(dot))
+		id = LR0ItemID(hash64(b))
 	}
 
 	dottedSymbol := symbolNil
@@ -98,10 +90,10 @@ func newLR0Item(prod *production, dot int) (*LR0Item, error) {
 	return item, nil
 }
 
-type KernelID [32]byte
+type KernelID uint64
 
 func (id KernelID) String() string {
-	return fmt.Sprintf("%x", binary.LittleEndian.Uint32(id[:]))
+	return fmt.Sprintf("%016x", uint64(id))
 }
 
 type Kernel struct {
@@ -122,6 +114,9 @@ func newKernel(items []*LR0Item) (*Kernel, error) {
 			if !item.kernel {
 				return nil, fmt.Errorf("not a kernel item: %v", item)
 			}
+			if i, ok := m[item.id]; ok && (i.prod != item.prod || i.dot != item.dot) {
+				return nil, fmt.Errorf("LR0 item IDs collided; ID: %v", item.id)
+			}
 			m[item.id] = item
 		}
 		sortedItems = []*LR0Item{}
@@ -129,18 +124,18 @@ func newKernel(items []*LR0Item) (*Kernel, error) {
 			sortedItems = append(sortedItems, item)
 		}
 		sort.Slice(sortedItems, func(i, j int) bool {
-			return sortedItems[i].id.num() < sortedItems[j].id.num()
+			return sortedItems[i].id < sortedItems[j].id
 		})
 	}
 
 	// generate a kernel ID
 	var id KernelID
 	{
-		b := []byte{}
+		b := make([]byte, 0, len(sortedItems)*8)
 		for _, item := range sortedItems {
-			b = append(b, item.id[:]...)
+			b = appendUint64(b, uint64(item.id))
 		}
-		id = sha256.Sum256(b)
+		id = KernelID(hash64(b))
 	}
 
 	return &Kernel{
@@ -149,6 +144,18 @@ func newKernel(items []*LR0Item) (*Kernel, error) {
 	}, nil
 }
 
+func (k *Kernel) hasSameItems(l *Kernel) bool {
+	if len(k.Items) != len(l.Items) {
+		return false
+	}
+	for i, item := range k.Items {
+		if l.Items[i].prod != item.prod || l.Items[i].dot != item.dot {
+			return false
+		}
+	}
+	return true
+}
+
 type StateNum int
 
 const stateNumInitial = StateNum(0)
@@ -170,6 +177,9 @@ type LR0State struct {
 	Num       StateNum
 	Next      map[Symbol]KernelID
 	Reducible map[ProductionID]struct{}
+
+	// Closure is the item set of the state. It consists of the kernel items followed by the non-kernel items.
+	Closure []*LR0Item
 }
 
 type LR0Automaton struct {
@@ -177,7 +187,69 @@ type LR0Automaton struct {
 	states       map[KernelID]*LR0State
 }
 
+func (a *LR0Automaton) getStatesSorted() []*LR0State {
+	states := make([]*LR0State, 0, len(a.states))
+	for _, state := range a.states {
+		states = append(states, state)
+	}
+	sort.Slice(states, func(i, j int) bool {
+		return states[i].Num < states[j].Num
+	})
+	return states
+}
+
+// StatesReducing returns the states that can reduce by the production in ascending order.
+func (a *LR0Automaton) StatesReducing(prodID ProductionID) []StateNum {
+	var nums []StateNum
+	for _, state := range a.getStatesSorted() {
+		if _, ok := state.Reducible[prodID]; ok {
+			nums = append(nums, state.Num)
+		}
+	}
+	return nums
+}
+
+// getShortestPaths returns, for each state, a shortest sequence of symbols leading from the initial state to the
+// state. When there are some shortest sequences, the one consisting of the smallest symbols comes first.
+func (a *LR0Automaton) getShortestPaths() map[StateNum][]Symbol {
+	init := a.states[a.initialState]
+	paths := map[StateNum][]Symbol{
+		init.Num: {},
+	}
+	queue := []*LR0State{init}
+	for len(queue) > 0 {
+		state := queue[0]
+		queue = queue[1:]
+
+		syms := make([]Symbol, 0, len(state.Next))
+		for sym := range state.Next {
+			syms = append(syms, sym)
+		}
+		sort.Slice(syms, func(i, j int) bool {
+			return syms[i] < syms[j]
+		})
+		for _, sym := range syms {
+			next := a.states[state.Next[sym]]
+			if _, ok := paths[next.Num]; ok {
+				continue
+			}
+			path := make([]Symbol, len(paths[state.Num])+1)
+			copy(path, paths[state.Num])
+			path[len(path)-1] = sym
+			paths[next.Num] = path
+			queue = append(queue, next)
+		}
+	}
+	return paths
+}
+
 func genLR0Automaton(prods *productionSet, startSym Symbol) (*LR0Automaton, error) {
+	return genLR0AutomatonWithCache(prods, startSym, newClosureCache(prods))
+}
+
+// genLR0AutomatonWithCache generates an LR0 automaton using closures that cache holds. cache must be created from
+// prods.
+func genLR0AutomatonWithCache(prods *productionSet, startSym Symbol, cache *closureCache) (*LR0Automaton, error) {
 	if !startSym.isStart() {
 		return nil, fmt.Errorf("symbold passed is not start symbol")
 	}
@@ -187,7 +259,7 @@ func genLR0Automaton(prods *productionSet, startSym Symbol) (*LR0Automaton, erro
 	}
 
 	currentState := stateNumInitial
-	knownKernels := map[KernelID]struct{}{}
+	knownKernels := map[KernelID]*Kernel{}
 	uncheckedKernels := []*Kernel{}
 
 	// generate the initial kernel
@@ -203,14 +275,14 @@ func genLR0Automaton(prods *productionSet, startSym Symbol) (*LR0Automaton, erro
 			return nil, err
 		}
 		automaton.initialState = k.ID
-		knownKernels[k.ID] = struct{}{}
+		knownKernels[k.ID] = k
 		uncheckedKernels = append(uncheckedKernels, k)
 	}
 
 	for len(uncheckedKernels) > 0 {
 		nextUncheckedKernels := []*Kernel{}
 		for _, k := range uncheckedKernels {
-			state, neighbours, err := genStateAndNeighbourKernels(k, prods)
+			state, neighbours, err := genStateAndNeighbourKernels(k, prods, cache)
 			if err != nil {
 				return nil, err
 			}
@@ -220,10 +292,13 @@ func genLR0Automaton(prods *productionSet, startSym Symbol) (*LR0Automaton, erro
 			automaton.states[state.ID] = state
 
 			for _, k := range neighbours {
-				if _, known := knownKernels[k.ID]; known {
+				if known, ok := knownKernels[k.ID]; ok {
+					if !known.hasSameItems(k) {
+						return nil, fmt.Errorf("kernel IDs collided; ID: %v", k.ID)
+					}
 					continue
 				}
-				knownKernels[k.ID] = struct{}{}
+				knownKernels[k.ID] = k
 				nextUncheckedKernels = append(nextUncheckedKernels, k)
 			}
 		}
@@ -233,8 +308,8 @@ func genLR0Automaton(prods *productionSet, startSym Symbol) (*LR0Automaton, erro
 	return automaton, nil
 }
 
-func genStateAndNeighbourKernels(kernel *Kernel, prods *productionSet) (*LR0State, []*Kernel, error) {
-	items, err := genClosure(kernel, prods)
+func genStateAndNeighbourKernels(kernel *Kernel, prods *productionSet, cache *closureCache) (*LR0State, []*Kernel, error) {
+	items, err := genClosure(kernel, cache)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -261,40 +336,113 @@ func genStateAndNeighbourKernels(kernel *Kernel, prods *productionSet) (*LR0Stat
 		Kernel:    kernel,
 		Next:      next,
 		Reducible: reducible,
+		Closure:   items,
 	}, kernels, nil
 }
 
-func genClosure(kernel *Kernel, prods *productionSet) ([]*LR0Item, error) {
+func genClosure(kernel *Kernel, cache *closureCache) ([]*LR0Item, error) {
 	items := []*LR0Item{}
-	knownItems := map[LR0ItemID]struct{}{}
-	uncheckedItems := []*LR0Item{}
 	for _, item := range kernel.Items {
 		items = append(items, item)
-		uncheckedItems = append(uncheckedItems, item)
 	}
-	for len(uncheckedItems) > 0 {
-		nextUncheckedItems := []*LR0Item{}
-		for _, item := range uncheckedItems {
-			if item.dottedSymbol.isTerminal() {
+
+	syms := []Symbol{}
+	knownSyms := map[Symbol]struct{}{}
+	for _, item := range kernel.Items {
+		if !item.dottedSymbol.isNonTerminal() {
+			continue
+		}
+		if _, known := knownSyms[item.dottedSymbol]; known {
+			continue
+		}
+		knownSyms[item.dottedSymbol] = struct{}{}
+		syms = append(syms, item.dottedSymbol)
+	}
+
+	// The cached items are non-kernel items, so they never duplicate the kernel items.
+	if len(syms) == 1 {
+		cItems, err := cache.get(syms[0])
+		if err != nil {
+			return nil, err
+		}
+		return append(items, cItems...), nil
+	}
+
+	knownItems := map[*LR0Item]struct{}{}
+	for _, sym := range syms {
+		cItems, err := cache.get(sym)
+		if err != nil {
+			return nil, err
+		}
+		for _, item := range cItems {
+			if _, exist := knownItems[item]; exist {
 				continue
 			}
+			items = append(items, item)
+			knownItems[item] = struct{}{}
+		}
+	}
+
+	return items, nil
+}
+
+// closureCache memoizes the non-kernel items that each non-terminal symbol derives.
+// The items depend only on the dotted symbol, so states sharing the symbol reuse them.
+type closureCache struct {
+	prods *productionSet
+	items map[Symbol][]*LR0Item
+
+	// initialItems holds items whose dot is 0 so that the cached item lists share the same instances.
+	initialItems map[ProductionID]*LR0Item
+}
+
+func newClosureCache(prods *productionSet) *closureCache {
+	return &closureCache{
+		prods:        prods,
+		items:        map[Symbol][]*LR0Item{},
+		initialItems: map[ProductionID]*LR0Item{},
+	}
+}
+
+func (c *closureCache) get(sym Symbol) ([]*LR0Item, error) {
+	if items, ok := c.items[sym]; ok {
+		return items, nil
+	}
 
-			ps, _ := prods.findByLHS(item.dottedSymbol)
+	items := []*LR0Item{}
+	knownSyms := map[Symbol]struct{}{
+		sym: {},
+	}
+	uncheckedSyms := []Symbol{sym}
+	for len(uncheckedSyms) > 0 {
+		nextUncheckedSyms := []Symbol{}
+		for _, sym := range uncheckedSyms {
+			ps, _ := c.prods.findByLHS(sym)
 			for _, prod := range ps {
-				item, err := newLR0Item(prod, 0)
-				if err != nil {
-					return nil, err
+				item, ok := c.initialItems[prod.id]
+				if !ok {
+					var err error
+					item, err = newLR0Item(prod, 0)
+					if err != nil {
+						return nil, err
+					}
+					c.initialItems[prod.id] = item
+				}
+				items = append(items, item)
+
+				if !item.dottedSymbol.isNonTerminal() {
+					continue
 				}
-				if _, exist := knownItems[item.id]; exist {
+				if _, known := knownSyms[item.dottedSymbol]; known {
 					continue
 				}
-				items = append(items, item)
-				knownItems[item.id] = struct{}{}
-				nextUncheckedItems = append(nextUncheckedItems, item)
+				knownSyms[item.dottedSymbol] = struct{}{}
+				nextUncheckedSyms = append(nextUncheckedSyms, item.dottedSymbol)
 			}
 		}
-		uncheckedItems = nextUncheckedItems
+		uncheckedSyms = nextUncheckedSyms
 	}
+	c.items[sym] = items
 
 	return items, nil
 }
@@ -344,17 +492,35 @@ func genNeighbourKernels(items []*LR0Item, prods *productionSet) ([]*neighbourKe
 	return kernels, nil
 }
 
+func lr0ItemText(item *LR0Item, prods *productionSet, symTab *SymbolTable) (string, error) {
+	prod, ok := prods.findByID(item.prod)
+	if !ok {
+		return "", fmt.Errorf("production was not found; production: %v", item.prod)
+	}
+
+	var b strings.Builder
+	lhs, _ := symTab.ToText(prod.lhs)
+	fmt.Fprintf(&b, "%v →", lhs)
+	for i := 0; i < prod.rhsLen; i++ {
+		rhs, _ := symTab.ToText(prod.rhs[i])
+		if i == item.dot {
+			fmt.Fprintf(&b, "・%v", rhs)
+		} else {
+			fmt.Fprintf(&b, " %v", rhs)
+		}
+	}
+	if item.reducible {
+		fmt.Fprintf(&b, "・")
+	}
+	return b.String(), nil
+}
+
 func PrintLR0Automaton(w io.Writer, automaton *LR0Automaton, prods *productionSet, symTab *SymbolTable) {
 	if w == nil {
 		return
 	}
 
-	sortedStates := make([]*LR0State, len(automaton.states))
-	for _, state := range automaton.states {
-		sortedStates[state.Num] = state
-	}
-
-	for _, state := range sortedStates {
+	for _, state := range automaton.getStatesSorted() {
 		var b strings.Builder
 		if state.ID == automaton.initialState {
 			fmt.Fprintf(&b, "#%v (initial):\n", state.Num)
@@ -364,33 +530,121 @@ func PrintLR0Automaton(w io.Writer, automaton *LR0Automaton, prods *productionSe
 		fmt.Fprintf(&b, "  ID: %v\n", state.ID)
 		fmt.Fprintf(&b, "  Kernel:\n")
 		for _, kItem := range state.Items {
-			prod, _ := prods.findByID(kItem.prod)
-			lhs, _ := symTab.ToText(prod.lhs)
-			fmt.Fprintf(&b, "    %v →", lhs)
-			for i := 0; i < prod.rhsLen; i++ {
-				rhs, _ := symTab.ToText(prod.rhs[i])
-				if i == kItem.dot {
-					fmt.Fprintf(&b, "・%v", rhs)
-				} else {
-					fmt.Fprintf(&b, " %v", rhs)
-				}
-			}
-			if kItem.reducible {
-				fmt.Fprintf(&b, "・")
+			text, _ := lr0ItemText(kItem, prods, symTab)
+			fmt.Fprintf(&b, "    %v (%v)\n", text, kItem.id)
+		}
+		fmt.Fprintf(&b, "  Non-Kernel:\n")
+		for _, item := range state.Closure {
+			if item.kernel {
+				continue
 			}
-			fmt.Fprintf(&b, " (%v)\n", kItem.id)
+			text, _ := lr0ItemText(item, prods, symTab)
+			fmt.Fprintf(&b, "    %v (%v)\n", text, item.id)
 		}
 		fmt.Fprintf(&b, "  Next:\n")
-		for sym, kID := range state.Next {
+		var nextSyms []Symbol
+		for sym := range state.Next {
+			nextSyms = append(nextSyms, sym)
+		}
+		sort.Slice(nextSyms, func(i, j int) bool {
+			return nextSyms[i] < nextSyms[j]
+		})
+		for _, sym := range nextSyms {
 			symText, _ := symTab.ToText(sym)
-			nextState := automaton.states[kID]
+			nextState := automaton.states[state.Next[sym]]
 			fmt.Fprintf(&b, "    %v → %v\n", symText, nextState.Num)
 		}
 		fmt.Fprintf(&b, "  Reducible:\n")
+		var reducibleProds []*production
 		for prodID := range state.Reducible {
 			prod, _ := prods.findByID(prodID)
+			reducibleProds = append(reducibleProds, prod)
+		}
+		sort.Slice(reducibleProds, func(i, j int) bool {
+			return reducibleProds[i].num < reducibleProds[j].num
+		})
+		for _, prod := range reducibleProds {
 			fmt.Fprintf(&b, "    %v\n", prod.num)
 		}
 		w.Write([]byte(b.String()))
 	}
 }
+
+// WriteAutomatonCanonical writes the automaton in a form stable enough for golden files. The states are written in
+// the order of their numbers, the kernel and the non-kernel items of each state in the order of their IDs, the
+// transitions in the order of their symbols, and the reducible productions in the order of their numbers. Unlike
+// PrintLR0Automaton, it omits the IDs of the kernels and the items, which are hashes meaningless to a reader.
+func WriteAutomatonCanonical(w io.Writer, automaton *LR0Automaton, prods *productionSet, symTab *SymbolTable) error {
+	for _, state := range automaton.getStatesSorted() {
+		var b strings.Builder
+		if state.ID == automaton.initialState {
+			fmt.Fprintf(&b, "state %v (initial)\n", state.Num)
+		} else {
+			fmt.Fprintf(&b, "state %v\n", state.Num)
+		}
+
+		var kItems, nItems []*LR0Item
+		kItems = append(kItems, state.Items...)
+		for _, item := range state.Closure {
+			if !item.kernel {
+				nItems = append(nItems, item)
+			}
+		}
+		for _, items := range [][]*LR0Item{kItems, nItems} {
+			sort.Slice(items, func(i, j int) bool {
+				return items[i].id < items[j].id
+			})
+		}
+		fmt.Fprintf(&b, "  kernel:\n")
+		for _, item := range kItems {
+			text, err := lr0ItemText(item, prods, symTab)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(&b, "    %v\n", text)
+		}
+		fmt.Fprintf(&b, "  non-kernel:\n")
+		for _, item := range nItems {
+			text, err := lr0ItemText(item, prods, symTab)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(&b, "    %v\n", text)
+		}
+
+		fmt.Fprintf(&b, "  next:\n")
+		var nextSyms []Symbol
+		for sym := range state.Next {
+			nextSyms = append(nextSyms, sym)
+		}
+		sort.Slice(nextSyms, func(i, j int) bool {
+			return nextSyms[i] < nextSyms[j]
+		})
+		for _, sym := range nextSyms {
+			symText, _ := symTab.ToText(sym)
+			fmt.Fprintf(&b, "    %v → %v\n", symText, automaton.states[state.Next[sym]].Num)
+		}
+
+		fmt.Fprintf(&b, "  reducible:\n")
+		var reducibleProds []*production
+		for prodID := range state.Reducible {
+			prod, ok := prods.findByID(prodID)
+			if !ok {
+				return fmt.Errorf("production was not found; production: %v", prodID)
+			}
+			reducibleProds = append(reducibleProds, prod)
+		}
+		sort.Slice(reducibleProds, func(i, j int) bool {
+			return reducibleProds[i].num < reducibleProds[j].num
+		})
+		for _, prod := range reducibleProds {
+			fmt.Fprintf(&b, "    #%v %v\n", prod.num, productionText(prod, symTab))
+		}
+
+		_, err := io.WriteString(w, b.String())
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
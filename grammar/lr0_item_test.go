@@ -245,8 +245,254 @@ func TestGenLR0Automaton(t *testing.T) {
 	PrintLR0Automaton(os.Stdout, automaton, gram.ProductionSet, gram.SymbolTable)
 }
 
+func TestLR0State_Closure(t *testing.T) {
+	gram := genTestGrammar(t, "e: e ADD t | t; t: t MUL f | f; f: LPAREN e RPAREN | NUMBER;")
+	automaton, err := genLR0Automaton(gram.ProductionSet, gram.AugmentedStartSymbol)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	genSym := newTestSymbolGenerator(t, gram.SymbolTable)
+	genProd := newTestProductionGenerator(t, genSym)
+	genLR0Item := newTestLR0ItemGenerator(t, genProd)
+
+	expectedKernel := []*LR0Item{
+		genLR0Item("e'", 0, "e"),
+	}
+	expectedNonKernel := []*LR0Item{
+		genLR0Item("e", 0, "e", "ADD", "t"),
+		genLR0Item("e", 0, "t"),
+		genLR0Item("t", 0, "t", "MUL", "f"),
+		genLR0Item("t", 0, "f"),
+		genLR0Item("f", 0, "LPAREN", "e", "RPAREN"),
+		genLR0Item("f", 0, "NUMBER"),
+	}
+
+	state := automaton.states[automaton.initialState]
+	if len(state.Closure) != len(expectedKernel)+len(expectedNonKernel) {
+		t.Fatalf("unexpected number of closure items; want: %v, got: %v", len(expectedKernel)+len(expectedNonKernel), len(state.Closure))
+	}
+	for i, eItem := range expectedKernel {
+		if state.Closure[i].id != eItem.id || !state.Closure[i].kernel {
+			t.Fatalf("the closure must start with the kernel items; want: %v, got: %v", eItem.id, state.Closure[i].id)
+		}
+	}
+	ids := map[LR0ItemID]bool{}
+	for _, item := range state.Closure[len(expectedKernel):] {
+		ids[item.id] = item.kernel
+	}
+	for _, eItem := range expectedNonKernel {
+		kernel, ok := ids[eItem.id]
+		if !ok {
+			t.Fatalf("a non-kernel item was not found; item: %v", eItem.id)
+		}
+		if kernel {
+			t.Fatalf("the item must be a non-kernel item; item: %v", eItem.id)
+		}
+	}
+
+	var b strings.Builder
+	PrintLR0Automaton(&b, automaton, gram.ProductionSet, gram.SymbolTable)
+	if !strings.Contains(b.String(), "  Non-Kernel:\n    e →・e ADD t") {
+		t.Fatalf("the output must contain the non-kernel items:\n%v", b.String())
+	}
+}
+
 type expectedLR0State struct {
 	kernelItems    []*LR0Item
 	nextStates     map[Symbol][]*LR0Item
 	reducibleProds []*production
 }
+
+// genBenchmarkGrammarSource returns a grammar of 3*n+1 productions. Every state that has a0 in its closure has to
+// expand all the non-terminals a0 to a<n-1>.
+func genBenchmarkGrammarSource(n int) string {
+	var src strings.Builder
+	for i := 0; i < n; i++ {
+		fmt.Fprintf(&src, "a%v: a%v B%v | C%v a%v | D%v;\n", i, i+1, i, i, i+1, i)
+	}
+	fmt.Fprintf(&src, "a%v: E;\n", n)
+	return src.String()
+}
+
+// genBenchmarkGrammar returns the grammar of genBenchmarkGrammarSource(300), which consists of 901 productions.
+func genBenchmarkGrammar(b *testing.B) *Grammar {
+	b.Helper()
+
+	psr, err := parser.NewParser(strings.NewReader(genBenchmarkGrammarSource(300)))
+	if err != nil {
+		b.Fatal(err)
+	}
+	ast, err := psr.Parse()
+	if err != nil {
+		b.Fatal(err)
+	}
+	gram, err := GenGrammar(ast)
+	if err != nil {
+		b.Fatal(err)
+	}
+	return gram
+}
+
+func BenchmarkGenLR0Automaton(b *testing.B) {
+	gram := genBenchmarkGrammar(b)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, err := genLR0Automaton(gram.ProductionSet, gram.AugmentedStartSymbol)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestLR0Automaton_ShortestPaths(t *testing.T) {
+	gram := genTestGrammar(t, "e: e ADD t | t; t: t MUL f | f; f: LPAREN e RPAREN | NUMBER;")
+	automaton, err := genLR0Automaton(gram.ProductionSet, gram.AugmentedStartSymbol)
+	if err != nil {
+		t.Fatal(err)
+	}
+	genSym := newTestSymbolGenerator(t, gram.SymbolTable)
+
+	paths := automaton.getShortestPaths()
+	if len(paths) != len(automaton.states) {
+		t.Fatalf("every state must have a path; want: %v, got: %v", len(automaton.states), len(paths))
+	}
+	for _, state := range automaton.states {
+		path, ok := paths[state.Num]
+		if !ok {
+			t.Fatalf("a path was not found; state: %v", state.Num)
+		}
+		kID := automaton.initialState
+		for _, sym := range path {
+			kID, ok = automaton.states[kID].Next[sym]
+			if !ok {
+				t.Fatalf("the path must follow transitions; state: %v, path: %v", state.Num, path)
+			}
+		}
+		if kID != state.ID {
+			t.Fatalf("the path must lead to the state; state: %v, path: %v", state.Num, path)
+		}
+	}
+
+	// Both `t MUL` and `e ADD t MUL` lead to the state having `t → t MUL・f`.
+	kID := automaton.initialState
+	for _, text := range []string{"e", "ADD", "t", "MUL"} {
+		kID = automaton.states[kID].Next[genSym(text)]
+	}
+	path := paths[automaton.states[kID].Num]
+	if len(path) != 2 || path[0] != genSym("t") || path[1] != genSym("MUL") {
+		t.Fatalf("unexpected path; want: t MUL, got: %v", path)
+	}
+}
+
+func TestLR0Automaton_StatesReducing(t *testing.T) {
+	gram := genTestGrammar(t, "s: a X | b Y | X a Z; a: A; b: A;")
+	automaton, err := genLR0Automaton(gram.ProductionSet, gram.AugmentedStartSymbol)
+	if err != nil {
+		t.Fatal(err)
+	}
+	genSym := newTestSymbolGenerator(t, gram.SymbolTable)
+	genProd := newTestProductionGenerator(t, genSym)
+
+	// `a: A` is reduced in the state after A and the one after X A.
+	prod := genProd("a", "A")
+	var expected []StateNum
+	for _, path := range [][]string{{"A"}, {"X", "A"}} {
+		kID := automaton.initialState
+		for _, text := range path {
+			kID = automaton.states[kID].Next[genSym(text)]
+		}
+		expected = append(expected, automaton.states[kID].Num)
+	}
+	if expected[0] > expected[1] {
+		expected[0], expected[1] = expected[1], expected[0]
+	}
+	nums := automaton.StatesReducing(prod.id)
+	if fmt.Sprint(nums) != fmt.Sprint(expected) {
+		t.Fatalf("unexpected states; want: %v, got: %v", expected, nums)
+	}
+
+	if nums := automaton.StatesReducing(genProd("s", "X", "a", "Z").id); len(nums) != 1 {
+		t.Fatalf("unexpected states; want: 1 state, got: %v", nums)
+	}
+	if nums := automaton.StatesReducing(ProductionID(0)); len(nums) != 0 {
+		t.Fatalf("an unknown production must have no states; got: %v", nums)
+	}
+}
+
+func TestWriteAutomatonCanonical(t *testing.T) {
+	src := "s: A s | B;"
+	gram, tab := genTestTable(t, src)
+	var b strings.Builder
+	err := WriteAutomatonCanonical(&b, tab.LR0Automaton, gram.ProductionSet, gram.SymbolTable)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `state 0 (initial)
+  kernel:
+    s' →・s
+  non-kernel:
+    s →・A s
+    s →・B
+  next:
+    s → 1
+    A → 2
+    B → 3
+  reducible:
+state 1
+  kernel:
+    s' → s・
+  non-kernel:
+  next:
+  reducible:
+    #1 s': s
+state 2
+  kernel:
+    s → A・s
+  non-kernel:
+    s →・A s
+    s →・B
+  next:
+    s → 4
+    A → 2
+    B → 3
+  reducible:
+state 3
+  kernel:
+    s → B・
+  non-kernel:
+  next:
+  reducible:
+    #3 s: B
+state 4
+  kernel:
+    s → A s・
+  non-kernel:
+  next:
+  reducible:
+    #2 s: A s
+`
+	if b.String() != expected {
+		t.Fatalf("unexpected output;\nwant:\n%v\ngot:\n%v", expected, b.String())
+	}
+
+	// The output doesn't depend on the iteration order of the maps in the automaton.
+	for i := 0; i < 10; i++ {
+		gram, tab := genTestTable(t, mediumGrammarSource)
+		var first strings.Builder
+		err := WriteAutomatonCanonical(&first, tab.LR0Automaton, gram.ProductionSet, gram.SymbolTable)
+		if err != nil {
+			t.Fatal(err)
+		}
+		gram2, tab2 := genTestTable(t, mediumGrammarSource)
+		var second strings.Builder
+		err = WriteAutomatonCanonical(&second, tab2.LR0Automaton, gram2.ProductionSet, gram2.SymbolTable)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if first.String() != second.String() {
+			t.Fatalf("the output must be deterministic")
+		}
+	}
+}
@@ -0,0 +1,38 @@
+package grammar
+
+// Nullable returns whether each non-terminal symbol can derive the empty string.
+// The returned map contains all symbols appearing on the LHS of the productions.
+func Nullable(prods *productionSet) map[Symbol]bool {
+	nullable := map[Symbol]bool{}
+	for _, prod := range prods.getAll() {
+		nullable[prod.lhs] = false
+	}
+
+	for {
+		more := false
+		for _, prod := range prods.getAll() {
+			if nullable[prod.lhs] {
+				continue
+			}
+			if !isNullableSequence(nullable, prod.rhs) {
+				continue
+			}
+			nullable[prod.lhs] = true
+			more = true
+		}
+		if !more {
+			break
+		}
+	}
+
+	return nullable
+}
+
+func isNullableSequence(nullable map[Symbol]bool, syms []Symbol) bool {
+	for _, sym := range syms {
+		if sym.isTerminal() || !nullable[sym] {
+			return false
+		}
+	}
+	return true
+}
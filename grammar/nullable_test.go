@@ -0,0 +1,90 @@
+package grammar
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/nihei9/9gram/parser"
+)
+
+func TestNullable(t *testing.T) {
+	tests := []struct {
+		caption  string
+		src      string
+		nullable []string
+	}{
+		{
+			caption:  "productions contain only non-empty productions",
+			src:      "e: e PLUS t | t; t: t STAR f | f; f: LPAREN e RPAREN | NUMBER;",
+			nullable: []string{},
+		},
+		{
+			caption:  "productions contain the empty start production",
+			src:      "s: ;",
+			nullable: []string{"s'", "s"},
+		},
+		{
+			caption:  "nullability propagates through a chain of non-terminals",
+			src:      "s: a b; a: b b; b: c | B; c: ;",
+			nullable: []string{"s'", "s", "a", "b", "c"},
+		},
+		{
+			caption:  "a terminal symbol stops nullability",
+			src:      "s: a B; a: ;",
+			nullable: []string{"a"},
+		},
+		{
+			caption:  "a left-recursive non-terminal without an empty base is not nullable",
+			src:      "s: s A | a; a: a B;",
+			nullable: []string{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.caption, func(t *testing.T) {
+			parser, err := parser.NewParser(strings.NewReader(tt.src))
+			if err != nil {
+				t.Fatal(err)
+			}
+			ast, err := parser.Parse()
+			if err != nil {
+				t.Fatal(err)
+			}
+			gram, err := GenGrammar(ast)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			nullable := Nullable(gram.ProductionSet)
+
+			expected := map[Symbol]struct{}{}
+			for _, text := range tt.nullable {
+				sym, ok := gram.SymbolTable.ToSymbol(text)
+				if !ok {
+					t.Fatalf("a symbol was not found; symbol: %v", text)
+				}
+				expected[sym] = struct{}{}
+			}
+			for _, prod := range gram.ProductionSet.getAll() {
+				_, eNullable := expected[prod.lhs]
+				aNullable, ok := nullable[prod.lhs]
+				if !ok {
+					t.Fatalf("a non-terminal symbol is missing; symbol: %v", prod.lhs)
+				}
+				if aNullable != eNullable {
+					text, _ := gram.SymbolTable.ToText(prod.lhs)
+					t.Fatalf("nullability is mismatched; symbol: %v, want: %v, got: %v", text, eNullable, aNullable)
+				}
+			}
+
+			fst, err := genFirst(gram.ProductionSet)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for sym, n := range nullable {
+				if fst.getBySymbol(sym).empty != n {
+					t.Fatalf("nullability is inconsistent with the FIRST set; symbol: %v", sym)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,423 @@
+package grammar
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/nihei9/9gram/parser"
+	"gopkg.in/yaml.v2"
+)
+
+type outputConfig struct {
+	grammarSource *string
+	compress      bool
+}
+
+type OutputOption func(*outputConfig)
+
+// WithGrammarSource embeds the grammar source in the output as the grammar_source field.
+func WithGrammarSource(src string) OutputOption {
+	return func(c *outputConfig) {
+		c.grammarSource = &src
+	}
+}
+
+// SerializedTableVersion is the version of the layout of SerializedTable. Bump it whenever the layout changes
+// incompatibly.
+const SerializedTableVersion = 2
+
+// SerializedRowDisplacedTable is a table compressed with row displacement. States sharing identical rows are
+// mapped to one row by Row. The entry (state, col) is Next[Base[r]+col] when Check[Base[r]+col] is r; otherwise it
+// is Default[r], where r is Row[state].
+type SerializedRowDisplacedTable struct {
+	Row     []int   `json:"row" yaml:"row"`
+	Base    []int   `json:"base" yaml:"base"`
+	Default []int64 `json:"default" yaml:"default"`
+	Next    []int64 `json:"next" yaml:"next"`
+	Check   []int   `json:"check" yaml:"check"`
+}
+
+// SerializedOrigin is what a generated non-terminal symbol expands. See SyntheticOrigin. Symbol is the
+// number of a terminal symbol when Terminal is true, or of a non-terminal symbol otherwise.
+type SerializedOrigin struct {
+	Kind     SyntheticKind `json:"kind" yaml:"kind"`
+	Symbol   int           `json:"symbol" yaml:"symbol"`
+	Terminal bool          `json:"terminal" yaml:"terminal"`
+}
+
+// SerializedTable is the parsing table that GenJSON and GenYAML emit, which is the layout of the JSON and YAML outputs.
+//
+// An ACTION entry is 0 for an error, a negative number -N for shifting to state N, AcceptAction for accepting the
+// input, or another positive number N for reducing by production N. A GOTO entry is 0 for an error or N for going to
+// state N.
+type SerializedTable struct {
+	Version          int                          `json:"version" yaml:"version"`
+	Action           []int32                      `json:"action,omitempty" yaml:"action,omitempty"`
+	GoTo             []uint32                     `json:"goto,omitempty" yaml:"goto,omitempty"`
+	CompressedAction *SerializedRowDisplacedTable `json:"compressed_action,omitempty" yaml:"compressed_action,omitempty"`
+	CompressedGoTo   *SerializedRowDisplacedTable `json:"compressed_goto,omitempty" yaml:"compressed_goto,omitempty"`
+	StateCount       int                          `json:"state_count" yaml:"state_count"`
+	InitialState     StateNum                     `json:"initial_state" yaml:"initial_state"`
+	AcceptAction     int32                        `json:"accept_action" yaml:"accept_action"`
+
+	// EOFMode tells whether the accept actions may appear on other than EOF. See EOFModeImplicit.
+	EOFMode EOFMode `json:"eof_mode" yaml:"eof_mode"`
+
+	// ExpectedTokens holds, for each state, the terminal symbols that have non-error actions, which a driver can report
+	// on a syntax error.
+	ExpectedTokens [][]int `json:"expected_tokens" yaml:"expected_tokens"`
+
+	// DefaultActions holds, for each state, the production that every reduce action in the state reduces by, or 0 when
+	// the state has no such production. A driver can store the reduce actions of a state as the default. A state having
+	// an error entry made by `%nonassoc` has no default so that the error isn't overlooked.
+	DefaultActions []int `json:"default_actions" yaml:"default_actions"`
+
+	// ReduceOnlyStates holds, for each state, the production when every non-error action of the state reduces by it,
+	// or 0. A non-zero entry always equals the one of DefaultActions; the difference is that a state having shift or
+	// accept actions can have a default but is never reduce-only. A driver can check this before looking up the action
+	// table and, on a non-zero entry, reduce by the production whatever the lookahead is. The compressed action table
+	// already returns the reduce action on any terminal symbol in such states, while the dense action table keeps
+	// their error entries, with which a driver that looks them up detects a syntax error before the reduction. A state
+	// having an error entry made by `%nonassoc` is never such a state.
+	ReduceOnlyStates []int `json:"reduce_only_states" yaml:"reduce_only_states"`
+
+	StartProduction         int      `json:"start_production" yaml:"start_production"`
+	HeadSymbols             []int    `json:"head_symbols" yaml:"head_symbols"`
+	AlternativeLabels       []string `json:"alternative_labels" yaml:"alternative_labels"`
+	AlternativeSymbolCounts []int    `json:"alternative_symbol_counts" yaml:"alternative_symbol_counts"`
+
+	// Actions holds the code of the action of each production without the braces, or an empty string when the
+	// production has no action. 9gram passes the code through as it is.
+	Actions []string `json:"actions" yaml:"actions"`
+
+	// ProductionTexts holds the text of each production like `expr → expr ADD term` so that a driver can log it.
+	ProductionTexts []string `json:"production_texts" yaml:"production_texts"`
+
+	EOFSymbol       int            `json:"eof_symbol" yaml:"eof_symbol"`
+	TerminalSymbols []string       `json:"terminal_symbols" yaml:"terminal_symbols"`
+	LiteralSymbols  map[string]int `json:"literal_symbols" yaml:"literal_symbols"`
+
+	// TerminalSymbolPatterns holds the pattern of each terminal symbol. The patterns are normalized already, so a lexer
+	// can use them as they are. See Grammar.Patterns.
+	TerminalSymbolPatterns []string `json:"terminal_symbol_patterns" yaml:"terminal_symbol_patterns"`
+
+	// TerminalCaseInsensitive tells, for each terminal symbol, whether a lexer should fold the case of the input when
+	// matching the pattern.
+	TerminalCaseInsensitive []bool `json:"terminal_case_insensitive" yaml:"terminal_case_insensitive"`
+
+	// TerminalModes holds, for each terminal symbol, the modes of a lexer that `%mode` annotates the lexeme with, or an
+	// empty list.
+	TerminalModes [][]string `json:"terminal_modes" yaml:"terminal_modes"`
+
+	TerminalSymbolCount    int      `json:"terminal_symbol_count" yaml:"terminal_symbol_count"`
+	UnusedTerminalSymbols  []int    `json:"unused_terminal_symbols" yaml:"unused_terminal_symbols"`
+	NonTerminalSymbols     []string `json:"non_terminal_symbols" yaml:"non_terminal_symbols"`
+	NonTerminalSymbolCount int      `json:"non_terminal_symbol_count" yaml:"non_terminal_symbol_count"`
+
+	// NonTerminalOrigins maps the numbers of the generated non-terminal symbols like `$$0` to what they expand. A
+	// driver can use it to hide the generated symbols from its AST.
+	NonTerminalOrigins map[int]SerializedOrigin `json:"non_terminal_origins" yaml:"non_terminal_origins"`
+
+	GrammarHash   string  `json:"grammar_hash" yaml:"grammar_hash"`
+	GrammarSource *string `json:"grammar_source,omitempty" yaml:"grammar_source,omitempty"`
+}
+
+// WithCompressedTable replaces the action and goto fields with the compressed_action and compressed_goto fields
+// compressed with row displacement.
+func WithCompressedTable() OutputOption {
+	return func(c *outputConfig) {
+		c.compress = true
+	}
+}
+
+func GenJSON(gram *Grammar, tab *Table, opts ...OutputOption) ([]byte, error) {
+	var b bytes.Buffer
+	err := WriteJSON(&b, gram, tab, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(b.Bytes(), []byte("\n")), nil
+}
+
+// WriteJSON writes the same output as GenJSON to w followed by a new line. It encodes the table directly into w
+// instead of building the whole output in memory.
+func WriteJSON(w io.Writer, gram *Grammar, tab *Table, opts ...OutputOption) error {
+	t, err := genSerializedTable(gram, tab, opts...)
+	if err != nil {
+		return err
+	}
+	return json.NewEncoder(w).Encode(t)
+}
+
+// DecodeTable decodes a JSON output of GenJSON. It rejects outputs of unknown versions.
+func DecodeTable(d []byte) (*SerializedTable, error) {
+	var t SerializedTable
+	err := json.Unmarshal(d, &t)
+	if err != nil {
+		return nil, err
+	}
+	if t.Version != SerializedTableVersion {
+		return nil, fmt.Errorf("unsupported table version: %v; supported version: %v", t.Version, SerializedTableVersion)
+	}
+	return &t, nil
+}
+
+func GenYAML(gram *Grammar, tab *Table, opts ...OutputOption) ([]byte, error) {
+	t, err := genSerializedTable(gram, tab, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return yaml.Marshal(t)
+}
+
+func genSerializedTable(gram *Grammar, tab *Table, opts ...OutputOption) (*SerializedTable, error) {
+	config := &outputConfig{}
+	for _, opt := range opts {
+		opt(config)
+	}
+
+	prods := gram.ProductionSet.getAllSorted()
+	headSyms := make([]int, len(prods)+1)
+	altSymCounts := make([]int, len(prods)+1)
+	altLabels := make([]string, len(prods)+1)
+	actions := make([]string, len(prods)+1)
+	prodTexts := make([]string, len(prods)+1)
+	for _, p := range prods {
+		headSyms[p.num] = p.lhs.Num().Int()
+		altSymCounts[p.num] = p.rhsLen
+		altLabels[p.num] = p.label
+		actions[p.num] = p.action
+		prodTexts[p.num] = formatProduction(p, gram.SymbolTable, " →", " ")
+	}
+
+	tsymCount := gram.SymbolTable.getNumOfTerminalSymbols()
+	tsyms := make([]string, tsymCount)
+	patterns := make([]string, tsymCount)
+	caseInsensitive := make([]bool, tsymCount)
+	modes := make([][]string, tsymCount)
+	for num := range modes {
+		modes[num] = []string{}
+	}
+	literals := map[string]int{}
+	for num := terminalSymbolNumMin.Int(); num < tsymCount; num++ {
+		text, err := gram.SymbolTable.ToTextFromNumT(SymbolNum(num))
+		if err != nil {
+			return nil, err
+		}
+		tsyms[num] = text
+		pat, ok := gram.Patterns[SymbolNum(num)]
+		if !ok {
+			continue
+		}
+		patterns[num] = pat
+		literals[pat] = num
+		_, caseInsensitive[num] = gram.CaseInsensitiveTerminals[SymbolNum(num)]
+		if m, ok := gram.TerminalModes[SymbolNum(num)]; ok {
+			modes[num] = m
+		}
+	}
+	var unusedTSyms []int
+	for _, num := range findUnusedTerminalSymbols(gram) {
+		unusedTSyms = append(unusedTSyms, num.Int())
+	}
+
+	nsymCount := gram.SymbolTable.getNumOfNonTerminalSymbols()
+	nsyms := make([]string, nsymCount)
+	origins := map[int]SerializedOrigin{}
+	// nonTerminalSymbolNumMin represents the augmented start symbol.
+	for num := nonTerminalSymbolNumMin.Int() + 1; num < nsymCount; num++ {
+		text, err := gram.SymbolTable.ToTextFromNumN(SymbolNum(num))
+		if err != nil {
+			return nil, err
+		}
+		nsyms[num] = text
+		if origin, ok := gram.SyntheticOrigins[SymbolNum(num)]; ok {
+			origins[num] = SerializedOrigin{
+				Kind:     origin.Kind,
+				Symbol:   origin.Symbol.Num().Int(),
+				Terminal: origin.Symbol.isTerminal(),
+			}
+		}
+	}
+
+	action := make([]int32, len(tab.LR.actionTable))
+	for i, e := range tab.LR.actionTable {
+		action[i] = int32(e)
+	}
+	goTo := make([]uint32, len(tab.LR.goToTable))
+	for i, e := range tab.LR.goToTable {
+		goTo[i] = uint32(e)
+	}
+
+	expected := make([][]int, tab.LR.numOfStates)
+	for state := 0; state < tab.LR.numOfStates; state++ {
+		syms := tab.LR.expectedTerminals(StateNum(state))
+		expected[state] = make([]int, len(syms))
+		for i, sym := range syms {
+			expected[state][i] = sym.Int()
+		}
+	}
+
+	defActs := make([]int, tab.LR.numOfStates)
+	for state := 0; state < tab.LR.numOfStates; state++ {
+		defActs[state] = tab.LR.defaultReduction(StateNum(state)).Int()
+	}
+	reduceOnly := make([]int, tab.LR.numOfStates)
+	for state := 0; state < tab.LR.numOfStates; state++ {
+		reduceOnly[state] = tab.LR.reduceOnlyProduction(StateNum(state)).Int()
+	}
+
+	var compAction, compGoTo *SerializedRowDisplacedTable
+	if config.compress {
+		ctab := CompressParsingTable(tab.LR)
+		compAction = serializeRowDisplacedTable(ctab.action)
+		compGoTo = serializeRowDisplacedTable(ctab.goTo)
+		action = nil
+		goTo = nil
+	}
+
+	return &SerializedTable{
+		Version:                 SerializedTableVersion,
+		Action:                  action,
+		GoTo:                    goTo,
+		CompressedAction:        compAction,
+		CompressedGoTo:          compGoTo,
+		StateCount:              len(tab.LR0Automaton.states),
+		InitialState:            tab.LR.InitialState,
+		AcceptAction:            int32(actionEntryAccept),
+		EOFMode:                 tab.LR.eofMode,
+		ExpectedTokens:          expected,
+		DefaultActions:          defActs,
+		ReduceOnlyStates:        reduceOnly,
+		StartProduction:         ProductionNumStart.Int(),
+		HeadSymbols:             headSyms,
+		AlternativeSymbolCounts: altSymCounts,
+		Actions:                 actions,
+		AlternativeLabels:       altLabels,
+		ProductionTexts:         prodTexts,
+		EOFSymbol:               SymbolEOF.Num().Int(),
+		TerminalSymbols:         tsyms,
+		TerminalSymbolPatterns:  patterns,
+		TerminalCaseInsensitive: caseInsensitive,
+		TerminalModes:           modes,
+		LiteralSymbols:          literals,
+		TerminalSymbolCount:     tsymCount,
+		UnusedTerminalSymbols:   unusedTSyms,
+		NonTerminalSymbols:      nsyms,
+		NonTerminalSymbolCount:  nsymCount,
+		NonTerminalOrigins:      origins,
+		GrammarHash:             gram.Hash(),
+		GrammarSource:           config.grammarSource,
+	}, nil
+}
+
+func serializeRowDisplacedTable(t *rowDisplacedTable) *SerializedRowDisplacedTable {
+	return &SerializedRowDisplacedTable{
+		Row:     t.row,
+		Base:    t.base,
+		Default: t.def,
+		Next:    t.next,
+		Check:   t.check,
+	}
+}
+
+// Hash returns a SHA-256 hash of the canonical form of the grammar. The canonical form consists of the symbols,
+// the productions, and the patterns in the order of their numbers, so the hash changes whenever the parsing table
+// would change.
+func (g *Grammar) Hash() string {
+	var b strings.Builder
+	tsymCount := g.SymbolTable.getNumOfTerminalSymbols()
+	for num := terminalSymbolNumMin.Int(); num < tsymCount; num++ {
+		text, _ := g.SymbolTable.ToTextFromNumT(SymbolNum(num))
+		fmt.Fprintf(&b, "t %v %q %q", num, text, g.Patterns[SymbolNum(num)])
+		if _, ok := g.CaseInsensitiveTerminals[SymbolNum(num)]; ok {
+			fmt.Fprintf(&b, " i")
+		}
+		if modes, ok := g.TerminalModes[SymbolNum(num)]; ok {
+			fmt.Fprintf(&b, " mode %q", strings.Join(modes, ","))
+		}
+		if prec, ok := g.Precedences[SymbolNum(num)]; ok {
+			fmt.Fprintf(&b, " %v %v", prec.Assoc, prec.Level)
+		}
+		fmt.Fprintf(&b, "\n")
+	}
+	nsymCount := g.SymbolTable.getNumOfNonTerminalSymbols()
+	for num := nonTerminalSymbolNumMin.Int() + 1; num < nsymCount; num++ {
+		text, _ := g.SymbolTable.ToTextFromNumN(SymbolNum(num))
+		fmt.Fprintf(&b, "n %v %q\n", num, text)
+	}
+	for _, prod := range g.ProductionSet.getAllSorted() {
+		fmt.Fprintf(&b, "p %v %v #%v", prod.num, productionText(prod, g.SymbolTable), prod.label)
+		if prod.action != "" {
+			fmt.Fprintf(&b, " %q", prod.action)
+		}
+		if prod.prec != nil {
+			fmt.Fprintf(&b, " %v %v", prod.prec.Assoc, prod.prec.Level)
+		}
+		fmt.Fprintf(&b, "\n")
+	}
+	return fmt.Sprintf("%x", sha256.Sum256([]byte(b.String())))
+}
+
+// MatchesGrammar reports whether a serialized table was generated from the grammar source.
+func MatchesGrammar(src []byte, t *SerializedTable) (bool, error) {
+	psr, err := parser.NewParser(bytes.NewReader(src))
+	if err != nil {
+		return false, err
+	}
+	ast, err := psr.Parse()
+	if err != nil {
+		return false, err
+	}
+	gram, err := GenGrammar(ast)
+	if err != nil {
+		return false, err
+	}
+	return gram.Hash() == t.GrammarHash, nil
+}
+
+func GenDOT(gram *Grammar, tab *Table) ([]byte, error) {
+	var b strings.Builder
+	fmt.Fprintf(&b, "digraph lr0 {\n")
+	fmt.Fprintf(&b, "  node [shape=box, fontname=monospace];\n")
+	for _, state := range tab.LR0Automaton.getStatesSorted() {
+		var label strings.Builder
+		fmt.Fprintf(&label, "#%v\\n", state.Num)
+		for _, item := range state.Items {
+			text, err := lr0ItemText(item, gram.ProductionSet, gram.SymbolTable)
+			if err != nil {
+				return nil, err
+			}
+			fmt.Fprintf(&label, "%v\\l", escapeDOT(text))
+		}
+		fmt.Fprintf(&b, "  s%v [label=\"%v\"];\n", state.Num, label.String())
+	}
+	for _, state := range tab.LR0Automaton.getStatesSorted() {
+		var nextSyms []Symbol
+		for sym := range state.Next {
+			nextSyms = append(nextSyms, sym)
+		}
+		sort.Slice(nextSyms, func(i, j int) bool {
+			return nextSyms[i] < nextSyms[j]
+		})
+		for _, sym := range nextSyms {
+			symText, ok := gram.SymbolTable.ToText(sym)
+			if !ok {
+				return nil, fmt.Errorf("text was not found; symbol: %v", sym)
+			}
+			nextState := tab.LR0Automaton.states[state.Next[sym]]
+			fmt.Fprintf(&b, "  s%v -> s%v [label=\"%v\"];\n", state.Num, nextState.Num, escapeDOT(symText))
+		}
+	}
+	fmt.Fprintf(&b, "}\n")
+	return []byte(b.String()), nil
+}
+
+func escapeDOT(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s)
+}
@@ -0,0 +1,583 @@
+package grammar
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/nihei9/9gram/parser"
+	"gopkg.in/yaml.v2"
+)
+
+func TestGenJSON_GrammarSource(t *testing.T) {
+	src := "s: FOO s | ;"
+
+	parser, err := parser.NewParser(strings.NewReader(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ast, err := parser.Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	gram, err := GenGrammar(ast)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tab, err := GenTable(gram)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("when the option is enabled, the JSON contains the grammar source", func(t *testing.T) {
+		d, err := GenJSON(gram, tab, WithGrammarSource(src))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var out map[string]interface{}
+		err = json.Unmarshal(d, &out)
+		if err != nil {
+			t.Fatal(err)
+		}
+		v, ok := out["grammar_source"]
+		if !ok {
+			t.Fatalf("grammar_source field was not found")
+		}
+		if v != src {
+			t.Fatalf("unexpected grammar source; want: %v, got: %v", src, v)
+		}
+	})
+
+	t.Run("when the option is disabled, the JSON doesn't contain the grammar source", func(t *testing.T) {
+		d, err := GenJSON(gram, tab)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var out map[string]interface{}
+		err = json.Unmarshal(d, &out)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, ok := out["grammar_source"]; ok {
+			t.Fatalf("grammar_source field must be absent")
+		}
+	})
+}
+
+func TestGenJSON_Deterministic(t *testing.T) {
+	src := `
+expr: expr "+" term | term;
+term: term "*" factor | factor;
+factor: "(" expr ")" | sign? NUMBER;
+sign: "-";
+list: expr* | expr+;
+`
+
+	gen := func() ([]byte, string, string) {
+		t.Helper()
+
+		parser, err := parser.NewParser(strings.NewReader(src))
+		if err != nil {
+			t.Fatal(err)
+		}
+		ast, err := parser.Parse()
+		if err != nil {
+			t.Fatal(err)
+		}
+		gram, err := GenGrammar(ast)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tab, err := GenTable(gram)
+		if err != nil {
+			t.Fatal(err)
+		}
+		d, err := GenJSON(gram, tab)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var prods strings.Builder
+		PrintProductionSet(&prods, gram.ProductionSet, gram.SymbolTable)
+		var automaton strings.Builder
+		PrintLR0Automaton(&automaton, tab.LR0Automaton, gram.ProductionSet, gram.SymbolTable)
+		return d, prods.String(), automaton.String()
+	}
+
+	eJSON, eProds, eAutomaton := gen()
+	for i := 0; i < 10; i++ {
+		aJSON, aProds, aAutomaton := gen()
+		if string(aJSON) != string(eJSON) {
+			t.Fatalf("JSON output is not deterministic\nwant: %s\ngot: %s", eJSON, aJSON)
+		}
+		if aProds != eProds {
+			t.Fatalf("production set output is not deterministic\nwant: %s\ngot: %s", eProds, aProds)
+		}
+		if aAutomaton != eAutomaton {
+			t.Fatalf("automaton output is not deterministic\nwant: %s\ngot: %s", eAutomaton, aAutomaton)
+		}
+	}
+}
+
+func TestGenYAML(t *testing.T) {
+	src := "s: FOO s | ;"
+	gram, tab := genTestTable(t, src)
+
+	jsonOut, err := GenJSON(gram, tab, WithGrammarSource(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	yamlOut, err := GenYAML(gram, tab, WithGrammarSource(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var fromYAML SerializedTable
+	err = yaml.Unmarshal(yamlOut, &fromYAML)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// YAML doesn't distinguish a nil slice from an empty one.
+	if len(fromYAML.UnusedTerminalSymbols) == 0 {
+		fromYAML.UnusedTerminalSymbols = nil
+	}
+	reJSON, err := json.Marshal(fromYAML)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(reJSON) != string(jsonOut) {
+		t.Fatalf("YAML output doesn't have the same structure as JSON output\nwant: %s\ngot: %s", jsonOut, reJSON)
+	}
+}
+
+func TestGenDOT(t *testing.T) {
+	gram, tab := genTestTable(t, `s: "\"" s | ;`)
+
+	d, err := GenDOT(gram, tab)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := string(d)
+	if !strings.HasPrefix(out, "digraph ") || !strings.HasSuffix(out, "}\n") {
+		t.Fatalf("the output is not a DOT graph: %v", out)
+	}
+	for _, state := range tab.LR0Automaton.states {
+		for _, kID := range state.Next {
+			edge := fmt.Sprintf("s%v -> s%v ", state.Num, tab.LR0Automaton.states[kID].Num)
+			if !strings.Contains(out, edge) {
+				t.Fatalf("an edge was not found; edge: %v, output: %v", edge, out)
+			}
+		}
+	}
+	if strings.Contains(out, "s'\"") {
+		t.Fatalf("a double quote was not escaped: %v", out)
+	}
+}
+
+func genTestTable(t *testing.T, src string) (*Grammar, *Table) {
+	t.Helper()
+
+	parser, err := parser.NewParser(strings.NewReader(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ast, err := parser.Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	gram, err := GenGrammar(ast)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tab, err := GenTable(gram)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return gram, tab
+}
+
+func TestGenJSON_AlternativeLabels(t *testing.T) {
+	gram, tab := genTestTable(t, "s: s ADD NUM #add | NUM #num | LPAREN RPAREN;")
+	d, err := GenJSON(gram, tab)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out SerializedTable
+	err = json.Unmarshal(d, &out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(out.AlternativeLabels) != len(out.HeadSymbols) {
+		t.Fatalf("alternative_labels must be parallel to head_symbols; labels: %v, head symbols: %v", out.AlternativeLabels, out.HeadSymbols)
+	}
+	expected := map[int]string{
+		2: "add",
+		3: "num",
+		4: "",
+	}
+	for num, label := range expected {
+		if out.AlternativeLabels[num] != label {
+			t.Fatalf("unexpected label; production: #%v, want: %q, got: %q", num, label, out.AlternativeLabels[num])
+		}
+	}
+}
+
+func TestGenJSON_ExpectedTokens(t *testing.T) {
+	gram, tab := genTestTable(t, "s: s ADD NUM | NUM | LPAREN RPAREN;")
+	d, err := GenJSON(gram, tab)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out SerializedTable
+	err = json.Unmarshal(d, &out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(out.ExpectedTokens) != out.StateCount {
+		t.Fatalf("expected_tokens must have an entry per state; want: %v, got: %v", out.StateCount, len(out.ExpectedTokens))
+	}
+	for state, syms := range out.ExpectedTokens {
+		var want []int
+		for sym := 0; sym < out.TerminalSymbolCount; sym++ {
+			if out.Action[state*out.TerminalSymbolCount+sym] != 0 {
+				want = append(want, sym)
+			}
+		}
+		if fmt.Sprint(syms) != fmt.Sprint(want) {
+			t.Fatalf("unexpected expected tokens; state: %v, want: %v, got: %v", state, want, syms)
+		}
+	}
+
+	var texts []string
+	for _, sym := range out.ExpectedTokens[out.InitialState] {
+		texts = append(texts, out.TerminalSymbols[sym])
+	}
+	if strings.Join(texts, " ") != "NUM LPAREN" {
+		t.Fatalf("unexpected expected tokens in the initial state; want: NUM LPAREN, got: %v", texts)
+	}
+}
+
+func TestGenJSON_DefaultActions(t *testing.T) {
+	gram, tab := genTestTable(t, "s: s ADD NUM | NUM | LPAREN RPAREN;")
+	d, err := GenJSON(gram, tab)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out SerializedTable
+	err = json.Unmarshal(d, &out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(out.DefaultActions) != out.StateCount {
+		t.Fatalf("default_actions must have an entry per state; want: %v, got: %v", out.StateCount, len(out.DefaultActions))
+	}
+	var defs []int
+	for state, def := range out.DefaultActions {
+		prods := map[int32]struct{}{}
+		for sym := 0; sym < out.TerminalSymbolCount; sym++ {
+			if act := out.Action[state*out.TerminalSymbolCount+sym]; act > 0 {
+				prods[act] = struct{}{}
+			}
+		}
+		if def == 0 {
+			continue
+		}
+		if _, ok := prods[int32(def)]; !ok || len(prods) != 1 {
+			t.Fatalf("a default action must be the only reduce action in the state; state: %v, default: %v, reduce actions: %v", state, def, prods)
+		}
+		defs = append(defs, def)
+	}
+	sort.Ints(defs)
+	// The accepting state has no default action.
+	if fmt.Sprint(defs) != "[2 3 4]" {
+		t.Fatalf("unexpected default actions; want: [2 3 4], got: %v", defs)
+	}
+}
+
+func TestGenJSON_AcceptAction(t *testing.T) {
+	gram, tab := genTestTable(t, "s: s ADD NUM | NUM;")
+	d, err := GenJSON(gram, tab)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out SerializedTable
+	err = json.Unmarshal(d, &out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.AcceptAction <= 0 || out.AcceptAction == int32(out.StartProduction) {
+		t.Fatalf("accept_action must be distinct from the reduce action of the start production; got: %v", out.AcceptAction)
+	}
+	var accepts []int
+	for i, act := range out.Action {
+		if act == int32(out.StartProduction) {
+			t.Fatalf("the table must not contain a reduce action of the start production; index: %v", i)
+		}
+		if act != out.AcceptAction {
+			continue
+		}
+		if sym := i % out.TerminalSymbolCount; sym != out.EOFSymbol {
+			t.Fatalf("an accept action must be on EOF; symbol: %v", sym)
+		}
+		accepts = append(accepts, i/out.TerminalSymbolCount)
+	}
+	if len(accepts) != 1 {
+		t.Fatalf("the table must have exactly one accept action; got: %v states", len(accepts))
+	}
+}
+
+func TestGenJSON_TerminalCaseInsensitive(t *testing.T) {
+	gram, tab := genTestTable(t, `s: SELECT ID; SELECT: "select" %i; ID: "[a-z]+";`)
+	d, err := GenJSON(gram, tab)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out SerializedTable
+	err = json.Unmarshal(d, &out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(out.TerminalCaseInsensitive) != out.TerminalSymbolCount {
+		t.Fatalf("terminal_case_insensitive must have an entry per terminal symbol; want: %v, got: %v", out.TerminalSymbolCount, len(out.TerminalCaseInsensitive))
+	}
+	for num, text := range out.TerminalSymbols {
+		if out.TerminalCaseInsensitive[num] != (text == "SELECT") {
+			t.Fatalf("unexpected case sensitivity; symbol: %v, case-insensitive: %v", text, out.TerminalCaseInsensitive[num])
+		}
+	}
+}
+
+func TestGenJSON_TerminalModes(t *testing.T) {
+	gram, tab := genTestTable(t, `s: QUOTE CHAR QUOTE | ID; QUOTE: "\"" %mode(normal, string); CHAR: "[^\"]" %mode(string); ID: "[a-z]+";`)
+	d, err := GenJSON(gram, tab)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out SerializedTable
+	err = json.Unmarshal(d, &out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(out.TerminalModes) != out.TerminalSymbolCount {
+		t.Fatalf("terminal_modes must have an entry per terminal symbol; want: %v, got: %v", out.TerminalSymbolCount, len(out.TerminalModes))
+	}
+	expected := map[string]string{
+		"QUOTE": "normal string",
+		"CHAR":  "string",
+	}
+	for num, text := range out.TerminalSymbols {
+		if actual := strings.Join(out.TerminalModes[num], " "); actual != expected[text] {
+			t.Fatalf("unexpected modes; symbol: %v, want: %v, got: %v", text, expected[text], actual)
+		}
+	}
+
+	// The modes don't affect the parsing table but change the hash, so a stale output is detected.
+	other := genTestGrammar(t, `s: QUOTE CHAR QUOTE | ID; QUOTE: "\"" %mode(normal, string); CHAR: "[^\"]"; ID: "[a-z]+";`)
+	if other.Hash() == gram.Hash() {
+		t.Fatalf("the hash must change when the modes change")
+	}
+}
+
+func TestGenJSON_ProductionTexts(t *testing.T) {
+	gram, tab := genTestTable(t, "expr: expr ADD term | term; term: NUM | ;")
+	d, err := GenJSON(gram, tab)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out SerializedTable
+	err = json.Unmarshal(d, &out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{
+		"",
+		"expr' → expr",
+		"expr → expr ADD term",
+		"expr → term",
+		"term → NUM",
+		"term →",
+	}
+	if strings.Join(out.ProductionTexts, "\n") != strings.Join(expected, "\n") {
+		t.Fatalf("unexpected production texts;\nwant: %q\ngot:  %q", expected, out.ProductionTexts)
+	}
+	if len(out.ProductionTexts) != len(out.HeadSymbols) {
+		t.Fatalf("production_texts must be parallel to head_symbols; want: %v entries, got: %v", len(out.HeadSymbols), len(out.ProductionTexts))
+	}
+}
+
+func TestGenJSON_Actions(t *testing.T) {
+	src := `
+expr: expr ADD term { $$ = $1 + $3 } | term;
+term: NUM {
+	$$ = parse($1)
+} | LPAREN expr RPAREN #paren { $$ = { v: $2 } };
+`
+	gram, tab := genTestTable(t, src)
+	d, err := GenJSON(gram, tab)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out SerializedTable
+	err = json.Unmarshal(d, &out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{
+		"",
+		"",
+		" $$ = $1 + $3 ",
+		"",
+		"\n\t$$ = parse($1)\n",
+		" $$ = { v: $2 } ",
+	}
+	if fmt.Sprintf("%q", out.Actions) != fmt.Sprintf("%q", expected) {
+		t.Fatalf("unexpected actions;\nwant: %q\ngot:  %q", expected, out.Actions)
+	}
+
+	noActions, _ := genTestTable(t, "expr: expr ADD term | term; term: NUM | LPAREN expr RPAREN #paren;")
+	if gram.Hash() == noActions.Hash() {
+		t.Fatalf("the actions must affect the hash")
+	}
+}
+
+func TestGenJSON_NonTerminalOrigins(t *testing.T) {
+	gram, tab := genTestTable(t, "s: a? B; a: C A*;")
+	d, err := GenJSON(gram, tab)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out SerializedTable
+	err = json.Unmarshal(d, &out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var origins []string
+	for num, text := range out.NonTerminalSymbols {
+		origin, ok := out.NonTerminalOrigins[num]
+		if !ok {
+			continue
+		}
+		symText := out.NonTerminalSymbols[origin.Symbol]
+		if origin.Terminal {
+			symText = out.TerminalSymbols[origin.Symbol]
+		}
+		origins = append(origins, fmt.Sprintf("%v: %v %v", text, origin.Kind, symText))
+	}
+	expected := "$$0: ? a, $$1: * A"
+	if strings.Join(origins, ", ") != expected {
+		t.Fatalf("unexpected origins; want: %v, got: %v", expected, strings.Join(origins, ", "))
+	}
+	if len(out.NonTerminalOrigins) != 2 {
+		t.Fatalf("only the generated symbols must have origins; got: %v", out.NonTerminalOrigins)
+	}
+}
+
+func TestWriteJSON(t *testing.T) {
+	src := "s: FOO s | ;"
+	gram, tab := genTestTable(t, src)
+	for _, opts := range [][]OutputOption{
+		nil,
+		{WithCompressedTable(), WithGrammarSource(src)},
+	} {
+		d, err := GenJSON(gram, tab, opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var b bytes.Buffer
+		err = WriteJSON(&b, gram, tab, opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if b.String() != string(d)+"\n" {
+			t.Fatalf("WriteJSON must write the output of GenJSON followed by a new line;\nwant: %s\ngot: %v", d, b.String())
+		}
+	}
+}
+
+func TestDecodeTable(t *testing.T) {
+	src := "s: FOO s | ;"
+	gram, tab := genTestTable(t, src)
+
+	d, err := GenJSON(gram, tab, WithGrammarSource(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := DecodeTable(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decoded.Version != SerializedTableVersion {
+		t.Fatalf("unexpected version; want: %v, got: %v", SerializedTableVersion, decoded.Version)
+	}
+	if decoded.StateCount != len(tab.LR0Automaton.states) {
+		t.Fatalf("unexpected state count; want: %v, got: %v", len(tab.LR0Automaton.states), decoded.StateCount)
+	}
+	if decoded.GrammarSource == nil || *decoded.GrammarSource != src {
+		t.Fatalf("unexpected grammar source: %v", decoded.GrammarSource)
+	}
+	reJSON, err := json.Marshal(decoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(reJSON) != string(d) {
+		t.Fatalf("the decoded table doesn't round-trip\nwant: %s\ngot: %s", d, reJSON)
+	}
+
+	for _, v := range []string{`{}`, `{"version":0}`, `{"version":1}`, `{"version":3}`} {
+		_, err := DecodeTable([]byte(v))
+		if err == nil {
+			t.Fatalf("DecodeTable must reject an unknown version: %v", v)
+		}
+	}
+}
+
+func TestMatchesGrammar(t *testing.T) {
+	src := "s: FOO s | ;"
+	gram, tab := genTestTable(t, src)
+	d, err := GenJSON(gram, tab)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := DecodeTable(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		caption string
+		src     string
+		match   bool
+	}{
+		{
+			caption: "the original source matches",
+			src:     src,
+			match:   true,
+		},
+		{
+			caption: "a source that differs only in formatting matches",
+			src:     "s\n  : FOO s\n  |\n  ;\n",
+			match:   true,
+		},
+		{
+			caption: "a source that has another production doesn't match",
+			src:     "s: FOO s | BAR | ;",
+		},
+		{
+			caption: "a source that renames a symbol doesn't match",
+			src:     "s: BAR s | ;",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.caption, func(t *testing.T) {
+			match, err := MatchesGrammar([]byte(tt.src), decoded)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if match != tt.match {
+				t.Fatalf("unexpected result; want: %v, got: %v", tt.match, match)
+			}
+		})
+	}
+}
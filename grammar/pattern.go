@@ -0,0 +1,317 @@
+package grammar
+
+import (
+	"fmt"
+	"regexp"
+	"regexp/syntax"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// verbosePatternFlag at the head of the pattern of a lexeme production turns on the verbose mode.
+const verbosePatternFlag = "(?x)"
+
+// normalizePattern returns the pattern that a lexeme production defines. When the pattern starts with `(?x)`,
+// normalizePattern removes the flag and normalizes the rest in the verbose (free-spacing) mode as follows:
+//
+//   - Whitespace is removed.
+//   - `#` starts a comment that runs until the end of the line. The comment is removed.
+//   - `\` followed by whitespace or `#` means the character itself, so `\ ` becomes ` ` and `\#` becomes `#`.
+//   - Other escape sequences such as `\d` are kept as they are.
+//   - Characters inside a character class like `[ #]` are kept as they are.
+//
+// So the downstream lexer receives the normalized pattern and never sees the verbose mode. Other patterns are
+// returned as they are.
+func normalizePattern(pat string) string {
+	if !strings.HasPrefix(pat, verbosePatternFlag) {
+		return pat
+	}
+
+	src := []rune(strings.TrimPrefix(pat, verbosePatternFlag))
+	var b strings.Builder
+	inClass := false
+	for i := 0; i < len(src); i++ {
+		c := src[i]
+		switch {
+		case c == '\\':
+			if i+1 >= len(src) {
+				b.WriteRune(c)
+				continue
+			}
+			i++
+			next := src[i]
+			if !inClass && (unicode.IsSpace(next) || next == '#') {
+				b.WriteRune(next)
+				continue
+			}
+			b.WriteRune(c)
+			b.WriteRune(next)
+		case inClass:
+			b.WriteRune(c)
+			if c == ']' {
+				inClass = false
+			}
+		case c == '[':
+			b.WriteRune(c)
+			inClass = true
+			// `]` right after `[` or `[^` is a member of the class.
+			if i+1 < len(src) && src[i+1] == '^' {
+				i++
+				b.WriteRune(src[i])
+			}
+			if i+1 < len(src) && src[i+1] == ']' {
+				i++
+				b.WriteRune(src[i])
+			}
+		case unicode.IsSpace(c):
+		case c == '#':
+			for i+1 < len(src) && src[i+1] != '\n' {
+				i++
+			}
+		default:
+			b.WriteRune(c)
+		}
+	}
+	return b.String()
+}
+
+type PatternConflictType string
+
+const (
+	// PatternConflictTypeEqual means the patterns match the same sample strings.
+	PatternConflictTypeEqual = PatternConflictType("equal")
+
+	// PatternConflictTypeSubset means every sample string of a pattern matches the other pattern, but not vice versa.
+	PatternConflictTypeSubset = PatternConflictType("subset")
+
+	// PatternConflictTypePrefix means every sample string of a pattern is a strict prefix of a sample string of the
+	// other pattern, and no sample string matches both.
+	PatternConflictTypePrefix = PatternConflictType("prefix")
+)
+
+// PatternConflict represents two terminal symbols whose patterns overlap. For PatternConflictTypeSubset and
+// PatternConflictTypePrefix, Symbol is the one whose strings are covered by Other.
+type PatternConflict struct {
+	Type         PatternConflictType
+	Symbol       string
+	Pattern      string
+	Other        string
+	OtherPattern string
+}
+
+func (c *PatternConflict) String() string {
+	switch c.Type {
+	case PatternConflictTypeEqual:
+		return fmt.Sprintf("patterns match the same strings; symbols: %v (%v), %v (%v)", c.Symbol, c.Pattern, c.Other, c.OtherPattern)
+	case PatternConflictTypeSubset:
+		return fmt.Sprintf("every string that a pattern matches is matched by another pattern; symbol: %v (%v), other: %v (%v)", c.Symbol, c.Pattern, c.Other, c.OtherPattern)
+	default:
+		return fmt.Sprintf("strings that a pattern matches are prefixes of strings that another pattern matches; symbol: %v (%v), other: %v (%v)", c.Symbol, c.Pattern, c.Other, c.OtherPattern)
+	}
+}
+
+// maxPatternSamples is the maximum number of sample strings that genPatternSamples generates for a pattern.
+const maxPatternSamples = 64
+
+// FindPatternConflicts compares the patterns of the terminal symbols with each other and reports the pairs that
+// overlap. A pattern is compiled as a regular expression. A pattern that isn't a valid regular expression, such as
+// `+`, is taken as a literal string. The comparison uses a handful of sample strings generated from each pattern, so
+// it isn't exhaustive. It only catches obvious overlaps like duplicate token definitions or keywords that an
+// identifier pattern also matches.
+func FindPatternConflicts(gram *Grammar) ([]*PatternConflict, error) {
+	type patternInfo struct {
+		text    string
+		pattern string
+		re      *regexp.Regexp
+		samples []string
+	}
+
+	var nums []SymbolNum
+	for num := range gram.Patterns {
+		nums = append(nums, num)
+	}
+	sort.Slice(nums, func(i, j int) bool {
+		return nums[i] < nums[j]
+	})
+	var infos []*patternInfo
+	for _, num := range nums {
+		text, err := gram.SymbolTable.ToTextFromNumT(num)
+		if err != nil {
+			return nil, err
+		}
+		pat := gram.Patterns[num]
+		expr := pat
+		tree, err := syntax.Parse(expr, syntax.Perl)
+		if err != nil {
+			expr = regexp.QuoteMeta(pat)
+			tree, err = syntax.Parse(expr, syntax.Perl)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse a pattern; symbol: %v, pattern: %v, error: %v", text, pat, err)
+			}
+		}
+		re, err := regexp.Compile("^(?:" + expr + ")$")
+		if err != nil {
+			return nil, fmt.Errorf("failed to compile a pattern; symbol: %v, pattern: %v, error: %v", text, pat, err)
+		}
+		infos = append(infos, &patternInfo{
+			text:    text,
+			pattern: pat,
+			re:      re,
+			samples: genPatternSamples(tree.Simplify()),
+		})
+	}
+
+	matchesAll := func(samples []string, re *regexp.Regexp) bool {
+		for _, s := range samples {
+			if !re.MatchString(s) {
+				return false
+			}
+		}
+		return len(samples) > 0
+	}
+	matchesAny := func(samples []string, re *regexp.Regexp) bool {
+		for _, s := range samples {
+			if re.MatchString(s) {
+				return true
+			}
+		}
+		return false
+	}
+	arePrefixes := func(samples, others []string) bool {
+		for _, s := range samples {
+			found := false
+			for _, o := range others {
+				if len(o) > len(s) && strings.HasPrefix(o, s) {
+					found = true
+					break
+				}
+			}
+			if !found {
+				return false
+			}
+		}
+		return len(samples) > 0
+	}
+
+	newConflict := func(ty PatternConflictType, x, y *patternInfo) *PatternConflict {
+		return &PatternConflict{
+			Type:         ty,
+			Symbol:       x.text,
+			Pattern:      x.pattern,
+			Other:        y.text,
+			OtherPattern: y.pattern,
+		}
+	}
+
+	var conflicts []*PatternConflict
+	for i, a := range infos {
+		for _, b := range infos[i+1:] {
+			aInB := matchesAll(a.samples, b.re)
+			bInA := matchesAll(b.samples, a.re)
+			switch {
+			case a.pattern == b.pattern || aInB && bInA:
+				conflicts = append(conflicts, newConflict(PatternConflictTypeEqual, a, b))
+			case aInB:
+				conflicts = append(conflicts, newConflict(PatternConflictTypeSubset, a, b))
+			case bInA:
+				conflicts = append(conflicts, newConflict(PatternConflictTypeSubset, b, a))
+			case matchesAny(a.samples, b.re) || matchesAny(b.samples, a.re):
+			case arePrefixes(a.samples, b.samples):
+				conflicts = append(conflicts, newConflict(PatternConflictTypePrefix, a, b))
+			case arePrefixes(b.samples, a.samples):
+				conflicts = append(conflicts, newConflict(PatternConflictTypePrefix, b, a))
+			}
+		}
+	}
+	return conflicts, nil
+}
+
+// genPatternSamples generates sample strings that the regular expression matches. It picks the bounds of each range
+// of a character class and repeats a repetition at most twice, so the samples are few but representative.
+func genPatternSamples(re *syntax.Regexp) []string {
+	switch re.Op {
+	case syntax.OpLiteral:
+		return []string{string(re.Rune)}
+	case syntax.OpCharClass:
+		var samples []string
+		for i := 0; i+1 < len(re.Rune); i += 2 {
+			samples = append(samples, string(re.Rune[i]))
+			if re.Rune[i+1] != re.Rune[i] {
+				samples = append(samples, string(re.Rune[i+1]))
+			}
+		}
+		return limitPatternSamples(samples)
+	case syntax.OpAnyChar, syntax.OpAnyCharNotNL:
+		return []string{"a"}
+	case syntax.OpEmptyMatch, syntax.OpBeginLine, syntax.OpEndLine, syntax.OpBeginText, syntax.OpEndText, syntax.OpWordBoundary, syntax.OpNoWordBoundary:
+		return []string{""}
+	case syntax.OpCapture:
+		return genPatternSamples(re.Sub[0])
+	case syntax.OpStar:
+		return repeatPatternSamples(genPatternSamples(re.Sub[0]), 0, 2)
+	case syntax.OpPlus:
+		return repeatPatternSamples(genPatternSamples(re.Sub[0]), 1, 2)
+	case syntax.OpQuest:
+		return repeatPatternSamples(genPatternSamples(re.Sub[0]), 0, 1)
+	case syntax.OpRepeat:
+		max := re.Max
+		if max < 0 || max > re.Min+1 {
+			max = re.Min + 1
+		}
+		return repeatPatternSamples(genPatternSamples(re.Sub[0]), re.Min, max)
+	case syntax.OpConcat:
+		samples := []string{""}
+		for _, sub := range re.Sub {
+			samples = concatPatternSamples(samples, genPatternSamples(sub))
+		}
+		return samples
+	case syntax.OpAlternate:
+		var samples []string
+		for _, sub := range re.Sub {
+			samples = append(samples, genPatternSamples(sub)...)
+		}
+		return limitPatternSamples(samples)
+	}
+	return nil
+}
+
+func concatPatternSamples(prefixes, suffixes []string) []string {
+	var samples []string
+	for _, p := range prefixes {
+		for _, s := range suffixes {
+			samples = append(samples, p+s)
+		}
+	}
+	return limitPatternSamples(samples)
+}
+
+// repeatPatternSamples returns the concatenations of min to max samples.
+func repeatPatternSamples(samples []string, min, max int) []string {
+	var result []string
+	acc := []string{""}
+	for n := 0; n <= max; n++ {
+		if n >= min {
+			result = append(result, acc...)
+		}
+		acc = concatPatternSamples(acc, samples)
+	}
+	return limitPatternSamples(result)
+}
+
+// limitPatternSamples removes duplicates and keeps at most maxPatternSamples samples.
+func limitPatternSamples(samples []string) []string {
+	var result []string
+	seen := map[string]struct{}{}
+	for _, s := range samples {
+		if _, ok := seen[s]; ok {
+			continue
+		}
+		seen[s] = struct{}{}
+		result = append(result, s)
+		if len(result) >= maxPatternSamples {
+			break
+		}
+	}
+	return result
+}
@@ -0,0 +1,78 @@
+package grammar
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestNormalizePattern(t *testing.T) {
+	tests := []struct {
+		caption string
+		pat     string
+		result  string
+	}{
+		{
+			caption: "a pattern without the flag is kept as it is",
+			pat:     "a b # c",
+			result:  "a b # c",
+		},
+		{
+			caption: "whitespace and comments are removed",
+			pat:     "(?x)\n  [0-9]+   # integer part\n  (\\.[0-9]+)?  # fraction part\n",
+			result:  "[0-9]+(\\.[0-9]+)?",
+		},
+		{
+			caption: "escaped whitespace and # mean the characters themselves",
+			pat:     "(?x) a\\ b \\# c \\d",
+			result:  "a b#c\\d",
+		},
+		{
+			caption: "characters inside a character class are kept",
+			pat:     "(?x) [ #] []# ] [^] ] x",
+			result:  "[ #][]# ][^] ]x",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.caption, func(t *testing.T) {
+			result := normalizePattern(tt.pat)
+			if result != tt.result {
+				t.Fatalf("unexpected pattern; want: %q, got: %q", tt.result, result)
+			}
+		})
+	}
+}
+
+func TestFindPatternConflicts(t *testing.T) {
+	gram := genTestGrammar(t, `
+s: IF ID ASSIGN NUM EQ FLOAT DUP "+" "(";
+IF: "if";
+ID: "[a-z]+";
+NUM: "[0-9]+";
+FLOAT: /[0-9]+\.[0-9]+/;
+ASSIGN: "=";
+EQ: "==";
+DUP: "[0-9]+";
+`)
+	conflicts, err := FindPatternConflicts(gram)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var texts []string
+	for _, c := range conflicts {
+		texts = append(texts, fmt.Sprintf("%v %v %v", c.Type, c.Symbol, c.Other))
+	}
+	expected := []string{
+		"subset IF ID",
+		"prefix NUM FLOAT",
+		"equal NUM DUP",
+		"prefix DUP FLOAT",
+		"prefix ASSIGN EQ",
+	}
+	if strings.Join(texts, "\n") != strings.Join(expected, "\n") {
+		t.Fatalf("unexpected conflicts;\nwant:\n%v\ngot:\n%v", strings.Join(expected, "\n"), strings.Join(texts, "\n"))
+	}
+	if conflicts[0].String() != "every string that a pattern matches is matched by another pattern; symbol: IF (if), other: ID ([a-z]+)" {
+		t.Fatalf("unexpected text: %v", conflicts[0])
+	}
+}
@@ -0,0 +1,212 @@
+package grammar
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/nihei9/9gram/parser"
+)
+
+// Associativity decides a shift/reduce conflict between a production and a terminal symbol of the same precedence.
+type Associativity string
+
+const (
+	// AssociativityLeft prefers the reduce action, so `a - b - c` groups as `(a - b) - c`.
+	AssociativityLeft = Associativity("left")
+
+	// AssociativityRight prefers the shift action, so `a ^ b ^ c` groups as `a ^ (b ^ c)`.
+	AssociativityRight = Associativity("right")
+
+	// AssociativityNonAssoc makes the input a syntax error, so `a < b < c` is rejected.
+	AssociativityNonAssoc = Associativity("nonassoc")
+)
+
+// Precedence is the precedence of a terminal symbol or a production. A higher level binds tighter.
+type Precedence struct {
+	Level int
+	Assoc Associativity
+}
+
+// precedenceDecls holds the precedences that the `%left`, `%right`, and `%nonassoc` directives declare. A number
+// leading the arguments of a directive gives the level explicitly, like `%left 10 ADD`. Otherwise, the directive
+// declares the level one higher than the preceding directive. The terminal symbols appearing only in alternatives
+// aren't registered until the alternatives are, so the precedences of symbols are kept by their names until then. A
+// name that never becomes a terminal symbol, such as `UMINUS`, can be named only by `%prec`.
+type precedenceDecls struct {
+	terminals map[SymbolNum]*Precedence
+	names     map[string]*Precedence
+}
+
+func isPrecedenceDirective(name string) bool {
+	switch Associativity(name) {
+	case AssociativityLeft, AssociativityRight, AssociativityNonAssoc:
+		return true
+	}
+	return false
+}
+
+// genPrecedenceDecls resolves the arguments of the precedence directives. A pattern that no lexeme defines is
+// registered as a terminal symbol like an inline pattern in an alternative.
+func genPrecedenceDecls(root *parser.AST, symTab *SymbolTable, sym2Pat map[SymbolNum]string, pat2Sym map[string]Symbol, patNum *int) (*precedenceDecls, error) {
+	decls := &precedenceDecls{
+		terminals: map[SymbolNum]*Precedence{},
+		names:     map[string]*Precedence{},
+	}
+	level := 0
+	for _, ast := range root.Children {
+		if ast.Ty != parser.ASTTypeDirective {
+			continue
+		}
+		name, _ := ast.GetText()
+		if !isPrecedenceDirective(name) {
+			continue
+		}
+		args := ast.Children
+		if len(args) > 0 && args[0].Ty == parser.ASTTypeNumber {
+			text, _ := args[0].GetText()
+			n, err := strconv.Atoi(text)
+			if err != nil {
+				return nil, fmt.Errorf("%%%v takes an invalid level; level: %v", name, text)
+			}
+			level = n
+			args = args[1:]
+		} else {
+			level++
+		}
+		prec := &Precedence{
+			Level: level,
+			Assoc: Associativity(name),
+		}
+		if len(args) == 0 {
+			return nil, fmt.Errorf("%%%v takes at least one symbol or pattern", name)
+		}
+		for _, arg := range args {
+			text, _ := arg.GetText()
+			var sym Symbol
+			if arg.Ty == parser.ASTTypePattern {
+				var ok bool
+				sym, ok = pat2Sym[text]
+				if !ok {
+					var err error
+					sym, err = symTab.registerTerminalSymbol(fmt.Sprintf("$%v", *patNum))
+					if err != nil {
+						return nil, err
+					}
+					*patNum = *patNum + 1
+					pat2Sym[text] = sym
+					sym2Pat[sym.Num()] = text
+				}
+			} else {
+				if _, ok := directiveSymbolText(arg); !ok {
+					return nil, fmt.Errorf("%%%v takes a level only as the first argument and symbols or patterns; argument: %v", name, arg)
+				}
+				if err := checkUserSymbolText(text); err != nil {
+					return nil, err
+				}
+				// All the non-terminal symbols are registered already.
+				var ok bool
+				sym, ok = symTab.ToSymbol(text)
+				if ok && !sym.isTerminal() {
+					return nil, fmt.Errorf("%%%v takes only terminal symbols; symbol: %v", name, text)
+				}
+				if !ok {
+					if _, ok := decls.names[text]; ok {
+						return nil, fmt.Errorf("a precedence is declared more than once; symbol: %v", text)
+					}
+					decls.names[text] = prec
+					continue
+				}
+			}
+			if _, ok := decls.terminals[sym.Num()]; ok {
+				return nil, fmt.Errorf("a precedence is declared more than once; symbol: %v", text)
+			}
+			decls.terminals[sym.Num()] = prec
+		}
+	}
+	return decls, nil
+}
+
+// lookUp returns the precedence that `%prec` names. It is an error when the name has no precedence.
+func (d *precedenceDecls) lookUp(precAST *parser.AST, symTab *SymbolTable, pat2Sym map[string]Symbol) (*Precedence, error) {
+	arg := precAST.Children[0]
+	text, _ := arg.GetText()
+	if arg.Ty == parser.ASTTypePattern {
+		if sym, ok := pat2Sym[text]; ok {
+			if prec, ok := d.terminals[sym.Num()]; ok {
+				return prec, nil
+			}
+		}
+	} else {
+		if sym, ok := symTab.ToSymbol(text); ok {
+			if prec, ok := d.terminals[sym.Num()]; ok {
+				return prec, nil
+			}
+		}
+		if prec, ok := d.names[text]; ok {
+			return prec, nil
+		}
+	}
+	pos, _ := precAST.Pos()
+	return nil, fmt.Errorf("%%prec names a symbol without a precedence; symbol: %v, position: (%v, %v)", text, pos.Line, pos.Column)
+}
+
+// defaultPrecedence returns the precedence of the last terminal symbol in the RHS that has a precedence, or nil.
+func (d *precedenceDecls) defaultPrecedence(rhs []Symbol, symTab *SymbolTable) *Precedence {
+	for i := len(rhs) - 1; i >= 0; i-- {
+		if !rhs[i].isTerminal() {
+			continue
+		}
+		if prec, ok := d.terminals[rhs[i].Num()]; ok {
+			return prec
+		}
+		text, _ := symTab.ToText(rhs[i])
+		if prec, ok := d.names[text]; ok {
+			return prec
+		}
+	}
+	return nil
+}
+
+// resolveNames moves the precedences of the names that have become terminal symbols to the terminal symbols.
+func (d *precedenceDecls) resolveNames(symTab *SymbolTable) {
+	for text, prec := range d.names {
+		if sym, ok := symTab.ToSymbol(text); ok && sym.isTerminal() {
+			d.terminals[sym.Num()] = prec
+		}
+	}
+}
+
+type precedenceResolution int
+
+const (
+	precedenceResolutionNone = precedenceResolution(iota)
+	precedenceResolutionShift
+	precedenceResolutionReduce
+	precedenceResolutionError
+)
+
+// resolveByPrecedence decides a shift/reduce conflict between shifting sym and reducing by prod. It returns
+// precedenceResolutionNone when either has no precedence.
+func (t *ParsingTable) resolveByPrecedence(sym Symbol, prod ProductionNum) precedenceResolution {
+	symPrec, ok := t.symPrecs[sym.Num()]
+	if !ok {
+		return precedenceResolutionNone
+	}
+	prodPrec, ok := t.prodPrecs[prod]
+	if !ok {
+		return precedenceResolutionNone
+	}
+	switch {
+	case prodPrec.Level > symPrec.Level:
+		return precedenceResolutionReduce
+	case prodPrec.Level < symPrec.Level:
+		return precedenceResolutionShift
+	}
+	switch symPrec.Assoc {
+	case AssociativityLeft:
+		return precedenceResolutionReduce
+	case AssociativityRight:
+		return precedenceResolutionShift
+	}
+	return precedenceResolutionError
+}
@@ -0,0 +1,206 @@
+package grammar
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/nihei9/9gram/parser"
+)
+
+func TestGenTable_Precedence(t *testing.T) {
+	src := `
+%left ADD SUB;
+%left MUL;
+%right POW;
+%nonassoc LT;
+%right UMINUS;
+expr
+    : expr ADD expr
+    | expr SUB expr
+    | expr MUL expr
+    | expr POW expr
+    | expr LT expr
+    | SUB expr %prec UMINUS
+    | NUM
+    ;
+`
+	gram, tab := genTestTable(t, src)
+	if len(tab.ResolvedConflicts) > 0 {
+		t.Fatalf("the conflicts resolved by the precedences must not be reported: %v", tab.ResolvedConflicts)
+	}
+
+	tests := []struct {
+		caption  string
+		input    string
+		reduced  []string
+		accepted bool
+	}{
+		{
+			caption: "a higher precedence binds tighter",
+			input:   "NUM SUB NUM MUL NUM",
+			reduced: []string{"expr: NUM", "expr: NUM", "expr: NUM", "expr: expr MUL expr", "expr: expr SUB expr"},
+		},
+		{
+			caption: "a left-associative operator reduces first",
+			input:   "NUM SUB NUM ADD NUM",
+			reduced: []string{"expr: NUM", "expr: NUM", "expr: expr SUB expr", "expr: NUM", "expr: expr ADD expr"},
+		},
+		{
+			caption: "a right-associative operator shifts first",
+			input:   "NUM POW NUM POW NUM",
+			reduced: []string{"expr: NUM", "expr: NUM", "expr: NUM", "expr: expr POW expr", "expr: expr POW expr"},
+		},
+		{
+			caption: "%prec gives an alternative the precedence of the named symbol",
+			input:   "SUB NUM MUL NUM",
+			reduced: []string{"expr: NUM", "expr: SUB expr", "expr: NUM", "expr: expr MUL expr"},
+		},
+		{
+			caption: "an alternative without %prec has the precedence of its last terminal symbol",
+			input:   "NUM MUL NUM ADD NUM",
+			reduced: []string{"expr: NUM", "expr: NUM", "expr: expr MUL expr", "expr: NUM", "expr: expr ADD expr"},
+		},
+		{
+			caption: "a non-associative operator can't be chained",
+			input:   "NUM LT NUM LT NUM",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.caption, func(t *testing.T) {
+			trace, err := TraceParse(gram, tab, strings.Fields(tt.input))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if trace.Accepted != (tt.reduced != nil) {
+				t.Fatalf("unexpected result; want: %v, got: %v", tt.reduced != nil, trace.Accepted)
+			}
+			var reduced []string
+			for _, step := range trace.Steps {
+				if step.Type != ActionTypeReduce {
+					continue
+				}
+				for _, prod := range gram.ProductionSet.getAll() {
+					if prod.num == step.Production {
+						reduced = append(reduced, productionText(prod, gram.SymbolTable))
+					}
+				}
+			}
+			if tt.reduced != nil && strings.Join(reduced, "\n") != strings.Join(tt.reduced, "\n") {
+				t.Fatalf("unexpected reductions;\nwant:\n%v\ngot:\n%v", strings.Join(tt.reduced, "\n"), strings.Join(reduced, "\n"))
+			}
+		})
+	}
+
+	// A precedence declared with an alias applies to the canonical symbol.
+	_, err := GenTable(genTestGrammar(t, "%alias ADD PLUS; %left PLUS; %left MUL; expr: expr ADD expr | expr MUL expr | NUM;"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A production without a precedence still conflicts.
+	_, err = GenTable(genTestGrammar(t, "%left ADD; expr: expr ADD expr | NEG expr | NUM;"))
+	if err == nil || !strings.Contains(err.Error(), "reduce by #3 expr: NEG expr") {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestGenGrammar_PrecedenceLevels(t *testing.T) {
+	gram := genTestGrammar(t, "%left 10 ADD; %left 5 MUL; %right POW; %left -3 2; expr: expr ADD expr | expr MUL expr | expr POW expr | expr 2 expr | NUM;")
+	expected := map[string]*Precedence{
+		"ADD": {Level: 10, Assoc: AssociativityLeft},
+		"MUL": {Level: 5, Assoc: AssociativityLeft},
+		"POW": {Level: 6, Assoc: AssociativityRight},
+		"2":   {Level: -3, Assoc: AssociativityLeft},
+	}
+	for text, want := range expected {
+		sym := symbolOf(t, gram, text)
+		got, ok := gram.Precedences[sym.Num()]
+		if !ok || *got != *want {
+			t.Fatalf("unexpected precedence; symbol: %v, want: %+v, got: %+v", text, want, got)
+		}
+	}
+}
+
+func TestGenTable_NonAssocDefaultReduction(t *testing.T) {
+	gram, tab := genTestTable(t, "%left ADD; %nonassoc LT; expr: expr ADD expr | expr LT expr | NUM;")
+	var ltProd *production
+	for _, prod := range gram.ProductionSet.getAll() {
+		if productionText(prod, gram.SymbolTable) == "expr: expr LT expr" {
+			ltProd = prod
+		}
+	}
+	states := tab.LR0Automaton.StatesReducing(ltProd.id)
+	if len(states) != 1 {
+		t.Fatalf("unexpected states reducing by the production; want: 1 state, got: %v", states)
+	}
+	// Reducing by default on LT in the state would let the parser shift LT afterward and accept `NUM LT NUM LT NUM`.
+	if def := tab.LR.defaultReduction(states[0]); def != productionNumNil {
+		t.Fatalf("a state having an error entry made by %%nonassoc must have no default reduction; got: %v", def)
+	}
+	d, err := GenJSON(gram, tab)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := DecodeTable(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if def := out.DefaultActions[states[0]]; def != 0 {
+		t.Fatalf("default_actions must have no entry for the state; got: %v", def)
+	}
+}
+
+func TestGenGrammar_PrecedenceErrors(t *testing.T) {
+	tests := []struct {
+		caption string
+		src     string
+		message string
+	}{
+		{
+			caption: "%prec must name a symbol that has a precedence",
+			src:     "%left ADD; expr: expr ADD expr | SUB expr %prec UMINUS | NUM;",
+			message: "%prec names a symbol without a precedence; symbol: UMINUS, position: (1, 43)",
+		},
+		{
+			caption: "a precedence directive takes only terminal symbols",
+			src:     "%left expr; expr: expr ADD expr | NUM;",
+			message: "%left takes only terminal symbols; symbol: expr",
+		},
+		{
+			caption: "a precedence can't be declared more than once",
+			src:     "%left ADD; %right ADD; expr: expr ADD expr | NUM;",
+			message: "a precedence is declared more than once; symbol: ADD",
+		},
+		{
+			caption: "a precedence directive needs a symbol following the level",
+			src:     "%left 10; expr: expr ADD expr | NUM;",
+			message: "%left takes at least one symbol or pattern",
+		},
+		{
+			caption: "a precedence directive takes a negative number only as the level",
+			src:     "%left ADD -3; expr: expr ADD expr | NUM;",
+			message: "%left takes a level only as the first argument and symbols or patterns; argument: -3",
+		},
+		{
+			caption: "a precedence directive takes no string literals",
+			src:     "%left 'hi' ADD; expr: expr ADD expr | NUM;",
+			message: "%left takes a level only as the first argument and symbols or patterns; argument: 'hi'",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.caption, func(t *testing.T) {
+			psr, err := parser.NewParser(strings.NewReader(tt.src))
+			if err != nil {
+				t.Fatal(err)
+			}
+			ast, err := psr.Parse()
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, err = GenGrammar(ast)
+			if err == nil || !strings.Contains(err.Error(), tt.message) {
+				t.Fatalf("unexpected error; want: %v, got: %v", tt.message, err)
+			}
+		})
+	}
+}
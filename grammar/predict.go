@@ -0,0 +1,115 @@
+package grammar
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Predict holds a PREDICT set of each production. A PREDICT set consists of the FIRST set of the RHS
+// and, when the RHS is nullable, the FOLLOW set of the LHS.
+type Predict struct {
+	set map[ProductionID]*FollowEntry
+}
+
+func (p *Predict) Get(prod *production) (*FollowEntry, bool) {
+	e, ok := p.set[prod.id]
+	return e, ok
+}
+
+func genPredict(prods *productionSet, first *First, follow *Follow) (*Predict, error) {
+	predict := &Predict{
+		set: map[ProductionID]*FollowEntry{},
+	}
+	for _, prod := range prods.getAll() {
+		fst, err := first.Get(prod, 0)
+		if err != nil {
+			return nil, err
+		}
+		e := newFollowEntry()
+		e.merge(fst, nil)
+		if fst.empty {
+			e.merge(nil, follow.Get(prod.lhs))
+		}
+		predict.set[prod.id] = e
+	}
+	return predict, nil
+}
+
+type LL1Conflict struct {
+	LHS         string
+	Production1 ProductionNum
+	Production2 ProductionNum
+	Lookaheads  []string
+}
+
+func (c *LL1Conflict) String() string {
+	return fmt.Sprintf("LL(1) conflict: productions #%v and #%v of %v share lookaheads: %v", c.Production1, c.Production2, c.LHS, strings.Join(c.Lookaheads, ", "))
+}
+
+// CheckLL1 reports pairs of productions that have the same LHS and overlapping PREDICT sets.
+// When CheckLL1 returns no conflicts, the grammar is LL(1).
+func CheckLL1(gram *Grammar) ([]*LL1Conflict, error) {
+	fst, err := genFirst(gram.ProductionSet)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create a FIRST set: %v", err)
+	}
+	flw, err := genFollow(gram.ProductionSet, fst)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create a FOLLOW set: %v", err)
+	}
+	predict, err := genPredict(gram.ProductionSet, fst, flw)
+	if err != nil {
+		return nil, err
+	}
+
+	var lhsSyms []Symbol
+	for lhs := range gram.ProductionSet.lhs2Prods {
+		lhsSyms = append(lhsSyms, lhs)
+	}
+	sort.Slice(lhsSyms, func(i, j int) bool {
+		return lhsSyms[i] < lhsSyms[j]
+	})
+
+	var conflicts []*LL1Conflict
+	for _, lhs := range lhsSyms {
+		prods, _ := gram.ProductionSet.findByLHS(lhs)
+		for i, p1 := range prods {
+			e1, _ := predict.Get(p1)
+			for _, p2 := range prods[i+1:] {
+				e2, _ := predict.Get(p2)
+
+				var lookaheads []string
+				if e1.eof && e2.eof {
+					lookaheads = append(lookaheads, "<eof>")
+				}
+				var syms []Symbol
+				for sym := range e1.symbols {
+					if _, ok := e2.symbols[sym]; ok {
+						syms = append(syms, sym)
+					}
+				}
+				sort.Slice(syms, func(i, j int) bool {
+					return syms[i] < syms[j]
+				})
+				for _, sym := range syms {
+					text, _ := gram.SymbolTable.ToText(sym)
+					lookaheads = append(lookaheads, text)
+				}
+				if len(lookaheads) == 0 {
+					continue
+				}
+
+				lhsText, _ := gram.SymbolTable.ToText(lhs)
+				conflicts = append(conflicts, &LL1Conflict{
+					LHS:         lhsText,
+					Production1: p1.num,
+					Production2: p2.num,
+					Lookaheads:  lookaheads,
+				})
+			}
+		}
+	}
+
+	return conflicts, nil
+}
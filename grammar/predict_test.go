@@ -0,0 +1,109 @@
+package grammar
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/nihei9/9gram/parser"
+)
+
+func TestGenPredict(t *testing.T) {
+	gram := genTestGrammar(t, "s: a B | C; a: A | ;")
+	first, err := genFirst(gram.ProductionSet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	follow, err := genFollow(gram.ProductionSet, first)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	predict, err := genPredict(gram.ProductionSet, first, follow)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	genSym := newTestSymbolGenerator(t, gram.SymbolTable)
+	genProd := newTestProductionGenerator(t, genSym)
+
+	tests := []struct {
+		prod    *production
+		symbols []string
+		eof     bool
+	}{
+		{prod: genProd("s'", "s"), symbols: []string{"A", "B", "C"}},
+		{prod: genProd("s", "a", "B"), symbols: []string{"A", "B"}},
+		{prod: genProd("s", "C"), symbols: []string{"C"}},
+		{prod: genProd("a", "A"), symbols: []string{"A"}},
+		{prod: genProd("a"), symbols: []string{"B"}},
+	}
+	for _, tt := range tests {
+		actual, ok := predict.Get(tt.prod)
+		if !ok {
+			t.Fatalf("a PREDICT set was not found; production: %v", tt.prod.id)
+		}
+		expected := genExpectedFollowEntry(t, tt.symbols, tt.eof, gram.SymbolTable)
+		testFollow(t, actual, expected)
+	}
+}
+
+func TestCheckLL1(t *testing.T) {
+	tests := []struct {
+		caption   string
+		src       string
+		conflicts []string
+	}{
+		{
+			caption: "a LL(1) grammar has no conflicts",
+			src:     "e: t e2; e2: PLUS t e2 | ; t: LPAREN e RPAREN | NUMBER;",
+		},
+		{
+			caption: "a left-recursive grammar has conflicts",
+			src:     "e: e PLUS t | t; t: NUMBER;",
+			conflicts: []string{
+				"LL(1) conflict: productions #2 and #3 of e share lookaheads: NUMBER",
+			},
+		},
+		{
+			caption: "nullable alternatives conflict on the FOLLOW set",
+			src:     "s: a A; a: A | ;",
+			conflicts: []string{
+				"LL(1) conflict: productions #3 and #4 of a share lookaheads: A",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.caption, func(t *testing.T) {
+			gram := genTestGrammar(t, tt.src)
+			conflicts, err := CheckLL1(gram)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var actual []string
+			for _, c := range conflicts {
+				actual = append(actual, c.String())
+			}
+			if strings.Join(actual, "\n") != strings.Join(tt.conflicts, "\n") {
+				t.Fatalf("unexpected conflicts\nwant: %v\ngot: %v", tt.conflicts, actual)
+			}
+		})
+	}
+}
+
+func genTestGrammar(t *testing.T, src string) *Grammar {
+	t.Helper()
+
+	psr, err := parser.NewParser(strings.NewReader(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ast, err := psr.Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	gram, err := GenGrammar(ast)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return gram
+}
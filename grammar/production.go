@@ -1,17 +1,17 @@
 package grammar
 
 import (
-	"crypto/sha256"
-	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
 	"sort"
+	"strings"
 )
 
-type ProductionID [32]byte
+type ProductionID uint64
 
 func (id ProductionID) String() string {
-	return hex.EncodeToString(id[:])
+	return fmt.Sprintf("%016x", uint64(id))
 }
 
 func genProductionID(lhs Symbol, rhs []Symbol) ProductionID {
@@ -19,12 +19,13 @@ func genProductionID(lhs Symbol, rhs []Symbol) ProductionID {
 	for _, sym := range rhs {
 		seq = append(seq, sym.Byte()...)
 	}
-	return ProductionID(sha256.Sum256(seq))
+	return ProductionID(hash64(seq))
 }
 
 type ProductionNum uint16
 
 const (
+	productionNumNil   = ProductionNum(0)
 	ProductionNumStart = ProductionNum(1)
 
 	// Avoid using 0 as a production number.
@@ -42,9 +43,49 @@ type production struct {
 	lhs    Symbol
 	rhs    []Symbol
 	rhsLen int
+
+	// label is the name given to the alternative by `#label`. It is empty when the alternative has no label.
+	label string
+
+	// action is the code of the action attached to the alternative like `{ $$ = $1 + $3 }` without the braces. It
+	// is empty when the alternative has no action.
+	action string
+
+	// prec is the precedence that `%prec` gives, or the one of the last terminal symbol having a precedence in the
+	// RHS. It is nil when neither exists.
+	prec *Precedence
+
+	// synthetic is true when the production doesn't appear in the source, such as the ones that the qualifiers
+	// expand into.
+	synthetic bool
+}
+
+// rhsLenError means that the RHS of a production has more symbols than the limit. describeRHSLenError turns it into
+// an error naming the LHS by its text.
+type rhsLenError struct {
+	lhs    Symbol
+	length int
+	limit  int
+}
+
+func (e *rhsLenError) Error() string {
+	return fmt.Sprintf("an alternative has too many symbols; LHS: %v, length: %v, limit: %v", e.lhs, e.length, e.limit)
+}
+
+// describeRHSLenError returns an error naming the LHS by its text when err is an *rhsLenError. Otherwise, it returns
+// err as it is.
+func describeRHSLenError(err error, symTab *SymbolTable) error {
+	var lenErr *rhsLenError
+	if !errors.As(err, &lenErr) {
+		return err
+	}
+	lhsText, _ := symTab.ToText(lenErr.lhs)
+	return fmt.Errorf("an alternative has too many symbols; symbol: %v, length: %v, limit: %v", lhsText, lenErr.length, lenErr.limit)
 }
 
-func newProduction(lhs Symbol, rhs []Symbol) (*production, error) {
+// newProduction returns a production. An RHS having more symbols than maxRHSLen is an error of *rhsLenError, and
+// maxRHSLen <= 0 means no limit.
+func newProduction(lhs Symbol, rhs []Symbol, maxRHSLen int) (*production, error) {
 	if lhs.isNil() {
 		return nil, fmt.Errorf("LHS must be a non-nil symbol; LHS: %v, RHS: %v", lhs, rhs)
 	}
@@ -54,6 +95,14 @@ func newProduction(lhs Symbol, rhs []Symbol) (*production, error) {
 		}
 	}
 
+	if maxRHSLen > 0 && len(rhs) > maxRHSLen {
+		return nil, &rhsLenError{
+			lhs:    lhs,
+			length: len(rhs),
+			limit:  maxRHSLen,
+		}
+	}
+
 	p := &production{
 		id:     genProductionID(lhs, rhs),
 		lhs:    lhs,
@@ -64,10 +113,29 @@ func newProduction(lhs Symbol, rhs []Symbol) (*production, error) {
 	return p, nil
 }
 
+func (p *production) clone() *production {
+	c := *p
+	c.rhs = make([]Symbol, len(p.rhs))
+	copy(c.rhs, p.rhs)
+	return &c
+}
+
 func (p *production) equals(q *production) bool {
 	return q.id == p.id
 }
 
+func (p *production) hasSameSymbols(q *production) bool {
+	if p.lhs != q.lhs || p.rhsLen != q.rhsLen {
+		return false
+	}
+	for i, sym := range p.rhs {
+		if q.rhs[i] != sym {
+			return false
+		}
+	}
+	return true
+}
+
 func (p *production) isEmpty() bool {
 	return p.rhsLen <= 0
 }
@@ -86,9 +154,36 @@ func newProductionSet() *productionSet {
 	}
 }
 
+// clone returns a copy of the set. The productions are copied too, and they keep their IDs and numbers.
+func (ps *productionSet) clone() *productionSet {
+	c := &productionSet{
+		lhs2Prods: make(map[Symbol][]*production, len(ps.lhs2Prods)),
+		id2Prod:   make(map[ProductionID]*production, len(ps.id2Prod)),
+		num:       ps.num,
+	}
+	for lhs, prods := range ps.lhs2Prods {
+		cProds := make([]*production, len(prods))
+		for i, prod := range prods {
+			cProd := prod.clone()
+			cProds[i] = cProd
+			c.id2Prod[cProd.id] = cProd
+		}
+		c.lhs2Prods[lhs] = cProds
+	}
+	return c
+}
+
 func (ps *productionSet) append(prod *production) bool {
-	if _, ok := ps.id2Prod[prod.id]; ok {
-		return false
+	for {
+		p, ok := ps.id2Prod[prod.id]
+		if !ok {
+			break
+		}
+		if p.hasSameSymbols(prod) {
+			return false
+		}
+		// The IDs collided. Probe the next ID.
+		prod.id++
 	}
 
 	if prod.lhs.isStart() {
@@ -126,32 +221,93 @@ func (ps *productionSet) getAll() map[ProductionID]*production {
 	return ps.id2Prod
 }
 
-func PrintProductionSet(w io.Writer, prods *productionSet, symTab *SymbolTable) {
-	if w == nil {
-		return
+func (ps *productionSet) getAllSorted() []*production {
+	prods := make([]*production, 0, len(ps.id2Prod))
+	for _, prod := range ps.id2Prod {
+		prods = append(prods, prod)
 	}
+	sort.Slice(prods, func(i, j int) bool {
+		return prods[i].num < prods[j].num
+	})
+	return prods
+}
+
+// ProductionView is a read-only view of a production. The symbols are resolved to their texts.
+type ProductionView struct {
+	num       ProductionNum
+	lhs       string
+	rhs       []string
+	synthetic bool
+}
 
-	var ps []*production
-	for _, p := range prods.getAll() {
-		ps = append(ps, p)
+func newProductionView(prod *production, symTab *SymbolTable) ProductionView {
+	lhs, _ := symTab.ToText(prod.lhs)
+	rhs := make([]string, len(prod.rhs))
+	for i, sym := range prod.rhs {
+		rhs[i], _ = symTab.ToText(sym)
 	}
-	sort.Slice(ps, func(i, j int) bool {
-		return ps[i].num < ps[j].num
-	})
+	return ProductionView{
+		num:       prod.num,
+		lhs:       lhs,
+		rhs:       rhs,
+		synthetic: prod.synthetic,
+	}
+}
+
+// Num returns the production number, which the parsing table uses in reduce actions.
+func (v ProductionView) Num() int {
+	return v.num.Int()
+}
+
+// LHS returns the text of the LHS symbol.
+func (v ProductionView) LHS() string {
+	return v.lhs
+}
 
-	for _, p := range ps {
-		lhsText, ok := symTab.ToText(p.lhs)
+// RHS returns the texts of the RHS symbols. It returns an empty slice for an empty production. The caller may
+// modify the result.
+func (v ProductionView) RHS() []string {
+	rhs := make([]string, len(v.rhs))
+	copy(rhs, v.rhs)
+	return rhs
+}
+
+// IsSynthetic reports whether the production was generated rather than written in the source, such as the
+// productions that the qualifiers like `?` expand into. The augmented start production isn't synthetic.
+func (v ProductionView) IsSynthetic() bool {
+	return v.synthetic
+}
+
+// productionText returns a production in the source form like `e: e "+" t`.
+func productionText(prod *production, symTab *SymbolTable) string {
+	return formatProduction(prod, symTab, ":", " ")
+}
+
+// formatProduction renders a production as its LHS followed by arrow and its RHS symbols each preceded by sep, such
+// as `expr → expr ADD term` for the arrow ` →` and the separator ` `.
+func formatProduction(prod *production, symTab *SymbolTable, arrow string, sep string) string {
+	var b strings.Builder
+	lhsText, ok := symTab.ToText(prod.lhs)
+	if !ok {
+		lhsText = "<Symbol Not Found>"
+	}
+	fmt.Fprintf(&b, "%v%v", lhsText, arrow)
+	for _, rhsSym := range prod.rhs {
+		rhsText, ok := symTab.ToText(rhsSym)
 		if !ok {
-			lhsText = "<Symbol Not Found>"
-		}
-		fmt.Fprintf(w, "#%v: %v →", p.num, lhsText)
-		for _, rhsSym := range p.rhs {
-			rhsText, ok := symTab.ToText(rhsSym)
-			if !ok {
-				rhsText = "<Symbol Not Found>"
-			}
-			fmt.Fprintf(w, "　%v", rhsText)
+			rhsText = "<Symbol Not Found>"
 		}
-		fmt.Fprintf(w, "\n")
+		fmt.Fprintf(&b, "%v%v", sep, rhsText)
+	}
+	return b.String()
+}
+
+func PrintProductionSet(w io.Writer, prods *productionSet, symTab *SymbolTable) {
+	if w == nil {
+		return
+	}
+
+	for _, p := range prods.getAllSorted() {
+		fmt.Fprintf(w, "#%v: %v\n", p.num, formatProduction(p, symTab, " →", "　"))
 	}
 }
@@ -0,0 +1,216 @@
+package grammar
+
+import "sort"
+
+// FindLeftRecursion returns cycles of non-terminal symbols that can derive themselves as the leftmost symbol.
+// A symbol following a nullable prefix is also regarded as the leftmost symbol, so indirect cycles such as
+// `a: b a; b: ;` are reported as well. Each cycle is an ordered list of non-terminal symbols where each symbol
+// derives the next one as the leftmost symbol and the last one derives the first one. The cycle starts with
+// its smallest symbol, and the cycles are sorted. TraceLeftRecursion tells the nullable symbols that each cycle goes
+// through.
+func FindLeftRecursion(gram *Grammar) [][]Symbol {
+	nullable := Nullable(gram.ProductionSet)
+
+	leftCorners := map[Symbol][]Symbol{}
+	for _, prod := range gram.ProductionSet.getAllSorted() {
+		for _, sym := range prod.rhs {
+			if sym.isTerminal() {
+				break
+			}
+			leftCorners[prod.lhs] = appendSymbolIfAbsent(leftCorners[prod.lhs], sym)
+			if !nullable[sym] {
+				break
+			}
+		}
+	}
+
+	return findCycles(leftCorners)
+}
+
+// LeftRecursionStep is a step of a left-recursive cycle. LHS derives the next symbol of the cycle as the leftmost
+// symbol by Production once the symbols in Nullable, which precede the next symbol in the RHS, derive the empty
+// string. Nullable is empty for a step of classic left recursion like `a: a B;`.
+type LeftRecursionStep struct {
+	LHS        Symbol
+	Nullable   []Symbol
+	Production ProductionNum
+}
+
+// LeftRecursion is a cycle of FindLeftRecursion together with how each symbol derives the next one.
+type LeftRecursion struct {
+	Steps []*LeftRecursionStep
+}
+
+// IsHidden reports whether the cycle goes through a nullable prefix, such as the one of `a: b a; b: ;`. A check
+// comparing only the first symbol of the RHS with the LHS misses such a cycle.
+func (r *LeftRecursion) IsHidden() bool {
+	for _, step := range r.Steps {
+		if len(step.Nullable) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// Symbols returns the symbols of the cycle in the order of the traversal, including the nullable symbols. For
+// `a: b a; b: ;`, Symbols returns `a b`, and the cycle goes back to `a`.
+func (r *LeftRecursion) Symbols() []Symbol {
+	var syms []Symbol
+	for _, step := range r.Steps {
+		syms = append(syms, step.LHS)
+		syms = append(syms, step.Nullable...)
+	}
+	return syms
+}
+
+// TraceLeftRecursion returns the cycles of FindLeftRecursion in the same order along with their steps. When more
+// than one production of a symbol derives the next symbol, a step takes the one with the shortest nullable prefix,
+// and then the one with the smallest number.
+func TraceLeftRecursion(gram *Grammar) []*LeftRecursion {
+	nullable := Nullable(gram.ProductionSet)
+	prods := gram.ProductionSet.getAllSorted()
+
+	var recs []*LeftRecursion
+	for _, cycle := range FindLeftRecursion(gram) {
+		rec := &LeftRecursion{}
+		for i, lhs := range cycle {
+			next := cycle[(i+1)%len(cycle)]
+			var step *LeftRecursionStep
+			for _, prod := range prods {
+				if prod.lhs != lhs {
+					continue
+				}
+				for j, sym := range prod.rhs {
+					if sym == next {
+						if step == nil || j < len(step.Nullable) {
+							step = &LeftRecursionStep{
+								LHS:        lhs,
+								Nullable:   prod.rhs[:j],
+								Production: prod.num,
+							}
+						}
+						break
+					}
+					if sym.isTerminal() || !nullable[sym] {
+						break
+					}
+				}
+			}
+			rec.Steps = append(rec.Steps, step)
+		}
+		recs = append(recs, rec)
+	}
+	return recs
+}
+
+// FindUnitCycles returns cycles of non-terminal symbols that derive each other only through unit productions, that
+// is, productions whose RHS consists of exactly one non-terminal symbol, such as `a: b; b: a;`. The cycles are in the
+// same form as the ones of FindLeftRecursion.
+func FindUnitCycles(gram *Grammar) [][]Symbol {
+	units := map[Symbol][]Symbol{}
+	for _, prod := range gram.ProductionSet.getAllSorted() {
+		if len(prod.rhs) != 1 || !prod.rhs[0].isNonTerminal() {
+			continue
+		}
+		units[prod.lhs] = appendSymbolIfAbsent(units[prod.lhs], prod.rhs[0])
+	}
+	return findCycles(units)
+}
+
+// findCycles returns the shortest cycle through each symbol. Each cycle starts with its smallest symbol, the same
+// cycles are reported once, and the cycles are sorted.
+func findCycles(edges map[Symbol][]Symbol) [][]Symbol {
+	var syms []Symbol
+	for sym := range edges {
+		syms = append(syms, sym)
+	}
+	sort.Slice(syms, func(i, j int) bool {
+		return syms[i] < syms[j]
+	})
+
+	var cycles [][]Symbol
+	found := map[string]struct{}{}
+	for _, sym := range syms {
+		cycle := findShortestCycle(edges, sym)
+		if cycle == nil {
+			continue
+		}
+		cycle = rotateCycle(cycle)
+		key := symbolsKey(cycle)
+		if _, ok := found[key]; ok {
+			continue
+		}
+		found[key] = struct{}{}
+		cycles = append(cycles, cycle)
+	}
+	sort.Slice(cycles, func(i, j int) bool {
+		a, b := cycles[i], cycles[j]
+		for k := 0; k < len(a) && k < len(b); k++ {
+			if a[k] != b[k] {
+				return a[k] < b[k]
+			}
+		}
+		return len(a) < len(b)
+	})
+
+	return cycles
+}
+
+func appendSymbolIfAbsent(syms []Symbol, sym Symbol) []Symbol {
+	for _, s := range syms {
+		if s == sym {
+			return syms
+		}
+	}
+	return append(syms, sym)
+}
+
+// findShortestCycle returns the shortest path from the start symbol back to itself, excluding the last
+// occurrence of the start symbol. When no such path exists, findShortestCycle returns nil.
+func findShortestCycle(edges map[Symbol][]Symbol, start Symbol) []Symbol {
+	prev := map[Symbol]Symbol{}
+	queue := []Symbol{start}
+	for len(queue) > 0 {
+		sym := queue[0]
+		queue = queue[1:]
+		for _, next := range edges[sym] {
+			if next == start {
+				var cycle []Symbol
+				for s := sym; s != start; s = prev[s] {
+					cycle = append(cycle, s)
+				}
+				cycle = append(cycle, start)
+				for i, j := 0, len(cycle)-1; i < j; i, j = i+1, j-1 {
+					cycle[i], cycle[j] = cycle[j], cycle[i]
+				}
+				return cycle
+			}
+			if _, ok := prev[next]; ok {
+				continue
+			}
+			prev[next] = sym
+			queue = append(queue, next)
+		}
+	}
+	return nil
+}
+
+func rotateCycle(cycle []Symbol) []Symbol {
+	min := 0
+	for i, sym := range cycle {
+		if sym < cycle[min] {
+			min = i
+		}
+	}
+	rotated := make([]Symbol, 0, len(cycle))
+	rotated = append(rotated, cycle[min:]...)
+	return append(rotated, cycle[:min]...)
+}
+
+func symbolsKey(syms []Symbol) string {
+	var b []byte
+	for _, sym := range syms {
+		b = appendUint64(b, uint64(sym))
+	}
+	return string(b)
+}
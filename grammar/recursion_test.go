@@ -0,0 +1,117 @@
+package grammar
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestFindLeftRecursion(t *testing.T) {
+	tests := []struct {
+		caption string
+		src     string
+		cycles  []string
+	}{
+		{
+			caption: "a grammar without left recursion has no cycles",
+			src:     "s: A s | B;",
+		},
+		{
+			caption: "immediate left recursion",
+			src:     "e: e ADD t | t; t: t MUL f | f; f: NUM;",
+			cycles:  []string{"e", "t"},
+		},
+		{
+			caption: "indirect left recursion",
+			src:     "s: a A | B; a: b C; b: s D;",
+			cycles:  []string{"s a b"},
+		},
+		{
+			caption: "left recursion through a nullable prefix",
+			src:     "s: a s B | C; a: ;",
+			cycles:  []string{"s"},
+		},
+		{
+			caption: "a non-nullable prefix hides recursion",
+			src:     "s: a s B | C; a: A;",
+		},
+		{
+			caption: "each symbol in a cycle yields the same cycle once",
+			src:     "s: a | A; a: b | B; b: s C | a C;",
+			cycles:  []string{"s a b", "a b"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.caption, func(t *testing.T) {
+			gram := genTestGrammar(t, tt.src)
+			var cycles []string
+			for _, cycle := range FindLeftRecursion(gram) {
+				var texts []string
+				for _, sym := range cycle {
+					text, _ := gram.SymbolTable.ToText(sym)
+					texts = append(texts, text)
+				}
+				cycles = append(cycles, strings.Join(texts, " "))
+			}
+			if strings.Join(cycles, ",") != strings.Join(tt.cycles, ",") {
+				t.Fatalf("unexpected cycles; want: %v, got: %v", tt.cycles, cycles)
+			}
+		})
+	}
+}
+
+func TestTraceLeftRecursion(t *testing.T) {
+	tests := []struct {
+		caption string
+		src     string
+		cycles  []string
+		hidden  []bool
+	}{
+		{
+			caption: "classic left recursion goes through no nullable symbols",
+			src:     "e: e ADD t | t; t: NUM;",
+			cycles:  []string{"e"},
+			hidden:  []bool{false},
+		},
+		{
+			caption: "hidden left recursion includes the nullable symbols",
+			src:     "a: b a | C; b: ;",
+			cycles:  []string{"a b"},
+			hidden:  []bool{true},
+		},
+		{
+			caption: "hidden left recursion through more than one nullable symbol and another symbol",
+			src:     "s: a b c X | Y; a: ; b: A | ; c: s Z;",
+			cycles:  []string{"s a b c"},
+			hidden:  []bool{true},
+		},
+		{
+			caption: "a step takes the production with the shortest nullable prefix",
+			src:     "a: b a | a C | D; b: ;",
+			cycles:  []string{"a"},
+			hidden:  []bool{false},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.caption, func(t *testing.T) {
+			gram := genTestGrammar(t, tt.src)
+			var cycles []string
+			var hidden []bool
+			for _, rec := range TraceLeftRecursion(gram) {
+				var texts []string
+				for _, sym := range rec.Symbols() {
+					text, _ := gram.SymbolTable.ToText(sym)
+					texts = append(texts, text)
+				}
+				cycles = append(cycles, strings.Join(texts, " "))
+				hidden = append(hidden, rec.IsHidden())
+			}
+			if strings.Join(cycles, ",") != strings.Join(tt.cycles, ",") {
+				t.Fatalf("unexpected cycles; want: %v, got: %v", tt.cycles, cycles)
+			}
+			if fmt.Sprint(hidden) != fmt.Sprint(tt.hidden) {
+				t.Fatalf("unexpected hidden flags; want: %v, got: %v", tt.hidden, hidden)
+			}
+		})
+	}
+}
@@ -0,0 +1,255 @@
+package grammar
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// The reference implementations below compute FIRST and FOLLOW sets by brute force, following their definitions on
+// terminal strings literally. They enumerate the terminal strings that symbols derive, truncated to the first
+// prefixLen symbols. Truncation keeps the sets finite without losing any first symbol, so the results are exact for
+// grammars where every symbol derives some terminal string. They are used only to check genFirst and genFollow.
+
+// referenceLanguage is a set of terminal strings keyed by symbolsKey.
+type referenceLanguage map[string][]Symbol
+
+func (l referenceLanguage) add(str []Symbol) bool {
+	key := symbolsKey(str)
+	if _, ok := l[key]; ok {
+		return false
+	}
+	l[key] = str
+	return true
+}
+
+// concatReferenceLanguages returns the concatenations of the strings of the languages truncated to prefixLen
+// symbols.
+func concatReferenceLanguages(langs []referenceLanguage, prefixLen int) referenceLanguage {
+	acc := referenceLanguage{}
+	acc.add([]Symbol{})
+	for _, lang := range langs {
+		next := referenceLanguage{}
+		for _, prefix := range acc {
+			for _, suffix := range lang {
+				str := make([]Symbol, 0, len(prefix)+len(suffix))
+				str = append(str, prefix...)
+				str = append(str, suffix...)
+				if len(str) > prefixLen {
+					str = str[:prefixLen]
+				}
+				next.add(str)
+			}
+		}
+		acc = next
+	}
+	return acc
+}
+
+func terminalReferenceLanguage(sym Symbol) referenceLanguage {
+	return referenceLanguage{symbolsKey([]Symbol{sym}): {sym}}
+}
+
+// genReferenceLanguages returns the terminal strings that each non-terminal symbol derives, truncated to prefixLen
+// symbols.
+func genReferenceLanguages(prods *productionSet, prefixLen int) map[Symbol]referenceLanguage {
+	langs := map[Symbol]referenceLanguage{}
+	for _, prod := range prods.getAllSorted() {
+		langs[prod.lhs] = referenceLanguage{}
+	}
+
+	for {
+		more := false
+		for _, prod := range prods.getAllSorted() {
+			rhsLangs := make([]referenceLanguage, len(prod.rhs))
+			for i, sym := range prod.rhs {
+				if sym.isTerminal() {
+					rhsLangs[i] = terminalReferenceLanguage(sym)
+				} else {
+					rhsLangs[i] = langs[sym]
+				}
+			}
+			for _, str := range concatReferenceLanguages(rhsLangs, prefixLen) {
+				if langs[prod.lhs].add(str) {
+					more = true
+				}
+			}
+		}
+		if !more {
+			break
+		}
+	}
+	return langs
+}
+
+// genReferenceFollowingLanguages returns, for each non-terminal symbol X, the terminal strings v such that
+// X =>* α target β and β derives v. The strings are truncated to prefixLen symbols. Because α must derive some
+// terminal string too, the caller must make sure that every symbol derives some terminal string.
+func genReferenceFollowingLanguages(prods *productionSet, langs map[Symbol]referenceLanguage, target Symbol, prefixLen int) map[Symbol]referenceLanguage {
+	flwLangs := map[Symbol]referenceLanguage{}
+	for _, prod := range prods.getAllSorted() {
+		flwLangs[prod.lhs] = referenceLanguage{}
+	}
+	flwLangs[target].add([]Symbol{})
+
+	for {
+		more := false
+		for _, prod := range prods.getAllSorted() {
+			for i, sym := range prod.rhs {
+				if sym.isTerminal() {
+					continue
+				}
+				rhsLangs := []referenceLanguage{flwLangs[sym]}
+				for _, s := range prod.rhs[i+1:] {
+					if s.isTerminal() {
+						rhsLangs = append(rhsLangs, terminalReferenceLanguage(s))
+					} else {
+						rhsLangs = append(rhsLangs, langs[s])
+					}
+				}
+				for _, str := range concatReferenceLanguages(rhsLangs, prefixLen) {
+					if flwLangs[prod.lhs].add(str) {
+						more = true
+					}
+				}
+			}
+		}
+		if !more {
+			break
+		}
+	}
+	return flwLangs
+}
+
+type referenceEntry struct {
+	symbols map[Symbol]struct{}
+	// empty is true when the empty string belongs to a FIRST set, or when EOF belongs to a FOLLOW set.
+	empty bool
+}
+
+// newReferenceEntry returns the first symbols of the strings. empty is true when the strings contain the empty
+// string.
+func newReferenceEntry(strs referenceLanguage) *referenceEntry {
+	entry := &referenceEntry{
+		symbols: map[Symbol]struct{}{},
+	}
+	for _, str := range strs {
+		if len(str) == 0 {
+			entry.empty = true
+			continue
+		}
+		entry.symbols[str[0]] = struct{}{}
+	}
+	return entry
+}
+
+// genReferenceFirst returns FIRST(sym), that is, the terminal symbols a such that sym =>* a w. The set contains
+// the empty string when sym =>* ε.
+func genReferenceFirst(langs map[Symbol]referenceLanguage, sym Symbol) *referenceEntry {
+	return newReferenceEntry(langs[sym])
+}
+
+// genReferenceFollow returns FOLLOW(sym), that is, the terminal symbols a such that start =>* u sym a w. The set
+// contains EOF when start =>* u sym.
+func genReferenceFollow(prods *productionSet, langs map[Symbol]referenceLanguage, start, sym Symbol, prefixLen int) *referenceEntry {
+	return newReferenceEntry(genReferenceFollowingLanguages(prods, langs, sym, prefixLen)[start])
+}
+
+// genRandomGrammarSource returns a source of a small grammar. Every non-terminal symbol is reachable from the start
+// symbol and derives some terminal string because the definitions on terminal strings and the ones on sentential
+// forms agree only on such grammars.
+func genRandomGrammarSource(r *rand.Rand) string {
+	nts := []string{"s", "a", "b", "c"}[:2+r.Intn(3)]
+	ts := []string{"X", "Y", "Z"}
+	genRHS := func(nts []string) string {
+		var rhs []string
+		for l := r.Intn(4); l > 0; l-- {
+			if len(nts) > 0 && r.Intn(2) == 0 {
+				rhs = append(rhs, nts[r.Intn(len(nts))])
+			} else {
+				rhs = append(rhs, ts[r.Intn(len(ts))])
+			}
+		}
+		// A unit production could form a cycle like `a: b; b: a;`, which GenGrammar rejects.
+		if len(rhs) == 1 && rhs[0] == strings.ToLower(rhs[0]) {
+			rhs = append(rhs, ts[r.Intn(len(ts))])
+		}
+		return strings.Join(rhs, " ")
+	}
+
+	alts := make([][]string, len(nts))
+	for i := range nts {
+		// The first alternative refers to only the succeeding non-terminal symbols so that every symbol derives
+		// some terminal string.
+		alts[i] = append(alts[i], genRHS(nts[i+1:]))
+		for n := r.Intn(3); n > 0; n-- {
+			alts[i] = append(alts[i], genRHS(nts))
+		}
+		if i > 0 {
+			parent := r.Intn(i)
+			alts[parent] = append(alts[parent], nts[i]+" "+ts[r.Intn(len(ts))])
+		}
+	}
+
+	var b strings.Builder
+	for i, nt := range nts {
+		fmt.Fprintf(&b, "%v: %v;\n", nt, strings.Join(alts[i], " | "))
+	}
+	return b.String()
+}
+
+func TestFirstFollow_MatchReference(t *testing.T) {
+	const prefixLen = 2
+
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 300; i++ {
+		src := genRandomGrammarSource(r)
+		gram := genTestGrammar(t, src)
+		prods := gram.ProductionSet
+		first, err := genFirst(prods)
+		if err != nil {
+			t.Fatal(err)
+		}
+		follow, err := genFollow(prods, first)
+		if err != nil {
+			t.Fatal(err)
+		}
+		langs := genReferenceLanguages(prods, prefixLen)
+
+		for _, sym := range gram.SymbolTable.Symbols() {
+			if !sym.IsNonTerminal() {
+				continue
+			}
+			text, _ := gram.SymbolTable.ToText(sym)
+
+			fst := first.getBySymbol(sym)
+			refFst := genReferenceFirst(langs, sym)
+			if got, want := referenceText(gram.SymbolTable, fst.symbols, fst.empty), referenceText(gram.SymbolTable, refFst.symbols, refFst.empty); got != want {
+				t.Fatalf("FIRST set is mismatched; symbol: %v, want: %v, got: %v\n%v", text, want, got, src)
+			}
+
+			flw := follow.Get(sym)
+			refFlw := genReferenceFollow(prods, langs, gram.AugmentedStartSymbol, sym, prefixLen)
+			if got, want := referenceText(gram.SymbolTable, flw.symbols, flw.eof), referenceText(gram.SymbolTable, refFlw.symbols, refFlw.empty); got != want {
+				t.Fatalf("FOLLOW set is mismatched; symbol: %v, want: %v, got: %v\n%v", text, want, got, src)
+			}
+		}
+	}
+}
+
+// referenceText returns a set in a comparable form like `X Y <ε>`. <ε> stands for the empty string in a FIRST set
+// or EOF in a FOLLOW set.
+func referenceText(symTab *SymbolTable, syms map[Symbol]struct{}, empty bool) string {
+	var texts []string
+	for sym := range syms {
+		text, _ := symTab.ToText(sym)
+		texts = append(texts, text)
+	}
+	sort.Strings(texts)
+	if empty {
+		texts = append(texts, "<ε>")
+	}
+	return strings.Join(texts, " ")
+}
@@ -0,0 +1,76 @@
+package grammar
+
+import "errors"
+
+// GrammarReport is a summary of the health of a grammar. It is meant to be emitted as JSON and tracked over time.
+type GrammarReport struct {
+	TerminalSymbols    int `json:"terminal_symbols"`
+	NonTerminalSymbols int `json:"non_terminal_symbols"`
+	Productions        int `json:"productions"`
+	States             int `json:"states"`
+
+	// Conflicts is the number of the conflicts that the conflict policy didn't resolve.
+	Conflicts             int `json:"conflicts"`
+	ShiftReduceConflicts  int `json:"shift_reduce_conflicts"`
+	ReduceReduceConflicts int `json:"reduce_reduce_conflicts"`
+	ResolvedConflicts     int `json:"resolved_conflicts"`
+
+	UnreachableSymbols  []string `json:"unreachable_symbols"`
+	UnproductiveSymbols []string `json:"unproductive_symbols"`
+	MaxRHSLength        int      `json:"max_rhs_length"`
+	Warnings            []string `json:"warnings"`
+}
+
+// GenGrammarReport generates a parsing table and summarizes the grammar. Conflicts don't make GenGrammarReport
+// fail; they are counted in the report instead.
+func GenGrammarReport(gram *Grammar, opts ...TableOption) (*GrammarReport, error) {
+	report := &GrammarReport{
+		TerminalSymbols:     gram.NumOfTerminalSymbols(),
+		NonTerminalSymbols:  gram.NumOfNonTerminalSymbols(),
+		Productions:         gram.NumOfProductions(),
+		UnreachableSymbols:  FindUnreachableSymbols(gram),
+		UnproductiveSymbols: FindUnproductiveSymbols(gram),
+		Warnings:            gram.Warnings,
+	}
+	if report.UnreachableSymbols == nil {
+		report.UnreachableSymbols = []string{}
+	}
+	if report.UnproductiveSymbols == nil {
+		report.UnproductiveSymbols = []string{}
+	}
+	if report.Warnings == nil {
+		report.Warnings = []string{}
+	}
+	for _, prod := range gram.ProductionSet.getAll() {
+		if prod.lhs.isStart() {
+			continue
+		}
+		if prod.rhsLen > report.MaxRHSLength {
+			report.MaxRHSLength = prod.rhsLen
+		}
+	}
+
+	tab, err := GenTable(gram, opts...)
+	if err != nil {
+		var cErr *ConflictError
+		if !errors.As(err, &cErr) {
+			return nil, err
+		}
+		counts := cErr.Counts()
+		report.Conflicts = len(cErr.Conflicts)
+		report.ShiftReduceConflicts = counts.ShiftReduce
+		report.ReduceReduceConflicts = counts.ReduceReduce
+
+		// The table isn't available, but the automaton doesn't depend on the conflicts.
+		automaton, err := genLR0Automaton(gram.ProductionSet, gram.AugmentedStartSymbol)
+		if err != nil {
+			return nil, err
+		}
+		report.States = len(automaton.states)
+		return report, nil
+	}
+	report.States = tab.NumOfStates()
+	report.ResolvedConflicts = len(tab.ResolvedConflicts)
+
+	return report, nil
+}
@@ -0,0 +1,29 @@
+package grammar
+
+import "testing"
+
+func TestGenGrammarReport(t *testing.T) {
+	gram := genTestGrammar(t, "s: s ADD s | NUM | b; b: B b;")
+
+	report, err := GenGrammarReport(gram)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Conflicts != 1 || report.ShiftReduceConflicts != 1 || report.ResolvedConflicts != 0 {
+		t.Fatalf("unexpected conflict counts: %+v", report)
+	}
+	if len(report.UnproductiveSymbols) != 1 || report.UnproductiveSymbols[0] != "b" {
+		t.Fatalf("unexpected unproductive symbols: %v", report.UnproductiveSymbols)
+	}
+
+	report, err = GenGrammarReport(gram, WithConflictPolicy(ConflictPolicyPreferShift))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Conflicts != 0 || report.ResolvedConflicts != 1 {
+		t.Fatalf("unexpected conflict counts: %+v", report)
+	}
+	if report.States == 0 || report.MaxRHSLength != 3 || report.NonTerminalSymbols != 2 || report.TerminalSymbols != 3 {
+		t.Fatalf("unexpected report: %+v", report)
+	}
+}
@@ -1,8 +1,13 @@
 package grammar
 
 import (
+	"encoding/csv"
 	"fmt"
 	"io"
+	"math"
+	"sort"
+	"strings"
+	"unicode/utf8"
 )
 
 type ActionType string
@@ -10,12 +15,20 @@ type ActionType string
 const (
 	ActionTypeShift  = ActionType("shift")
 	ActionTypeReduce = ActionType("reduce")
+	ActionTypeAccept = ActionType("accept")
 	ActionTypeError  = ActionType("error")
 )
 
-type actionEntry int16
+// actionEntry is an entry of the ACTION table. A negative entry is a shift action to the state of the absolute
+// value, a positive entry is a reduce action by the production of the value, and actionEntryAccept is the accept
+// action. Because production numbers never exceed the range of ProductionNum, actionEntryAccept never collides with
+// a reduce action.
+type actionEntry int32
 
-const actionEntryEmpty = actionEntry(0)
+const (
+	actionEntryEmpty  = actionEntry(0)
+	actionEntryAccept = actionEntry(math.MaxInt32)
+)
 
 func newShiftActionEntry(state StateNum) actionEntry {
 	return actionEntry(state * -1)
@@ -25,6 +38,10 @@ func newReduceActionEntry(prod ProductionNum) actionEntry {
 	return actionEntry(prod)
 }
 
+func newAcceptActionEntry() actionEntry {
+	return actionEntryAccept
+}
+
 func (e actionEntry) isEmpty() bool {
 	return e == actionEntryEmpty
 }
@@ -33,12 +50,236 @@ func (e actionEntry) describe() (ActionType, StateNum, ProductionNum) {
 	if e == actionEntryEmpty {
 		return ActionTypeError, stateNumInitial, productionNumMin
 	}
+	if e == actionEntryAccept {
+		return ActionTypeAccept, stateNumInitial, ProductionNumStart
+	}
 	if e < 0 {
 		return ActionTypeShift, StateNum(e * -1), productionNumMin
 	}
 	return ActionTypeReduce, stateNumInitial, ProductionNum(e)
 }
 
+type ConflictType string
+
+const (
+	ConflictTypeShiftReduce  = ConflictType("shift/reduce")
+	ConflictTypeReduceReduce = ConflictType("reduce/reduce")
+)
+
+// ConflictPolicy decides how the table generator deals with conflicts.
+type ConflictPolicy string
+
+const (
+	// ConflictPolicyError makes every conflict an error.
+	ConflictPolicyError = ConflictPolicy("error")
+
+	// ConflictPolicyPreferShift resolves shift/reduce conflicts in favor of the shift action, which is the
+	// textbook resolution of the dangling else. reduce/reduce conflicts are still errors.
+	ConflictPolicyPreferShift = ConflictPolicy("prefer-shift")
+)
+
+// EOFMode decides on which lookahead symbols the parser accepts the input.
+type EOFMode string
+
+const (
+	// EOFModeExplicit accepts the input only at the end of the input, that is, on the EOF symbol.
+	EOFModeExplicit = EOFMode("explicit")
+
+	// EOFModeImplicit also accepts a prefix of the input, which lets a driver parse one statement from a stream of
+	// tokens. The parser accepts on EOF and on any terminal symbol in the FOLLOW set of the start symbol once it has
+	// derived the start symbol, unless it can shift the symbol. So the parser keeps consuming the input as long as
+	// it can, and it accepts the longest prefix. The lookahead symbol on accepting isn't consumed; it is the first
+	// symbol of the rest of the stream, and the driver must not report it as an error. A symbol out of the FOLLOW
+	// set is still a syntax error.
+	EOFModeImplicit = EOFMode("implicit")
+)
+
+// Conflict represents an entry of the ACTION table that has more than one action.
+type Conflict struct {
+	Type  ConflictType
+	State StateNum
+
+	// Resolved is true when the conflict policy resolved the conflict. A resolved shift/reduce conflict keeps the
+	// shift action and suppresses the reduce actions.
+	Resolved bool
+
+	// NextState is the state that the shift action moves to. It is used only when Type is ConflictTypeShiftReduce.
+	NextState StateNum
+
+	// Productions are the productions of the competing reduce actions.
+	Productions []ProductionNum
+
+	// SymbolText is the text of the lookahead symbol, and ProductionTexts are the source forms of Productions.
+	SymbolText      string
+	ProductionTexts []string
+
+	// PathTexts is a shortest sequence of symbols that leads the parser from the initial state to State. It is empty
+	// when State is the initial state.
+	PathTexts []string
+
+	// ExampleTexts is a shortest input of terminal symbols that leads the parser to State, followed by the lookahead
+	// symbol. It is set only for reduce/reduce conflicts, where the productions alone rarely tell the ambiguity.
+	ExampleTexts []string
+
+	symbol Symbol
+}
+
+func (c *Conflict) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "state %v: %v conflict on %v:", c.State, c.Type, c.SymbolText)
+	var actions []string
+	if c.Type == ConflictTypeShiftReduce {
+		actions = append(actions, fmt.Sprintf("shift to state %v", c.NextState))
+	}
+	for i, p := range c.Productions {
+		text := ""
+		if i < len(c.ProductionTexts) {
+			text = c.ProductionTexts[i]
+		}
+		actions = append(actions, fmt.Sprintf("reduce by #%v %v", p, text))
+	}
+	fmt.Fprintf(&b, " %v", strings.Join(actions, ", "))
+	if len(c.PathTexts) > 0 {
+		fmt.Fprintf(&b, "; path: %v", strings.Join(c.PathTexts, " "))
+	}
+	if len(c.ExampleTexts) > 0 {
+		fmt.Fprintf(&b, "; example: %v", strings.Join(c.ExampleTexts, " "))
+	}
+	if c.Resolved {
+		fmt.Fprintf(&b, "; resolved as shift")
+	}
+	return b.String()
+}
+
+// ConflictCounts is the number of conflicts of each type.
+type ConflictCounts struct {
+	ShiftReduce  int
+	ReduceReduce int
+}
+
+// CountConflicts classifies the conflicts by their types and counts them.
+func CountConflicts(conflicts []*Conflict) ConflictCounts {
+	var counts ConflictCounts
+	for _, c := range conflicts {
+		switch c.Type {
+		case ConflictTypeShiftReduce:
+			counts.ShiftReduce++
+		case ConflictTypeReduceReduce:
+			counts.ReduceReduce++
+		}
+	}
+	return counts
+}
+
+// String returns a summary like `3 shift/reduce, 1 reduce/reduce`.
+func (c ConflictCounts) String() string {
+	return fmt.Sprintf("%v %v, %v %v", c.ShiftReduce, ConflictTypeShiftReduce, c.ReduceReduce, ConflictTypeReduceReduce)
+}
+
+// ConflictError reports all conflicts found while generating a parsing table.
+type ConflictError struct {
+	Conflicts []*Conflict
+}
+
+// Counts returns the number of the conflicts of each type.
+func (e *ConflictError) Counts() ConflictCounts {
+	return CountConflicts(e.Conflicts)
+}
+
+func (e *ConflictError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%v conflicts found: %v", len(e.Conflicts), e.Counts())
+	for _, c := range e.Conflicts {
+		fmt.Fprintf(&b, "\n  %v", c)
+	}
+	return b.String()
+}
+
+func (e *ConflictError) resolveTexts(automaton *LR0Automaton, prods *productionSet, symTab *SymbolTable) {
+	resolveConflictTexts(e.Conflicts, automaton, prods, symTab)
+}
+
+func resolveConflictTexts(conflicts []*Conflict, automaton *LR0Automaton, prods *productionSet, symTab *SymbolTable) {
+	if len(conflicts) == 0 {
+		return
+	}
+	num2Prod := map[ProductionNum]*production{}
+	for _, prod := range prods.getAll() {
+		num2Prod[prod.num] = prod
+	}
+	paths := automaton.getShortestPaths()
+	var derivs map[Symbol][]Symbol
+	for _, c := range conflicts {
+		if c.symbol.isEOF() {
+			c.SymbolText = "<eof>"
+		} else {
+			c.SymbolText, _ = symTab.ToText(c.symbol)
+		}
+		c.ProductionTexts = nil
+		for _, p := range c.Productions {
+			c.ProductionTexts = append(c.ProductionTexts, productionText(num2Prod[p], symTab))
+		}
+		c.PathTexts = []string{}
+		for _, sym := range paths[c.State] {
+			text, _ := symTab.ToText(sym)
+			c.PathTexts = append(c.PathTexts, text)
+		}
+		c.ExampleTexts = nil
+		if c.Type == ConflictTypeReduceReduce {
+			if derivs == nil {
+				derivs = genShortestDerivations(prods)
+			}
+			for _, sym := range paths[c.State] {
+				for _, t := range derivs[sym] {
+					text, _ := symTab.ToText(t)
+					c.ExampleTexts = append(c.ExampleTexts, text)
+				}
+			}
+			c.ExampleTexts = append(c.ExampleTexts, c.SymbolText)
+		}
+	}
+}
+
+// genShortestDerivations returns, for each symbol, a shortest sequence of terminal symbols that the symbol derives.
+// A terminal symbol derives itself. A non-terminal symbol deriving no terminal strings is missing from the result.
+func genShortestDerivations(prods *productionSet) map[Symbol][]Symbol {
+	derivs := map[Symbol][]Symbol{}
+	sorted := prods.getAllSorted()
+	for _, prod := range sorted {
+		for _, sym := range prod.rhs {
+			if sym.isTerminal() {
+				derivs[sym] = []Symbol{sym}
+			}
+		}
+	}
+	for {
+		changed := false
+		for _, prod := range sorted {
+			deriv := []Symbol{}
+			derivable := true
+			for _, sym := range prod.rhs {
+				d, ok := derivs[sym]
+				if !ok {
+					derivable = false
+					break
+				}
+				deriv = append(deriv, d...)
+			}
+			if !derivable {
+				continue
+			}
+			if cur, ok := derivs[prod.lhs]; ok && len(cur) <= len(deriv) {
+				continue
+			}
+			derivs[prod.lhs] = deriv
+			changed = true
+		}
+		if !changed {
+			return derivs
+		}
+	}
+}
+
 type GoToType string
 
 const (
@@ -46,7 +287,7 @@ const (
 	GoToTypeError      = GoToType("error")
 )
 
-type goToEntry uint16
+type goToEntry uint32
 
 const goToEntryEmpty = goToEntry(0)
 
@@ -71,13 +312,40 @@ type ParsingTable struct {
 	numOfStates   int
 	numOfTSymbols int
 	numOfNSymbols int
+	policy        ConflictPolicy
+	eofMode       EOFMode
+
+	// resolvedConflicts are the conflicts that the conflict policy resolved.
+	resolvedConflicts []*Conflict
+
+	// symPrecs and prodPrecs are the precedences deciding shift/reduce conflicts silently. errorCells are the entries
+	// that a non-associative precedence made errors, and no action is written to them.
+	symPrecs   map[SymbolNum]*Precedence
+	prodPrecs  map[ProductionNum]*Precedence
+	errorCells map[int]struct{}
+
+	// reduceOnly holds, for each state, the production that every non-error ACTION entry of the state reduces by, or
+	// productionNumNil. A compact form of the table can store such a state as "reduce by the production on any
+	// terminal symbol" instead of the entries.
+	reduceOnly []ProductionNum
 
 	InitialState StateNum
 }
 
+// getAction returns the ACTION entry of a pair of a state and a terminal symbol. A reduce-only state reduces by its
+// production on any terminal symbol, so the result is the same as the one of a CompressedParsingTable, which has no
+// entries in the rows of such states.
 func (t *ParsingTable) getAction(state StateNum, sym SymbolNum) (ActionType, StateNum, ProductionNum) {
-	pos := state.Int()*t.numOfTSymbols + sym.Int()
-	return t.actionTable[pos].describe()
+	if prod := t.reduceOnlyProduction(state); prod != productionNumNil {
+		return ActionTypeReduce, stateNumInitial, prod
+	}
+	return t.actionEntryAt(state, sym).describe()
+}
+
+// actionEntryAt returns the entry written in the table. Unlike getAction, it doesn't take reduce-only states into
+// account, so the outputs listing the cells of the table show the errors of such states.
+func (t *ParsingTable) actionEntryAt(state StateNum, sym SymbolNum) actionEntry {
+	return t.actionTable[state.Int()*t.numOfTSymbols+sym.Int()]
 }
 
 func (t *ParsingTable) getGoTo(state StateNum, sym SymbolNum) (GoToType, StateNum) {
@@ -85,13 +353,127 @@ func (t *ParsingTable) getGoTo(state StateNum, sym SymbolNum) (GoToType, StateNu
 	return t.goToTable[pos].describe()
 }
 
-func (t *ParsingTable) writeShiftAction(state StateNum, sym Symbol, nextState StateNum) error {
+// expectedTerminals returns the terminal symbols that have shift or reduce actions in the state in ascending order.
+func (t *ParsingTable) expectedTerminals(state StateNum) []SymbolNum {
+	var syms []SymbolNum
+	row := t.actionTable[state.Int()*t.numOfTSymbols : (state.Int()+1)*t.numOfTSymbols]
+	for sym, act := range row {
+		if act.isEmpty() {
+			continue
+		}
+		syms = append(syms, SymbolNum(sym))
+	}
+	return syms
+}
+
+// defaultReduction returns the production that all the reduce actions in the state reduce by. When the state has
+// no reduce action, has reduce actions by different productions, or accepts the input, defaultReduction returns
+// productionNumNil. A driver can reduce by the production on any terminal symbol that has no shift action in the
+// state. Doing so only delays the detection of a syntax error until the next shift. The exception is a state having
+// an entry that a non-associative precedence made an error: reducing on its symbol would let the parser shift the
+// symbol afterward and accept an input like `a < b < c`, so defaultReduction returns productionNumNil for the state.
+func (t *ParsingTable) defaultReduction(state StateNum) ProductionNum {
+	if t.hasErrorCell(state) {
+		return productionNumNil
+	}
+	def := productionNumNil
+	row := t.actionTable[state.Int()*t.numOfTSymbols : (state.Int()+1)*t.numOfTSymbols]
+	for _, act := range row {
+		ty, _, prod := act.describe()
+		if ty == ActionTypeAccept {
+			return productionNumNil
+		}
+		if ty != ActionTypeReduce {
+			continue
+		}
+		if def != productionNumNil && def != prod {
+			return productionNumNil
+		}
+		def = prod
+	}
+	return def
+}
+
+// hasErrorCell reports whether the state has an entry that a non-associative precedence made an error.
+func (t *ParsingTable) hasErrorCell(state StateNum) bool {
+	for sym := 0; sym < t.numOfTSymbols; sym++ {
+		if _, ok := t.errorCells[state.Int()*t.numOfTSymbols+sym]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// markReduceOnlyStates finds the states whose non-error ACTION entries are all reduce actions by the same production.
+// Unlike defaultReduction, such a state has no shift action, so reducing on any terminal symbol never takes the place
+// of a shift. A state having an entry that a non-associative precedence made an error isn't marked because reducing
+// on the symbol would let the parser shift it afterward.
+func (t *ParsingTable) markReduceOnlyStates() {
+	t.reduceOnly = make([]ProductionNum, t.numOfStates)
+	for state := 0; state < t.numOfStates; state++ {
+		if t.hasErrorCell(StateNum(state)) {
+			continue
+		}
+		prod := productionNumNil
+		row := t.actionTable[state*t.numOfTSymbols : (state+1)*t.numOfTSymbols]
+		for _, act := range row {
+			if act.isEmpty() {
+				continue
+			}
+			ty, _, p := act.describe()
+			if ty != ActionTypeReduce || (prod != productionNumNil && prod != p) {
+				prod = productionNumNil
+				break
+			}
+			prod = p
+		}
+		t.reduceOnly[state] = prod
+	}
+}
+
+// reduceOnlyProduction returns the production that the state reduces by on any terminal symbol, or productionNumNil
+// when the state isn't a reduce-only state. See markReduceOnlyStates.
+func (t *ParsingTable) reduceOnlyProduction(state StateNum) ProductionNum {
+	if t.reduceOnly == nil {
+		return productionNumNil
+	}
+	return t.reduceOnly[state.Int()]
+}
+
+// writeShiftAction writes a shift action. When the entry is already occupied by a reduce action,
+// writeShiftAction returns a conflict and overwrites the entry only when the policy prefers the shift action.
+func (t *ParsingTable) writeShiftAction(state StateNum, sym Symbol, nextState StateNum) *Conflict {
 	pos := state.Int()*t.numOfTSymbols + sym.Num().Int()
+	if _, ok := t.errorCells[pos]; ok {
+		return nil
+	}
 	act := t.actionTable[pos]
 	if !act.isEmpty() {
-		ty, _, _ := act.describe()
+		ty, _, p := act.describe()
 		if ty == ActionTypeReduce {
-			return fmt.Errorf("shift/reduce conflict")
+			switch t.resolveByPrecedence(sym, p) {
+			case precedenceResolutionShift:
+				t.actionTable[pos] = newShiftActionEntry(nextState)
+				return nil
+			case precedenceResolutionReduce:
+				return nil
+			case precedenceResolutionError:
+				t.writeErrorCell(pos)
+				return nil
+			}
+			c := &Conflict{
+				Type:        ConflictTypeShiftReduce,
+				State:       state,
+				symbol:      sym,
+				NextState:   nextState,
+				Productions: []ProductionNum{p},
+			}
+			if t.policy != ConflictPolicyPreferShift {
+				return c
+			}
+			c.Resolved = true
+			t.actionTable[pos] = newShiftActionEntry(nextState)
+			return c
 		}
 	}
 	t.actionTable[pos] = newShiftActionEntry(nextState)
@@ -99,77 +481,215 @@ func (t *ParsingTable) writeShiftAction(state StateNum, sym Symbol, nextState St
 	return nil
 }
 
-func (t *ParsingTable) writeReduceAction(state StateNum, sym Symbol, prod ProductionNum) error {
+// writeReduceAction writes a reduce action unless the entry is already occupied. When the entry is occupied by
+// another action, writeReduceAction keeps the existing action and returns a conflict. An accept action competes with
+// reduce actions as a reduce action by the production of the augmented start symbol.
+func (t *ParsingTable) writeReduceAction(state StateNum, sym Symbol, prod ProductionNum) *Conflict {
+	return t.writeReduceOrAcceptAction(state, sym, prod, newReduceActionEntry(prod))
+}
+
+// writeAcceptAction writes the accept action on a lookahead symbol, which is EOF unless the EOF mode is implicit.
+func (t *ParsingTable) writeAcceptAction(state StateNum, sym Symbol) *Conflict {
+	return t.writeReduceOrAcceptAction(state, sym, ProductionNumStart, newAcceptActionEntry())
+}
+
+func (t *ParsingTable) writeReduceOrAcceptAction(state StateNum, sym Symbol, prod ProductionNum, entry actionEntry) *Conflict {
 	pos := state.Int()*t.numOfTSymbols + sym.Num().Int()
+	if _, ok := t.errorCells[pos]; ok {
+		return nil
+	}
 	act := t.actionTable[pos]
 	if !act.isEmpty() {
-		ty, _, p := act.describe()
-		if ty == ActionTypeReduce && p != prod {
-			return fmt.Errorf("reduce/reduce conflict")
+		ty, next, p := act.describe()
+		if ty == ActionTypeReduce || ty == ActionTypeAccept {
+			if p == prod {
+				return nil
+			}
+			return &Conflict{
+				Type:        ConflictTypeReduceReduce,
+				State:       state,
+				symbol:      sym,
+				Productions: []ProductionNum{p, prod},
+			}
+		}
+		switch t.resolveByPrecedence(sym, prod) {
+		case precedenceResolutionShift:
+			return nil
+		case precedenceResolutionReduce:
+			t.actionTable[pos] = entry
+			return nil
+		case precedenceResolutionError:
+			t.writeErrorCell(pos)
+			return nil
+		}
+		return &Conflict{
+			Type:        ConflictTypeShiftReduce,
+			State:       state,
+			Resolved:    t.policy == ConflictPolicyPreferShift,
+			symbol:      sym,
+			NextState:   next,
+			Productions: []ProductionNum{prod},
 		}
-		return fmt.Errorf("shift/reduce conflict")
 	}
-	t.actionTable[pos] = newReduceActionEntry(prod)
+	t.actionTable[pos] = entry
 
 	return nil
 }
 
+// writeErrorCell makes the entry an error that no action can overwrite.
+func (t *ParsingTable) writeErrorCell(pos int) {
+	t.actionTable[pos] = actionEntryEmpty
+	t.errorCells[pos] = struct{}{}
+}
+
 func (t *ParsingTable) writeGoTo(state StateNum, sym Symbol, nextState StateNum) {
 	pos := state.Int()*t.numOfNSymbols + sym.Num().Int()
 	t.goToTable[pos] = newGoToEntry(nextState)
 }
 
-func genSLRParsingTable(automaton *LR0Automaton, prods *productionSet, follow *Follow, numOfTSyms, numOfNSyms int) (*ParsingTable, error) {
+// genSLRParsingTable generates the parsing table. A shift/reduce conflict between a terminal symbol in symPrecs and a
+// production having a precedence is resolved by the precedences and isn't reported.
+func genSLRParsingTable(automaton *LR0Automaton, prods *productionSet, follow *Follow, numOfTSyms, numOfNSyms int, policy ConflictPolicy, eofMode EOFMode, symPrecs map[SymbolNum]*Precedence) (*ParsingTable, error) {
 	var ptab *ParsingTable
 	{
 		initialState := automaton.states[automaton.initialState]
+		prodPrecs := map[ProductionNum]*Precedence{}
+		for _, prod := range prods.getAll() {
+			if prod.prec != nil {
+				prodPrecs[prod.num] = prod.prec
+			}
+		}
 		ptab = &ParsingTable{
 			actionTable:   make([]actionEntry, len(automaton.states)*numOfTSyms),
 			goToTable:     make([]goToEntry, len(automaton.states)*numOfNSyms),
 			numOfStates:   len(automaton.states),
 			numOfTSymbols: numOfTSyms,
 			numOfNSymbols: numOfNSyms,
+			policy:        policy,
+			eofMode:       eofMode,
+			symPrecs:      symPrecs,
+			prodPrecs:     prodPrecs,
+			errorCells:    map[int]struct{}{},
 			InitialState:  initialState.Num,
 		}
 	}
 
-	for _, state := range automaton.states {
+	var conflicts []*Conflict
+	// An entry may take part in a conflict with more than two actions, so we merge them into one conflict.
+	cellConflicts := map[int]*Conflict{}
+	addConflict := func(c *Conflict) {
+		pos := c.State.Int()*numOfTSyms + c.symbol.Num().Int()
+		if prev, ok := cellConflicts[pos]; ok {
+			for _, p := range c.Productions {
+				if !containsProductionNum(prev.Productions, p) {
+					prev.Productions = append(prev.Productions, p)
+				}
+			}
+			if c.Type == ConflictTypeShiftReduce {
+				prev.Type = ConflictTypeShiftReduce
+				prev.NextState = c.NextState
+				prev.Resolved = c.Resolved
+			}
+			return
+		}
+		cellConflicts[pos] = c
+		conflicts = append(conflicts, c)
+	}
+
+	for _, state := range automaton.getStatesSorted() {
 		for sym, kID := range state.Next {
 			nextState := automaton.states[kID]
 			if sym.isTerminal() {
-				err := ptab.writeShiftAction(state.Num, sym, nextState.Num)
-				if err != nil {
-					return nil, err
+				c := ptab.writeShiftAction(state.Num, sym, nextState.Num)
+				if c != nil {
+					addConflict(c)
 				}
 			} else {
 				ptab.writeGoTo(state.Num, sym, nextState.Num)
 			}
 		}
 
+		var reducibleProds []*production
 		for prodID := range state.Reducible {
 			prod, _ := prods.findByID(prodID)
-			flw, err := follow.Get(prod.lhs)
-			if err != nil {
-				return nil, err
-			}
-			for sym := range flw.symbols {
-				err := ptab.writeReduceAction(state.Num, sym, prod.num)
-				if err != nil {
-					return nil, err
+			reducibleProds = append(reducibleProds, prod)
+		}
+		sort.Slice(reducibleProds, func(i, j int) bool {
+			return reducibleProds[i].num < reducibleProds[j].num
+		})
+		for _, prod := range reducibleProds {
+			// The augmented start symbol is followed only by EOF, and reducing by its production means accepting the
+			// input.
+			if prod.num == ProductionNumStart {
+				c := ptab.writeAcceptAction(state.Num, SymbolEOF)
+				if c != nil {
+					addConflict(c)
 				}
+				if eofMode == EOFModeImplicit {
+					for _, sym := range sortedSymbols(follow.Get(prod.rhs[0]).symbols) {
+						// Shifting the symbol takes precedence, so the parser accepts the longest prefix.
+						if _, ok := state.Next[sym]; ok {
+							continue
+						}
+						c := ptab.writeAcceptAction(state.Num, sym)
+						if c != nil {
+							addConflict(c)
+						}
+					}
+				}
+				continue
 			}
+			flw := follow.Get(prod.lhs)
+			syms := sortedSymbols(flw.symbols)
 			if flw.eof {
-				err := ptab.writeReduceAction(state.Num, SymbolEOF, prod.num)
-				if err != nil {
-					return nil, err
+				syms = append(syms, SymbolEOF)
+			}
+			for _, sym := range syms {
+				c := ptab.writeReduceAction(state.Num, sym, prod.num)
+				if c != nil {
+					addConflict(c)
 				}
 			}
 		}
 	}
+	var unresolved []*Conflict
+	for _, c := range conflicts {
+		if c.Resolved {
+			ptab.resolvedConflicts = append(ptab.resolvedConflicts, c)
+		} else {
+			unresolved = append(unresolved, c)
+		}
+	}
+	if len(unresolved) > 0 {
+		return nil, &ConflictError{
+			Conflicts: unresolved,
+		}
+	}
+	ptab.markReduceOnlyStates()
 
 	return ptab, nil
 }
 
+func sortedSymbols(set map[Symbol]struct{}) []Symbol {
+	var syms []Symbol
+	for sym := range set {
+		syms = append(syms, sym)
+	}
+	sort.Slice(syms, func(i, j int) bool {
+		return syms[i] < syms[j]
+	})
+	return syms
+}
+
+func containsProductionNum(nums []ProductionNum, num ProductionNum) bool {
+	for _, n := range nums {
+		if n == num {
+			return true
+		}
+	}
+	return false
+}
+
 func PrintParsingTable(w io.Writer, ptab *ParsingTable) {
 	if w == nil {
 		return
@@ -179,12 +699,14 @@ func PrintParsingTable(w io.Writer, ptab *ParsingTable) {
 	for stateNum := 0; stateNum < ptab.numOfStates; stateNum++ {
 		for symNum := 0; symNum < ptab.numOfTSymbols; symNum++ {
 			fmt.Fprintf(w, "  %v-%v: ", stateNum, symNum)
-			ty, nextState, prod := ptab.getAction(StateNum(stateNum), SymbolNum(symNum))
+			ty, nextState, prod := ptab.actionEntryAt(StateNum(stateNum), SymbolNum(symNum)).describe()
 			switch ty {
 			case ActionTypeShift:
 				fmt.Fprintf(w, "shift %v", nextState)
 			case ActionTypeReduce:
 				fmt.Fprintf(w, "reduce %v", prod)
+			case ActionTypeAccept:
+				fmt.Fprintf(w, "accept")
 			default:
 				fmt.Fprintf(w, "error")
 			}
@@ -207,3 +729,158 @@ func PrintParsingTable(w io.Writer, ptab *ParsingTable) {
 		}
 	}
 }
+
+func (t *ParsingTable) actionCellText(state StateNum, sym SymbolNum) string {
+	ty, nextState, prod := t.actionEntryAt(state, sym).describe()
+	switch ty {
+	case ActionTypeShift:
+		return fmt.Sprintf("s%v", nextState)
+	case ActionTypeReduce:
+		return fmt.Sprintf("r%v", prod)
+	case ActionTypeAccept:
+		return "acc"
+	}
+	return ""
+}
+
+func (t *ParsingTable) goToCellText(state StateNum, sym SymbolNum) string {
+	ty, nextState := t.getGoTo(state, sym)
+	if ty != GoToTypeRegistered {
+		return ""
+	}
+	return nextState.String()
+}
+
+// WriteParsingTableGrid writes the parsing table as an aligned matrix. Each row is a state, and the columns are
+// terminal symbols, the EOF symbol, and non-terminal symbols. An ACTION cell is sN (shift to state N), rN (reduce by
+// production N), or acc (accept), and a GOTO cell is the next state. Error cells are blank.
+func WriteParsingTableGrid(w io.Writer, tab *Table) error {
+	ptab := tab.LR
+
+	header := []string{"state"}
+	for num := terminalSymbolNumMin.Int(); num < ptab.numOfTSymbols; num++ {
+		text, err := tab.symTab.ToTextFromNumT(SymbolNum(num))
+		if err != nil {
+			return err
+		}
+		header = append(header, text)
+	}
+	header = append(header, "<eof>")
+	// nonTerminalSymbolNumMin represents the augmented start symbol, which never appears in the GOTO table.
+	for num := nonTerminalSymbolNumMin.Int() + 1; num < ptab.numOfNSymbols; num++ {
+		text, err := tab.symTab.ToTextFromNumN(SymbolNum(num))
+		if err != nil {
+			return err
+		}
+		header = append(header, text)
+	}
+
+	rows := [][]string{header}
+	for stateNum := 0; stateNum < ptab.numOfStates; stateNum++ {
+		state := StateNum(stateNum)
+		row := []string{state.String()}
+		for symNum := terminalSymbolNumMin.Int(); symNum < ptab.numOfTSymbols; symNum++ {
+			row = append(row, ptab.actionCellText(state, SymbolNum(symNum)))
+		}
+		row = append(row, ptab.actionCellText(state, SymbolEOF.Num()))
+		for symNum := nonTerminalSymbolNumMin.Int() + 1; symNum < ptab.numOfNSymbols; symNum++ {
+			row = append(row, ptab.goToCellText(state, SymbolNum(symNum)))
+		}
+		rows = append(rows, row)
+	}
+
+	widths := make([]int, len(header))
+	for _, row := range rows {
+		for i, cell := range row {
+			if l := utf8.RuneCountInString(cell); l > widths[i] {
+				widths[i] = l
+			}
+		}
+	}
+	for _, row := range rows {
+		var b strings.Builder
+		for i, cell := range row {
+			if i > 0 {
+				b.WriteString(" | ")
+			}
+			b.WriteString(cell)
+			b.WriteString(strings.Repeat(" ", widths[i]-utf8.RuneCountInString(cell)))
+		}
+		_, err := fmt.Fprintln(w, strings.TrimRight(b.String(), " "))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// WriteParsingTableCSV writes the ACTION table and the GOTO table as two CSV blocks separated by an empty line.
+// The cells use the same notation as WriteParsingTableGrid.
+func WriteParsingTableCSV(w io.Writer, tab *Table, symTab *SymbolTable) error {
+	ptab := tab.LR
+	cw := csv.NewWriter(w)
+
+	header := []string{"ACTION"}
+	for num := terminalSymbolNumMin.Int(); num < ptab.numOfTSymbols; num++ {
+		text, err := symTab.ToTextFromNumT(SymbolNum(num))
+		if err != nil {
+			return err
+		}
+		header = append(header, text)
+	}
+	header = append(header, "<eof>")
+	err := cw.Write(header)
+	if err != nil {
+		return err
+	}
+	for stateNum := 0; stateNum < ptab.numOfStates; stateNum++ {
+		state := StateNum(stateNum)
+		row := []string{state.String()}
+		for symNum := terminalSymbolNumMin.Int(); symNum < ptab.numOfTSymbols; symNum++ {
+			row = append(row, ptab.actionCellText(state, SymbolNum(symNum)))
+		}
+		row = append(row, ptab.actionCellText(state, SymbolEOF.Num()))
+		err := cw.Write(row)
+		if err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintln(w)
+	if err != nil {
+		return err
+	}
+
+	header = []string{"GOTO"}
+	// nonTerminalSymbolNumMin represents the augmented start symbol, which never appears in the GOTO table.
+	for num := nonTerminalSymbolNumMin.Int() + 1; num < ptab.numOfNSymbols; num++ {
+		text, err := symTab.ToTextFromNumN(SymbolNum(num))
+		if err != nil {
+			return err
+		}
+		header = append(header, text)
+	}
+	err = cw.Write(header)
+	if err != nil {
+		return err
+	}
+	for stateNum := 0; stateNum < ptab.numOfStates; stateNum++ {
+		state := StateNum(stateNum)
+		row := []string{state.String()}
+		for symNum := nonTerminalSymbolNumMin.Int() + 1; symNum < ptab.numOfNSymbols; symNum++ {
+			row = append(row, ptab.goToCellText(state, SymbolNum(symNum)))
+		}
+		err := cw.Write(row)
+		if err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+
+	return cw.Error()
+}
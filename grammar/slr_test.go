@@ -3,6 +3,7 @@ package grammar
 import (
 	"fmt"
 	"os"
+	"sort"
 	"strings"
 	"testing"
 
@@ -39,7 +40,7 @@ func TestGenSLRParsingTable(t *testing.T) {
 
 	numOfTSyms := gram.SymbolTable.getNumOfTerminalSymbols()
 	numOfNSyms := gram.SymbolTable.getNumOfNonTerminalSymbols()
-	ptab, err := genSLRParsingTable(automaton, gram.ProductionSet, follow, numOfTSyms, numOfNSyms)
+	ptab, err := genSLRParsingTable(automaton, gram.ProductionSet, follow, numOfTSyms, numOfNSyms, ConflictPolicyError, EOFModeExplicit, nil)
 	if err != nil {
 		t.Fatalf("failed to create a SLR parsing table: %v", err)
 	}
@@ -125,8 +126,7 @@ func TestGenSLRParsingTable(t *testing.T) {
 					nextState: expectedKernels[6],
 				},
 				SymbolEOF: {
-					ty:         ActionTypeReduce,
-					production: genProd("e'", "e"),
+					ty: ActionTypeAccept,
 				},
 			},
 		},
@@ -385,7 +385,7 @@ func TestGenSLRParsingTable(t *testing.T) {
 					if _, checked := nonEmptyEntries[SymbolNum(symNum)]; checked {
 						continue
 					}
-					ty, stateNum, prodNum := ptab.getAction(state.Num, SymbolNum(symNum))
+					ty, stateNum, prodNum := ptab.actionEntryAt(state.Num, SymbolNum(symNum)).describe()
 					if ty != ActionTypeError {
 						t.Errorf("unexpected ACTION entry; state: #%v, symbol: #%v, action type: %v, next state: #%v, prodction: #%v", state.Num, symNum, ty, stateNum, prodNum)
 					}
@@ -454,3 +454,428 @@ func findProductionByNum(prods *productionSet, num ProductionNum) *production {
 	}
 	return nil
 }
+
+func TestActionEntry_WideValues(t *testing.T) {
+	for _, state := range []StateNum{1, 32767, 32768, 65536, 1 << 20} {
+		ty, s, _ := newShiftActionEntry(state).describe()
+		if ty != ActionTypeShift || s != state {
+			t.Errorf("shift action was broken; want: shift %v, got: %v %v", state, ty, s)
+		}
+
+		gTy, gs := newGoToEntry(state).describe()
+		if gTy != GoToTypeRegistered || gs != state {
+			t.Errorf("GOTO entry was broken; want: %v, got: %v %v", state, gTy, gs)
+		}
+	}
+	for _, prod := range []ProductionNum{productionNumMin, 32767, 32768, 65535} {
+		ty, _, p := newReduceActionEntry(prod).describe()
+		if ty != ActionTypeReduce || p != prod {
+			t.Errorf("reduce action was broken; want: reduce %v, got: %v %v", prod, ty, p)
+		}
+	}
+	if ty, _, _ := newAcceptActionEntry().describe(); ty != ActionTypeAccept {
+		t.Errorf("accept action was broken; want: %v, got: %v", ActionTypeAccept, ty)
+	}
+}
+
+func TestGenSLRParsingTable_ManyStates(t *testing.T) {
+	// Each alternative starts with a distinct terminal and continues with a long run of A,
+	// so every dot position of every alternative becomes a distinct state.
+	numOfAlts := 128
+	runLen := 256
+	var b strings.Builder
+	fmt.Fprintf(&b, "s:")
+	for i := 0; i < numOfAlts; i++ {
+		if i > 0 {
+			fmt.Fprintf(&b, " |")
+		}
+		fmt.Fprintf(&b, " T%v%v", i, strings.Repeat(" A", runLen))
+	}
+	fmt.Fprintf(&b, ";")
+
+	parser, err := parser.NewParser(strings.NewReader(b.String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ast, err := parser.Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	gram, err := GenGrammar(ast)
+	if err != nil {
+		t.Fatal(err)
+	}
+	first, err := genFirst(gram.ProductionSet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	follow, err := genFollow(gram.ProductionSet, first)
+	if err != nil {
+		t.Fatal(err)
+	}
+	automaton, err := genLR0Automaton(gram.ProductionSet, gram.AugmentedStartSymbol)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(automaton.states) <= 32768 {
+		t.Fatalf("the test grammar must produce more than 32768 states; got: %v", len(automaton.states))
+	}
+
+	numOfTSyms := gram.SymbolTable.getNumOfTerminalSymbols()
+	numOfNSyms := gram.SymbolTable.getNumOfNonTerminalSymbols()
+	ptab, err := genSLRParsingTable(automaton, gram.ProductionSet, follow, numOfTSyms, numOfNSyms, ConflictPolicyError, EOFModeExplicit, nil)
+	if err != nil {
+		t.Fatalf("failed to create a SLR parsing table: %v", err)
+	}
+
+	for _, state := range automaton.states {
+		for sym, kID := range state.Next {
+			nextState := automaton.states[kID]
+			if sym.isTerminal() {
+				ty, stateNum, _ := ptab.getAction(state.Num, sym.Num())
+				if ty != ActionTypeShift || stateNum != nextState.Num {
+					t.Fatalf("unexpected ACTION entry; state: #%v, symbol: %v, want: shift %v, got: %v %v", state.Num, sym, nextState.Num, ty, stateNum)
+				}
+			} else {
+				ty, stateNum := ptab.getGoTo(state.Num, sym.Num())
+				if ty != GoToTypeRegistered || stateNum != nextState.Num {
+					t.Fatalf("unexpected GOTO entry; state: #%v, symbol: %v, want: %v, got: %v %v", state.Num, sym, nextState.Num, ty, stateNum)
+				}
+			}
+		}
+	}
+}
+
+func TestWriteParsingTableGrid(t *testing.T) {
+	_, tab := genTestTable(t, "s: A s | B;")
+
+	var b strings.Builder
+	err := WriteParsingTableGrid(&b, tab)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `state | A  | B  | <eof> | s
+0     | s2 | s3 |       | 1
+1     |    |    | acc   |
+2     | s2 | s3 |       | 4
+3     |    |    | r3    |
+4     |    |    | r2    |
+`
+	if b.String() != expected {
+		t.Fatalf("unexpected grid\nwant:\n%v\ngot:\n%v", expected, b.String())
+	}
+}
+
+func TestWriteParsingTableCSV(t *testing.T) {
+	gram, tab := genTestTable(t, "s: A s | B;")
+
+	var b strings.Builder
+	err := WriteParsingTableCSV(&b, tab, gram.SymbolTable)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `ACTION,A,B,<eof>
+0,s2,s3,
+1,,,acc
+2,s2,s3,
+3,,,r3
+4,,,r2
+
+GOTO,s
+0,1
+1,
+2,4
+3,
+4,
+`
+	if b.String() != expected {
+		t.Fatalf("unexpected CSV\nwant:\n%v\ngot:\n%v", expected, b.String())
+	}
+}
+
+func TestGenTable_Conflicts(t *testing.T) {
+	src := "e: e ADD e | e MUL e | NUM;"
+
+	parser, err := parser.NewParser(strings.NewReader(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ast, err := parser.Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	gram, err := GenGrammar(ast)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = GenTable(gram)
+	cErr, ok := err.(*ConflictError)
+	if !ok {
+		t.Fatalf("GenTable must return a ConflictError; got: %v", err)
+	}
+	// Each of the states after `e ADD e` and `e MUL e` has conflicts on ADD and MUL.
+	if len(cErr.Conflicts) != 4 {
+		t.Fatalf("unexpected number of conflicts; want: %v, got: %v\n%v", 4, len(cErr.Conflicts), cErr)
+	}
+	if counts := cErr.Counts(); counts != (ConflictCounts{ShiftReduce: 4}) {
+		t.Fatalf("unexpected conflict counts; want: %v, got: %v", ConflictCounts{ShiftReduce: 4}, counts)
+	}
+	if !strings.HasPrefix(cErr.Error(), "4 conflicts found: 4 shift/reduce, 0 reduce/reduce\n") {
+		t.Fatalf("the error must start with the summary: %v", cErr)
+	}
+	for _, c := range cErr.Conflicts {
+		if c.Type != ConflictTypeShiftReduce {
+			t.Fatalf("unexpected conflict type; want: %v, got: %v", ConflictTypeShiftReduce, c.Type)
+		}
+		if c.SymbolText != "ADD" && c.SymbolText != "MUL" {
+			t.Fatalf("unexpected lookahead symbol: %v", c.SymbolText)
+		}
+		if len(c.ProductionTexts) != 1 || (c.ProductionTexts[0] != "e: e ADD e" && c.ProductionTexts[0] != "e: e MUL e") {
+			t.Fatalf("unexpected productions: %v", c.ProductionTexts)
+		}
+	}
+}
+
+func TestGenTable_PreferShift(t *testing.T) {
+	src := "stmt: IF cond THEN stmt | IF cond THEN stmt ELSE stmt | OTHER; cond: COND;"
+
+	t.Run("the error policy reports the dangling else", func(t *testing.T) {
+		gram := genTestGrammar(t, src)
+		_, err := GenTable(gram, WithConflictPolicy(ConflictPolicyError))
+		cErr, ok := err.(*ConflictError)
+		if !ok {
+			t.Fatalf("GenTable must return a ConflictError; got: %v", err)
+		}
+		if len(cErr.Conflicts) != 1 || cErr.Conflicts[0].Resolved {
+			t.Fatalf("unexpected conflicts: %v", cErr)
+		}
+	})
+
+	t.Run("the prefer-shift policy keeps the shift and records the resolved conflict", func(t *testing.T) {
+		gram := genTestGrammar(t, src)
+		tab, err := GenTable(gram, WithConflictPolicy(ConflictPolicyPreferShift))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(tab.ResolvedConflicts) != 1 {
+			t.Fatalf("unexpected number of resolved conflicts; want: %v, got: %v", 1, len(tab.ResolvedConflicts))
+		}
+		if counts := tab.ResolvedConflictCounts(); counts != (ConflictCounts{ShiftReduce: 1}) {
+			t.Fatalf("unexpected conflict counts; want: %v, got: %v", ConflictCounts{ShiftReduce: 1}, counts)
+		}
+		c := tab.ResolvedConflicts[0]
+		if !c.Resolved || c.Type != ConflictTypeShiftReduce || c.SymbolText != "ELSE" {
+			t.Fatalf("unexpected conflict: %v", c)
+		}
+		if strings.Join(c.PathTexts, " ") != "IF cond THEN stmt" {
+			t.Fatalf("unexpected path; want: IF cond THEN stmt, got: %v", c.PathTexts)
+		}
+		if len(c.ExampleTexts) > 0 {
+			t.Fatalf("a shift/reduce conflict must not have an example; got: %v", c.ExampleTexts)
+		}
+		if !strings.HasSuffix(c.String(), "; resolved as shift") {
+			t.Fatalf("the text must state the resolution: %v", c)
+		}
+		ty, next, _, ok := tab.ActionByName(c.State, "ELSE")
+		if !ok || ty != ActionTypeShift || next != c.NextState {
+			t.Fatalf("the entry must keep the shift action; want: shift %v, got: %v %v", c.NextState, ty, next)
+		}
+	})
+
+	t.Run("the prefer-shift policy doesn't resolve reduce/reduce conflicts", func(t *testing.T) {
+		gram := genTestGrammar(t, "s: a | b; a: A; b: A;")
+		_, err := GenTable(gram, WithConflictPolicy(ConflictPolicyPreferShift))
+		cErr, ok := err.(*ConflictError)
+		if !ok {
+			t.Fatalf("GenTable must return a ConflictError; got: %v", err)
+		}
+		if len(cErr.Conflicts) != 1 || cErr.Conflicts[0].Type != ConflictTypeReduceReduce {
+			t.Fatalf("unexpected conflicts: %v", cErr)
+		}
+		if counts := cErr.Counts(); counts != (ConflictCounts{ReduceReduce: 1}) {
+			t.Fatalf("unexpected conflict counts; want: %v, got: %v", ConflictCounts{ReduceReduce: 1}, counts)
+		}
+	})
+}
+
+func TestGenSLRParsingTable_EOFMode(t *testing.T) {
+	src := "s: LBRACE a RBRACE; a: s | NUM;"
+	input := []string{"LBRACE", "NUM", "RBRACE", "RBRACE"}
+
+	t.Run("the explicit mode accepts only at the end of the input", func(t *testing.T) {
+		gram := genTestGrammar(t, src)
+		tab, err := GenTable(gram)
+		if err != nil {
+			t.Fatal(err)
+		}
+		trace, err := TraceParse(gram, tab, input)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if trace.Accepted {
+			t.Fatalf("the explicit mode must not accept a prefix")
+		}
+		trace, err = TraceParse(gram, tab, input[:3])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !trace.Accepted {
+			t.Fatalf("the explicit mode must accept the whole input")
+		}
+	})
+
+	t.Run("the implicit mode accepts a prefix on a symbol following the start symbol", func(t *testing.T) {
+		gram := genTestGrammar(t, src)
+		tab, err := GenTable(gram, WithEOFMode(EOFModeImplicit))
+		if err != nil {
+			t.Fatal(err)
+		}
+		trace, err := TraceParse(gram, tab, input)
+		if err != nil {
+			t.Fatal(err)
+		}
+		last := trace.Steps[len(trace.Steps)-1]
+		if !trace.Accepted || last.SymbolText != "RBRACE" {
+			t.Fatalf("the implicit mode must accept the prefix leaving the lookahead; last step: %+v", last)
+		}
+		trace, err = TraceParse(gram, tab, input[:3])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !trace.Accepted {
+			t.Fatalf("the implicit mode must accept the whole input too")
+		}
+		// NUM doesn't follow the start symbol.
+		trace, err = TraceParse(gram, tab, []string{"LBRACE", "NUM", "RBRACE", "NUM"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if trace.Accepted {
+			t.Fatalf("the implicit mode must not accept on a symbol out of the FOLLOW set")
+		}
+	})
+
+	t.Run("the implicit mode prefers shifting to accepting", func(t *testing.T) {
+		gram := genTestGrammar(t, "s: s ADD NUM | NUM;")
+		tab, err := GenTable(gram, WithEOFMode(EOFModeImplicit))
+		if err != nil {
+			t.Fatal(err)
+		}
+		trace, err := TraceParse(gram, tab, []string{"NUM", "ADD", "NUM"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !trace.Accepted || trace.Steps[len(trace.Steps)-1].SymbolText != "<eof>" {
+			t.Fatalf("the parser must accept the longest prefix")
+		}
+	})
+
+	t.Run("an unknown mode is an error", func(t *testing.T) {
+		gram := genTestGrammar(t, src)
+		_, err := GenTable(gram, WithEOFMode(EOFMode("foo")))
+		if err == nil {
+			t.Fatalf("GenTable must reject an unknown mode")
+		}
+	})
+}
+
+func TestGenSLRParsingTable_ReduceOnlyStates(t *testing.T) {
+	gram, tab := genTestTable(t, "s: s ADD t | t | t MUL; t: NUM | LPAREN s RPAREN;")
+	var marked []string
+	for state := 0; state < tab.LR.numOfStates; state++ {
+		prod := tab.LR.reduceOnlyProduction(StateNum(state))
+		if prod == productionNumNil {
+			continue
+		}
+		for sym := 0; sym < tab.LR.numOfTSymbols; sym++ {
+			ty, _, p := tab.LR.getAction(StateNum(state), SymbolNum(sym))
+			if ty != ActionTypeError && (ty != ActionTypeReduce || p != prod) {
+				t.Fatalf("a reduce-only state has another action; state: %v, symbol: %v, action: %v %v", state, sym, ty, p)
+			}
+		}
+		for _, p := range gram.ProductionSet.getAllSorted() {
+			if p.num == prod {
+				marked = append(marked, productionText(p, gram.SymbolTable))
+			}
+		}
+	}
+	sort.Strings(marked)
+	// The state after `t` isn't marked because it can also shift MUL, and the accepting state isn't marked either.
+	expected := []string{"s: s ADD t", "s: t MUL", "t: LPAREN s RPAREN", "t: NUM"}
+	if strings.Join(marked, ", ") != strings.Join(expected, ", ") {
+		t.Fatalf("unexpected reduce-only states; want: %v, got: %v", expected, marked)
+	}
+}
+
+func TestConflict_Example(t *testing.T) {
+	gram := genTestGrammar(t, "s: x a C | x b C; a: A; b: A; x: X x | Y;")
+	_, err := GenTable(gram)
+	cErr, ok := err.(*ConflictError)
+	if !ok {
+		t.Fatalf("GenTable must return a ConflictError; got: %v", err)
+	}
+	if len(cErr.Conflicts) != 1 || cErr.Conflicts[0].Type != ConflictTypeReduceReduce {
+		t.Fatalf("unexpected conflicts: %v", cErr)
+	}
+	c := cErr.Conflicts[0]
+	if strings.Join(c.PathTexts, " ") != "x A" {
+		t.Fatalf("unexpected path; want: x A, got: %v", c.PathTexts)
+	}
+	// x derives Y, the shortest terminal string, and the lookahead symbol follows the input.
+	if strings.Join(c.ExampleTexts, " ") != "Y A C" {
+		t.Fatalf("unexpected example; want: Y A C, got: %v", c.ExampleTexts)
+	}
+	if !strings.HasSuffix(c.String(), "; path: x A; example: Y A C") {
+		t.Fatalf("the text must contain the example: %v", c)
+	}
+}
+
+func TestGenShortestDerivations(t *testing.T) {
+	gram := genTestGrammar(t, "s: a b | B; a: A a | A | b; b: C C C | D a;")
+	genSym := newTestSymbolGenerator(t, gram.SymbolTable)
+	derivs := genShortestDerivations(gram.ProductionSet)
+	for _, tt := range []struct {
+		sym      string
+		expected string
+	}{
+		{sym: "s", expected: "B"},
+		{sym: "a", expected: "A"},
+		{sym: "b", expected: "D A"},
+	} {
+		var texts []string
+		for _, sym := range derivs[genSym(tt.sym)] {
+			text, _ := gram.SymbolTable.ToText(sym)
+			texts = append(texts, text)
+		}
+		if strings.Join(texts, " ") != tt.expected {
+			t.Fatalf("unexpected derivation; symbol: %v, want: %v, got: %v", tt.sym, tt.expected, texts)
+		}
+	}
+}
+
+func BenchmarkGenSLRParsingTable(b *testing.B) {
+	gram := genBenchmarkGrammar(b)
+	fst, err := genFirst(gram.ProductionSet)
+	if err != nil {
+		b.Fatal(err)
+	}
+	flw, err := genFollow(gram.ProductionSet, fst)
+	if err != nil {
+		b.Fatal(err)
+	}
+	automaton, err := genLR0Automaton(gram.ProductionSet, gram.AugmentedStartSymbol)
+	if err != nil {
+		b.Fatal(err)
+	}
+	numOfTSyms := gram.SymbolTable.getNumOfTerminalSymbols()
+	numOfNSyms := gram.SymbolTable.getNumOfNonTerminalSymbols()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, err := genSLRParsingTable(automaton, gram.ProductionSet, flw, numOfTSyms, numOfNSyms, ConflictPolicyError, EOFModeExplicit, nil)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package grammar
+
+import "fmt"
+
+// Stats is a summary of an LR0 automaton.
+type Stats struct {
+	NumOfStates int
+
+	AvgKernelSize float64
+	MaxKernelSize int
+
+	// NumOfReduceOnlyStates is the number of states that have reducible items and no transitions.
+	NumOfReduceOnlyStates int
+
+	// NumOfConflictStates is the number of states that have LR(0) conflicts, that is, states that have a reducible
+	// item together with a transition on a terminal symbol or another reducible item. The lookaheads of the SLR
+	// parsing table may resolve them.
+	NumOfConflictStates int
+}
+
+func (s Stats) String() string {
+	return fmt.Sprintf("states: %v, kernel size: avg %.2f, max %v, reduce-only states: %v, LR(0) conflict states: %v",
+		s.NumOfStates, s.AvgKernelSize, s.MaxKernelSize, s.NumOfReduceOnlyStates, s.NumOfConflictStates)
+}
+
+// AutomatonStats walks the states of the automaton and summarizes them.
+func AutomatonStats(automaton *LR0Automaton) Stats {
+	stats := Stats{
+		NumOfStates: len(automaton.states),
+	}
+	if stats.NumOfStates == 0 {
+		return stats
+	}
+
+	kernelItems := 0
+	for _, state := range automaton.states {
+		kernelItems += len(state.Items)
+		if len(state.Items) > stats.MaxKernelSize {
+			stats.MaxKernelSize = len(state.Items)
+		}
+
+		if len(state.Reducible) == 0 {
+			continue
+		}
+		if len(state.Next) == 0 {
+			stats.NumOfReduceOnlyStates++
+		}
+		shiftsTerminal := false
+		for sym := range state.Next {
+			if sym.isTerminal() {
+				shiftsTerminal = true
+				break
+			}
+		}
+		if shiftsTerminal || len(state.Reducible) > 1 {
+			stats.NumOfConflictStates++
+		}
+	}
+	stats.AvgKernelSize = float64(kernelItems) / float64(stats.NumOfStates)
+
+	return stats
+}
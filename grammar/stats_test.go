@@ -0,0 +1,47 @@
+package grammar
+
+import "testing"
+
+func TestAutomatonStats(t *testing.T) {
+	tests := []struct {
+		caption string
+		src     string
+		stats   Stats
+	}{
+		{
+			caption: "an expression grammar",
+			src:     "e: e ADD t | t; t: t MUL f | f; f: LPAREN e RPAREN | NUMBER;",
+			stats: Stats{
+				NumOfStates:           12,
+				AvgKernelSize:         16.0 / 12.0,
+				MaxKernelSize:         2,
+				NumOfReduceOnlyStates: 4,
+				NumOfConflictStates:   3,
+			},
+		},
+		{
+			caption: "a grammar that has a state reducing two productions",
+			src:     "s: a B | b C; a: A; b: A;",
+			stats: Stats{
+				NumOfStates:           7,
+				AvgKernelSize:         8.0 / 7.0,
+				MaxKernelSize:         2,
+				NumOfReduceOnlyStates: 4,
+				NumOfConflictStates:   1,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.caption, func(t *testing.T) {
+			gram := genTestGrammar(t, tt.src)
+			automaton, err := genLR0Automaton(gram.ProductionSet, gram.AugmentedStartSymbol)
+			if err != nil {
+				t.Fatal(err)
+			}
+			stats := AutomatonStats(automaton)
+			if stats != tt.stats {
+				t.Fatalf("unexpected stats;\nwant: %+v\ngot:  %+v", tt.stats, stats)
+			}
+		})
+	}
+}
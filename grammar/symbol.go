@@ -17,13 +17,13 @@ func (t symbolKind) String() string {
 	return string(t)
 }
 
-type SymbolNum uint16
+type SymbolNum uint32
 
 func (n SymbolNum) Int() int {
 	return int(n)
 }
 
-type Symbol uint16
+type Symbol uint32
 
 func (s Symbol) String() string {
 	kind, isStart, isEOF, base := s.describe()
@@ -44,12 +44,21 @@ func (s Symbol) String() string {
 }
 
 const (
-	symbolNil = Symbol(0)      // 0000 0000 0000 0000
-	SymbolEOF = Symbol(0xc001) // 1100 0000 0000 0001: The EOF symbol is treated as a terminal symbol.
+	// A symbol consists of a kind bit, a start/EOF bit, and a 30-bit base.
+	//
+	// bit 31   : 1 = terminal, 0 = non-terminal
+	// bit 30   : 1 = start symbol (non-terminal) or EOF symbol (terminal)
+	// bit 0-29 : base
+	symbolNil = Symbol(0)          // 0000 0000 0000 0000 0000 0000 0000 0000
+	SymbolEOF = Symbol(0xc0000001) // 1100 0000 0000 0000 0000 0000 0000 0001: The EOF symbol is treated as a terminal symbol.
+
+	symbolKindMask  = Symbol(0x80000000)
+	symbolStartMask = Symbol(0x40000000)
+	symbolBaseMask  = Symbol(0x3fffffff)
 
 	terminalSymbolNumMin    = SymbolNum(2) // The number 1 is used by the EOF symbol.
 	nonTerminalSymbolNumMin = SymbolNum(1)
-	symbolBaseMax           = SymbolNum(0xffff) >> 2
+	symbolBaseMax           = SymbolNum(symbolBaseMask)
 )
 
 func newSymbol(kind symbolKind, isStart bool, base SymbolNum) (Symbol, error) {
@@ -57,15 +66,15 @@ func newSymbol(kind symbolKind, isStart bool, base SymbolNum) (Symbol, error) {
 		return symbolNil, fmt.Errorf("a base of a symbol exceeds the limit; limit: %v, passed: %v", symbolBaseMax, base)
 	}
 
-	var kindMask uint16 = 0x0000
+	var kindMask Symbol
 	if kind == symbolKindTerminal {
-		kindMask = 0x8000
+		kindMask = symbolKindMask
 	}
-	var startMask uint16 = 0x0000
+	var startMask Symbol
 	if isStart {
-		startMask = 0x4000
+		startMask = symbolStartMask
 	}
-	return Symbol(kindMask | startMask | uint16(base)), nil
+	return kindMask | startMask | Symbol(base), nil
 }
 
 func (s Symbol) Num() SymbolNum {
@@ -75,9 +84,9 @@ func (s Symbol) Num() SymbolNum {
 
 func (s Symbol) Byte() []byte {
 	if s.isNil() {
-		return []byte{0, 0}
+		return []byte{0, 0, 0, 0}
 	}
-	return []byte{byte(uint16(s) >> 8), byte(uint16(s) & 0x00ff)}
+	return []byte{byte(s >> 24), byte(s >> 16), byte(s >> 8), byte(s)}
 }
 
 func (s Symbol) isNil() bool {
@@ -119,21 +128,31 @@ func (s Symbol) isTerminal() bool {
 	return !s.isNonTerminal()
 }
 
+// IsNonTerminal returns whether the symbol is a non-terminal symbol, including the augmented start symbol.
+func (s Symbol) IsNonTerminal() bool {
+	return s.isNonTerminal()
+}
+
+// IsTerminal returns whether the symbol is a terminal symbol, including the EOF symbol.
+func (s Symbol) IsTerminal() bool {
+	return s.isTerminal()
+}
+
 func (s Symbol) describe() (symbolKind, bool, bool, SymbolNum) {
 	kind := symbolKindNonTerminal
-	if s&0x8000 > 0 {
+	if s&symbolKindMask > 0 {
 		kind = symbolKindTerminal
 	}
 	isStart := false
 	isEOF := false
-	if s&0x4000 > 0 {
+	if s&symbolStartMask > 0 {
 		if kind == symbolKindNonTerminal {
 			isStart = true
 		} else {
 			isEOF = true
 		}
 	}
-	base := SymbolNum(s & 0x3fff)
+	base := SymbolNum(s & symbolBaseMask)
 	return kind, isStart, isEOF, base
 }
 
@@ -153,6 +172,22 @@ func newSymbolTable() *SymbolTable {
 	}
 }
 
+func (t *SymbolTable) clone() *SymbolTable {
+	c := &SymbolTable{
+		text2Sym: make(map[string]Symbol, len(t.text2Sym)),
+		sym2Text: make(map[Symbol]string, len(t.sym2Text)),
+		nsymBase: t.nsymBase,
+		tsymBase: t.tsymBase,
+	}
+	for text, sym := range t.text2Sym {
+		c.text2Sym[text] = sym
+	}
+	for sym, text := range t.sym2Text {
+		c.sym2Text[sym] = text
+	}
+	return c
+}
+
 func (t *SymbolTable) registerStartSymbol(text string) (Symbol, error) {
 	if sym, ok := t.text2Sym[text]; ok {
 		return sym, nil
@@ -169,6 +204,9 @@ func (t *SymbolTable) registerStartSymbol(text string) (Symbol, error) {
 
 func (t *SymbolTable) registerNonTerminalSymbol(text string) (Symbol, error) {
 	if sym, ok := t.text2Sym[text]; ok {
+		if sym.isStart() {
+			return symbolNil, fmt.Errorf("a symbol collides with the augmented start symbol; symbol: %v", text)
+		}
 		return sym, nil
 	}
 	sym, err := newSymbol(symbolKindNonTerminal, false, t.nsymBase)
@@ -183,6 +221,9 @@ func (t *SymbolTable) registerNonTerminalSymbol(text string) (Symbol, error) {
 
 func (t *SymbolTable) registerTerminalSymbol(text string) (Symbol, error) {
 	if sym, ok := t.text2Sym[text]; ok {
+		if sym.isStart() {
+			return symbolNil, fmt.Errorf("a symbol collides with the augmented start symbol; symbol: %v", text)
+		}
 		return sym, nil
 	}
 	sym, err := newSymbol(symbolKindTerminal, false, t.tsymBase)
@@ -195,6 +236,19 @@ func (t *SymbolTable) registerTerminalSymbol(text string) (Symbol, error) {
 	return sym, nil
 }
 
+// registerAlias makes text another name of a registered symbol. ToSymbol resolves the alias to the symbol, while
+// ToText keeps returning the name that the symbol was registered with.
+func (t *SymbolTable) registerAlias(text string, sym Symbol) error {
+	if _, ok := t.text2Sym[text]; ok {
+		return fmt.Errorf("an alias collides with a symbol; alias: %v", text)
+	}
+	if _, ok := t.sym2Text[sym]; !ok {
+		return fmt.Errorf("an alias refers to an unregistered symbol; alias: %v, symbol: %v", text, sym)
+	}
+	t.text2Sym[text] = sym
+	return nil
+}
+
 func (t *SymbolTable) getNumOfTerminalSymbols() int {
 	if t.tsymBase == terminalSymbolNumMin {
 		return 0
@@ -247,14 +301,13 @@ func (t *SymbolTable) ToTextFromNumN(num SymbolNum) (string, error) {
 	return text, nil
 }
 
-func PrintSymbolTable(w io.Writer, symTab *SymbolTable) {
-	if w == nil {
-		return
-	}
-
+// Symbols returns all registered symbols. The non-terminal symbols come first in the order of their numbers,
+// followed by the terminal symbols in the order of their numbers. The EOF symbol isn't registered, so it's not
+// included.
+func (t *SymbolTable) Symbols() []Symbol {
 	var nsyms []Symbol
 	var tsyms []Symbol
-	for sym := range symTab.sym2Text {
+	for sym := range t.sym2Text {
 		if sym.isNonTerminal() {
 			nsyms = append(nsyms, sym)
 		} else {
@@ -267,24 +320,30 @@ func PrintSymbolTable(w io.Writer, symTab *SymbolTable) {
 	sort.Slice(tsyms, func(i, j int) bool {
 		return tsyms[i].Num() < tsyms[j].Num()
 	})
+	return append(nsyms, tsyms...)
+}
 
+// EachSymbol calls fn with each registered symbol and its text in the order of Symbols.
+func (t *SymbolTable) EachSymbol(fn func(sym Symbol, text string)) {
+	for _, sym := range t.Symbols() {
+		fn(sym, t.sym2Text[sym])
+	}
+}
+
+func PrintSymbolTable(w io.Writer, symTab *SymbolTable) {
+	if w == nil {
+		return
+	}
+
+	syms := symTab.Symbols()
+	i := 0
 	fmt.Fprintln(w, "Non-Terminal Symbols:")
-	for _, sym := range nsyms {
-		text, ok := symTab.ToText(sym)
-		if !ok {
-			fmt.Fprintf(w, "  %v(!): %v\n", sym, text)
-		} else {
-			fmt.Fprintf(w, "  %v: %v\n", sym, text)
-		}
+	for ; i < len(syms) && syms[i].isNonTerminal(); i++ {
+		fmt.Fprintf(w, "  %v: %v\n", syms[i], symTab.sym2Text[syms[i]])
 	}
 	fmt.Fprintln(w, "Terminal Symbols:")
 	fmt.Fprintf(w, "  %v: <eof>\n", SymbolEOF)
-	for _, sym := range tsyms {
-		text, ok := symTab.ToText(sym)
-		if !ok {
-			fmt.Fprintf(w, "  %v(!): %v\n", sym, text)
-		} else {
-			fmt.Fprintf(w, "  %v: %v\n", sym, text)
-		}
+	for ; i < len(syms); i++ {
+		fmt.Fprintf(w, "  %v: %v\n", syms[i], symTab.sym2Text[syms[i]])
 	}
 }
@@ -1,6 +1,9 @@
 package grammar
 
-import "testing"
+import (
+	"strings"
+	"testing"
+)
 
 func TestSymbol(t *testing.T) {
 	tab := newSymbolTable()
@@ -90,3 +93,81 @@ func testSymbolProperty(t *testing.T, sym Symbol, null, start, eof, nonTerminal,
 		t.Fatalf("isTerminal property is mismatched; want: %v, got: %v", terminal, v)
 	}
 }
+
+func TestSymbol_WideBase(t *testing.T) {
+	tab := newSymbolTable()
+	tab.nsymBase = 0x4000
+	tab.tsymBase = 0xffff
+	nsym, err := tab.registerNonTerminalSymbol("n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tsym, err := tab.registerTerminalSymbol("t")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testSymbolProperty(t, nsym, false, false, false, true, false)
+	testSymbolProperty(t, tsym, false, false, false, false, true)
+	if nsym.Num() != 0x4000 {
+		t.Fatalf("unexpected base; want: %v, got: %v", 0x4000, nsym.Num())
+	}
+	if tsym.Num() != 0xffff {
+		t.Fatalf("unexpected base; want: %v, got: %v", 0xffff, tsym.Num())
+	}
+	if nsym.String() != "n16384" || tsym.String() != "t65535" {
+		t.Fatalf("unexpected text representation; got: %v, %v", nsym, tsym)
+	}
+	if string(nsym.Byte()) == string(tsym.Byte()) {
+		t.Fatalf("byte representations of different symbols must be different; got: %v", nsym.Byte())
+	}
+
+	_, err = newSymbol(symbolKindTerminal, false, symbolBaseMax+1)
+	if err == nil {
+		t.Fatalf("a base exceeding the limit must be rejected")
+	}
+}
+
+func TestSymbolTable_Symbols(t *testing.T) {
+	gram := genTestGrammar(t, `e: e ADD t | t; t: "(" e ")" | NUM;`)
+
+	var texts []string
+	gram.SymbolTable.EachSymbol(func(sym Symbol, text string) {
+		kind := "n"
+		if sym.IsTerminal() {
+			kind = "t"
+		}
+		texts = append(texts, kind+":"+text)
+	})
+	expected := []string{"n:e'", "n:e", "n:t", "t:ADD", "t:$0", "t:$1", "t:NUM"}
+	if strings.Join(texts, ",") != strings.Join(expected, ",") {
+		t.Fatalf("unexpected symbols; want: %v, got: %v", expected, texts)
+	}
+
+	syms := gram.SymbolTable.Symbols()
+	if len(syms) != len(expected) {
+		t.Fatalf("unexpected number of symbols; want: %v, got: %v", len(expected), len(syms))
+	}
+	for _, sym := range syms {
+		if sym.IsTerminal() == sym.IsNonTerminal() {
+			t.Fatalf("a symbol must be either a terminal or a non-terminal; symbol: %v", sym)
+		}
+	}
+}
+
+func TestSymbolTable_AugmentedStartCollision(t *testing.T) {
+	symTab := newSymbolTable()
+	_, err := symTab.registerStartSymbol("e'")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := symTab.registerNonTerminalSymbol("e'"); err == nil {
+		t.Fatalf("a non-terminal symbol must not collide with the augmented start symbol")
+	}
+	if _, err := symTab.registerTerminalSymbol("e'"); err == nil {
+		t.Fatalf("a terminal symbol must not collide with the augmented start symbol")
+	}
+	if _, err := symTab.registerNonTerminalSymbol("e"); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -26,7 +26,7 @@ func newTestProductionGenerator(t *testing.T, genSym testSymbolGenerator) testPr
 		for _, text := range rhs {
 			rhsSym = append(rhsSym, genSym(text))
 		}
-		prod, err := newProduction(genSym(lhs), rhsSym)
+		prod, err := newProduction(genSym(lhs), rhsSym, DefaultMaxRHSLen)
 		if err != nil {
 			t.Fatalf("failed to create a production: %v", err)
 		}
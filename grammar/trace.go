@@ -0,0 +1,135 @@
+package grammar
+
+import (
+	"fmt"
+	"sort"
+)
+
+// TraceStep is an action that the parser performed.
+type TraceStep struct {
+	Type ActionType
+
+	// State is the state on the top of the stack before the action, and SymbolText is the text of the lookahead
+	// symbol. The lookahead of EOF is `<eof>`.
+	State      StateNum
+	SymbolText string
+
+	// NextState is the state that the action pushed: the destination of a shift action, or the GOTO state of a
+	// reduce action. It is unused for the other actions.
+	NextState StateNum
+
+	// Production is the production that a reduce action reduced by. It is unused for the other actions.
+	Production ProductionNum
+}
+
+// ParseTrace is the sequence of the actions that a parsing table performs on an input.
+type ParseTrace struct {
+	Steps []*TraceStep
+
+	// Accepted is true when the table accepted the input. Otherwise, the last step is the error action.
+	Accepted bool
+}
+
+// States returns the states that the parser visited in ascending order.
+func (tr *ParseTrace) States() []StateNum {
+	visited := map[StateNum]struct{}{}
+	var states []StateNum
+	visit := func(state StateNum) {
+		if _, ok := visited[state]; ok {
+			return
+		}
+		visited[state] = struct{}{}
+		states = append(states, state)
+	}
+	for _, step := range tr.Steps {
+		visit(step.State)
+		if step.Type == ActionTypeShift || step.Type == ActionTypeReduce {
+			visit(step.NextState)
+		}
+	}
+	sort.Slice(states, func(i, j int) bool {
+		return states[i] < states[j]
+	})
+	return states
+}
+
+// Productions returns the productions that the parser reduced by in ascending order. The start production is
+// included when the parser accepted the input.
+func (tr *ParseTrace) Productions() []ProductionNum {
+	reduced := map[ProductionNum]struct{}{}
+	var prods []ProductionNum
+	for _, step := range tr.Steps {
+		if step.Type != ActionTypeReduce && step.Type != ActionTypeAccept {
+			continue
+		}
+		if _, ok := reduced[step.Production]; ok {
+			continue
+		}
+		reduced[step.Production] = struct{}{}
+		prods = append(prods, step.Production)
+	}
+	sort.Slice(prods, func(i, j int) bool {
+		return prods[i] < prods[j]
+	})
+	return prods
+}
+
+// TraceParse runs the parsing table on an input, which is a sequence of the texts of terminal symbols, and records
+// the actions. A syntax error in the input isn't an error of TraceParse; the trace ends with the error action
+// instead. An unknown terminal symbol is an error.
+func TraceParse(gram *Grammar, tab *Table, input []string) (*ParseTrace, error) {
+	prods := map[ProductionNum]*production{}
+	for _, prod := range gram.ProductionSet.getAll() {
+		prods[prod.num] = prod
+	}
+	syms := make([]Symbol, len(input)+1)
+	for i, text := range input {
+		sym, ok := gram.SymbolTable.ToSymbol(text)
+		if !ok || !sym.isTerminal() {
+			return nil, fmt.Errorf("an input must consist of terminal symbols; position: %v, symbol: %v", i, text)
+		}
+		syms[i] = sym
+	}
+	syms[len(input)] = SymbolEOF
+
+	trace := &ParseTrace{}
+	stack := []StateNum{tab.LR.InitialState}
+	i := 0
+	for {
+		state := stack[len(stack)-1]
+		symText := "<eof>"
+		if !syms[i].isEOF() {
+			symText = input[i]
+		}
+		// The entries written in the table detect a syntax error before the reductions of a reduce-only state.
+		ty, next, prodNum := tab.LR.actionEntryAt(state, syms[i].Num()).describe()
+		step := &TraceStep{
+			Type:       ty,
+			State:      state,
+			SymbolText: symText,
+		}
+		trace.Steps = append(trace.Steps, step)
+		switch ty {
+		case ActionTypeShift:
+			step.NextState = next
+			stack = append(stack, next)
+			i++
+		case ActionTypeReduce:
+			prod, ok := prods[prodNum]
+			if !ok {
+				return nil, fmt.Errorf("a production was not found; production: %v", prodNum)
+			}
+			stack = stack[:len(stack)-prod.rhsLen]
+			_, next := tab.LR.getGoTo(stack[len(stack)-1], prod.lhs.Num())
+			step.Production = prodNum
+			step.NextState = next
+			stack = append(stack, next)
+		case ActionTypeAccept:
+			step.Production = ProductionNumStart
+			trace.Accepted = true
+			return trace, nil
+		default:
+			return trace, nil
+		}
+	}
+}
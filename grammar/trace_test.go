@@ -0,0 +1,99 @@
+package grammar
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestTraceParse(t *testing.T) {
+	gram, tab := genTestTable(t, "e: e ADD t | t; t: NUM | LPAREN e RPAREN;")
+
+	tests := []struct {
+		caption  string
+		input    []string
+		actions  string
+		accepted bool
+		prods    string
+	}{
+		{
+			caption:  "an accepted input records the actions up to the accept action",
+			input:    []string{"NUM", "ADD", "NUM"},
+			actions:  "shift NUM, reduce ADD, reduce ADD, shift ADD, shift NUM, reduce <eof>, reduce <eof>, accept <eof>",
+			accepted: true,
+			prods:    "[1 2 3 4]",
+		},
+		{
+			caption:  "a rejected input ends with the error action",
+			input:    []string{"NUM", "NUM"},
+			actions:  "shift NUM, error NUM",
+			accepted: false,
+			prods:    "[]",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.caption, func(t *testing.T) {
+			trace, err := TraceParse(gram, tab, tt.input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var actions []string
+			for _, step := range trace.Steps {
+				actions = append(actions, fmt.Sprintf("%v %v", step.Type, step.SymbolText))
+			}
+			if strings.Join(actions, ", ") != tt.actions {
+				t.Fatalf("unexpected actions;\nwant: %v\ngot:  %v", tt.actions, strings.Join(actions, ", "))
+			}
+			if trace.Accepted != tt.accepted {
+				t.Fatalf("unexpected result; want: %v, got: %v", tt.accepted, trace.Accepted)
+			}
+			if fmt.Sprint(trace.Productions()) != tt.prods {
+				t.Fatalf("unexpected productions; want: %v, got: %v", tt.prods, trace.Productions())
+			}
+
+			// Every step must start at the state that the previous step pushed.
+			for i, step := range trace.Steps[1:] {
+				prev := trace.Steps[i]
+				if step.State != prev.NextState {
+					t.Fatalf("a step doesn't start at the state pushed by the previous step; step: %+v, previous: %+v", step, prev)
+				}
+			}
+			states := trace.States()
+			if len(states) == 0 || states[0] != tab.LR.InitialState {
+				t.Fatalf("the visited states must contain the initial state; got: %v", states)
+			}
+		})
+	}
+
+	_, err := TraceParse(gram, tab, []string{"NUM", "e"})
+	if err == nil {
+		t.Fatalf("TraceParse must reject a non-terminal symbol in an input")
+	}
+}
+
+func TestParseTrace_Coverage(t *testing.T) {
+	gram, tab := genTestTable(t, "e: e ADD t | t; t: NUM | LPAREN e RPAREN;")
+
+	covered := map[ProductionNum]struct{}{}
+	for _, input := range [][]string{
+		{"NUM"},
+		{"NUM", "ADD", "NUM"},
+	} {
+		trace, err := TraceParse(gram, tab, input)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, prod := range trace.Productions() {
+			covered[prod] = struct{}{}
+		}
+	}
+	var missing []string
+	for _, prod := range gram.ProductionSet.getAllSorted() {
+		if _, ok := covered[prod.num]; !ok {
+			missing = append(missing, productionText(prod, gram.SymbolTable))
+		}
+	}
+	if strings.Join(missing, ", ") != "t: LPAREN e RPAREN" {
+		t.Fatalf("unexpected never-exercised productions; want: t: LPAREN e RPAREN, got: %v", missing)
+	}
+}
@@ -0,0 +1,235 @@
+package grammar
+
+import "fmt"
+
+// EliminateUnitProductions returns a new grammar where unit productions, that is, productions whose RHS consists of
+// exactly one non-terminal symbol like `a: b;`, are replaced with the alternatives of their RHS. The new grammar
+// derives the same language. The production of the augmented start symbol is kept as it is. An inlined alternative
+// keeps its label and action, and the labels and the actions of the unit productions are dropped. The symbol table
+// and the patterns are shared with the original grammar.
+func EliminateUnitProductions(gram *Grammar) (*Grammar, error) {
+	prods := newProductionSet()
+	for _, prod := range gram.ProductionSet.getAllSorted() {
+		if prod.lhs.isStart() {
+			p, err := copyProduction(prod.lhs, prod, gram.maxRHSLen)
+			if err != nil {
+				return nil, err
+			}
+			prods.append(p)
+			continue
+		}
+		if isUnitProduction(prod) {
+			err := inlineUnitProduction(prods, gram.ProductionSet, prod.lhs, prod.rhs[0], map[Symbol]struct{}{prod.lhs: {}}, gram.maxRHSLen)
+			if err != nil {
+				return nil, describeRHSLenError(err, gram.SymbolTable)
+			}
+			continue
+		}
+		p, err := copyProduction(prod.lhs, prod, gram.maxRHSLen)
+		if err != nil {
+			return nil, err
+		}
+		prods.append(p)
+	}
+
+	var warnings []string
+	if gram.Warnings != nil {
+		warnings = make([]string, len(gram.Warnings))
+		copy(warnings, gram.Warnings)
+	}
+	return &Grammar{
+		SymbolTable:              gram.SymbolTable,
+		Patterns:                 gram.Patterns,
+		CaseInsensitiveTerminals: gram.CaseInsensitiveTerminals,
+		TerminalModes:            gram.TerminalModes,
+		SyntheticOrigins:         gram.SyntheticOrigins,
+		Precedences:              gram.Precedences,
+		ProductionSet:            prods,
+		AugmentedStartSymbol:     gram.AugmentedStartSymbol,
+		Warnings:                 warnings,
+		maxRHSLen:                gram.maxRHSLen,
+	}, nil
+}
+
+func isUnitProduction(prod *production) bool {
+	return len(prod.rhs) == 1 && prod.rhs[0].isNonTerminal()
+}
+
+// inlineUnitProduction appends the alternatives of sym to lhs. The unit productions of sym are inlined
+// recursively. visited holds the symbols inlined already to stop at cycles.
+func inlineUnitProduction(dst, src *productionSet, lhs, sym Symbol, visited map[Symbol]struct{}, maxRHSLen int) error {
+	if _, ok := visited[sym]; ok {
+		return nil
+	}
+	visited[sym] = struct{}{}
+
+	alts, _ := src.findByLHS(sym)
+	for _, alt := range alts {
+		if isUnitProduction(alt) {
+			err := inlineUnitProduction(dst, src, lhs, alt.rhs[0], visited, maxRHSLen)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		p, err := copyProduction(lhs, alt, maxRHSLen)
+		if err != nil {
+			return err
+		}
+		dst.append(p)
+	}
+	return nil
+}
+
+// copyProduction returns a production having the LHS and the RHS and the label and the action of the production.
+func copyProduction(lhs Symbol, prod *production, maxRHSLen int) (*production, error) {
+	rhs := make([]Symbol, len(prod.rhs))
+	copy(rhs, prod.rhs)
+	p, err := newProduction(lhs, rhs, maxRHSLen)
+	if err != nil {
+		return nil, err
+	}
+	p.label = prod.label
+	p.action = prod.action
+	p.prec = prod.prec
+	p.synthetic = prod.synthetic
+	return p, nil
+}
+
+// LeftFactor returns a new grammar where the alternatives of each non-terminal symbol sharing leading symbols, like
+// `a: B C D | B C E;`, are factored into one alternative followed by a fresh non-terminal symbol deriving the rest,
+// like `a: B C $$0; $$0: D | E;`. The fresh symbols are named like the ones that GenGrammar generates for
+// qualifiers. The new grammar derives the same language, and the alternatives keep their labels. An alternative
+// having an action is left unfactored because the `$N` references of the action would point at other symbols in the
+// shorter RHS. The original grammar isn't changed.
+func LeftFactor(gram *Grammar) (*Grammar, error) {
+	factored := gram.Clone()
+	if factored.SyntheticOrigins == nil {
+		factored.SyntheticOrigins = map[SymbolNum]*SyntheticOrigin{}
+	}
+	f := &leftFactorer{
+		symTab:    factored.SymbolTable,
+		prods:     newProductionSet(),
+		origins:   factored.SyntheticOrigins,
+		maxRHSLen: factored.maxRHSLen,
+	}
+	var lhsSyms []Symbol
+	lhs2Alts := map[Symbol][]*production{}
+	for _, prod := range gram.ProductionSet.getAllSorted() {
+		if _, ok := lhs2Alts[prod.lhs]; !ok {
+			lhsSyms = append(lhsSyms, prod.lhs)
+		}
+		lhs2Alts[prod.lhs] = append(lhs2Alts[prod.lhs], prod)
+	}
+	for _, lhs := range lhsSyms {
+		err := f.factor(lhs, lhs2Alts[lhs])
+		if err != nil {
+			return nil, describeRHSLenError(err, factored.SymbolTable)
+		}
+	}
+	factored.ProductionSet = f.prods
+	return factored, nil
+}
+
+type leftFactorer struct {
+	symTab    *SymbolTable
+	prods     *productionSet
+	origins   map[SymbolNum]*SyntheticOrigin
+	symNum    int
+	maxRHSLen int
+}
+
+// factor appends the alternatives of lhs to the production set factoring out their common prefixes.
+func (f *leftFactorer) factor(lhs Symbol, alts []*production) error {
+	var deferred []func() error
+	done := map[*production]struct{}{}
+	for i, alt := range alts {
+		if _, ok := done[alt]; ok {
+			continue
+		}
+		group := []*production{alt}
+		if len(alt.rhs) > 0 && alt.action == "" {
+			for _, other := range alts[i+1:] {
+				if len(other.rhs) > 0 && other.rhs[0] == alt.rhs[0] && other.action == "" {
+					group = append(group, other)
+				}
+			}
+		}
+		if len(group) == 1 || lhs.isStart() {
+			p, err := copyProduction(lhs, alt, f.maxRHSLen)
+			if err != nil {
+				return err
+			}
+			f.prods.append(p)
+			continue
+		}
+
+		prefixLen := commonPrefixLen(group)
+		sym, err := f.newSymbol()
+		if err != nil {
+			return err
+		}
+		f.origins[sym.Num()] = &SyntheticOrigin{
+			Kind:   SyntheticKindLeftFactor,
+			Symbol: lhs,
+		}
+		rhs := make([]Symbol, prefixLen, prefixLen+1)
+		copy(rhs, alt.rhs[:prefixLen])
+		p, err := newProduction(lhs, append(rhs, sym), f.maxRHSLen)
+		if err != nil {
+			return err
+		}
+		p.synthetic = alt.synthetic
+		f.prods.append(p)
+
+		suffixes := make([]*production, len(group))
+		for j, g := range group {
+			done[g] = struct{}{}
+			suffixRHS := make([]Symbol, len(g.rhs)-prefixLen)
+			copy(suffixRHS, g.rhs[prefixLen:])
+			suffix, err := newProduction(sym, suffixRHS, f.maxRHSLen)
+			if err != nil {
+				return err
+			}
+			suffix.label = g.label
+			suffix.prec = g.prec
+			suffix.synthetic = true
+			suffixes[j] = suffix
+		}
+		// The productions of the fresh symbol follow the ones of lhs.
+		deferred = append(deferred, func() error {
+			return f.factor(sym, suffixes)
+		})
+	}
+	for _, fn := range deferred {
+		err := fn()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (f *leftFactorer) newSymbol() (Symbol, error) {
+	for {
+		text := fmt.Sprintf("$$%v", f.symNum)
+		f.symNum++
+		if _, ok := f.symTab.ToSymbol(text); ok {
+			continue
+		}
+		return f.symTab.registerNonTerminalSymbol(text)
+	}
+}
+
+// commonPrefixLen returns the length of the longest sequence of symbols that all the productions begin with.
+func commonPrefixLen(prods []*production) int {
+	n := len(prods[0].rhs)
+	for _, prod := range prods[1:] {
+		i := 0
+		for i < n && i < len(prod.rhs) && prod.rhs[i] == prods[0].rhs[i] {
+			i++
+		}
+		n = i
+	}
+	return n
+}
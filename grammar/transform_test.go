@@ -0,0 +1,275 @@
+package grammar
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestEliminateUnitProductions(t *testing.T) {
+	src := `
+s: stmt s | ;
+stmt: expr SEMI | block;
+block: LBRACE s RBRACE;
+expr: term #expr;
+term: NUM #num | ID | paren;
+paren: LPAREN expr RPAREN;
+`
+	gram, tab := genTestTable(t, src)
+	elimGram, err := EliminateUnitProductions(gram)
+	if err != nil {
+		t.Fatal(err)
+	}
+	elimTab, err := GenTable(elimGram)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var prods []string
+	for _, prod := range elimGram.ProductionSet.getAllSorted() {
+		text := productionText(prod, elimGram.SymbolTable)
+		if prod.label != "" {
+			text += " #" + prod.label
+		}
+		prods = append(prods, text)
+	}
+	expected := []string{
+		"s': s",
+		"s: stmt s",
+		"s:",
+		"stmt: expr SEMI",
+		"stmt: LBRACE s RBRACE",
+		"block: LBRACE s RBRACE",
+		"expr: NUM #num",
+		"expr: ID",
+		"expr: LPAREN expr RPAREN",
+		"term: NUM #num",
+		"term: ID",
+		"term: LPAREN expr RPAREN",
+		"paren: LPAREN expr RPAREN",
+	}
+	if strings.Join(prods, "\n") != strings.Join(expected, "\n") {
+		t.Fatalf("unexpected productions;\nwant:\n%v\ngot:\n%v", strings.Join(expected, "\n"), strings.Join(prods, "\n"))
+	}
+	if gram.NumOfProductions() != 10 {
+		t.Fatalf("the original grammar must not be changed; want: %v productions, got: %v", 10, gram.NumOfProductions())
+	}
+
+	// Both grammars must accept the same strings.
+	terminals := []string{"SEMI", "LBRACE", "RBRACE", "NUM", "ID", "LPAREN", "RPAREN"}
+	accepted := 0
+	var walk func(input []string)
+	walk = func(input []string) {
+		ok := testParse(t, gram, tab, input)
+		if testParse(t, elimGram, elimTab, input) != ok {
+			t.Fatalf("the grammars disagree; input: %v, original: %v", input, ok)
+		}
+		if ok {
+			accepted++
+		}
+		if len(input) >= 5 {
+			return
+		}
+		for _, term := range terminals {
+			walk(append(input[:len(input):len(input)], term))
+		}
+	}
+	walk(nil)
+	if accepted < 10 {
+		t.Fatalf("the sample is too small; accepted: %v", accepted)
+	}
+}
+
+func TestLeftFactor(t *testing.T) {
+	src := `
+s: a s | ;
+a: B C D #bcd | B C E #bce | B F | G;
+`
+	gram, tab := genTestTable(t, src)
+	factored, err := LeftFactor(gram)
+	if err != nil {
+		t.Fatal(err)
+	}
+	factoredTab, err := GenTable(factored)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var prods []string
+	for _, prod := range factored.ProductionSet.getAllSorted() {
+		text := productionText(prod, factored.SymbolTable)
+		if prod.label != "" {
+			text += " #" + prod.label
+		}
+		prods = append(prods, text)
+	}
+	expected := []string{
+		"s': s",
+		"s: a s",
+		"s:",
+		"a: B $$0",
+		"a: G",
+		"$$0: C $$1",
+		"$$0: F",
+		"$$1: D #bcd",
+		"$$1: E #bce",
+	}
+	if strings.Join(prods, "\n") != strings.Join(expected, "\n") {
+		t.Fatalf("unexpected productions;\nwant:\n%v\ngot:\n%v", strings.Join(expected, "\n"), strings.Join(prods, "\n"))
+	}
+	if gram.NumOfProductions() != 6 {
+		t.Fatalf("the original grammar must not be changed; want: %v productions, got: %v", 6, gram.NumOfProductions())
+	}
+	if _, ok := gram.SymbolTable.ToSymbol("$$0"); ok {
+		t.Fatalf("the symbol table of the original grammar must not be changed")
+	}
+	for _, v := range factored.Productions() {
+		if v.IsSynthetic() != strings.HasPrefix(v.LHS(), "$$") {
+			t.Fatalf("only the productions of the fresh symbols must be synthetic; production: %v: %v", v.LHS(), v.RHS())
+		}
+	}
+
+	// Both grammars must accept the same strings.
+	terminals := []string{"B", "C", "D", "E", "F", "G"}
+	accepted := 0
+	var walk func(input []string)
+	walk = func(input []string) {
+		ok := testParse(t, gram, tab, input)
+		if testParse(t, factored, factoredTab, input) != ok {
+			t.Fatalf("the grammars disagree; input: %v, original: %v", input, ok)
+		}
+		if ok {
+			accepted++
+		}
+		if len(input) >= 5 {
+			return
+		}
+		for _, term := range terminals {
+			walk(append(input[:len(input):len(input)], term))
+		}
+	}
+	walk(nil)
+	if accepted < 10 {
+		t.Fatalf("the sample is too small; accepted: %v", accepted)
+	}
+}
+
+func TestLeftFactor_Actions(t *testing.T) {
+	gram := genTestGrammar(t, "s: B C D { $$ = $3 } | B C E | B C F | G;")
+	factored, err := LeftFactor(gram)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var prods []string
+	for _, prod := range factored.ProductionSet.getAllSorted() {
+		text := productionText(prod, factored.SymbolTable)
+		if prod.action != "" {
+			text += " {" + prod.action + "}"
+		}
+		prods = append(prods, text)
+	}
+	// The alternative having the action keeps its RHS so that `$3` still refers to D.
+	expected := []string{
+		"s': s",
+		"s: B C D { $$ = $3 }",
+		"s: B C $$0",
+		"s: G",
+		"$$0: E",
+		"$$0: F",
+	}
+	if strings.Join(prods, "\n") != strings.Join(expected, "\n") {
+		t.Fatalf("unexpected productions;\nwant:\n%v\ngot:\n%v", strings.Join(expected, "\n"), strings.Join(prods, "\n"))
+	}
+}
+
+func TestTransforms_MaxRHSLen(t *testing.T) {
+	gram := genTestGrammar(t, "s: a | A B C | A B D; a: B C D;")
+	// The limit applies to the productions that the transformations make, too.
+	gram.maxRHSLen = 2
+
+	_, err := LeftFactor(gram)
+	expected := "an alternative has too many symbols; symbol: s, length: 3, limit: 2"
+	if err == nil || err.Error() != expected {
+		t.Fatalf("unexpected error; want: %v, got: %v", expected, err)
+	}
+	_, err = EliminateUnitProductions(gram)
+	if err == nil || err.Error() != expected {
+		t.Fatalf("unexpected error; want: %v, got: %v", expected, err)
+	}
+}
+
+func TestLeftFactor_AvoidsGeneratedNames(t *testing.T) {
+	gram := genTestGrammar(t, "s: E? F | B C | B D;")
+	factored, err := LeftFactor(gram)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := GenTable(factored); err != nil {
+		t.Fatal(err)
+	}
+	var texts []string
+	for _, prod := range factored.ProductionSet.getAllSorted() {
+		texts = append(texts, productionText(prod, factored.SymbolTable))
+	}
+	// $$0 is the symbol generated for `E?`.
+	if !strings.Contains(strings.Join(texts, "\n"), "s: B $$1") {
+		t.Fatalf("the fresh symbol must not reuse a generated name;\n%v", strings.Join(texts, "\n"))
+	}
+	for text, expected := range map[string]SyntheticOrigin{
+		"$$0": {Kind: SyntheticKindOptional, Symbol: symbolOf(t, factored, "E")},
+		"$$1": {Kind: SyntheticKindLeftFactor, Symbol: symbolOf(t, factored, "s")},
+	} {
+		origin, ok := factored.SyntheticOrigins[symbolOf(t, factored, text).Num()]
+		if !ok || *origin != expected {
+			t.Fatalf("unexpected origin; symbol: %v, want: %+v, got: %+v", text, expected, origin)
+		}
+	}
+}
+
+func symbolOf(t *testing.T, gram *Grammar, text string) Symbol {
+	t.Helper()
+
+	sym, ok := gram.SymbolTable.ToSymbol(text)
+	if !ok {
+		t.Fatalf("symbol was not found; text: %v", text)
+	}
+	return sym
+}
+
+// testParse runs the parsing table on the input, which is a sequence of terminal symbols, and reports whether the
+// table accepts it.
+func testParse(t *testing.T, gram *Grammar, tab *Table, input []string) bool {
+	t.Helper()
+
+	prods := map[ProductionNum]*production{}
+	for _, prod := range gram.ProductionSet.getAllSorted() {
+		prods[prod.num] = prod
+	}
+
+	stack := []StateNum{tab.LR.InitialState}
+	i := 0
+	for {
+		sym := SymbolEOF
+		if i < len(input) {
+			var ok bool
+			sym, ok = gram.SymbolTable.ToSymbol(input[i])
+			if !ok {
+				t.Fatalf("symbol was not found; text: %v", input[i])
+			}
+		}
+		ty, next, prodNum := tab.LR.getAction(stack[len(stack)-1], sym.Num())
+		switch ty {
+		case ActionTypeShift:
+			stack = append(stack, next)
+			i++
+		case ActionTypeAccept:
+			return true
+		case ActionTypeReduce:
+			prod := prods[prodNum]
+			stack = stack[:len(stack)-prod.rhsLen]
+			_, next := tab.LR.getGoTo(stack[len(stack)-1], prod.lhs.Num())
+			stack = append(stack, next)
+		default:
+			return false
+		}
+	}
+}
@@ -0,0 +1,147 @@
+package grammar
+
+import "sort"
+
+// FindUnreachableSymbols returns the texts of non-terminal symbols that cannot be reached from the start symbol.
+func FindUnreachableSymbols(gram *Grammar) []string {
+	reachable := map[Symbol]struct{}{
+		gram.AugmentedStartSymbol: {},
+	}
+	uncheckedSyms := []Symbol{gram.AugmentedStartSymbol}
+	for len(uncheckedSyms) > 0 {
+		nextUncheckedSyms := []Symbol{}
+		for _, sym := range uncheckedSyms {
+			prods, _ := gram.ProductionSet.findByLHS(sym)
+			for _, prod := range prods {
+				for _, rhsSym := range prod.rhs {
+					if !rhsSym.isNonTerminal() {
+						continue
+					}
+					if _, ok := reachable[rhsSym]; ok {
+						continue
+					}
+					reachable[rhsSym] = struct{}{}
+					nextUncheckedSyms = append(nextUncheckedSyms, rhsSym)
+				}
+			}
+		}
+		uncheckedSyms = nextUncheckedSyms
+	}
+
+	var syms []Symbol
+	for sym := range gram.ProductionSet.lhs2Prods {
+		if _, ok := reachable[sym]; ok {
+			continue
+		}
+		syms = append(syms, sym)
+	}
+	return symbolTexts(syms, gram.SymbolTable)
+}
+
+// FindUnproductiveSymbols returns the texts of non-terminal symbols that derive no string of terminal symbols.
+func FindUnproductiveSymbols(gram *Grammar) []string {
+	productive := map[Symbol]struct{}{}
+	for {
+		more := false
+		for _, prod := range gram.ProductionSet.getAll() {
+			if _, ok := productive[prod.lhs]; ok {
+				continue
+			}
+			isProductive := true
+			for _, sym := range prod.rhs {
+				if !sym.isNonTerminal() {
+					continue
+				}
+				if _, ok := productive[sym]; !ok {
+					isProductive = false
+					break
+				}
+			}
+			if isProductive {
+				productive[prod.lhs] = struct{}{}
+				more = true
+			}
+		}
+		if !more {
+			break
+		}
+	}
+
+	var syms []Symbol
+	for sym := range gram.ProductionSet.lhs2Prods {
+		if _, ok := productive[sym]; ok {
+			continue
+		}
+		syms = append(syms, sym)
+	}
+	return symbolTexts(syms, gram.SymbolTable)
+}
+
+// findUnusedTerminalSymbols returns the numbers of terminal symbols that no production refers to in ascending
+// order, such as the ones defined only by lexeme productions. A terminal symbol that a precedence directive names is
+// used, because it can be a pseudo-token like `UMINUS` that only `%prec` refers to.
+func findUnusedTerminalSymbols(gram *Grammar) []SymbolNum {
+	tsymCount := gram.SymbolTable.getNumOfTerminalSymbols()
+	used := make([]bool, tsymCount)
+	used[symbolNil.Num().Int()] = true
+	used[SymbolEOF.Num().Int()] = true
+	for num := range gram.Precedences {
+		used[num.Int()] = true
+	}
+	for _, prod := range gram.ProductionSet.getAll() {
+		for _, rhsSym := range prod.rhs {
+			if !rhsSym.isTerminal() {
+				continue
+			}
+			used[rhsSym.Num().Int()] = true
+		}
+	}
+	var nums []SymbolNum
+	for num, ok := range used {
+		if ok {
+			continue
+		}
+		nums = append(nums, SymbolNum(num))
+	}
+	return nums
+}
+
+// FindNeverReducedProductions returns the texts of the productions that some states of the LR0 automaton can reduce
+// by but no ACTION entry of the parsing table does, in ascending order of the production numbers. Such a production
+// is dead: the conflict resolution, by the precedences or the conflict policy, shadowed all of its reduce actions.
+// The productions that no state can reduce by aren't reported because they belong to unreachable symbols.
+func FindNeverReducedProductions(gram *Grammar, tab *Table) []string {
+	live := map[ProductionNum]struct{}{}
+	for _, act := range tab.LR.actionTable {
+		ty, _, prod := act.describe()
+		if ty == ActionTypeReduce {
+			live[prod] = struct{}{}
+		}
+	}
+	var texts []string
+	for _, prod := range gram.ProductionSet.getAllSorted() {
+		if prod.num == ProductionNumStart {
+			continue
+		}
+		if _, ok := live[prod.num]; ok {
+			continue
+		}
+		if len(tab.LR0Automaton.StatesReducing(prod.id)) == 0 {
+			continue
+		}
+		texts = append(texts, productionText(prod, gram.SymbolTable))
+	}
+	return texts
+}
+
+func symbolTexts(syms []Symbol, symTab *SymbolTable) []string {
+	sort.Slice(syms, func(i, j int) bool {
+		return syms[i] < syms[j]
+	})
+	var texts []string
+	for _, sym := range syms {
+		text, _ := symTab.ToText(sym)
+		texts = append(texts, text)
+	}
+	return texts
+}
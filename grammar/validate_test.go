@@ -0,0 +1,102 @@
+package grammar
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFindUnreachableSymbols(t *testing.T) {
+	tests := []struct {
+		caption string
+		src     string
+		syms    []string
+	}{
+		{
+			caption: "all non-terminal symbols are reachable",
+			src:     "s: a | B; a: C s;",
+		},
+		{
+			caption: "non-terminal symbols that the start symbol doesn't derive are unreachable",
+			src:     "s: A; a: b; b: B;",
+			syms:    []string{"a", "b"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.caption, func(t *testing.T) {
+			gram := genTestGrammar(t, tt.src)
+			syms := FindUnreachableSymbols(gram)
+			if strings.Join(syms, ",") != strings.Join(tt.syms, ",") {
+				t.Fatalf("unexpected symbols; want: %v, got: %v", tt.syms, syms)
+			}
+		})
+	}
+}
+
+func TestFindUnproductiveSymbols(t *testing.T) {
+	tests := []struct {
+		caption string
+		src     string
+		syms    []string
+	}{
+		{
+			caption: "all non-terminal symbols are productive",
+			src:     "s: a s | ; a: A;",
+		},
+		{
+			caption: "non-terminal symbols that only derive themselves are unproductive",
+			src:     "s: a | B; a: A b; b: b C;",
+			syms:    []string{"a", "b"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.caption, func(t *testing.T) {
+			gram := genTestGrammar(t, tt.src)
+			syms := FindUnproductiveSymbols(gram)
+			if strings.Join(syms, ",") != strings.Join(tt.syms, ",") {
+				t.Fatalf("unexpected symbols; want: %v, got: %v", tt.syms, syms)
+			}
+		})
+	}
+}
+
+func TestFindNeverReducedProductions(t *testing.T) {
+	tests := []struct {
+		caption string
+		src     string
+		opts    []TableOption
+		prods   []string
+	}{
+		{
+			caption: "every production is reduced",
+			src:     "s: a s | ; a: A;",
+		},
+		{
+			caption: "the conflict policy shadows a production",
+			src:     "s: A B | a B C; a: A;",
+			opts:    []TableOption{WithConflictPolicy(ConflictPolicyPreferShift)},
+			prods:   []string{"a: A"},
+		},
+		{
+			caption: "the precedences shadow a production",
+			src:     "%left A; %left B; s: A B | a B C; a: A;",
+			prods:   []string{"a: A"},
+		},
+		{
+			caption: "productions of unreachable symbols aren't reported",
+			src:     "s: A; a: B;",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.caption, func(t *testing.T) {
+			gram := genTestGrammar(t, tt.src)
+			tab, err := GenTable(gram, tt.opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			prods := FindNeverReducedProductions(gram, tab)
+			if strings.Join(prods, ",") != strings.Join(tt.prods, ",") {
+				t.Fatalf("unexpected productions; want: %v, got: %v", tt.prods, prods)
+			}
+		})
+	}
+}
@@ -4,45 +4,169 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"strings"
+	"sync"
 )
 
+type Level int
+
+const (
+	LevelDebug Level = iota
+	LevelInfo
+	LevelWarn
+	LevelError
+)
+
+func (l Level) String() string {
+	switch l {
+	case LevelDebug:
+		return "debug"
+	case LevelInfo:
+		return "info"
+	case LevelWarn:
+		return "warn"
+	case LevelError:
+		return "error"
+	}
+	return fmt.Sprintf("Level(%d)", int(l))
+}
+
+func ParseLevel(s string) (Level, error) {
+	for _, level := range []Level{LevelDebug, LevelInfo, LevelWarn, LevelError} {
+		if strings.EqualFold(s, level.String()) {
+			return level, nil
+		}
+	}
+	return LevelDebug, fmt.Errorf("unknown log level: %v; supported levels: debug, info, warn, error", s)
+}
+
 type logger struct {
-	out io.WriteCloser
+	// file is the log file that Init opened. It is nil when the logger writes to a writer given by InitWithWriter.
+	file  io.WriteCloser
+	out   io.Writer
+	ws    []io.Writer
+	level Level
 }
 
-var l *logger
+// mu guards l and all writes to the sinks so that the log is safe for concurrent use.
+var (
+	mu sync.Mutex
+	l  *logger
+)
 
-func Init(outputPath string) error {
+// Init opens the log file and makes the logger write to it. Messages below level are discarded.
+func Init(outputPath string, level Level) error {
 	f, err := os.OpenFile(outputPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0666)
 	if err != nil {
 		return err
 	}
+	initLogger(f, f, level)
+	return nil
+}
+
+// InitWithWriter makes the logger write to w, such as a bytes.Buffer, instead of a file. Messages below level are
+// discarded. Close doesn't close w.
+func InitWithWriter(w io.Writer, level Level) {
+	initLogger(nil, w, level)
+}
 
+func initLogger(file io.WriteCloser, w io.Writer, level Level) {
+	mu.Lock()
+	defer mu.Unlock()
 	l = &logger{
-		out: f,
+		file:  file,
+		out:   w,
+		ws:    []io.Writer{w},
+		level: level,
 	}
+}
 
-	return nil
+// AddWriter makes the logger also write to w. The writer given to Init or InitWithWriter remains the default sink.
+func AddWriter(w io.Writer) {
+	mu.Lock()
+	defer mu.Unlock()
+	if l == nil || w == nil {
+		return
+	}
+	l.ws = append(l.ws, w)
+	l.out = io.MultiWriter(l.ws...)
 }
 
+// Close closes the log file. Logging after Close is a no-op.
 func Close() error {
+	mu.Lock()
+	defer mu.Unlock()
 	if l == nil {
 		return nil
 	}
 
-	return l.out.Close()
+	var err error
+	if l.file != nil {
+		err = l.file.Close()
+	}
+	l = nil
+	return err
+}
+
+// lockedWriter serializes each write with log messages.
+type lockedWriter struct{}
+
+func (lockedWriter) Write(p []byte) (int, error) {
+	mu.Lock()
+	defer mu.Unlock()
+	if l == nil {
+		return len(p), nil
+	}
+	return l.out.Write(p)
 }
 
 func GetWriter() io.Writer {
+	mu.Lock()
+	defer mu.Unlock()
 	if l == nil {
 		return nil
 	}
-	return l.out
+	return lockedWriter{}
 }
 
-func Log(format string, opts ...interface{}) {
-	if l == nil {
+// GetWriterAt returns nil when the level is disabled so that callers can skip building verbose dumps.
+func GetWriterAt(level Level) io.Writer {
+	mu.Lock()
+	defer mu.Unlock()
+	if l == nil || level < l.level {
+		return nil
+	}
+	return lockedWriter{}
+}
+
+func logAt(level Level, format string, opts ...interface{}) {
+	msg := fmt.Sprintf(format+"\n", opts...)
+
+	mu.Lock()
+	defer mu.Unlock()
+	if l == nil || level < l.level {
 		return
 	}
-	fmt.Fprintf(l.out, format+"\n", opts...)
+	io.WriteString(l.out, msg)
+}
+
+func Debug(format string, opts ...interface{}) {
+	logAt(LevelDebug, format, opts...)
+}
+
+func Info(format string, opts ...interface{}) {
+	logAt(LevelInfo, format, opts...)
+}
+
+func Warn(format string, opts ...interface{}) {
+	logAt(LevelWarn, format, opts...)
+}
+
+func Error(format string, opts ...interface{}) {
+	logAt(LevelError, format, opts...)
+}
+
+// Log is an alias of Info.
+func Log(format string, opts ...interface{}) {
+	Info(format, opts...)
 }
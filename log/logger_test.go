@@ -0,0 +1,84 @@
+package log
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestLog_Concurrent(t *testing.T) {
+	dir, err := ioutil.TempDir("", "9gram")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "test.log")
+
+	err = Init(path, LevelDebug)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const goroutines = 16
+	const lines = 100
+	var wg sync.WaitGroup
+	for i := 0; i < goroutines; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < lines; j++ {
+				Log("goroutine %v: line %v", i, j)
+				fmt.Fprintf(GetWriter(), "writer %v: line %v\n", i, j)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	err = Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Logging after Close must be a no-op.
+	Log("after close")
+
+	d, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	logLines := strings.Split(strings.TrimSuffix(string(d), "\n"), "\n")
+	if len(logLines) != goroutines*lines*2 {
+		t.Fatalf("unexpected number of lines; want: %v, got: %v", goroutines*lines*2, len(logLines))
+	}
+	for _, line := range logLines {
+		if !strings.HasPrefix(line, "goroutine ") && !strings.HasPrefix(line, "writer ") {
+			t.Fatalf("a line is corrupted: %q", line)
+		}
+	}
+}
+
+func TestInitWithWriter(t *testing.T) {
+	var b bytes.Buffer
+	InitWithWriter(&b, LevelInfo)
+	Debug("debug")
+	Info("info")
+	Warn("warn %v", 1)
+	fmt.Fprintf(GetWriterAt(LevelError), "error\n")
+	if GetWriterAt(LevelDebug) != nil {
+		t.Fatalf("a writer of a disabled level must be nil")
+	}
+	err := Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	Error("after close")
+
+	expected := "info\nwarn 1\nerror\n"
+	if b.String() != expected {
+		t.Fatalf("unexpected log; want: %q, got: %q", expected, b.String())
+	}
+}
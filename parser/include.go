@@ -0,0 +1,183 @@
+package parser
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+)
+
+// includeDirective is the name of the directive including another grammar file like `%include "expr.9g";`.
+const includeDirective = "include"
+
+// IncludeError is an error found in an included file. Source is the content of the file, so the caller can pass it
+// to FormatError along with Err when Err is a *SyntaxError.
+type IncludeError struct {
+	Path   string
+	Source []byte
+	Err    error
+}
+
+func (e *IncludeError) Error() string {
+	return fmt.Sprintf("%v: %v", e.Path, e.Err)
+}
+
+func (e *IncludeError) Unwrap() error {
+	return e.Err
+}
+
+// ResolveIncludes replaces each `%include "path";` directive in root with the definitions of the file. path is the
+// path of the file that root was parsed from, and a relative path in a directive is resolved against the directory
+// of the including file. When root was parsed from other than a file, such as stdin, path must be empty, and the
+// paths are resolved against the current directory. Included files can include other files. A file included more
+// than once is expanded only at its first appearance, and a cycle of includes is an error. A symbol must be defined
+// in only one file. The files parse with opts.
+//
+// ResolveIncludes returns the paths of the included files in the order of their appearance. The errors in an
+// included file are reported as *IncludeError.
+func ResolveIncludes(root *AST, path string, opts ...ParserOption) ([]string, error) {
+	r := &includeResolver{
+		opts:      opts,
+		included:  map[string]struct{}{},
+		definedIn: map[string]*includedFile{},
+	}
+	file := &includedFile{
+		path: path,
+	}
+	if path != "" {
+		abs, err := filepath.Abs(path)
+		if err != nil {
+			return nil, err
+		}
+		file.absPath = abs
+		r.included[abs] = struct{}{}
+	}
+	err := r.resolve(root, file, []*includedFile{file})
+	if err != nil {
+		return nil, err
+	}
+	return r.paths, nil
+}
+
+type includedFile struct {
+	path    string
+	absPath string
+}
+
+type includeResolver struct {
+	opts      []ParserOption
+	included  map[string]struct{}
+	paths     []string
+	definedIn map[string]*includedFile
+}
+
+func (r *includeResolver) resolve(root *AST, file *includedFile, stack []*includedFile) error {
+	var children []*AST
+	for _, child := range root.Children {
+		if child.Ty == ASTTypeProduction {
+			err := r.define(child, file)
+			if err != nil {
+				return err
+			}
+			children = append(children, child)
+			continue
+		}
+		if name, _ := child.GetText(); child.Ty != ASTTypeDirective || name != includeDirective {
+			children = append(children, child)
+			continue
+		}
+
+		pos, _ := child.Pos()
+		if len(child.Children) != 1 || child.Children[0].Ty != ASTTypePattern {
+			return newSyntaxError(pos, "%include takes exactly one path string")
+		}
+		incPath, _ := child.Children[0].GetText()
+		if !filepath.IsAbs(incPath) {
+			incPath = filepath.Join(filepath.Dir(file.path), incPath)
+		}
+		abs, err := filepath.Abs(incPath)
+		if err != nil {
+			return newSyntaxError(pos, fmt.Sprintf("invalid include path; path: %v, error: %v", incPath, err))
+		}
+		for i, f := range stack {
+			if f.absPath != abs {
+				continue
+			}
+			var paths []string
+			for _, f := range stack[i:] {
+				paths = append(paths, f.path)
+			}
+			paths = append(paths, incPath)
+			return newSyntaxError(pos, fmt.Sprintf("include cycle; files: %v", strings.Join(paths, " → ")))
+		}
+		if _, ok := r.included[abs]; ok {
+			continue
+		}
+		r.included[abs] = struct{}{}
+		r.paths = append(r.paths, incPath)
+
+		src, err := ioutil.ReadFile(incPath)
+		if err != nil {
+			return newSyntaxError(pos, fmt.Sprintf("failed to read an included file; path: %v, error: %v", incPath, err))
+		}
+		incFile := &includedFile{
+			path:    incPath,
+			absPath: abs,
+		}
+		incRoot, err := r.resolveFile(src, incFile, append(stack, incFile))
+		if err != nil {
+			// The errors in the included file itself are syntax errors. The ones in the files that it includes are
+			// wrapped already.
+			if synErr, ok := err.(*SyntaxError); ok {
+				return &IncludeError{
+					Path:   incPath,
+					Source: src,
+					Err:    synErr,
+				}
+			}
+			return err
+		}
+		children = append(children, incRoot.Children...)
+	}
+	root.Children = children
+	return nil
+}
+
+// resolveFile parses an included file and resolves the includes in it.
+func (r *includeResolver) resolveFile(src []byte, file *includedFile, stack []*includedFile) (*AST, error) {
+	p, err := NewParser(bytes.NewReader(src), r.opts...)
+	if err != nil {
+		return nil, err
+	}
+	root, err := p.Parse()
+	if err != nil {
+		return nil, err
+	}
+	err = r.resolve(root, file, stack)
+	if err != nil {
+		return nil, err
+	}
+	return root, nil
+}
+
+func (r *includeResolver) define(prod *AST, file *includedFile) error {
+	lhs, _ := prod.Children[0].GetText()
+	f, ok := r.definedIn[lhs]
+	if !ok {
+		r.definedIn[lhs] = file
+		return nil
+	}
+	if f == file {
+		return nil
+	}
+	pos, _ := prod.Pos()
+	return newSyntaxError(pos, fmt.Sprintf("a symbol is defined in more than one file; symbol: %v, files: %v, %v", lhs, displayPath(f.path), displayPath(file.path)))
+}
+
+func displayPath(path string) string {
+	if path == "" {
+		return "<stdin>"
+	}
+	return path
+}
@@ -0,0 +1,183 @@
+package parser
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestResolveIncludes(t *testing.T) {
+	dir, err := ioutil.TempDir("", "9gram")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	writeTestFiles(t, dir, map[string]string{
+		"main.9g":     `%include "lib/expr.9g"; s: expr; %include "lib/term.9g";`,
+		"lib/expr.9g": `%include "term.9g"; expr: expr "+" term | term;`,
+		"lib/term.9g": `term: NUM; NUM: "[0-9]+";`,
+	})
+
+	path := filepath.Join(dir, "main.9g")
+	ast := parseTestFile(t, path)
+	included, err := ResolveIncludes(ast, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedPaths := []string{
+		filepath.Join(dir, "lib", "expr.9g"),
+		filepath.Join(dir, "lib", "term.9g"),
+	}
+	if fmt.Sprint(included) != fmt.Sprint(expectedPaths) {
+		t.Fatalf("unexpected included files; want: %v, got: %v", expectedPaths, included)
+	}
+	expected := `term: NUM;
+NUM: "[0-9]+";
+expr: expr "+" term | term;
+s: expr;
+`
+	if ast.String() != expected {
+		t.Fatalf("unexpected AST;\nwant:\n%v\ngot:\n%v", expected, ast.String())
+	}
+}
+
+func TestResolveIncludes_Error(t *testing.T) {
+	tests := []struct {
+		caption  string
+		files    map[string]string
+		errFile  string
+		pos      Position
+		message  string
+		included bool
+	}{
+		{
+			caption: "a cycle of includes is an error",
+			files: map[string]string{
+				"main.9g": `%include "a.9g"; s: a;`,
+				"a.9g":    `%include "b.9g"; a: b;`,
+				"b.9g":    `b: B; %include "a.9g";`,
+			},
+			errFile:  "b.9g",
+			pos:      pos(1, 7, 6),
+			message:  "include cycle; files: a.9g → b.9g → a.9g",
+			included: true,
+		},
+		{
+			caption: "a file including itself is an error",
+			files: map[string]string{
+				"main.9g": `s: A; %include "main.9g";`,
+			},
+			errFile: "main.9g",
+			pos:     pos(1, 7, 6),
+			message: "include cycle; files: main.9g → main.9g",
+		},
+		{
+			caption: "a symbol defined in more than one file is an error",
+			files: map[string]string{
+				"main.9g": `%include "a.9g"; s: A; a: C;`,
+				"a.9g":    `a: B;`,
+			},
+			errFile: "main.9g",
+			pos:     pos(1, 24, 23),
+			message: "a symbol is defined in more than one file; symbol: a, files: a.9g, main.9g",
+		},
+		{
+			caption: "a missing file is an error",
+			files: map[string]string{
+				"main.9g": `s: A;
+%include "missing.9g";`,
+			},
+			errFile: "main.9g",
+			pos:     pos(2, 1, 6),
+			message: "failed to read an included file; path: missing.9g",
+		},
+		{
+			caption: "%include takes exactly one path string",
+			files: map[string]string{
+				"main.9g": `s: A; %include a;`,
+			},
+			errFile: "main.9g",
+			pos:     pos(1, 7, 6),
+			message: "%include takes exactly one path string",
+		},
+		{
+			caption: "a syntax error in an included file is reported with the file",
+			files: map[string]string{
+				"main.9g": `%include "a.9g"; s: a;`,
+				"a.9g":    `a: B`,
+			},
+			errFile:  "a.9g",
+			pos:      pos(1, 5, 4),
+			message:  "unexpected token",
+			included: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.caption, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "9gram")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(dir)
+			writeTestFiles(t, dir, tt.files)
+
+			path := filepath.Join(dir, "main.9g")
+			_, err = ResolveIncludes(parseTestFile(t, path), path)
+			var incErr *IncludeError
+			if errors.As(err, &incErr) != tt.included {
+				t.Fatalf("unexpected error type; included: %v, error: %v", tt.included, err)
+			}
+			if incErr != nil && incErr.Path != filepath.Join(dir, tt.errFile) {
+				t.Fatalf("unexpected file; want: %v, got: %v", filepath.Join(dir, tt.errFile), incErr.Path)
+			}
+			var synErr *SyntaxError
+			if !errors.As(err, &synErr) {
+				t.Fatalf("unexpected error; want: %T, got: %v", synErr, err)
+			}
+			if synErr.Pos() != tt.pos {
+				t.Fatalf("unexpected position; want: %+v, got: %+v (%v)", tt.pos, synErr.Pos(), synErr)
+			}
+			if !strings.Contains(strings.ReplaceAll(synErr.Message(), dir+string(filepath.Separator), ""), tt.message) {
+				t.Fatalf("unexpected message; want: %v, got: %v", tt.message, synErr.Message())
+			}
+		})
+	}
+}
+
+func writeTestFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+
+	for name, src := range files {
+		path := filepath.Join(dir, name)
+		err := os.MkdirAll(filepath.Dir(path), 0755)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = ioutil.WriteFile(path, []byte(src), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func parseTestFile(t *testing.T, path string) *AST {
+	t.Helper()
+
+	src, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := NewParser(strings.NewReader(string(src)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ast, err := p.Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ast
+}
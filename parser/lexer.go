@@ -8,50 +8,72 @@ import (
 	"unicode"
 )
 
-type tokenKind string
+// TokenKind is the kind of a token. The value is a readable name of the kind, such as `id` and `:`.
+type TokenKind string
 
 const (
-	tokenKindColon      = tokenKind(":")
-	tokenKindVBar       = tokenKind("|")
-	tokenKindSemicolon  = tokenKind(";")
-	tokenKindOptional   = tokenKind("?")
-	tokenKindZeorOrMore = tokenKind("*")
-	tokenKindOneOrMore  = tokenKind("+")
-	tokenKindID         = tokenKind("id")
-	tokenKindPattern    = tokenKind("pattern")
-	tokenKindComment    = tokenKind("comment")
-	tokenKindEOF        = tokenKind("eof")
-	tokenKindUnknown    = tokenKind("unknown")
+	TokenKindColon        = TokenKind(":")
+	TokenKindVBar         = TokenKind("|")
+	TokenKindSemicolon    = TokenKind(";")
+	TokenKindOptional     = TokenKind("?")
+	TokenKindZeroOrMore   = TokenKind("*")
+	TokenKindOneOrMore    = TokenKind("+")
+	TokenKindLParen       = TokenKind("(")
+	TokenKindRParen       = TokenKind(")")
+	TokenKindComma        = TokenKind(",")
+	TokenKindID           = TokenKind("id")
+	TokenKindNumber       = TokenKind("number")
+	TokenKindString       = TokenKind("string")
+	TokenKindPattern      = TokenKind("pattern")
+	TokenKindLabel        = TokenKind("label")
+	TokenKindDirective    = TokenKind("directive")
+	TokenKindComment      = TokenKind("comment")
+	TokenKindBlockComment = TokenKind("block comment")
+	TokenKindAction       = TokenKind("action")
+	TokenKindEOF          = TokenKind("eof")
+	TokenKindUnknown      = TokenKind("unknown")
 )
 
 type Position struct {
 	Line   int
 	Column int
+
+	// Offset is a byte offset from the beginning of the source.
+	Offset int
 }
 
 func newPosition() Position {
 	return Position{
 		Line:   1,
 		Column: 1,
+		Offset: 0,
 	}
 }
 
-func (p *Position) increment(c rune) {
-	if c == '\n' || c == '\r' {
+// increment advances the position past the rune c whose UTF-8 encoding is size bytes long. Each of `\n`, `\r`,
+// and `\r\n` is a line break, so `\n` following `\r` only advances the offset. afterCR tells whether c follows
+// `\r`. A tab advances the column to the next tab stop, which is placed every tabWidth columns.
+func (p *Position) increment(c rune, size int, afterCR bool, tabWidth int) {
+	switch {
+	case c == '\n' && afterCR:
+	case c == '\n' || c == '\r':
 		p.Line += 1
 		p.Column = 1
-	} else {
+	case c == '\t' && tabWidth > 1:
+		p.Column = ((p.Column-1)/tabWidth+1)*tabWidth + 1
+	default:
 		p.Column += 1
 	}
+	p.Offset += size
 }
 
 type token struct {
-	kind tokenKind
+	kind TokenKind
 	pos  Position
 	text string
 }
 
-func newSymbolToken(pos Position, kind tokenKind) *token {
+func newSymbolToken(pos Position, kind TokenKind) *token {
 	return &token{
 		kind: kind,
 		pos:  pos,
@@ -60,38 +82,94 @@ func newSymbolToken(pos Position, kind tokenKind) *token {
 
 func newIDToken(pos Position, text string) *token {
 	return &token{
-		kind: tokenKindID,
+		kind: TokenKindID,
+		pos:  pos,
+		text: text,
+	}
+}
+
+func newNumberToken(pos Position, text string) *token {
+	return &token{
+		kind: TokenKindNumber,
 		pos:  pos,
 		text: text,
 	}
 }
 
+func newStringToken(pos Position, text string) *token {
+	return &token{
+		pos:  pos,
+		kind: TokenKindString,
+		text: text,
+	}
+}
+
 func newPatternToken(pos Position, text string) *token {
 	return &token{
-		kind: tokenKindPattern,
+		kind: TokenKindPattern,
+		pos:  pos,
+		text: text,
+	}
+}
+
+func newLabelToken(pos Position, text string) *token {
+	return &token{
+		kind: TokenKindLabel,
 		pos:  pos,
 		text: text,
 	}
 }
 
+func newDirectiveToken(pos Position, name string) *token {
+	return &token{
+		kind: TokenKindDirective,
+		pos:  pos,
+		text: name,
+	}
+}
+
 func newCommentToken(pos Position, text string) *token {
 	return &token{
-		kind: tokenKindComment,
+		kind: TokenKindComment,
 		pos:  pos,
 		text: text,
 	}
 }
 
+func newBlockCommentToken(pos Position, text string) *token {
+	return &token{
+		kind: TokenKindBlockComment,
+		pos:  pos,
+		text: text,
+	}
+}
+
+// commentSource returns the comment as written in the source.
+func (t *token) commentSource() string {
+	if t.kind == TokenKindBlockComment {
+		return "/*" + t.text + "*/"
+	}
+	return "//" + t.text
+}
+
+func newActionToken(pos Position, code string) *token {
+	return &token{
+		kind: TokenKindAction,
+		pos:  pos,
+		text: code,
+	}
+}
+
 func newEOFToken(pos Position) *token {
 	return &token{
-		kind: tokenKindEOF,
+		kind: TokenKindEOF,
 		pos:  pos,
 	}
 }
 
 func newUnknownToken(pos Position, text string) *token {
 	return &token{
-		kind: tokenKindUnknown,
+		kind: TokenKindUnknown,
 		pos:  pos,
 		text: text,
 	}
@@ -99,14 +177,80 @@ func newUnknownToken(pos Position, text string) *token {
 
 const nullChar = '\u0000'
 
+// Token is a token of a grammar source.
+type Token struct {
+	Kind TokenKind
+
+	// Text is the identifier of an ID, the digits of a number with its sign, the decoded string of a string literal
+	// or a pattern, the name of a label or a directive, the contents of a comment or an action without its delimiters,
+	// or the characters of an unknown token. It is empty for the other kinds.
+	Text string
+
+	Pos Position
+}
+
+// Lexer splits a grammar source into tokens. Unlike the parser, it returns comments as tokens as well.
+type Lexer struct {
+	l   *lexer
+	eof *token
+}
+
+func NewLexer(src io.Reader) *Lexer {
+	return &Lexer{
+		l: newLexer(src),
+	}
+}
+
+// Next returns the next token. After the source runs out, Next keeps returning the EOF token. When the source
+// contains a malformed token, Next returns a *SyntaxError.
+func (l *Lexer) Next() (Token, error) {
+	tok := l.eof
+	if tok == nil {
+		var err error
+		tok, err = l.l.next()
+		if err != nil {
+			return Token{}, err
+		}
+		if tok.kind == TokenKindEOF {
+			l.eof = tok
+		}
+	}
+	return Token{
+		Kind: tok.kind,
+		Text: tok.text,
+		Pos:  tok.pos,
+	}, nil
+}
+
+// maxRestore is the number of runes that the lexer can push back in a row.
+const maxRestore = 8
+
+// readRune is a rune that the lexer has read. When eof is true, the lexer reached the end of the source.
+type readRune struct {
+	c    rune
+	size int
+	pos  Position
+	eof  bool
+
+	// afterCR is true when the rune follows `\r` in the source.
+	afterCR bool
+}
+
 type lexer struct {
 	src         *bufio.Reader
 	pos         Position
 	lastChar    rune
 	lastCharPos Position
-	prevChar    rune
-	prevCharPos Position
-	reachedEOF  bool
+
+	// history holds the most recent runes read, and pushback holds the runes restored, the most recent one last.
+	history  []readRune
+	pushback []readRune
+
+	// lastSrcChar is the last rune read from src. It is independent of restore.
+	lastSrcChar rune
+
+	// tabWidth is the interval of tab stops. A tab advances the column by one when tabWidth is 1.
+	tabWidth int
 }
 
 func newLexer(src io.Reader) *lexer {
@@ -115,9 +259,8 @@ func newLexer(src io.Reader) *lexer {
 		pos:         newPosition(),
 		lastChar:    nullChar,
 		lastCharPos: newPosition(),
-		prevChar:    nullChar,
-		prevCharPos: newPosition(),
-		reachedEOF:  false,
+		lastSrcChar: nullChar,
+		tabWidth:    1,
 	}
 }
 
@@ -138,43 +281,141 @@ func (l *lexer) next() (*token, error) {
 
 	switch {
 	case c == ':':
-		return newSymbolToken(pos, tokenKindColon), nil
+		return newSymbolToken(pos, TokenKindColon), nil
 	case c == '|':
-		return newSymbolToken(pos, tokenKindVBar), nil
+		return newSymbolToken(pos, TokenKindVBar), nil
 	case c == ';':
-		return newSymbolToken(pos, tokenKindSemicolon), nil
+		return newSymbolToken(pos, TokenKindSemicolon), nil
 	case c == '?':
-		return newSymbolToken(pos, tokenKindOptional), nil
+		return newSymbolToken(pos, TokenKindOptional), nil
 	case c == '*':
-		return newSymbolToken(pos, tokenKindZeorOrMore), nil
+		return newSymbolToken(pos, TokenKindZeroOrMore), nil
 	case c == '+':
-		return newSymbolToken(pos, tokenKindOneOrMore), nil
+		return newSymbolToken(pos, TokenKindOneOrMore), nil
+	case c == '(':
+		return newSymbolToken(pos, TokenKindLParen), nil
+	case c == ')':
+		return newSymbolToken(pos, TokenKindRParen), nil
+	case c == ',':
+		return newSymbolToken(pos, TokenKindComma), nil
 	case isIDChar(c):
 		text, err := l.readID()
 		if err != nil {
 			return nil, err
 		}
+		// The ' suffix is reserved for the augmented start symbol like `expr'`. Without this check, the quote would
+		// begin a string literal and fail with a misleading error.
+		c, eof, err := l.read()
+		if err != nil {
+			return nil, err
+		}
+		if !eof && c == '\'' {
+			return nil, newSyntaxError(pos, fmt.Sprintf("the ' suffix is reserved for the augmented start symbol; identifier: %v'", text))
+		}
+		l.restore()
+		// An identifier consisting only of digits is a number. An identifier beginning with digits like `1st` is
+		// still an identifier.
+		if isNumber(text) {
+			return newNumberToken(pos, text), nil
+		}
 		return newIDToken(pos, text), nil
+	case c == '-':
+		c, eof, err := l.read()
+		if err != nil {
+			return nil, err
+		}
+		if !eof && isDigit(c) {
+			text, err := l.readDigits()
+			if err != nil {
+				return nil, err
+			}
+			return newNumberToken(pos, "-"+text), nil
+		}
+		l.restore()
+	case c == '#':
+		c, eof, err := l.read()
+		if err != nil {
+			return nil, err
+		}
+		if eof || !isIDChar(c) {
+			return nil, newSyntaxError(pos, "a label must be an identifier following #")
+		}
+		text, err := l.readID()
+		if err != nil {
+			return nil, err
+		}
+		return newLabelToken(pos, text), nil
+	case c == '%':
+		c, eof, err := l.read()
+		if err != nil {
+			return nil, err
+		}
+		if eof || !isIDChar(c) {
+			return nil, newSyntaxError(pos, "a directive must be an identifier following %")
+		}
+		text, err := l.readID()
+		if err != nil {
+			return nil, err
+		}
+		return newDirectiveToken(pos, text), nil
+	case c == '$':
+		c, eof, err := l.read()
+		if err != nil {
+			return nil, err
+		}
+		if !eof && isIDChar(c) {
+			text, err := l.readID()
+			if err != nil {
+				return nil, err
+			}
+			return nil, newSyntaxError(pos, fmt.Sprintf("the $ prefix is reserved for generated symbols; identifier: $%v", text))
+		}
+		l.restore()
+	case c == '{':
+		code, err := l.readAction(pos)
+		if err != nil {
+			return nil, err
+		}
+		return newActionToken(pos, code), nil
 	case c == '"':
-		text, err := l.readPattern()
+		text, err := l.readPattern(pos)
 		if err != nil {
 			return nil, err
 		}
 		return newPatternToken(pos, text), nil
-	case c == '/':
-		c, _, err := l.read()
+	case c == '\'':
+		text, err := l.readString(pos)
 		if err != nil {
 			return nil, err
 		}
-		if c != '/' {
-			l.restore()
-			break
-		}
-		text, err := l.readComment()
+		return newStringToken(pos, text), nil
+	case c == '/':
+		c, eof, err := l.read()
 		if err != nil {
 			return nil, err
 		}
-		return newCommentToken(pos, text), nil
+		switch {
+		case c == '/':
+			text, err := l.readComment()
+			if err != nil {
+				return nil, err
+			}
+			return newCommentToken(pos, text), nil
+		case c == '*':
+			text, err := l.readBlockComment(pos)
+			if err != nil {
+				return nil, err
+			}
+			return newBlockCommentToken(pos, text), nil
+		case !eof:
+			l.restore()
+			text, err := l.readSlashPattern(pos)
+			if err != nil {
+				return nil, err
+			}
+			return newPatternToken(pos, text), nil
+		}
+		l.restore()
 	}
 
 	text, err := l.readUnknown()
@@ -233,56 +474,220 @@ func (l *lexer) readID() (string, error) {
 	return b.String(), nil
 }
 
+// readDigits reads a sequence of decimal digits beginning with the last character read.
+func (l *lexer) readDigits() (string, error) {
+	var b strings.Builder
+	fmt.Fprint(&b, string(l.lastChar))
+	for {
+		c, eof, err := l.read()
+		if err != nil {
+			return "", err
+		}
+		if eof {
+			break
+		}
+		if !isDigit(c) {
+			err := l.restore()
+			if err != nil {
+				return "", err
+			}
+			break
+		}
+		fmt.Fprint(&b, string(c))
+	}
+
+	return b.String(), nil
+}
+
+func isNumber(text string) bool {
+	for _, c := range text {
+		if !isDigit(c) {
+			return false
+		}
+	}
+	return text != ""
+}
+
+// isDigit reports whether c is a decimal digit. Unlike unicode.IsDigit, it accepts only ASCII digits, so an
+// identifier of digits of other scripts is still an identifier.
+func isDigit(c rune) bool {
+	return c >= '0' && c <= '9'
+}
+
 func isIDChar(c rune) bool {
-	return isIDHeadChar(c) || c >= '0' && c <= '9'
+	return isIDHeadChar(c) || unicode.IsDigit(c)
 }
 
 func isIDHeadChar(c rune) bool {
-	return c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z' || c == '_'
+	return unicode.IsLetter(c) || c == '_'
+}
+
+// readPattern reads a pattern string following a double quote at pos and decodes the escape sequences in it.
+// The following escape sequences are available:
+//
+//	\"   double quote
+//	\\   backslash
+//	\n   newline (U+000A)
+//	\t   horizontal tab (U+0009)
+//	\xNN the character U+00NN, where NN is two hexadecimal digits
+func (l *lexer) readPattern(pos Position) (string, error) {
+	text, err := l.readQuoted(pos, '"', "unclosed pattern string")
+	if err != nil {
+		return "", err
+	}
+	if text == "" {
+		return "", newSyntaxError(pos, "empty pattern string")
+	}
+
+	return text, nil
 }
 
-func (l *lexer) readPattern() (string, error) {
+// readString reads a string literal following a single quote at pos, such as the value of a directive. A string
+// literal can be empty, and the escape sequences are the same as those of a pattern except that `\'` means a single
+// quote instead of `\"`.
+func (l *lexer) readString(pos Position) (string, error) {
+	return l.readQuoted(pos, '\'', "unclosed string literal")
+}
+
+// readQuoted reads the characters until the closing quote and decodes the escape sequences in them. unclosed is the
+// message of the error that the end of the source raises.
+func (l *lexer) readQuoted(pos Position, quote rune, unclosed string) (string, error) {
 	var b strings.Builder
 	for {
-		c, terminated, eof, err := l.readEscapedChar()
+		c, eof, err := l.read()
 		if err != nil {
 			return "", err
 		}
 		if eof {
-			return "", fmt.Errorf("unclosed pattern string")
+			return "", newSyntaxError(pos, unclosed)
 		}
-		if terminated {
+		if c == quote {
 			break
 		}
+		if c == '\\' {
+			c, err = l.readEscapeSequence(pos, l.lastCharPos, quote, unclosed)
+			if err != nil {
+				return "", err
+			}
+		}
 
 		fmt.Fprint(&b, string(c))
 	}
-	if b.Len() <= 0 {
-		return "", fmt.Errorf("empty pattern string")
+
+	return b.String(), nil
+}
+
+// readSlashPattern reads a pattern delimited by slashes like `/[0-9]+/`. Unlike a pattern delimited by double
+// quotes, the pattern is taken as it is except that `\/` means `/`, so a regular expression needs no extra escapes.
+// Because `//` and `/*` begin comments, a pattern can begin with neither `/` nor `*`; write `\/` for the former. A
+// pattern cannot contain a new line.
+func (l *lexer) readSlashPattern(pos Position) (string, error) {
+	var b strings.Builder
+	for {
+		c, eof, err := l.read()
+		if err != nil {
+			return "", err
+		}
+		if eof || c == '\n' {
+			return "", newSyntaxError(pos, "unclosed pattern string")
+		}
+		if c == '/' {
+			break
+		}
+		if c == '\\' {
+			next, eof, err := l.read()
+			if err != nil {
+				return "", err
+			}
+			if eof || next == '\n' {
+				return "", newSyntaxError(pos, "unclosed pattern string")
+			}
+			if next != '/' {
+				fmt.Fprint(&b, string(c))
+			}
+			c = next
+		}
+
+		fmt.Fprint(&b, string(c))
 	}
 
 	return b.String(), nil
 }
 
-func (l *lexer) readEscapedChar() (rune, bool, bool, error) {
+func (l *lexer) readEscapeSequence(patPos, escPos Position, quote rune, unclosed string) (rune, error) {
 	c, eof, err := l.read()
 	if err != nil {
-		return nullChar, false, false, err
+		return nullChar, err
 	}
-	if c == '\\' {
-		ec, _, err := l.read()
-		if err != nil {
-			return nullChar, false, false, err
-		}
-		if ec == '"' || ec == '\\' {
-			return ec, false, false, nil
+	if eof {
+		return nullChar, newSyntaxError(patPos, unclosed)
+	}
+	switch c {
+	case quote, '\\':
+		return c, nil
+	case 'n':
+		return '\n', nil
+	case 't':
+		return '\t', nil
+	case 'x':
+		var code rune
+		for i := 0; i < 2; i++ {
+			h, eof, err := l.read()
+			if err != nil {
+				return nullChar, err
+			}
+			v, ok := hexDigitValue(h)
+			if eof || !ok {
+				return nullChar, newSyntaxError(escPos, "invalid escape sequence: \\x must be followed by two hexadecimal digits")
+			}
+			code = code*16 + v
 		}
-		return nullChar, false, false, fmt.Errorf("unsupported escape sequence: \\%s", string(ec))
+		return code, nil
+	}
+	return nullChar, newSyntaxError(escPos, fmt.Sprintf("unsupported escape sequence: \\%s", string(c)))
+}
+
+func hexDigitValue(c rune) (rune, bool) {
+	switch {
+	case c >= '0' && c <= '9':
+		return c - '0', true
+	case c >= 'a' && c <= 'f':
+		return c - 'a' + 10, true
+	case c >= 'A' && c <= 'F':
+		return c - 'A' + 10, true
 	}
-	if c == '"' {
-		return nullChar, true, false, nil
+	return 0, false
+}
+
+// escapePattern is the inverse of the decoding that readPattern performs.
+func escapePattern(text string) string {
+	return escapeQuoted(text, '"')
+}
+
+// escapeString is the inverse of the decoding that readString performs.
+func escapeString(text string) string {
+	return escapeQuoted(text, '\'')
+}
+
+func escapeQuoted(text string, quote rune) string {
+	var b strings.Builder
+	for _, c := range text {
+		switch {
+		case c == quote:
+			fmt.Fprintf(&b, `\%v`, string(c))
+		case c == '\\':
+			fmt.Fprint(&b, `\\`)
+		case c == '\n':
+			fmt.Fprint(&b, `\n`)
+		case c == '\t':
+			fmt.Fprint(&b, `\t`)
+		case c < 0x20 || c == 0x7f:
+			fmt.Fprintf(&b, `\x%02x`, c)
+		default:
+			fmt.Fprint(&b, string(c))
+		}
 	}
-	return c, false, eof, nil
+	return b.String()
 }
 
 func (l *lexer) readComment() (string, error) {
@@ -301,6 +706,74 @@ func (l *lexer) readComment() (string, error) {
 	return b.String(), nil
 }
 
+// readBlockComment reads a block comment following /* at pos. Block comments cannot be nested.
+func (l *lexer) readBlockComment(pos Position) (string, error) {
+	var b strings.Builder
+	for {
+		c, eof, err := l.read()
+		if err != nil {
+			return "", err
+		}
+		if eof {
+			return "", newSyntaxError(pos, "unclosed block comment")
+		}
+		switch c {
+		case '*':
+			c2, eof, err := l.read()
+			if err != nil {
+				return "", err
+			}
+			if eof {
+				return "", newSyntaxError(pos, "unclosed block comment")
+			}
+			if c2 == '/' {
+				return b.String(), nil
+			}
+			l.restore()
+		case '/':
+			nestedPos := l.lastCharPos
+			c2, eof, err := l.read()
+			if err != nil {
+				return "", err
+			}
+			if eof {
+				return "", newSyntaxError(pos, "unclosed block comment")
+			}
+			if c2 == '*' {
+				return "", newSyntaxError(nestedPos, "nested block comments are not supported")
+			}
+			l.restore()
+		}
+		fmt.Fprint(&b, string(c))
+	}
+}
+
+// readAction reads the code of an action following { at pos as it is. The braces in the code must be balanced; the
+// lexer doesn't know the syntax of the target language, so a brace in a string literal of the code counts too.
+func (l *lexer) readAction(pos Position) (string, error) {
+	var b strings.Builder
+	depth := 0
+	for {
+		c, eof, err := l.read()
+		if err != nil {
+			return "", err
+		}
+		if eof {
+			return "", newSyntaxError(pos, "unclosed action")
+		}
+		switch c {
+		case '{':
+			depth++
+		case '}':
+			if depth == 0 {
+				return b.String(), nil
+			}
+			depth--
+		}
+		fmt.Fprint(&b, string(c))
+	}
+}
+
 func (l *lexer) readUnknown() (string, error) {
 	var b strings.Builder
 	fmt.Fprint(&b, string(l.lastChar))
@@ -330,45 +803,94 @@ func isUnknownChar(c rune) bool {
 }
 
 func isHeadChar(c rune) bool {
-	return c == ':' || c == '|' || c == ';' || c == '?' || c == '*' || c == '+' || isIDHeadChar(c) || c == '"' || c == '/' || isWhitespace(c)
+	return c == ':' || c == '|' || c == ';' || c == '?' || c == '*' || c == '+' || c == '(' || c == ')' || c == ',' || c == '-' || c == '#' || c == '%' || c == '$' || isIDHeadChar(c) || c == '"' || c == '\'' || c == '/' || c == '{' || isWhitespace(c)
 }
 
 func (l *lexer) read() (rune, bool, error) {
-	c, _, err := l.src.ReadRune()
-	if err != nil {
-		if err == io.EOF {
-			l.prevChar = l.lastChar
-			l.prevCharPos = l.lastCharPos
-			l.lastChar = nullChar
-			l.lastCharPos = l.pos
-			l.reachedEOF = true
-			return nullChar, true, nil
+	var r readRune
+	if n := len(l.pushback); n > 0 {
+		r = l.pushback[n-1]
+		l.pushback = l.pushback[:n-1]
+	} else {
+		c, size, err := l.src.ReadRune()
+		if err != nil {
+			if err != io.EOF {
+				return nullChar, false, err
+			}
+			r = readRune{
+				c:   nullChar,
+				pos: l.pos,
+				eof: true,
+			}
+		} else {
+			r = readRune{
+				c:       c,
+				size:    size,
+				pos:     l.pos,
+				afterCR: l.lastSrcChar == '\r',
+			}
+			l.lastSrcChar = c
 		}
-		return nullChar, false, err
 	}
-	l.prevChar = l.lastChar
-	l.prevCharPos = l.lastCharPos
-	l.lastChar = c
-	l.lastCharPos = l.pos
-	l.pos.increment(c)
-	return c, false, nil
+
+	if len(l.history) >= maxRestore {
+		l.history = append(l.history[:0], l.history[1:]...)
+	}
+	l.history = append(l.history, r)
+	l.lastChar = r.c
+	l.lastCharPos = r.pos
+	if !r.eof {
+		l.pos.increment(r.c, r.size, r.afterCR, l.tabWidth)
+	}
+	return r.c, r.eof, nil
 }
 
+// restore pushes back the most recent rune read, including the EOF, so that the next read returns it again.
+// The lexer can restore up to maxRestore runes in a row.
 func (l *lexer) restore() error {
-	if l.reachedEOF {
-		l.pos = l.lastCharPos
-		l.lastChar = l.prevChar
-		l.lastCharPos = l.prevCharPos
-		l.prevChar = nullChar
-		l.reachedEOF = false
-		return l.src.UnreadRune()
-	}
-	if l.lastChar == nullChar {
-		return fmt.Errorf("since the previous character is null, the lexer failed to call the restore")
-	}
-	l.pos = l.lastCharPos
-	l.lastChar = l.prevChar
-	l.lastCharPos = l.prevCharPos
-	l.prevChar = nullChar
-	return l.src.UnreadRune()
+	n := len(l.history)
+	if n == 0 {
+		return fmt.Errorf("the lexer failed to restore a character because no character can be restored")
+	}
+	r := l.history[n-1]
+	l.history = l.history[:n-1]
+	l.pushback = append(l.pushback, r)
+	l.pos = r.pos
+	if n > 1 {
+		prev := l.history[n-2]
+		l.lastChar = prev.c
+		l.lastCharPos = prev.pos
+	} else {
+		l.lastChar = nullChar
+		l.lastCharPos = r.pos
+	}
+	return nil
+}
+
+// peek returns up to n following runes without consuming them. The returned runes are fewer than n when the lexer
+// reaches the end of the source.
+func (l *lexer) peek(n int) ([]rune, error) {
+	if n > maxRestore {
+		return nil, fmt.Errorf("the lexer can peek at most %v characters; requested: %v", maxRestore, n)
+	}
+	var cs []rune
+	read := 0
+	for read < n {
+		c, eof, err := l.read()
+		if err != nil {
+			return nil, err
+		}
+		read++
+		if eof {
+			break
+		}
+		cs = append(cs, c)
+	}
+	for ; read > 0; read-- {
+		err := l.restore()
+		if err != nil {
+			return nil, err
+		}
+	}
+	return cs, nil
 }
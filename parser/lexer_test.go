@@ -1,12 +1,13 @@
 package parser
 
 import (
+	"errors"
 	"strings"
 	"testing"
 )
 
 func TestLexer_Run(t *testing.T) {
-	dummyPos := pos(0, 0)
+	dummyPos := pos(0, 0, 0)
 
 	tests := []struct {
 		caption       string
@@ -18,12 +19,12 @@ func TestLexer_Run(t *testing.T) {
 			caption: "the lexer can recognize all kinds of tokens",
 			src:     `|:;?*+id_1"pattern"!!! `,
 			tokens: []*token{
-				newSymbolToken(dummyPos, tokenKindVBar),
-				newSymbolToken(dummyPos, tokenKindColon),
-				newSymbolToken(dummyPos, tokenKindSemicolon),
-				newSymbolToken(dummyPos, tokenKindOptional),
-				newSymbolToken(dummyPos, tokenKindZeorOrMore),
-				newSymbolToken(dummyPos, tokenKindOneOrMore),
+				newSymbolToken(dummyPos, TokenKindVBar),
+				newSymbolToken(dummyPos, TokenKindColon),
+				newSymbolToken(dummyPos, TokenKindSemicolon),
+				newSymbolToken(dummyPos, TokenKindOptional),
+				newSymbolToken(dummyPos, TokenKindZeroOrMore),
+				newSymbolToken(dummyPos, TokenKindOneOrMore),
 				newIDToken(dummyPos, "id_1"),
 				newPatternToken(dummyPos, "pattern"),
 				newUnknownToken(dummyPos, "!!!"),
@@ -32,9 +33,84 @@ func TestLexer_Run(t *testing.T) {
 		},
 		{
 			caption: "the lexer can recognize escape sequences in pattern",
-			src:     `"\"\\"`,
+			src:     `"\"\\" "\n\t" "\x41\x7e\x0A"`,
 			tokens: []*token{
 				newPatternToken(dummyPos, `"\`),
+				newPatternToken(dummyPos, "\n\t"),
+				newPatternToken(dummyPos, "A~\n"),
+			},
+		},
+		{
+			caption: "the lexer can recognize labels",
+			src:     "a #add| #num;",
+			tokens: []*token{
+				newIDToken(dummyPos, "a"),
+				newLabelToken(dummyPos, "add"),
+				newSymbolToken(dummyPos, TokenKindVBar),
+				newLabelToken(dummyPos, "num"),
+				newSymbolToken(dummyPos, TokenKindSemicolon),
+				newEOFToken(dummyPos),
+			},
+		},
+		{
+			caption: "the lexer can recognize directives",
+			src:     "%start expr;",
+			tokens: []*token{
+				newDirectiveToken(dummyPos, "start"),
+				newIDToken(dummyPos, "expr"),
+				newSymbolToken(dummyPos, TokenKindSemicolon),
+				newEOFToken(dummyPos),
+			},
+		},
+		{
+			caption: "the lexer can recognize numbers",
+			src:     "%left 10 -3 007 1st -x",
+			tokens: []*token{
+				newDirectiveToken(dummyPos, "left"),
+				newNumberToken(dummyPos, "10"),
+				newNumberToken(dummyPos, "-3"),
+				newNumberToken(dummyPos, "007"),
+				newIDToken(dummyPos, "1st"),
+				newUnknownToken(dummyPos, "-"),
+				newIDToken(dummyPos, "x"),
+				newEOFToken(dummyPos),
+			},
+		},
+		{
+			caption: "the lexer can recognize string literals",
+			src:     `%name 'hi' '' 'it\'s "x"\n' "hi"`,
+			tokens: []*token{
+				newDirectiveToken(dummyPos, "name"),
+				newStringToken(dummyPos, "hi"),
+				newStringToken(dummyPos, ""),
+				newStringToken(dummyPos, "it's \"x\"\n"),
+				newPatternToken(dummyPos, "hi"),
+				newEOFToken(dummyPos),
+			},
+		},
+		{
+			caption: "the lexer can recognize parentheses and commas",
+			src:     "%mode(a,b)",
+			tokens: []*token{
+				newDirectiveToken(dummyPos, "mode"),
+				newSymbolToken(dummyPos, TokenKindLParen),
+				newIDToken(dummyPos, "a"),
+				newSymbolToken(dummyPos, TokenKindComma),
+				newIDToken(dummyPos, "b"),
+				newSymbolToken(dummyPos, TokenKindRParen),
+				newEOFToken(dummyPos),
+			},
+		},
+		{
+			caption: "the lexer can recognize actions verbatim",
+			src:     "a {} b { if (x) { y(); }\n} |",
+			tokens: []*token{
+				newIDToken(dummyPos, "a"),
+				newActionToken(dummyPos, ""),
+				newIDToken(dummyPos, "b"),
+				newActionToken(dummyPos, " if (x) { y(); }\n"),
+				newSymbolToken(dummyPos, TokenKindVBar),
+				newEOFToken(dummyPos),
 			},
 		},
 		{
@@ -45,30 +121,62 @@ func TestLexer_Run(t *testing.T) {
 				newCommentToken(dummyPos, " This is eof-terminated comment."),
 			},
 		},
+		{
+			caption: "the lexer can recognize block comments",
+			src:     "/* single line */ /*\n * multiple\n * lines\n **/ a/**/b",
+			tokens: []*token{
+				newBlockCommentToken(dummyPos, " single line "),
+				newBlockCommentToken(dummyPos, "\n * multiple\n * lines\n *"),
+				newIDToken(dummyPos, "a"),
+				newBlockCommentToken(dummyPos, ""),
+				newIDToken(dummyPos, "b"),
+				newEOFToken(dummyPos),
+			},
+		},
+		{
+			caption: "the lexer can recognize patterns delimited by slashes",
+			src:     `a: /[0-9]+/ /"a"\/b\d/ /\//;`,
+			tokens: []*token{
+				newIDToken(dummyPos, "a"),
+				newSymbolToken(dummyPos, TokenKindColon),
+				newPatternToken(dummyPos, "[0-9]+"),
+				newPatternToken(dummyPos, `"a"/b\d`),
+				newPatternToken(dummyPos, "/"),
+				newSymbolToken(dummyPos, TokenKindSemicolon),
+				newEOFToken(dummyPos),
+			},
+		},
+		{
+			caption: "the lexer treats a slash at the end of the source as an unknown token",
+			src:     `a /`,
+			tokens: []*token{
+				newIDToken(dummyPos, "a"),
+				newUnknownToken(dummyPos, "/"),
+				newEOFToken(dummyPos),
+			},
+		},
 		{
 			caption: "the lexer can recognize correct format tokens following unknown tokens",
 			src:     `!|!:!;!?!*!+!id!"pattern"!/foo/`,
 			tokens: []*token{
 				newUnknownToken(dummyPos, "!"),
-				newSymbolToken(dummyPos, tokenKindVBar),
+				newSymbolToken(dummyPos, TokenKindVBar),
 				newUnknownToken(dummyPos, "!"),
-				newSymbolToken(dummyPos, tokenKindColon),
+				newSymbolToken(dummyPos, TokenKindColon),
 				newUnknownToken(dummyPos, "!"),
-				newSymbolToken(dummyPos, tokenKindSemicolon),
+				newSymbolToken(dummyPos, TokenKindSemicolon),
 				newUnknownToken(dummyPos, "!"),
-				newSymbolToken(dummyPos, tokenKindOptional),
+				newSymbolToken(dummyPos, TokenKindOptional),
 				newUnknownToken(dummyPos, "!"),
-				newSymbolToken(dummyPos, tokenKindZeorOrMore),
+				newSymbolToken(dummyPos, TokenKindZeroOrMore),
 				newUnknownToken(dummyPos, "!"),
-				newSymbolToken(dummyPos, tokenKindOneOrMore),
+				newSymbolToken(dummyPos, TokenKindOneOrMore),
 				newUnknownToken(dummyPos, "!"),
 				newIDToken(dummyPos, "id"),
 				newUnknownToken(dummyPos, "!"),
 				newPatternToken(dummyPos, "pattern"),
 				newUnknownToken(dummyPos, "!"),
-				newUnknownToken(dummyPos, "/"),
-				newIDToken(dummyPos, "foo"),
-				newUnknownToken(dummyPos, "/"),
+				newPatternToken(dummyPos, "foo"),
 				newEOFToken(dummyPos),
 			},
 		},
@@ -77,15 +185,52 @@ func TestLexer_Run(t *testing.T) {
 			src:           "a: b;\nc: d;\n",
 			checkPosition: true,
 			tokens: []*token{
-				newIDToken(pos(1, 1), "a"),
-				newSymbolToken(pos(1, 2), tokenKindColon),
-				newIDToken(pos(1, 4), "b"),
-				newSymbolToken(pos(1, 5), tokenKindSemicolon),
-				newIDToken(pos(2, 1), "c"),
-				newSymbolToken(pos(2, 2), tokenKindColon),
-				newIDToken(pos(2, 4), "d"),
-				newSymbolToken(pos(2, 5), tokenKindSemicolon),
-				newEOFToken(pos(3, 1)),
+				newIDToken(pos(1, 1, 0), "a"),
+				newSymbolToken(pos(1, 2, 1), TokenKindColon),
+				newIDToken(pos(1, 4, 3), "b"),
+				newSymbolToken(pos(1, 5, 4), TokenKindSemicolon),
+				newIDToken(pos(2, 1, 6), "c"),
+				newSymbolToken(pos(2, 2, 7), TokenKindColon),
+				newIDToken(pos(2, 4, 9), "d"),
+				newSymbolToken(pos(2, 5, 10), TokenKindSemicolon),
+				newEOFToken(pos(3, 1, 12)),
+			},
+		},
+		{
+			caption:       "the lexer counts CRLF as a single line break and a bare CR as a line break",
+			src:           "a: b;\r\n// c\r\nd: e;\r\n\r\nf\rg;\r\n",
+			checkPosition: true,
+			tokens: []*token{
+				newIDToken(pos(1, 1, 0), "a"),
+				newSymbolToken(pos(1, 2, 1), TokenKindColon),
+				newIDToken(pos(1, 4, 3), "b"),
+				newSymbolToken(pos(1, 5, 4), TokenKindSemicolon),
+				newCommentToken(pos(2, 1, 7), " c"),
+				newIDToken(pos(3, 1, 13), "d"),
+				newSymbolToken(pos(3, 2, 14), TokenKindColon),
+				newIDToken(pos(3, 4, 16), "e"),
+				newSymbolToken(pos(3, 5, 17), TokenKindSemicolon),
+				newIDToken(pos(5, 1, 22), "f"),
+				newIDToken(pos(6, 1, 24), "g"),
+				newSymbolToken(pos(6, 2, 25), TokenKindSemicolon),
+				newEOFToken(pos(7, 1, 28)),
+			},
+		},
+		{
+			caption:       "the lexer can recognize Unicode identifiers",
+			src:           "café: 日本語_1 ñ;\nΣ: x２;",
+			checkPosition: true,
+			tokens: []*token{
+				newIDToken(pos(1, 1, 0), "café"),
+				newSymbolToken(pos(1, 5, 5), TokenKindColon),
+				newIDToken(pos(1, 7, 7), "日本語_1"),
+				newIDToken(pos(1, 13, 19), "ñ"),
+				newSymbolToken(pos(1, 14, 21), TokenKindSemicolon),
+				newIDToken(pos(2, 1, 23), "Σ"),
+				newSymbolToken(pos(2, 2, 25), TokenKindColon),
+				newIDToken(pos(2, 4, 27), "x２"),
+				newSymbolToken(pos(2, 6, 31), TokenKindSemicolon),
+				newEOFToken(pos(2, 7, 32)),
 			},
 		},
 	}
@@ -106,16 +251,17 @@ func TestLexer_Run(t *testing.T) {
 	}
 }
 
-func pos(line, column int) Position {
+func pos(line, column, offset int) Position {
 	return Position{
 		Line:   line,
 		Column: column,
+		Offset: offset,
 	}
 }
 
 func matchToken(expected, actual *token, checkPosition bool) bool {
 	if checkPosition {
-		if actual.pos.Line != expected.pos.Line || actual.pos.Column != expected.pos.Column {
+		if actual.pos != expected.pos {
 			return false
 		}
 	}
@@ -125,3 +271,354 @@ func matchToken(expected, actual *token, checkPosition bool) bool {
 
 	return true
 }
+
+func TestLexer_Next(t *testing.T) {
+	l := NewLexer(strings.NewReader("// c\na: \"x\" #l;\n%start a;"))
+	expected := []Token{
+		{Kind: TokenKindComment, Text: " c", Pos: pos(1, 1, 0)},
+		{Kind: TokenKindID, Text: "a", Pos: pos(2, 1, 5)},
+		{Kind: TokenKindColon, Pos: pos(2, 2, 6)},
+		{Kind: TokenKindPattern, Text: "x", Pos: pos(2, 4, 8)},
+		{Kind: TokenKindLabel, Text: "l", Pos: pos(2, 8, 12)},
+		{Kind: TokenKindSemicolon, Pos: pos(2, 10, 14)},
+		{Kind: TokenKindDirective, Text: "start", Pos: pos(3, 1, 16)},
+		{Kind: TokenKindID, Text: "a", Pos: pos(3, 8, 23)},
+		{Kind: TokenKindSemicolon, Pos: pos(3, 9, 24)},
+		{Kind: TokenKindEOF, Pos: pos(3, 10, 25)},
+		{Kind: TokenKindEOF, Pos: pos(3, 10, 25)},
+	}
+	for _, eTok := range expected {
+		tok, err := l.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tok != eTok {
+			t.Fatalf("unexpected token; want: %+v, got: %+v", eTok, tok)
+		}
+	}
+
+	t.Run("a malformed token is a syntax error", func(t *testing.T) {
+		l := NewLexer(strings.NewReader(`a: "b`))
+		var err error
+		for i := 0; i < 3 && err == nil; i++ {
+			_, err = l.Next()
+		}
+		var synErr *SyntaxError
+		if !errors.As(err, &synErr) {
+			t.Fatalf("unexpected error; want: %T, got: %v", synErr, err)
+		}
+	})
+}
+
+func TestLexer_BlockCommentError(t *testing.T) {
+	tests := []struct {
+		caption string
+		src     string
+		message string
+		pos     Position
+	}{
+		{
+			caption: "an unclosed block comment",
+			src:     "a: b; /* foo",
+			message: "unclosed block comment",
+			pos:     pos(1, 7, 6),
+		},
+		{
+			caption: "an unclosed block comment ending with an asterisk",
+			src:     "/* foo *",
+			message: "unclosed block comment",
+			pos:     pos(1, 1, 0),
+		},
+		{
+			caption: "a nested block comment",
+			src:     "/* foo\n  /* bar */ */",
+			message: "nested block comments are not supported",
+			pos:     pos(2, 3, 9),
+		},
+		{
+			caption: "an unclosed action",
+			src:     "a: b { c { d }",
+			message: "unclosed action",
+			pos:     pos(1, 6, 5),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.caption, func(t *testing.T) {
+			l := newLexer(strings.NewReader(tt.src))
+			var err error
+			for {
+				var tok *token
+				tok, err = l.next()
+				if err != nil || tok.kind == TokenKindEOF {
+					break
+				}
+			}
+			var synErr *SyntaxError
+			if !errors.As(err, &synErr) {
+				t.Fatalf("unexpected error; want: %T, got: %v", synErr, err)
+			}
+			if synErr.Message() != tt.message {
+				t.Fatalf("unexpected message; want: %v, got: %v", tt.message, synErr.Message())
+			}
+			if synErr.Pos() != tt.pos {
+				t.Fatalf("unexpected position; want: %+v, got: %+v (%v)", tt.pos, synErr.Pos(), synErr)
+			}
+		})
+	}
+}
+
+func TestLexer_ReservedIdentifier(t *testing.T) {
+	t.Run("an identifier beginning with $ is rejected", func(t *testing.T) {
+		l := newLexer(strings.NewReader("a: $foo;"))
+		var err error
+		for {
+			var tok *token
+			tok, err = l.next()
+			if err != nil || tok.kind == TokenKindEOF {
+				break
+			}
+		}
+		var synErr *SyntaxError
+		if !errors.As(err, &synErr) {
+			t.Fatalf("unexpected error; want: %T, got: %v", synErr, err)
+		}
+		msg := "the $ prefix is reserved for generated symbols; identifier: $foo"
+		if synErr.Message() != msg {
+			t.Fatalf("unexpected message; want: %v, got: %v", msg, synErr.Message())
+		}
+		if synErr.Pos() != pos(1, 4, 3) {
+			t.Fatalf("unexpected position; want: %+v, got: %+v", pos(1, 4, 3), synErr.Pos())
+		}
+	})
+
+	t.Run("an identifier ending with ' is rejected", func(t *testing.T) {
+		l := newLexer(strings.NewReader("s: e' A;"))
+		var err error
+		for {
+			var tok *token
+			tok, err = l.next()
+			if err != nil || tok.kind == TokenKindEOF {
+				break
+			}
+		}
+		var synErr *SyntaxError
+		if !errors.As(err, &synErr) {
+			t.Fatalf("unexpected error; want: %T, got: %v", synErr, err)
+		}
+		msg := "the ' suffix is reserved for the augmented start symbol; identifier: e'"
+		if synErr.Message() != msg {
+			t.Fatalf("unexpected message; want: %v, got: %v", msg, synErr.Message())
+		}
+		if synErr.Pos() != pos(1, 4, 3) {
+			t.Fatalf("unexpected position; want: %+v, got: %+v", pos(1, 4, 3), synErr.Pos())
+		}
+	})
+
+	t.Run("a lone $ is an unknown token", func(t *testing.T) {
+		l := newLexer(strings.NewReader("$ a"))
+		tok, err := l.next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tok.kind != TokenKindUnknown || tok.text != "$" {
+			t.Fatalf("unexpected token; want: an unknown token $, got: %+v", tok)
+		}
+	})
+}
+
+func TestLexer_TabWidth(t *testing.T) {
+	tests := []struct {
+		caption  string
+		tabWidth int
+		tokens   []*token
+	}{
+		{
+			caption:  "a tab advances the column by one by default",
+			tabWidth: 1,
+			tokens: []*token{
+				newIDToken(pos(1, 2, 1), "a"),
+				newSymbolToken(pos(1, 3, 2), TokenKindColon),
+				newIDToken(pos(1, 4, 3), "bc"),
+				newIDToken(pos(1, 7, 6), "d"),
+				newIDToken(pos(2, 2, 9), "e"),
+			},
+		},
+		{
+			caption:  "a tab advances the column to the next tab stop",
+			tabWidth: 8,
+			tokens: []*token{
+				newIDToken(pos(1, 9, 1), "a"),
+				newSymbolToken(pos(1, 10, 2), TokenKindColon),
+				newIDToken(pos(1, 11, 3), "bc"),
+				newIDToken(pos(1, 17, 6), "d"),
+				newIDToken(pos(2, 9, 9), "e"),
+			},
+		},
+		{
+			caption:  "a tab advances the column to the next multiple of the width",
+			tabWidth: 4,
+			tokens: []*token{
+				newIDToken(pos(1, 5, 1), "a"),
+				newSymbolToken(pos(1, 6, 2), TokenKindColon),
+				newIDToken(pos(1, 7, 3), "bc"),
+				newIDToken(pos(1, 13, 6), "d"),
+				newIDToken(pos(2, 5, 9), "e"),
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.caption, func(t *testing.T) {
+			l := newLexer(strings.NewReader("\ta:bc\td\n\te"))
+			l.tabWidth = tt.tabWidth
+			for _, eTok := range tt.tokens {
+				aTok, err := l.next()
+				if err != nil {
+					t.Fatal(err)
+				}
+				if aTok.text != eTok.text || aTok.pos != eTok.pos {
+					t.Fatalf("unexpected token; want: %v %+v, got: %v %+v", eTok.text, eTok.pos, aTok.text, aTok.pos)
+				}
+			}
+		})
+	}
+}
+
+func TestLexer_PatternError(t *testing.T) {
+	tests := []struct {
+		caption string
+		src     string
+		pos     Position
+	}{
+		{
+			caption: "an unclosed pattern string",
+			src:     `a: "foo`,
+			pos:     pos(1, 4, 3),
+		},
+		{
+			caption: "an unclosed pattern string ending with a backslash",
+			src:     `a: "foo\`,
+			pos:     pos(1, 4, 3),
+		},
+		{
+			caption: "an unclosed pattern delimited by slashes",
+			src:     `a: /foo`,
+			pos:     pos(1, 4, 3),
+		},
+		{
+			caption: "a pattern delimited by slashes ending with a backslash",
+			src:     `a: /foo\`,
+			pos:     pos(1, 4, 3),
+		},
+		{
+			caption: "a pattern delimited by slashes containing a new line",
+			src:     "a: /foo\nbar/",
+			pos:     pos(1, 4, 3),
+		},
+		{
+			caption: "an empty pattern string",
+			src:     `a: ""`,
+			pos:     pos(1, 4, 3),
+		},
+		{
+			caption: "an unsupported escape sequence",
+			src:     "a:\n  \"foo\\qbar\"",
+			pos:     pos(2, 7, 9),
+		},
+		{
+			caption: "an invalid hexadecimal escape sequence",
+			src:     `"\x4g"`,
+			pos:     pos(1, 2, 1),
+		},
+		{
+			caption: "a truncated hexadecimal escape sequence",
+			src:     `"\x4`,
+			pos:     pos(1, 2, 1),
+		},
+		{
+			caption: "an unclosed string literal",
+			src:     `%name 'foo\'`,
+			pos:     pos(1, 7, 6),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.caption, func(t *testing.T) {
+			l := newLexer(strings.NewReader(tt.src))
+			var err error
+			for {
+				var tok *token
+				tok, err = l.next()
+				if err != nil || tok.kind == TokenKindEOF {
+					break
+				}
+			}
+			var synErr *SyntaxError
+			if !errors.As(err, &synErr) {
+				t.Fatalf("unexpected error; want: %T, got: %v", synErr, err)
+			}
+			if synErr.Pos() != tt.pos {
+				t.Fatalf("unexpected position; want: %+v, got: %+v (%v)", tt.pos, synErr.Pos(), synErr)
+			}
+		})
+	}
+}
+
+func TestLexer_Restore(t *testing.T) {
+	l := newLexer(strings.NewReader("ab\nc"))
+	want := []struct {
+		c   rune
+		eof bool
+		pos Position
+	}{
+		{c: 'a', pos: pos(1, 1, 0)},
+		{c: 'b', pos: pos(1, 2, 1)},
+		{c: '\n', pos: pos(1, 3, 2)},
+		{c: 'c', pos: pos(2, 1, 3)},
+		{c: nullChar, eof: true, pos: pos(2, 2, 4)},
+	}
+	readAll := func() {
+		t.Helper()
+		for _, w := range want {
+			c, eof, err := l.read()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if c != w.c || eof != w.eof || l.lastCharPos != w.pos {
+				t.Fatalf("unexpected character; want: %q (eof: %v, pos: %+v), got: %q (eof: %v, pos: %+v)", w.c, w.eof, w.pos, c, eof, l.lastCharPos)
+			}
+		}
+	}
+
+	readAll()
+	for i := len(want) - 1; i >= 0; i-- {
+		err := l.restore()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if l.pos != want[i].pos {
+			t.Fatalf("unexpected position after restoring; want: %+v, got: %+v", want[i].pos, l.pos)
+		}
+	}
+	if l.lastChar != nullChar {
+		t.Fatalf("the last character must be null after restoring all characters; got: %q", l.lastChar)
+	}
+	if err := l.restore(); err == nil {
+		t.Fatal("restoring more characters than read must fail")
+	}
+	readAll()
+
+	l = newLexer(strings.NewReader("abc"))
+	cs, err := l.peek(5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(cs) != "abc" {
+		t.Fatalf("unexpected characters peeked; want: %q, got: %q", "abc", string(cs))
+	}
+	c, _, err := l.read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c != 'a' || l.lastCharPos != pos(1, 1, 0) {
+		t.Fatalf("peek must not consume characters; got: %q at %+v", c, l.lastCharPos)
+	}
+}
@@ -1,8 +1,11 @@
 package parser
 
 import (
+	"bytes"
 	"fmt"
 	"io"
+	"strconv"
+	"strings"
 )
 
 type ASTType string
@@ -16,12 +19,40 @@ const (
 	ASTTypeOptional    = ASTType("optional")
 	ASTTypeZeroOrMore  = ASTType("zero or more")
 	ASTTypeOneOrMore   = ASTType("one or more ")
+	ASTTypeLabel       = ASTType("label")
+	ASTTypeDirective   = ASTType("directive")
+	ASTTypeEmpty       = ASTType("empty")
+
+	// ASTTypeCaseInsensitive is the `%i` flag following the pattern of a lexeme production.
+	ASTTypeCaseInsensitive = ASTType("case insensitive")
+
+	// ASTTypeMode is `%mode(NAME, ...)` following the pattern of a lexeme production. Its children are the names of the
+	// modes as symbols.
+	ASTTypeMode = ASTType("mode")
+
+	// ASTTypeAction is the code of an action like `{ $$ = $1 + $3 }`. The code is opaque to 9gram.
+	ASTTypeAction = ASTType("action")
+
+	// ASTTypePrec is `%prec NAME` giving an alternative the precedence of NAME. Its child is a symbol or a pattern.
+	ASTTypePrec = ASTType("prec")
+
+	// ASTTypeNumber is a number argument of a directive like `10` and `-3`. Digits without a sign can name a symbol as
+	// well, so GenGrammar decides what a number means for each directive.
+	ASTTypeNumber = ASTType("number")
+
+	// ASTTypeString is a string literal argument of a directive like `'hi'`. Its text is the decoded string.
+	ASTTypeString = ASTType("string")
 )
 
 type AST struct {
 	Ty       ASTType
 	Children []*AST
 
+	// Comments are the comments preceding a production or a directive in the source form, such as `// text` and
+	// `/* text */`.
+	// The parser sets them only in the KeepComments mode.
+	Comments []string
+
 	token *token
 	prev  *AST
 }
@@ -30,12 +61,143 @@ func (ast *AST) GetText() (string, bool) {
 	if ast.token == nil {
 		return "", false
 	}
-	if ast.token.kind == tokenKindID || ast.token.kind == tokenKindPattern {
+	switch ast.token.kind {
+	case TokenKindID, TokenKindNumber, TokenKindString, TokenKindPattern, TokenKindLabel, TokenKindDirective, TokenKindAction:
 		return ast.token.text, true
 	}
 	return "", false
 }
 
+// String returns the canonical grammar source that the AST represents.
+// Parsing the returned source yields an equivalent AST.
+func (ast *AST) String() string {
+	var b strings.Builder
+	ast.writeSource(&b)
+	return b.String()
+}
+
+func (ast *AST) writeSource(b *strings.Builder) {
+	switch ast.Ty {
+	case ASTTypeStart:
+		for _, child := range ast.Children {
+			child.writeSource(b)
+			fmt.Fprintf(b, "\n")
+		}
+	case ASTTypeProduction:
+		for _, c := range ast.Comments {
+			fmt.Fprintf(b, "%v\n", c)
+		}
+		ast.Children[0].writeSource(b)
+		fmt.Fprintf(b, ":")
+		for i, alt := range ast.Children[1:] {
+			if i > 0 {
+				fmt.Fprintf(b, " |")
+			}
+			if len(alt.Children) > 0 {
+				fmt.Fprintf(b, " ")
+			}
+			alt.writeSource(b)
+		}
+		fmt.Fprintf(b, ";")
+	case ASTTypeDirective:
+		for _, c := range ast.Comments {
+			fmt.Fprintf(b, "%v\n", c)
+		}
+		name, _ := ast.GetText()
+		fmt.Fprintf(b, "%%%v", name)
+		for _, arg := range ast.Children {
+			fmt.Fprintf(b, " ")
+			arg.writeSource(b)
+		}
+		fmt.Fprintf(b, ";")
+	case ASTTypeAlternative:
+		for i, elem := range ast.Children {
+			if i > 0 && (elem.Ty == ASTTypeSymbol || elem.Ty == ASTTypePattern || elem.Ty == ASTTypeLabel || elem.Ty == ASTTypeEmpty || elem.Ty == ASTTypeCaseInsensitive || elem.Ty == ASTTypeMode || elem.Ty == ASTTypeAction || elem.Ty == ASTTypePrec) {
+				fmt.Fprintf(b, " ")
+			}
+			elem.writeSource(b)
+		}
+	case ASTTypeSymbol:
+		text, _ := ast.GetText()
+		fmt.Fprintf(b, "%v", text)
+	case ASTTypePattern:
+		text, _ := ast.GetText()
+		fmt.Fprintf(b, `"%v"`, escapePattern(text))
+	case ASTTypeNumber:
+		text, _ := ast.GetText()
+		fmt.Fprintf(b, "%v", text)
+	case ASTTypeString:
+		text, _ := ast.GetText()
+		fmt.Fprintf(b, "'%v'", escapeString(text))
+	case ASTTypeLabel:
+		text, _ := ast.GetText()
+		fmt.Fprintf(b, "#%v", text)
+	case ASTTypeEmpty:
+		fmt.Fprintf(b, "%%%v", emptyDirective)
+	case ASTTypeCaseInsensitive:
+		fmt.Fprintf(b, "%%%v", caseInsensitiveDirective)
+	case ASTTypeMode:
+		fmt.Fprintf(b, "%%%v(", modeDirective)
+		for i, mode := range ast.Children {
+			if i > 0 {
+				fmt.Fprintf(b, ", ")
+			}
+			mode.writeSource(b)
+		}
+		fmt.Fprintf(b, ")")
+	case ASTTypeAction:
+		code, _ := ast.GetText()
+		fmt.Fprintf(b, "{%v}", code)
+	case ASTTypePrec:
+		fmt.Fprintf(b, "%%%v ", precDirective)
+		ast.Children[0].writeSource(b)
+	case ASTTypeOptional:
+		fmt.Fprintf(b, "?")
+	case ASTTypeZeroOrMore:
+		fmt.Fprintf(b, "*")
+	case ASTTypeOneOrMore:
+		fmt.Fprintf(b, "+")
+	}
+}
+
+// Walk traverses an AST in pre-order. When fn returns false, Walk doesn't descend into the children of the node.
+func Walk(ast *AST, fn func(*AST) bool) {
+	if ast == nil {
+		return
+	}
+	if !fn(ast) {
+		return
+	}
+	for _, child := range ast.Children {
+		Walk(child, fn)
+	}
+}
+
+// Find returns all nodes of the type ty in pre-order.
+func Find(ast *AST, ty ASTType) []*AST {
+	var nodes []*AST
+	Walk(ast, func(node *AST) bool {
+		if node.Ty == ty {
+			nodes = append(nodes, node)
+		}
+		return true
+	})
+	return nodes
+}
+
+// Pos returns the position where the node starts. When the node is an empty node, such as an empty alternative, Pos returns false.
+func (ast *AST) Pos() (Position, bool) {
+	if ast.token != nil {
+		return ast.token.pos, true
+	}
+	for _, child := range ast.Children {
+		if pos, ok := child.Pos(); ok {
+			return pos, true
+		}
+	}
+	return Position{}, false
+}
+
 func (ast *AST) appendChild(child *AST) {
 	if ast.Children == nil {
 		ast.Children = []*AST{}
@@ -59,26 +221,115 @@ func (e *SyntaxError) Error() string {
 	return fmt.Sprintf("syntax error: %s (%v, %v)", e.message, e.pos.Line, e.pos.Column)
 }
 
+// Is reports whether target is a *SyntaxError so that errors.Is can distinguish syntax errors from other errors.
+func (e *SyntaxError) Is(target error) bool {
+	_, ok := target.(*SyntaxError)
+	return ok
+}
+
+func (e *SyntaxError) Pos() Position {
+	return e.pos
+}
+
+func (e *SyntaxError) Message() string {
+	return e.message
+}
+
+// FormatError renders a syntax error with the offending line of src and a caret under the column where the error occurred.
+func FormatError(src []byte, err *SyntaxError) string {
+	offset := err.pos.Offset
+	if offset > len(src) {
+		offset = len(src)
+	}
+	lineStart := bytes.LastIndexAny(src[:offset], "\n\r") + 1
+	lineEnd := len(src)
+	if i := bytes.IndexAny(src[lineStart:], "\n\r"); i >= 0 {
+		lineEnd = lineStart + i
+	}
+	if offset > lineEnd {
+		offset = lineEnd
+	}
+
+	// Keep tabs in the indentation of the caret so that the caret lines up with the offending line.
+	var indent strings.Builder
+	for _, c := range string(src[lineStart:offset]) {
+		if c == '\t' {
+			fmt.Fprint(&indent, "\t")
+		} else {
+			fmt.Fprint(&indent, " ")
+		}
+	}
+
+	lineNum := strconv.Itoa(err.pos.Line)
+	var b strings.Builder
+	fmt.Fprintf(&b, "%v\n", err.Error())
+	fmt.Fprintf(&b, "%v | %s\n", lineNum, src[lineStart:lineEnd])
+	fmt.Fprintf(&b, "%v | %v^", strings.Repeat(" ", len(lineNum)), indent.String())
+	return b.String()
+}
+
 type Parser interface {
 	Parse() (*AST, error)
 }
 
+type ParserOption func(*parser)
+
+// KeepComments makes the parser attach the comments preceding each production to the production node.
+func KeepComments() ParserOption {
+	return func(p *parser) {
+		p.keepComments = true
+	}
+}
+
+// DefaultTabWidth is the interval of tab stops that most editors use.
+const DefaultTabWidth = 8
+
+// WithTabWidth makes a tab advance the column of positions to the next tab stop, which is placed every width
+// columns, so that the columns match what editors show. Without this option, a tab advances the column by one like
+// other characters. A width less than 1 is treated as 1.
+func WithTabWidth(width int) ParserOption {
+	return func(p *parser) {
+		if width < 1 {
+			width = 1
+		}
+		p.lex.tabWidth = width
+	}
+}
+
+// maxLookahead is the number of tokens that the parser can look ahead.
+const maxLookahead = 2
+
+// lookaheadToken is a token that the parser has read ahead, with the comments preceding it.
+type lookaheadToken struct {
+	tok      *token
+	comments []string
+}
+
 type parser struct {
-	lex         *lexer
-	peekedTok   *token
-	lastTok     *token
-	root        *AST
-	currentNode *AST
+	lex *lexer
+	// lookahead is a ring buffer of the tokens read ahead. The next token is lookahead[lookaheadHead].
+	lookahead     [maxLookahead]lookaheadToken
+	lookaheadHead int
+	lookaheadLen  int
+	lastTok       *token
+	root          *AST
+	currentNode   *AST
+
+	keepComments    bool
+	pendingComments []string
 }
 
-func NewParser(src io.Reader) (Parser, error) {
-	return &parser{
+func NewParser(src io.Reader, opts ...ParserOption) (Parser, error) {
+	p := &parser{
 		lex:         newLexer(src),
-		peekedTok:   nil,
 		lastTok:     nil,
 		root:        nil,
 		currentNode: nil,
-	}, nil
+	}
+	for _, opt := range opts {
+		opt(p)
+	}
+	return p, nil
 }
 
 func (p *parser) Parse() (ast *AST, retErr error) {
@@ -99,58 +350,211 @@ func (p *parser) parseStart() {
 	p.enter(ASTTypeStart)
 	defer p.leave()
 
-	p.parseProduction()
+	// The parser accepts a source without definitions. GenGrammar reports it as an error.
 	for {
-		if p.consume(tokenKindEOF) {
+		if p.consume(TokenKindEOF) {
 			break
 		}
-		p.parseProduction()
+		p.parseDefinition()
 	}
 }
 
+func (p *parser) parseDefinition() {
+	if p.consume(TokenKindDirective) {
+		p.parseDirective()
+		return
+	}
+	p.parseProduction()
+}
+
+// parseDirective parses the arguments of a directive like `%start expr;`. The directive name must have been
+// consumed.
+func (p *parser) parseDirective() {
+	p.enter(ASTTypeDirective)
+	defer p.leave()
+
+	p.currentNode.token = p.lastTok
+	p.lastTok = nil
+	p.currentNode.Comments = p.pendingComments
+	p.pendingComments = nil
+	for {
+		switch {
+		case p.consume(TokenKindID):
+			p.as(ASTTypeSymbol)
+			continue
+		case p.consume(TokenKindPattern):
+			p.as(ASTTypePattern)
+			continue
+		case p.consume(TokenKindNumber):
+			p.as(ASTTypeNumber)
+			continue
+		case p.consume(TokenKindString):
+			p.as(ASTTypeString)
+			continue
+		}
+		break
+	}
+	p.expect(TokenKindSemicolon)
+	p.pendingComments = nil
+}
+
 func (p *parser) parseProduction() {
 	p.enter(ASTTypeProduction)
 	defer p.leave()
 
-	p.expect(tokenKindID)
+	p.expectSymbol()
 	p.as(ASTTypeSymbol)
-	p.expect(tokenKindColon)
+	// The comments read until the LHS precede the production.
+	p.currentNode.Comments = p.pendingComments
+	p.pendingComments = nil
+	p.expect(TokenKindColon)
 	p.parseAlternative()
 	for {
-		if !p.consume(tokenKindVBar) {
+		if !p.consume(TokenKindVBar) {
 			break
 		}
 		p.parseAlternative()
 	}
-	p.expect(tokenKindSemicolon)
+	p.expect(TokenKindSemicolon)
+	// Discard the comments inside the production.
+	p.pendingComments = nil
 }
 
 func (p *parser) parseAlternative() {
 	p.enter(ASTTypeAlternative)
 	defer p.leave()
 
+	// `%empty` explicitly denotes an empty alternative, so it must be the only element except a label.
+	if p.peekEmpty() {
+		p.consume(TokenKindDirective)
+		p.as(ASTTypeEmpty)
+		if tok := p.peek(1); isSymbolToken(tok) || tok.kind == TokenKindPattern || p.peekEmpty() {
+			raiseSyntaxError(tok.pos, "%empty cannot be mixed with other symbols")
+		}
+		if p.consume(TokenKindLabel) {
+			p.as(ASTTypeLabel)
+		}
+		p.parseAction()
+		return
+	}
+
 	for {
+		// An ID followed by a colon is the LHS of the next production, which means a semicolon is missing.
+		if isSymbolToken(p.peek(1)) && p.peek(2).kind == TokenKindColon {
+			break
+		}
 		switch {
-		case p.consume(tokenKindID):
+		case p.consumeSymbol():
 			p.as(ASTTypeSymbol)
 			p.parseQualifier()
 			continue
-		case p.consume(tokenKindPattern):
+		case p.consume(TokenKindPattern):
 			p.as(ASTTypePattern)
 			p.parseQualifier()
 			continue
 		}
 		break
 	}
+
+	if p.peekEmpty() {
+		raiseSyntaxError(p.peek(1).pos, "%empty cannot be mixed with other symbols")
+	}
+
+	// `%i` marks the pattern as case-insensitive. GenGrammar accepts it only in lexeme productions.
+	if p.peekDirective(caseInsensitiveDirective) {
+		p.consume(TokenKindDirective)
+		p.as(ASTTypeCaseInsensitive)
+	}
+
+	// `%mode(NAME, ...)` tells the modes of the lexer that the pattern is active in. GenGrammar accepts it only in
+	// lexeme productions.
+	if p.peekDirective(modeDirective) {
+		p.parseMode()
+	}
+
+	if p.peekDirective(precDirective) {
+		p.parsePrec()
+	}
+
+	// A label and an action are trailing metadata of an alternative.
+	if p.consume(TokenKindLabel) {
+		p.as(ASTTypeLabel)
+	}
+	p.parseAction()
+}
+
+// parsePrec parses `%prec NAME`, where NAME is a symbol or a pattern.
+func (p *parser) parsePrec() {
+	p.enter(ASTTypePrec)
+	defer p.leave()
+
+	p.consume(TokenKindDirective)
+	p.currentNode.token = p.lastTok
+	p.lastTok = nil
+	switch {
+	case p.consumeSymbol():
+		p.as(ASTTypeSymbol)
+	case p.consume(TokenKindPattern):
+		p.as(ASTTypePattern)
+	default:
+		raiseSyntaxError(p.currentNode.token.pos, "%prec takes a symbol or a pattern")
+	}
+}
+
+// parseMode parses `%mode(NAME, ...)`, which takes one or more identifiers.
+func (p *parser) parseMode() {
+	p.enter(ASTTypeMode)
+	defer p.leave()
+
+	p.consume(TokenKindDirective)
+	p.currentNode.token = p.lastTok
+	p.lastTok = nil
+	p.expect(TokenKindLParen)
+	for {
+		p.expectSymbol()
+		p.as(ASTTypeSymbol)
+		if !p.consume(TokenKindComma) {
+			break
+		}
+	}
+	p.expect(TokenKindRParen)
+}
+
+// parseAction parses the action that ends an alternative, if any.
+func (p *parser) parseAction() {
+	if p.consume(TokenKindAction) {
+		p.as(ASTTypeAction)
+	}
+}
+
+// emptyDirective is the name of the directive denoting an empty alternative.
+const emptyDirective = "empty"
+
+// caseInsensitiveDirective is the name of the flag marking a lexeme as case-insensitive.
+const caseInsensitiveDirective = "i"
+
+// modeDirective is the name of the annotation telling the modes that a lexeme is active in.
+const modeDirective = "mode"
+
+// precDirective is the name of the directive giving an alternative the precedence of a symbol.
+const precDirective = "prec"
+
+func (p *parser) peekEmpty() bool {
+	return p.peekDirective(emptyDirective)
+}
+
+func (p *parser) peekDirective(name string) bool {
+	tok := p.peek(1)
+	return tok.kind == TokenKindDirective && tok.text == name
 }
 
 func (p *parser) parseQualifier() {
 	switch {
-	case p.consume(tokenKindOptional):
+	case p.consume(TokenKindOptional):
 		p.as(ASTTypeOptional)
-	case p.consume(tokenKindZeorOrMore):
+	case p.consume(TokenKindZeroOrMore):
 		p.as(ASTTypeZeroOrMore)
-	case p.consume(tokenKindOneOrMore):
+	case p.consume(TokenKindOneOrMore):
 		p.as(ASTTypeOneOrMore)
 	}
 }
@@ -178,44 +582,91 @@ func (p *parser) leave() {
 	ast.prev = nil
 }
 
-func (p *parser) expect(expected tokenKind) {
+func (p *parser) expect(expected TokenKind) {
 	if !p.consume(expected) {
-		tok := p.peekedTok
+		tok := p.peek(1)
 		errMsg := fmt.Sprintf("unexpected token; expected: %v, actual: %v", expected, tok.kind)
 		raiseSyntaxError(tok.pos, errMsg)
 	}
 }
 
-func (p *parser) consume(expected tokenKind) bool {
-	var tok *token
-	var err error
-	if p.peekedTok != nil {
-		tok = p.peekedTok
-		p.peekedTok = nil
-	} else {
+// expectSymbol is the same as expect except that it takes a token that consumeSymbol takes.
+func (p *parser) expectSymbol() {
+	if !p.consumeSymbol() {
+		tok := p.peek(1)
+		errMsg := fmt.Sprintf("unexpected token; expected: %v, actual: %v", TokenKindID, tok.kind)
+		raiseSyntaxError(tok.pos, errMsg)
+	}
+}
+
+// consumeSymbol consumes the next token when it can be a symbol. The lexer reads digits like `2` as a number, but a
+// number without a sign is a symbol as well as an identifier where only a symbol can appear. The arguments of
+// directives keep numbers as they are.
+func (p *parser) consumeSymbol() bool {
+	if tok := p.peek(1); tok.kind == TokenKindNumber && isSymbolToken(tok) {
+		return p.consume(TokenKindNumber)
+	}
+	return p.consume(TokenKindID)
+}
+
+func isSymbolToken(tok *token) bool {
+	switch tok.kind {
+	case TokenKindID:
+		return true
+	case TokenKindNumber:
+		return isNumber(tok.text)
+	}
+	return false
+}
+
+// consume consumes the next token when it is of the expected kind. Because peek skips comments, comments are allowed
+// anywhere whitespace is.
+func (p *parser) consume(expected TokenKind) bool {
+	tok := p.peek(1)
+	if tok.kind == TokenKindUnknown {
+		errMsg := fmt.Sprintf("unknown token: \"%s\"", tok.text)
+		raiseSyntaxError(tok.pos, errMsg)
+	}
+	if tok.kind != expected {
+		return false
+	}
+
+	la := p.lookahead[p.lookaheadHead]
+	p.lookahead[p.lookaheadHead] = lookaheadToken{}
+	p.lookaheadHead = (p.lookaheadHead + 1) % maxLookahead
+	p.lookaheadLen--
+	p.pendingComments = append(p.pendingComments, la.comments...)
+	p.lastTok = tok
+
+	return true
+}
+
+// peek returns the n-th token ahead without consuming it. peek(1) returns the token that the next consume checks.
+// Comments are skipped.
+func (p *parser) peek(n int) *token {
+	if n < 1 || n > maxLookahead {
+		panic(fmt.Errorf("the parser can look ahead 1 to %v tokens; requested: %v", maxLookahead, n))
+	}
+	for p.lookaheadLen < n {
+		var la lookaheadToken
 		for {
-			tok, err = p.lex.next()
+			tok, err := p.lex.next()
 			if err != nil {
 				panic(err)
 			}
-			if tok.kind == tokenKindComment {
+			if tok.kind == TokenKindComment || tok.kind == TokenKindBlockComment {
+				if p.keepComments {
+					la.comments = append(la.comments, tok.commentSource())
+				}
 				continue
 			}
+			la.tok = tok
 			break
 		}
+		p.lookahead[(p.lookaheadHead+p.lookaheadLen)%maxLookahead] = la
+		p.lookaheadLen++
 	}
-	p.lastTok = tok
-	if tok.kind == tokenKindUnknown {
-		errMsg := fmt.Sprintf("unknown token: \"%s\"", tok.text)
-		raiseSyntaxError(tok.pos, errMsg)
-	}
-	if tok.kind == expected {
-		return true
-	}
-	p.peekedTok = tok
-	p.lastTok = nil
-
-	return false
+	return p.lookahead[(p.lookaheadHead+n-1)%maxLookahead].tok
 }
 
 func (p *parser) as(ty ASTType) {
@@ -25,10 +25,125 @@ g: "bar"*;
 h: "baz"+;
 `,
 		},
+		{
+			caption: "when a source contains no definitions, the parser can recognize it",
+			src:     "// only a comment\n",
+		},
 		{
 			caption: "when a source is in the correct format (it contains non-empty productions), the parser can recognize it",
 			src:     `a: ; b: | ; c: | d | ;`,
 		},
+		{
+			caption: "when a source contains labeled alternatives, the parser can recognize it",
+			src:     `expr: expr "+" expr #add | NUM #num | #empty;`,
+		},
+		{
+			caption: "when a source contains actions, the parser can recognize it",
+			src:     `expr: expr "+" expr #add { $$ = $1 + $3 } | NUM { $$ = $1 } | %empty #none {};`,
+		},
+		{
+			caption:     "when an action precedes a symbol, the parser raises a syntax error",
+			src:         `expr: expr { $$ = $1 } "+" expr;`,
+			syntaxError: true,
+		},
+		{
+			caption:     "when an action precedes a label, the parser raises a syntax error",
+			src:         `expr: NUM { $$ = $1 } #num;`,
+			syntaxError: true,
+		},
+		{
+			caption:     "when a label is followed by a symbol, the parser raises a syntax error",
+			src:         `a: #foo b;`,
+			syntaxError: true,
+		},
+		{
+			caption:     "when # isn't followed by an identifier, the parser raises a syntax error",
+			src:         `a: b #;`,
+			syntaxError: true,
+		},
+		{
+			caption: "when a source contains directives, the parser can recognize it",
+			src:     `%start b; a: c; %foo; %bar x "y"; b: a;`,
+		},
+		{
+			caption:     "when a directive lacks \";\" (terminator), the parser raises a syntax error",
+			src:         `%start b a: c;`,
+			syntaxError: true,
+		},
+		{
+			caption:     "when % isn't followed by an identifier, the parser raises a syntax error",
+			src:         `% start b; a: c;`,
+			syntaxError: true,
+		},
+		{
+			caption: "when a source contains %empty, the parser can recognize it",
+			src:     `sign: "-" | %empty; a: %empty #none | b;`,
+		},
+		{
+			caption:     "when %empty precedes a symbol, the parser raises a syntax error",
+			src:         `a: %empty b;`,
+			syntaxError: true,
+		},
+		{
+			caption:     "when %empty follows a symbol, the parser raises a syntax error",
+			src:         `a: b %empty;`,
+			syntaxError: true,
+		},
+		{
+			caption:     "when %empty appears twice in an alternative, the parser raises a syntax error",
+			src:         `a: %empty %empty;`,
+			syntaxError: true,
+		},
+		{
+			caption: "when a source contains %i, the parser can recognize it",
+			src:     `select: "select" %i; a: select;`,
+		},
+		{
+			caption:     "when %i precedes a pattern, the parser raises a syntax error",
+			src:         `select: %i "select";`,
+			syntaxError: true,
+		},
+		{
+			caption: "when a source contains %mode, the parser can recognize it",
+			src:     `STRING_CHAR: "[^\"]" %mode(string); TEXT: "[a-z]+" %i %mode(normal, template); s: TEXT;`,
+		},
+		{
+			caption:     "when %mode lacks modes, the parser raises a syntax error",
+			src:         `STRING_CHAR: "[^\"]" %mode(); s: STRING_CHAR;`,
+			syntaxError: true,
+		},
+		{
+			caption:     "when %mode has a trailing comma, the parser raises a syntax error",
+			src:         `STRING_CHAR: "[^\"]" %mode(string,); s: STRING_CHAR;`,
+			syntaxError: true,
+		},
+		{
+			caption:     "when %mode lacks parentheses, the parser raises a syntax error",
+			src:         `STRING_CHAR: "[^\"]" %mode string; s: STRING_CHAR;`,
+			syntaxError: true,
+		},
+		{
+			caption: "when a source contains %prec, the parser can recognize it",
+			src:     `%left ADD; %right UMINUS; expr: expr ADD expr | SUB expr %prec UMINUS #neg | NUM %prec "[0-9]+";`,
+		},
+		{
+			caption:     "when %prec lacks a symbol, the parser raises a syntax error",
+			src:         `expr: SUB expr %prec;`,
+			syntaxError: true,
+		},
+		{
+			caption: "when a source contains symbols consisting of digits, the parser can recognize it",
+			src:     `%left 10 ADD; s: A 2 | A 1 B; 1: "x"; 2: "y" %mode(0);`,
+		},
+		{
+			caption: "when a directive takes numbers and string literals, the parser can recognize it",
+			src:     `%foo -3 'hi' 10 x "y";`,
+		},
+		{
+			caption:     "when a negative number appears in place of a symbol, the parser raises a syntax error",
+			src:         `s: A -3;`,
+			syntaxError: true,
+		},
 		{
 			caption:     "when a source contains an unknown token, the parser raises a syntax error",
 			src:         `a: !;`,
@@ -65,8 +180,8 @@ h: "baz"+;
 			ast, err := parser.Parse()
 			if tt.syntaxError {
 				syntaxErr := &SyntaxError{}
-				if errors.Is(err, syntaxErr) {
-					t.Fatalf("error type is mismatched; wont: %T, got: %T", syntaxErr, err)
+				if !errors.Is(err, syntaxErr) {
+					t.Fatalf("error type is mismatched; want: %T, got: %T", syntaxErr, err)
 				}
 				if ast != nil {
 					t.Fatalf("AST is not nil")
@@ -82,3 +197,522 @@ h: "baz"+;
 		})
 	}
 }
+
+type errReader struct {
+	err error
+}
+
+func (r *errReader) Read(p []byte) (int, error) {
+	return 0, r.err
+}
+
+func TestSyntaxError(t *testing.T) {
+	t.Run("a syntax error holds its position and message", func(t *testing.T) {
+		parser, err := NewParser(strings.NewReader("a: b;\nc d;"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = parser.Parse()
+		var syntaxErr *SyntaxError
+		if !errors.As(err, &syntaxErr) {
+			t.Fatalf("error type is mismatched; want: %T, got: %T", syntaxErr, err)
+		}
+		if pos := syntaxErr.Pos(); pos.Line != 2 || pos.Column != 3 {
+			t.Fatalf("unexpected position; want: (2, 3), got: (%v, %v)", pos.Line, pos.Column)
+		}
+		if syntaxErr.Message() == "" {
+			t.Fatalf("message is empty")
+		}
+	})
+
+	t.Run("a missing semicolon is reported at the LHS of the next production", func(t *testing.T) {
+		parser, err := NewParser(strings.NewReader("a: b c\nd: e;"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = parser.Parse()
+		var syntaxErr *SyntaxError
+		if !errors.As(err, &syntaxErr) {
+			t.Fatalf("error type is mismatched; want: %T, got: %T", syntaxErr, err)
+		}
+		if pos := syntaxErr.Pos(); pos.Line != 2 || pos.Column != 1 {
+			t.Fatalf("unexpected position; want: (2, 1), got: (%v, %v)", pos.Line, pos.Column)
+		}
+	})
+
+	t.Run("an I/O error is not a syntax error", func(t *testing.T) {
+		ioErr := errors.New("I/O error")
+		parser, err := NewParser(&errReader{err: ioErr})
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = parser.Parse()
+		if err == nil {
+			t.Fatalf("the parser didn't return any error")
+		}
+		if errors.Is(err, &SyntaxError{}) {
+			t.Fatalf("an I/O error must not be a syntax error: %v", err)
+		}
+		if !errors.Is(err, ioErr) {
+			t.Fatalf("unexpected error; want: %v, got: %v", ioErr, err)
+		}
+	})
+}
+
+func TestWalk(t *testing.T) {
+	parser, err := NewParser(strings.NewReader(`a: b "c"?; d: e | f*;`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ast, err := parser.Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("Walk visits all nodes in pre-order", func(t *testing.T) {
+		var tys []ASTType
+		Walk(ast, func(node *AST) bool {
+			tys = append(tys, node.Ty)
+			return true
+		})
+		expected := []ASTType{
+			ASTTypeStart,
+			ASTTypeProduction, ASTTypeSymbol, ASTTypeAlternative, ASTTypeSymbol, ASTTypePattern, ASTTypeOptional,
+			ASTTypeProduction, ASTTypeSymbol, ASTTypeAlternative, ASTTypeSymbol, ASTTypeAlternative, ASTTypeSymbol, ASTTypeZeroOrMore,
+		}
+		if len(tys) != len(expected) {
+			t.Fatalf("unexpected number of nodes; want: %v, got: %v", expected, tys)
+		}
+		for i, ty := range expected {
+			if tys[i] != ty {
+				t.Fatalf("unexpected node; want: %v, got: %v", expected, tys)
+			}
+		}
+	})
+
+	t.Run("Walk doesn't descend into children when fn returns false", func(t *testing.T) {
+		count := 0
+		Walk(ast, func(node *AST) bool {
+			count++
+			return node.Ty != ASTTypeProduction
+		})
+		if count != 3 {
+			t.Fatalf("unexpected number of visited nodes; want: %v, got: %v", 3, count)
+		}
+	})
+
+	t.Run("Find returns all nodes of the type", func(t *testing.T) {
+		alts := Find(ast, ASTTypeAlternative)
+		if len(alts) != 3 {
+			t.Fatalf("unexpected number of alternatives; want: %v, got: %v", 3, len(alts))
+		}
+		syms := Find(ast, ASTTypeSymbol)
+		var texts []string
+		for _, sym := range syms {
+			text, _ := sym.GetText()
+			texts = append(texts, text)
+		}
+		if strings.Join(texts, " ") != "a b d e f" {
+			t.Fatalf("unexpected symbols; want: %v, got: %v", "a b d e f", texts)
+		}
+	})
+}
+
+func TestAST_String(t *testing.T) {
+	tests := []struct {
+		caption string
+		src     string
+		output  string
+	}{
+		{
+			caption: "the output is in the canonical format",
+			src: `
+// comment
+expr
+    : expr "+" term
+    | term
+    ;
+term: factor* "\"\\"+ | sign? factor ; sign: | "-" | "\x41\x09\x0a\x01";
+`,
+			output: `expr: expr "+" term | term;
+term: factor* "\"\\"+ | sign? factor;
+sign: | "-" | "A\t\n\x01";
+`,
+		},
+		{
+			caption: "empty productions are kept",
+			src:     `a: ; b: | ; c: | d | ;`,
+			output: `a:;
+b: |;
+c: | d |;
+`,
+		},
+		{
+			caption: "directives are kept",
+			src:     `a: b; %start   a ; %foo "x"  y;`,
+			output: `a: b;
+%start a;
+%foo "x" y;
+`,
+		},
+		{
+			caption: "symbols consisting of digits are kept",
+			src:     `s: A 1 B; 1: "x"; %left 10 ADD;`,
+			output: `s: A 1 B;
+1: "x";
+%left 10 ADD;
+`,
+		},
+		{
+			caption: "numbers and string literals in directives are kept",
+			src:     `%foo -3   'it\'s\n' 10 "x";`,
+			output: `%foo -3 'it\'s\n' 10 "x";
+`,
+		},
+		{
+			caption: "patterns delimited by slashes are written in double quotes",
+			src:     `num: /[0-9]+/; str: /"[^"]*"/; path: /\/\w+/;`,
+			output: `num: "[0-9]+";
+str: "\"[^\"]*\"";
+path: "/\\w+";
+`,
+		},
+		{
+			caption: "%empty is kept",
+			src:     `sign: "-" | %empty ; a: %empty#none;`,
+			output: `sign: "-" | %empty;
+a: %empty #none;
+`,
+		},
+		{
+			caption: "%i is kept",
+			src:     `select: "select"%i; a: select;`,
+			output: `select: "select" %i;
+a: select;
+`,
+		},
+		{
+			caption: "%mode is kept",
+			src:     `TEXT: "[a-z]+"%i%mode(normal,template); s: TEXT;`,
+			output: `TEXT: "[a-z]+" %i %mode(normal, template);
+s: TEXT;
+`,
+		},
+		{
+			caption: "%prec is kept",
+			src:     `%right UMINUS; expr: SUB expr%prec UMINUS#neg | NUM;`,
+			output: `%right UMINUS;
+expr: SUB expr %prec UMINUS #neg | NUM;
+`,
+		},
+		{
+			caption: "labels are kept",
+			src:     `expr: expr "+" expr #add | NUM#num | #empty;`,
+			output: `expr: expr "+" expr #add | NUM #num | #empty;
+`,
+		},
+		{
+			caption: "actions are kept verbatim",
+			src:     "expr: expr \"+\" expr #add{ $$ = $1 + $3; }| NUM{\n\t$$ = { v: $1 }\n} | {};",
+			output:  "expr: expr \"+\" expr #add { $$ = $1 + $3; } | NUM {\n\t$$ = { v: $1 }\n} | {};\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.caption, func(t *testing.T) {
+			ast := parse(t, tt.src)
+			out := ast.String()
+			if out != tt.output {
+				t.Fatalf("unexpected output\nwant: %v\ngot: %v", tt.output, out)
+			}
+
+			reparsed := parse(t, out)
+			testEquivalentAST(t, ast, reparsed)
+		})
+	}
+}
+
+func TestParser_DirectiveArguments(t *testing.T) {
+	p, err := NewParser(strings.NewReader(`%foo -3 'hi' 10 x "y";`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	root, err := p.Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []struct {
+		ty   ASTType
+		text string
+	}{
+		{ty: ASTTypeNumber, text: "-3"},
+		{ty: ASTTypeString, text: "hi"},
+		{ty: ASTTypeNumber, text: "10"},
+		{ty: ASTTypeSymbol, text: "x"},
+		{ty: ASTTypePattern, text: "y"},
+	}
+	args := root.Children[0].Children
+	if len(args) != len(expected) {
+		t.Fatalf("unexpected number of arguments; want: %v, got: %v", len(expected), len(args))
+	}
+	for i, e := range expected {
+		text, ok := args[i].GetText()
+		if args[i].Ty != e.ty || !ok || text != e.text {
+			t.Fatalf("unexpected argument; want: %v %v, got: %v %v", e.ty, e.text, args[i].Ty, text)
+		}
+	}
+}
+
+func TestParser_KeepComments(t *testing.T) {
+	src := `// The start symbol.
+/* An expression. */
+expr: expr "+" term // inside a production
+    | term;
+
+term: NUM; /* trailing */ /* doc */
+// of fact
+fact: NUM;
+// at the end
+`
+
+	ast := parse(t, src)
+	for _, prod := range ast.Children {
+		if len(prod.Comments) > 0 {
+			t.Fatalf("the parser must not keep comments by default: %v", prod.Comments)
+		}
+	}
+
+	parser, err := NewParser(strings.NewReader(src), KeepComments())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ast, err = parser.Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := [][]string{
+		{"// The start symbol.", "/* An expression. */"},
+		nil,
+		{"/* trailing */", "/* doc */", "// of fact"},
+	}
+	if len(ast.Children) != len(expected) {
+		t.Fatalf("unexpected number of productions; want: %v, got: %v", len(expected), len(ast.Children))
+	}
+	for i, prod := range ast.Children {
+		if strings.Join(prod.Comments, "\n") != strings.Join(expected[i], "\n") {
+			t.Fatalf("unexpected comments; production: #%v, want: %q, got: %q", i, expected[i], prod.Comments)
+		}
+	}
+
+	output := `// The start symbol.
+/* An expression. */
+expr: expr "+" term | term;
+term: NUM;
+/* trailing */
+/* doc */
+// of fact
+fact: NUM;
+`
+	if ast.String() != output {
+		t.Fatalf("unexpected output\nwant: %v\ngot: %v", output, ast.String())
+	}
+}
+
+func TestParser_CommentsBetweenTokens(t *testing.T) {
+	tokens := []string{
+		"a", ":", "B", "?", `"x"`, "+", "C", "#l", "{ $$ = $1 }", "|", "%empty", "#e", ";",
+		"%start", "a", ";",
+		"D", ":", `"[0-9]+"`, "%i", ";",
+	}
+	expected := parse(t, strings.Join(tokens, " ")).String()
+	for _, comment := range []string{"// note\n", "/* note */"} {
+		for i := 1; i < len(tokens); i++ {
+			src := strings.Join(tokens[:i], " ") + comment + strings.Join(tokens[i:], " ")
+			for _, opts := range [][]ParserOption{nil, {KeepComments()}} {
+				p, err := NewParser(strings.NewReader(src), opts...)
+				if err != nil {
+					t.Fatal(err)
+				}
+				ast, err := p.Parse()
+				if err != nil {
+					t.Fatalf("failed to parse: %v\nsource: %v", err, src)
+				}
+				// Only the comments preceding a definition are kept.
+				out := strings.ReplaceAll(ast.String(), strings.TrimSuffix(comment, "\n")+"\n", "")
+				if out != expected {
+					t.Fatalf("unexpected output;\nsource: %v\nwant:\n%v\ngot:\n%v", src, expected, out)
+				}
+			}
+		}
+	}
+}
+
+func parse(t *testing.T, src string) *AST {
+	t.Helper()
+
+	parser, err := NewParser(strings.NewReader(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ast, err := parser.Parse()
+	if err != nil {
+		t.Fatalf("failed to parse: %v\nsource: %v", err, src)
+	}
+	return ast
+}
+
+func testEquivalentAST(t *testing.T, expected, actual *AST) {
+	t.Helper()
+
+	if actual.Ty != expected.Ty {
+		t.Fatalf("node type is mismatched; want: %v, got: %v", expected.Ty, actual.Ty)
+	}
+	eText, eOK := expected.GetText()
+	aText, aOK := actual.GetText()
+	if aText != eText || aOK != eOK {
+		t.Fatalf("node text is mismatched; want: %v, got: %v", eText, aText)
+	}
+	if len(actual.Children) != len(expected.Children) {
+		t.Fatalf("number of children is mismatched; node: %v, want: %v, got: %v", expected.Ty, len(expected.Children), len(actual.Children))
+	}
+	for i := range expected.Children {
+		testEquivalentAST(t, expected.Children[i], actual.Children[i])
+	}
+}
+
+func TestAST_Pos(t *testing.T) {
+	ast := parse(t, "a: b;\n  é: \"x\" | ;")
+
+	prods := Find(ast, ASTTypeProduction)
+	pos, ok := prods[1].Pos()
+	if !ok {
+		t.Fatalf("position was not found")
+	}
+	if pos != (Position{Line: 2, Column: 3, Offset: 8}) {
+		t.Fatalf("unexpected position; want: %+v, got: %+v", Position{Line: 2, Column: 3, Offset: 8}, pos)
+	}
+
+	pats := Find(ast, ASTTypePattern)
+	pos, ok = pats[0].Pos()
+	if !ok {
+		t.Fatalf("position was not found")
+	}
+	if pos != (Position{Line: 2, Column: 6, Offset: 12}) {
+		t.Fatalf("unexpected position; want: %+v, got: %+v", Position{Line: 2, Column: 6, Offset: 12}, pos)
+	}
+
+	alts := Find(prods[1], ASTTypeAlternative)
+	if _, ok := alts[1].Pos(); ok {
+		t.Fatalf("an empty alternative must not have a position")
+	}
+}
+
+func TestFormatError(t *testing.T) {
+	tests := []struct {
+		caption string
+		src     string
+		output  string
+	}{
+		{
+			caption: "the caret points at the column of the error",
+			src:     "a: b;\nc d;\ne: f;",
+			output: `syntax error: unexpected token; expected: :, actual: id (2, 3)
+2 | c d;
+  |   ^`,
+		},
+		{
+			caption: "tabs in the offending line are kept in the indentation of the caret",
+			src:     "a:\tb\t!;",
+			output: "syntax error: unknown token: \"!\" (1, 6)\n" +
+				"1 | a:\tb\t!;\n" +
+				"  |   \t \t^",
+		},
+		{
+			caption: "an error at the end of the source points after the last character",
+			src:     "a: b",
+			output: `syntax error: unexpected token; expected: ;, actual: eof (1, 5)
+1 | a: b
+  |     ^`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.caption, func(t *testing.T) {
+			parser, err := NewParser(strings.NewReader(tt.src))
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, err = parser.Parse()
+			var synErr *SyntaxError
+			if !errors.As(err, &synErr) {
+				t.Fatalf("unexpected error; want: %T, got: %v", synErr, err)
+			}
+			out := FormatError([]byte(tt.src), synErr)
+			if out != tt.output {
+				t.Fatalf("unexpected output\nwant:\n%v\ngot:\n%v", tt.output, out)
+			}
+		})
+	}
+}
+
+func TestWithTabWidth(t *testing.T) {
+	parser, err := NewParser(strings.NewReader("a:\tb\t!;"), WithTabWidth(DefaultTabWidth))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = parser.Parse()
+	var synErr *SyntaxError
+	if !errors.As(err, &synErr) {
+		t.Fatalf("unexpected error; want: %T, got: %v", synErr, err)
+	}
+	if synErr.Pos() != pos(1, 17, 5) {
+		t.Fatalf("unexpected position; want: %+v, got: %+v", pos(1, 17, 5), synErr.Pos())
+	}
+}
+
+func TestParser_Peek(t *testing.T) {
+	p := &parser{
+		lex:          newLexer(strings.NewReader("a: // foo\nb c;")),
+		keepComments: true,
+	}
+	if tok := p.peek(2); tok.kind != TokenKindColon {
+		t.Fatalf("unexpected token; want: %v, got: %v", TokenKindColon, tok.kind)
+	}
+	if tok := p.peek(1); tok.kind != TokenKindID || tok.text != "a" {
+		t.Fatalf("unexpected token; want: %v (a), got: %v (%v)", TokenKindID, tok.kind, tok.text)
+	}
+	if p.consume(TokenKindColon) {
+		t.Fatalf("consume must check only the next token")
+	}
+
+	want := []struct {
+		kind TokenKind
+		text string
+	}{
+		{kind: TokenKindID, text: "a"},
+		{kind: TokenKindColon},
+		{kind: TokenKindID, text: "b"},
+		{kind: TokenKindID, text: "c"},
+		{kind: TokenKindSemicolon},
+		{kind: TokenKindEOF},
+	}
+	for i, w := range want {
+		if i+1 < len(want) {
+			if next := p.peek(2); next.kind != want[i+1].kind {
+				t.Fatalf("unexpected second token; want: %v, got: %v", want[i+1].kind, next.kind)
+			}
+		}
+		if !p.consume(w.kind) {
+			t.Fatalf("failed to consume a token; want: %v, got: %v", w.kind, p.peek(1).kind)
+		}
+		if w.text != "" && p.lastTok.text != w.text {
+			t.Fatalf("unexpected text; want: %v, got: %v", w.text, p.lastTok.text)
+		}
+		// The comment precedes `b`, so it becomes pending only once `b` is consumed.
+		wantComments := 0
+		if i >= 2 {
+			wantComments = 1
+		}
+		if len(p.pendingComments) != wantComments {
+			t.Fatalf("unexpected pending comments after consuming #%v; want: %v, got: %v", i, wantComments, p.pendingComments)
+		}
+	}
+}